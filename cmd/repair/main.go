@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"bookmark-api/internal/di"
+)
+
+// Detects drift between bookmarks and the projections on their index items, fixes it with -fix
+func main() {
+	username := flag.String("username", "", "only repair the bookmarks of this user")
+	fix := flag.Bool("fix", false, "fix the drift instead of only reporting it")
+	flag.Parse()
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Print("Error loading .env file")
+	}
+
+	repairer, err := di.CreateBookmarkRepairer()
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.Background()
+
+	usernames := []string{*username}
+	if *username == "" {
+		usernames, err = repairer.Usernames(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list users: %v", err)
+		}
+	}
+
+	drifted := 0
+	for _, u := range usernames {
+		report, err := repairer.Repair(ctx, u, *fix)
+		if err != nil {
+			log.Fatalf("Failed to repair %s: %v", u, err)
+		}
+
+		if report.HasDrift() {
+			drifted++
+			log.Printf("%s: bookmarks=%d missing=%d stale=%d orphaned=%d fixed=%t",
+				report.Username, report.Bookmarks, report.Missing, report.Stale, report.Orphaned, report.Fixed)
+		}
+	}
+
+	log.Printf("Checked %d users, %d with drift", len(usernames), drifted)
+}
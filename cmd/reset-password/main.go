@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"bookmark-api/internal/di"
+	"bookmark-api/internal/password"
+)
+
+// Issues a password reset token of a local user, installs without a mailer hand it to the user themselves
+func main() {
+	username := flag.String("username", "", "local user whose password is reset")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("Missing -username")
+	}
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Print("Error loading .env file")
+	}
+
+	service, err := di.CreatePasswordService()
+	if err != nil {
+		panic(err)
+	}
+
+	token, err := service.CreateResetToken(context.Background(), *username)
+	if err != nil {
+		log.Fatalf("Failed to create reset token of %s: %v", *username, err)
+	}
+
+	log.Printf("Send {\"token\": \"%s\", \"password\": \"...\"} to POST /password/reset before %s",
+		token, time.Now().Add(password.ResetTokenTTL).Format(time.RFC3339))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -20,30 +21,34 @@ func init() {
 	authorizerApi, _ = di.CreateAuth()
 }
 
-func Handler(req events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+func Handler(ctx context.Context, req events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
 	token := req.AuthorizationToken
 	bearerToken := strings.Split(token, " ")[1]
 
-	claim, err := authorizerApi.VerifyToken(bearerToken)
+	// Personal API tokens are accepted as well as JWTs
+	authUser, err := authorizerApi.Verify(ctx, bearerToken)
 	if err != nil {
-		log.Printf("Unauthorized. Token : %s, Error : %v", token, err)
+		// The token is not logged, API tokens stay valid for months
+		log.Printf("Unauthorized. Error : %v", err)
 		return events.APIGatewayCustomAuthorizerResponse{}, errors.New("Unauthorized")
 	}
 
 	methodArn := NewMethodArn(req.MethodArn)
 	apiGatewayArn := NewAPIGatewayArn(methodArn.APIGatewayArn)
-	principalID := fmt.Sprintf("user|%s", claim.Username)
+	principalID := fmt.Sprintf("user|%s", authUser.ID())
 
 	resp := NewAuthorizerResponse(principalID, methodArn.AwsAccount)
 	resp.Region = methodArn.Region
 	resp.APIID = apiGatewayArn.APIID
 	resp.Stage = apiGatewayArn.Stage
-	// TODO: Be more restrictive
-	resp.AllowMethod(All, "/api/*")
+	resp.AllowUser(authUser)
 
 	resp.Context = map[string]interface{}{
-		"username": claim.Username,
-		"method":   claim.Method,
+		"username": authUser.Username,
+		"user_id":  authUser.ID(),
+		"method":   string(authUser.Method),
+		"scopes":   strings.Join(authUser.Scopes, ","),
+		"roles":    strings.Join(authUser.Roles, ","),
 	}
 
 	fmt.Printf("%+v", resp.APIGatewayCustomAuthorizerResponse)
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bookmark-api/internal/auth"
+)
+
+const (
+	authPaths  = "/auth/*"
+	adminPaths = auth.APIRoot + "/admin*"
+)
+
+// Methods of the API, each allowed on the paths auth.AllowedPaths returns for it
+var verbs = []HTTPVerb{Get, Head, Options, Post, Put, Patch, Delete}
+
+// AllowUser adds the statements the user is allowed by the scopes of its token and its roles.
+// The paths come from auth.AllowedPaths, which the middleware of the API checks as well.
+// Only JWTs may log out, the auth routes do not take API tokens.
+// Admin paths are denied to users without the admin role, whatever else is allowed
+func (r *AuthorizerResponse) AllowUser(authUser *auth.AuthUser) {
+	if authUser.Scopes == nil {
+		r.AllowMethod(Post, authPaths)
+	}
+
+	allowed := false
+	for _, verb := range verbs {
+		for _, path := range auth.AllowedPaths(authUser, verb.String()) {
+			r.AllowMethod(verb, auth.APIRoot+path)
+			allowed = true
+		}
+	}
+	if !allowed {
+		r.DenyAllMethods()
+		return
+	}
+
+	if !authUser.HasRole(auth.RoleAdmin) {
+		r.DenyMethod(All, adminPaths)
+	}
+}
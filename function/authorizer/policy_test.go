@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+)
+
+// Evaluates the policy like IAM does, an explicit deny wins over any allow
+func isAllowed(resp *AuthorizerResponse, verb HTTPVerb, path string) bool {
+	methodArn := "arn:aws:execute-api:us-east-2:123456789012:api/dev/" + verb.String() + path
+	allowed := false
+	for _, statement := range resp.PolicyDocument.Statement {
+		for _, resource := range statement.Resource {
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(resource), `\*`, ".*") + "$"
+			if !regexp.MustCompile(pattern).MatchString(methodArn) {
+				continue
+			}
+			if statement.Effect == Deny.String() {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+func TestAllowUser(t *testing.T) {
+	jwtUser := &auth.AuthUser{Username: "1", Method: auth.Google}
+	adminUser := &auth.AuthUser{Username: "1", Method: auth.Google, Roles: []string{auth.RoleAdmin}}
+	readToken := &auth.AuthUser{Username: "1", Method: auth.Google, Scopes: []string{entity.ScopeRead}}
+	writeToken := &auth.AuthUser{Username: "1", Method: auth.Google, Scopes: []string{entity.ScopeWrite}}
+	adminToken := &auth.AuthUser{Username: "1", Method: auth.Google, Scopes: []string{entity.ScopeAdmin}}
+	unknownToken := &auth.AuthUser{Username: "1", Method: auth.Google, Scopes: []string{"root"}}
+
+	tests := []struct {
+		name     string
+		user     *auth.AuthUser
+		verb     HTTPVerb
+		path     string
+		expected bool
+	}{
+		{"JWTReadsBookmarks", jwtUser, Get, "/api/v1/bookmarks", true},
+		{"JWTCreatesWebhooks", jwtUser, Post, "/api/v1/webhooks", true},
+		{"JWTWithoutAdminRole", jwtUser, Get, "/api/v1/admin/users", false},
+		{"AdminRole", adminUser, Get, "/api/v1/admin/users", true},
+		{"ReadTokenListsBookmarks", readToken, Get, "/api/v1/bookmarks", true},
+		{"ReadTokenGetsBookmark", readToken, Get, "/api/v1/bookmarks/2", true},
+		{"ReadTokenCanNotCreate", readToken, Post, "/api/v1/bookmarks", false},
+		{"ReadTokenCanNotDelete", readToken, Delete, "/api/v1/bookmarks/2", false},
+		{"ReadTokenOnlyBookmarks", readToken, Get, "/api/v1/webhooks", false},
+		{"WriteTokenCreates", writeToken, Post, "/api/v1/bookmarks", true},
+		{"WriteTokenPatches", writeToken, Patch, "/api/v1/bookmarks/2", true},
+		{"WriteTokenDeletesTags", writeToken, Delete, "/api/v1/bookmarks/2/tags/go", true},
+		{"WriteTokenOnlyBookmarks", writeToken, Post, "/api/v1/tokens", false},
+		{"AdminTokenManagesTokens", adminToken, Post, "/api/v1/tokens", true},
+		{"AdminTokenWithoutAdminRole", adminToken, Get, "/api/v1/admin/users", false},
+		{"UnknownScope", unknownToken, Get, "/api/v1/bookmarks", false},
+		{"JWTLogsOut", jwtUser, Post, "/auth/logout", true},
+		{"JWTLogsOutEverywhere", jwtUser, Post, "/auth/logout-all", true},
+		{"AdminTokenCanNotLogOut", adminToken, Post, "/auth/logout", false},
+		{"OutsideApi", jwtUser, Get, "/public/slug", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := NewAuthorizerResponse("user|1", "123456789012")
+			resp.Region = "us-east-2"
+			resp.APIID = "api"
+			resp.Stage = "dev"
+			resp.AllowUser(test.user)
+
+			assert.Equal(t, test.expected, isAllowed(resp, test.verb, test.path))
+		})
+	}
+}
+
+// Same cases as the middleware of the API, the paths are under the API root
+func TestAllowUserScopes(t *testing.T) {
+	var cases []struct {
+		Name    string `json:"name"`
+		Scope   string `json:"scope"`
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Allowed bool   `json:"allowed"`
+	}
+	data, err := ioutil.ReadFile("../../internal/auth/testdata/scopes.json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, test := range cases {
+		t.Run(test.Name, func(t *testing.T) {
+			authUser := &auth.AuthUser{Username: "1", Method: auth.Google}
+			if test.Scope != "" {
+				authUser.Scopes = []string{test.Scope}
+			}
+
+			var verb HTTPVerb
+			for _, v := range verbs {
+				if v.String() == test.Method {
+					verb = v
+				}
+			}
+
+			resp := NewAuthorizerResponse("user|1", "123456789012")
+			resp.Region = "us-east-2"
+			resp.APIID = "api"
+			resp.Stage = "dev"
+			resp.AllowUser(authUser)
+
+			assert.Equal(t, test.Allowed, isAllowed(resp, verb, auth.APIRoot+test.Path))
+		})
+	}
+}
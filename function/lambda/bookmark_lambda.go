@@ -26,6 +26,51 @@ func init() {
 		panic(err)
 	}
 
+	webhookApi, err := di.CreateWebhookApi()
+	if err != nil {
+		panic(err)
+	}
+
+	shareApi, err := di.CreateShareApi()
+	if err != nil {
+		panic(err)
+	}
+
+	feedApi, err := di.CreateFeedApi()
+	if err != nil {
+		panic(err)
+	}
+
+	workspaceApi, err := di.CreateWorkspaceApi()
+	if err != nil {
+		panic(err)
+	}
+
+	tokenApi, err := di.CreateTokenApi()
+	if err != nil {
+		panic(err)
+	}
+
+	passwordApi, err := di.CreatePasswordApi()
+	if err != nil {
+		panic(err)
+	}
+
+	magicLinkApi, err := di.CreateMagicLinkApi()
+	if err != nil {
+		panic(err)
+	}
+
+	accountApi, err := di.CreateAccountApi()
+	if err != nil {
+		panic(err)
+	}
+
+	adminApi, err := di.CreateAdminApi()
+	if err != nil {
+		panic(err)
+	}
+
 	r := gin.Default()
 	r.Use(gin.Logger())
 	r.Use(cors.Default())
@@ -33,8 +78,25 @@ func init() {
 
 	authApi.RegisterSigninHandlers(r.Group("/"))
 	authApi.RegisterAuthHandlers(r.Group("/"))
+	shareApi.RegisterPublicHandlers(r.Group("/"))
+	feedApi.RegisterPublicHandlers(r.Group("/"))
+	passwordApi.RegisterPublicHandlers(r.Group("/"))
+	magicLinkApi.RegisterPublicHandlers(r.Group("/"))
+	accountApi.RegisterPublicHandlers(r.Group("/"))
 
-	bookmarkApi.RegisterHandlers(r.Group("/api/v1", authApi.GetAuthMiddleware().MiddlewareFunc()))
+	api := r.Group("/api/v1", authApi.Middleware())
+	bookmarkApi.RegisterHandlers(api)
+	webhookApi.RegisterHandlers(api)
+	shareApi.RegisterHandlers(api)
+	feedApi.RegisterHandlers(api)
+	workspaceApi.RegisterHandlers(api)
+	tokenApi.RegisterHandlers(api)
+	passwordApi.RegisterHandlers(api)
+	authApi.RegisterHandlers(api)
+	accountApi.RegisterHandlers(api)
+	adminApi.RegisterHandlers(api)
+	bookmarkApi.RegisterHandlers(workspaceApi.Scoped(api))
+	webhookApi.RegisterHandlers(workspaceApi.Scoped(api))
 
 	ginLambda = ginadapter.New(r)
 }
@@ -2,31 +2,77 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/joho/godotenv"
+
+	"bookmark-api/internal/account"
+	"bookmark-api/internal/di"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/webhook"
 )
 
-// var service bookmark.Service
+var dispatcher webhook.Dispatcher
+var accountService account.Service
 
-// func init() {
-// 	service, err := di.CreateService()
-// 	if err != nil {
-// 		panic(err)
-// 	}
-// }
+func init() {
+	var err error
+	dispatcher, err = di.CreateWebhookDispatcher()
+	if err != nil {
+		panic(err)
+	}
 
-// type Response events.APIGatewayProxyResponse
+	accountService, err = di.CreateAccountService()
+	if err != nil {
+		panic(err)
+	}
+}
 
 func Handler(ctx context.Context, sqsEvent events.SQSEvent) error {
 	if len(sqsEvent.Records) == 0 {
 		return errors.New("No SQS message passed to function")
 	}
 
-	return nil
+	// Batches hold a single message (batchSize in serverless.yml), a failure only receives that message again
+	var lastErr error
+	for _, record := range sqsEvent.Records {
+		var message entity.WebhookMessage
+		if err := json.Unmarshal([]byte(record.Body), &message); err != nil {
+			log.Printf("Dropping malformed message %s: %v", record.MessageId, err)
+			continue
+		}
+
+		switch message.Type {
+		case webhook.MessageTypeEvent:
+			if err := dispatcher.FanOut(ctx, message); err != nil {
+				log.Printf("Failed to queue deliveries of message %s: %v", record.MessageId, err)
+				lastErr = err
+			}
+		case webhook.MessageTypeDelivery:
+			if err := dispatcher.Deliver(ctx, message); err != nil {
+				log.Printf("Failed to deliver message %s: %v", record.MessageId, err)
+				lastErr = err
+			}
+		case account.MessageTypeDeletion:
+			var deletion entity.AccountMessage
+			if err := json.Unmarshal([]byte(record.Body), &deletion); err != nil {
+				log.Printf("Dropping malformed message %s: %v", record.MessageId, err)
+				continue
+			}
+			if err := accountService.ProcessDeletion(ctx, deletion); err != nil {
+				log.Printf("Failed to delete account items of message %s: %v", record.MessageId, err)
+				lastErr = err
+			}
+		default:
+			log.Printf("Dropping message %s of unknown type %s", record.MessageId, message.Type)
+		}
+	}
+
+	return lastErr
 }
 
 func main() {
@@ -9,21 +9,22 @@ require (
 	github.com/aws/aws-sdk-go v1.30.7
 	github.com/awslabs/aws-lambda-go-api-proxy v0.6.0
 	github.com/boj/redistore v0.0.0-20180917114910-cd5dcc76aeff // indirect
-	github.com/futurenda/google-auth-id-token-verifier v0.0.0-20170311140316-2a5b89f28b7e
 	github.com/gbrlsnchs/jwt/v3 v3.0.0-rc.2
 	github.com/gin-contrib/cors v1.3.1
+	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/contrib v0.0.0-20191209060500-d6e26eeaa607
 	github.com/gin-gonic/gin v1.6.2
 	github.com/golang/mock v1.4.3
 	github.com/golang/protobuf v1.3.5 // indirect
 	github.com/google/uuid v1.1.1
 	github.com/google/wire v0.4.0
-	github.com/gorilla/sessions v1.2.0 // indirect
+	github.com/gorilla/sessions v1.2.0
 	github.com/guregu/dynamo v1.7.0
 	github.com/joho/godotenv v1.3.0
 	github.com/stretchr/testify v1.5.1
 	github.com/thoas/go-funk v0.6.0
 	go.uber.org/zap v1.14.1
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e // indirect
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
@@ -0,0 +1,171 @@
+package account
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+	"bookmark-api/internal/user"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	// The progress of a deletion is looked up after the account has been signed out
+	RegisterPublicHandlers(rg *gin.RouterGroup)
+	RegisterHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterPublicHandlers(rg *gin.RouterGroup) {
+	rg.GET("/account-deletions/:id", r.deletion)
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	rg.GET("/me", r.profile)
+	rg.PATCH("/me", r.updateProfile)
+
+	// A leaked API token must not be able to take the data away or delete it
+	admin := session.RequireScope(entity.ScopeAdmin)
+	rg.GET("/me/export", admin, r.export)
+	rg.DELETE("/me", admin, r.delete)
+}
+
+// Fields left out are kept, empty strings reset them to their default
+type PatchProfileRequest struct {
+	DisplayName *string         `json:"display_name"`
+	AvatarURL   *string         `json:"avatar_url"`
+	DefaultSort *string         `json:"default_sort"`
+	DefaultTags []string        `json:"default_tags"`
+	Timezone    *string         `json:"timezone"`
+	Features    map[string]bool `json:"features"`
+}
+
+type DeletionResponse struct {
+	ID           string     `json:"id"`
+	Status       string     `json:"status"`
+	DeletedItems int        `json:"deleted_items"`
+	RequestedAt  time.Time  `json:"requested_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+func newDeletionResponse(deletion Deletion) DeletionResponse {
+	response := DeletionResponse{
+		ID:           deletion.ID,
+		Status:       deletion.Status,
+		DeletedItems: deletion.DeletedItems,
+		RequestedAt:  deletion.RequestedAt,
+		UpdatedAt:    deletion.UpdatedAt,
+	}
+	if deletion.Status == entity.DeletionCompleted {
+		response.CompletedAt = &deletion.CompletedAt
+	}
+	return response
+}
+
+func (r *resource) profile(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	profile, err := r.service.Profile(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get profile"))
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (r *resource) updateProfile(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := PatchProfileRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	profile, err := r.service.UpdateProfile(c.Request.Context(), authUser.ID(), user.ProfileUpdate{
+		DisplayName: request.DisplayName,
+		AvatarURL:   request.AvatarURL,
+		DefaultSort: request.DefaultSort,
+		DefaultTags: request.DefaultTags,
+		Timezone:    request.Timezone,
+		Features:    request.Features,
+	})
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Display name must be at most 100 characters, avatar_url an https url, default_sort one of %s, "+
+				"default_tags at most 20 tags, timezone an IANA time zone and features at most 50", strings.Join(user.SortOrders, ", "))))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to update profile"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (r *resource) export(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+
+	var archive bytes.Buffer
+	err := r.service.Export(c.Request.Context(), authUser.ID(), &archive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to export account"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"bookmarks-export-%s.zip\"", time.Now().UTC().Format("20060102")))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+func (r *resource) delete(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	deletion, err := r.service.RequestDeletion(c.Request.Context(), authUser.ID())
+	if err != nil {
+		switch err {
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Transfer ownership of your workspaces with other members first"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete account"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newDeletionResponse(deletion))
+}
+
+func (r *resource) deletion(c *gin.Context) {
+	deletion, err := r.service.Deletion(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Account deletion not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get account deletion"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newDeletionResponse(deletion))
+}
@@ -0,0 +1,158 @@
+package account
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/pkg/logger"
+)
+
+func sendBody(method, url, body string) *http.Response {
+	request, _ := http.NewRequest(method, url, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		panic(err)
+	}
+	return resp
+}
+
+func TestAccountRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+	api.RegisterHandlers(r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: "google", UserID: "user:1"})
+	}))
+	api.RegisterHandlers(r.Group("/scoped", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: "google", UserID: "user:1", Scopes: []string{entity.ScopeWrite}})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	send := func(method, path string) *http.Response {
+		return sendBody(method, ts.URL+path, "")
+	}
+
+	t.Run("Profile", func(t *testing.T) {
+		s.userRepository.EXPECT().GetProfile(gomock.Any(), "user:1").
+			Return(entity.Profile{UserID: "user:1", DisplayName: "Jane"}, nil).Times(1)
+
+		resp := send(http.MethodGet, "/api/me")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result user.Profile
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "Jane", result.DisplayName)
+		assert.Equal(t, "UTC", result.Timezone)
+	})
+
+	t.Run("UpdateProfile", func(t *testing.T) {
+		s.userRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{}, errors.ErrNotFound).Times(1)
+		s.userRepository.EXPECT().PutProfile(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		resp := sendBody(http.MethodPatch, ts.URL+"/api/me", `{"display_name": "Jane Doe", "default_tags": ["inbox"], "timezone": "Europe/Berlin"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result user.Profile
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "Jane Doe", result.DisplayName)
+		assert.Equal(t, []string{"inbox"}, result.DefaultTags)
+		assert.Equal(t, "Europe/Berlin", result.Timezone)
+	})
+
+	t.Run("UpdateProfileInvalid", func(t *testing.T) {
+		resp := sendBody(http.MethodPatch, ts.URL+"/api/me", `{"default_sort": "random"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return([]entity.User{}, nil).Times(1)
+		s.repository.EXPECT().ExportBookmarks(gomock.Any(), "user:1").Return([]entity.Bookmark{}, map[string][]string{}, nil).Times(1)
+
+		resp := send(http.MethodGet, "/api/me/export")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s.workspaceRepository.EXPECT().ListMemberships(gomock.Any(), "user:1").Return([]entity.WorkspaceMember{}, nil).Times(1)
+		s.repository.EXPECT().CreateDeletion(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		s.queue.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return([]entity.User{}, nil).Times(1)
+		s.userRepository.EXPECT().DeleteProfile(gomock.Any(), "user:1").Return(nil).Times(1)
+
+		resp := send(http.MethodDelete, "/api/me")
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		var result DeletionResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.NotEmpty(t, result.ID)
+		assert.Equal(t, entity.DeletionPending, result.Status)
+		assert.Nil(t, result.CompletedAt)
+	})
+
+	t.Run("DeleteLastOwner", func(t *testing.T) {
+		s.workspaceRepository.EXPECT().ListMemberships(gomock.Any(), "user:1").
+			Return([]entity.WorkspaceMember{{WorkspaceID: "team", Username: "user:1", Role: entity.RoleOwner}}, nil).Times(1)
+		s.workspaceRepository.EXPECT().ListMembers(gomock.Any(), "team").Return([]entity.WorkspaceMember{
+			{WorkspaceID: "team", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "team", Username: "user:2", Role: entity.RoleViewer},
+		}, nil).Times(1)
+
+		resp := send(http.MethodDelete, "/api/me")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("ScopeNotAdmin", func(t *testing.T) {
+		resp := send(http.MethodDelete, "/scoped/me")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = send(http.MethodGet, "/scoped/me/export")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		completedAt := time.Now()
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(entity.AccountDeletion{
+			ID:           "1",
+			Status:       entity.DeletionCompleted,
+			DeletedItems: 42,
+			CompletedAt:  completedAt,
+		}, nil).Times(1)
+
+		resp := send(http.MethodGet, "/account-deletions/1")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result DeletionResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, 42, result.DeletedItems)
+		assert.NotNil(t, result.CompletedAt)
+	})
+
+	t.Run("StatusNotFound", func(t *testing.T) {
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "unknown").Return(entity.AccountDeletion{}, errors.ErrNotFound).Times(1)
+
+		resp := send(http.MethodGet, "/account-deletions/unknown")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: bookmark-api/internal/account (interfaces: Repository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	entity "bookmark-api/internal/entity"
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockRepository is a mock of Repository interface
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateDeletion mocks base method
+func (m *MockRepository) CreateDeletion(arg0 context.Context, arg1 entity.AccountDeletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeletion", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateDeletion indicates an expected call of CreateDeletion
+func (mr *MockRepositoryMockRecorder) CreateDeletion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeletion", reflect.TypeOf((*MockRepository)(nil).CreateDeletion), arg0, arg1)
+}
+
+// DeletePage mocks base method
+func (m *MockRepository) DeletePage(arg0 context.Context, arg1 string, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePage indicates an expected call of DeletePage
+func (mr *MockRepositoryMockRecorder) DeletePage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePage", reflect.TypeOf((*MockRepository)(nil).DeletePage), arg0, arg1, arg2)
+}
+
+// ExportBookmarks mocks base method
+func (m *MockRepository) ExportBookmarks(arg0 context.Context, arg1 string) ([]entity.Bookmark, map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportBookmarks", arg0, arg1)
+	ret0, _ := ret[0].([]entity.Bookmark)
+	ret1, _ := ret[1].(map[string][]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExportBookmarks indicates an expected call of ExportBookmarks
+func (mr *MockRepositoryMockRecorder) ExportBookmarks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportBookmarks", reflect.TypeOf((*MockRepository)(nil).ExportBookmarks), arg0, arg1)
+}
+
+// GetDeletion mocks base method
+func (m *MockRepository) GetDeletion(arg0 context.Context, arg1 string) (entity.AccountDeletion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletion", arg0, arg1)
+	ret0, _ := ret[0].(entity.AccountDeletion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletion indicates an expected call of GetDeletion
+func (mr *MockRepositoryMockRecorder) GetDeletion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletion", reflect.TypeOf((*MockRepository)(nil).GetDeletion), arg0, arg1)
+}
+
+// UpdateDeletion mocks base method
+func (m *MockRepository) UpdateDeletion(arg0 context.Context, arg1 entity.AccountDeletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeletion", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeletion indicates an expected call of UpdateDeletion
+func (mr *MockRepositoryMockRecorder) UpdateDeletion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeletion", reflect.TypeOf((*MockRepository)(nil).UpdateDeletion), arg0, arg1)
+}
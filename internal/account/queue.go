@@ -0,0 +1,81 @@
+// queue.go
+//go:generate mockgen -destination=mocks/queue_mock.go -package=mocks . Queue
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/pkg/queue"
+)
+
+// Type of the messages on the processing queue, which is shared with other work
+const MessageTypeDeletion = "account_deletion"
+
+type Queue interface {
+	Send(ctx context.Context, message entity.AccountMessage) error
+}
+
+type sqsQueue struct {
+	client sqsiface.SQSAPI
+	name   string
+	url    string
+	mutex  sync.Mutex
+	logger *zap.Logger
+}
+
+func NewQueue(logger *zap.Logger) Queue {
+	return &sqsQueue{client: queue.GetSQS(), name: queue.GetQueueBookmark(), logger: logger}
+}
+
+func (q *sqsQueue) Send(ctx context.Context, message entity.AccountMessage) error {
+	logger := q.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	url, err := q.getUrl(ctx)
+	if err != nil {
+		logger.Errorw("Failed to get queue url", zap.String("Queue", q.name), zap.Error(err))
+		return err
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(url),
+		MessageBody: aws.String(string(body)),
+	})
+	if err != nil {
+		logger.Errorw("Failed to send message", zap.String("Queue", q.name), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (q *sqsQueue) getUrl(ctx context.Context) (string, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.url != "" {
+		return q.url, nil
+	}
+
+	output, err := q.client.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(q.name)})
+	if err != nil {
+		return "", err
+	}
+	q.url = aws.StringValue(output.QueueUrl)
+	return q.url, nil
+}
@@ -0,0 +1,210 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	CreateDeletion(ctx context.Context, deletion entity.AccountDeletion) error
+	GetDeletion(ctx context.Context, deletionId string) (entity.AccountDeletion, error)
+	UpdateDeletion(ctx context.Context, deletion entity.AccountDeletion) error
+	// Returns the bookmarks of the owner and the IDs of the bookmarks of each tag, as the tag index has them
+	ExportBookmarks(ctx context.Context, owner string) ([]entity.Bookmark, map[string][]string, error)
+	// Deletes at most limit items of the partition, along with the items elsewhere which look them up, like the
+	// hashes of API tokens. Returns how many items of the partition were deleted, 0 once it is empty
+	DeletePage(ctx context.Context, hashKey string, limit int) (int, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) CreateDeletion(ctx context.Context, deletion entity.AccountDeletion) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(deletion.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create account deletion", zap.String("UserID", deletion.UserID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetDeletion(ctx context.Context, deletionId string) (entity.AccountDeletion, error) {
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByAccountDeletion(deletionId)
+
+	var result entity.AccountDeletion
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		Consistent(true).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.AccountDeletion{}, errors.ErrNotFound
+		default:
+			return entity.AccountDeletion{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) UpdateDeletion(ctx context.Context, deletion entity.AccountDeletion) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(deletion.GetItem()).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to update account deletion", zap.String("DeletionID", deletion.ID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ExportBookmarks(ctx context.Context, owner string) ([]entity.Bookmark, map[string][]string, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByID(owner, "")
+	bookmarks := []entity.Bookmark{}
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		AllWithContext(ctx, &bookmarks)
+	if err != nil {
+		logger.Errorw("Failed to export bookmarks", zap.String("Username", owner), zap.Error(err))
+		return nil, nil, err
+	}
+
+	hashId, rangeId = entity.GetSearchKeyByTag(owner, "")
+	var searchByTags []entity.BookmarkSearchByTag
+	err = table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		Project("id", "range").
+		AllWithContext(ctx, &searchByTags)
+	if err != nil {
+		logger.Errorw("Failed to export tags", zap.String("Username", owner), zap.Error(err))
+		return nil, nil, err
+	}
+
+	// Index items are keyed by TAG_{tag}_{bookmark ID}, tags may contain underscores themselves
+	tags := make(map[string][]string)
+	for _, searchByTag := range searchByTags {
+		key := strings.TrimPrefix(searchByTag.Tag, rangeId)
+		separator := strings.LastIndex(key, "_")
+		if separator < 0 {
+			continue
+		}
+		tag := key[:separator]
+		tags[tag] = append(tags[tag], key[separator+1:])
+	}
+
+	return bookmarks, tags, nil
+}
+
+// Key fields of the items of a partition, and the attributes of those pointing to items elsewhere
+type partitionItem struct {
+	HashKey   string `dynamo:"id"`
+	RangeKey  string `dynamo:"range"`
+	TokenHash string `dynamo:"token_hash"`
+}
+
+// Returns the keys of the items elsewhere which look the item up, or copy it
+func linkedKeys(item partitionItem) []dynamo.Keys {
+	switch {
+	case strings.HasPrefix(item.RangeKey, "API_TOKEN_") && item.TokenHash != "":
+		hashId, rangeId := entity.GetSearchKeyByAPITokenHash(item.TokenHash)
+		return []dynamo.Keys{{hashId, rangeId}}
+	case item.RangeKey == "FEED_TOKEN" && item.TokenHash != "":
+		hashId, rangeId := entity.GetSearchKeyByFeedTokenHash(item.TokenHash)
+		return []dynamo.Keys{{hashId, rangeId}}
+	case strings.HasPrefix(item.RangeKey, "SHARE_"):
+		hashId, rangeId := entity.GetSearchKeyBySlug(strings.TrimPrefix(item.RangeKey, "SHARE_"))
+		return []dynamo.Keys{{hashId, rangeId}}
+	case strings.HasPrefix(item.HashKey, "WORKSPACE_") && strings.HasPrefix(item.RangeKey, "INVITATION_"):
+		// Invitation of a workspace being deleted, the invited user finds it under the email
+		hashId, rangeId := entity.GetSearchKeyByInvitationOf(strings.TrimPrefix(item.RangeKey, "INVITATION_"), strings.TrimPrefix(item.HashKey, "WORKSPACE_"))
+		return []dynamo.Keys{{hashId, rangeId}}
+	}
+	return nil
+}
+
+func (r *repository) DeletePage(ctx context.Context, hashKey string, limit int) (int, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	// Deleted items are gone from the next page, so every page starts from the beginning of the partition
+	var items []partitionItem
+	err := table.Get("id", hashKey).
+		Consistent(true).
+		Project("id", "range", "token_hash").
+		SearchLimit(int64(limit)).
+		AllWithContext(ctx, &items)
+	if err != nil {
+		logger.Errorw("Failed to read partition", zap.String("HashKey", hashKey), zap.Error(err))
+		return 0, err
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	// A batch may not delete the same item twice
+	seen := make(map[string]bool)
+	var deletes []dynamo.Keyed
+	for _, item := range items {
+		for _, keys := range append(linkedKeys(item), dynamo.Keys{item.HashKey, item.RangeKey}) {
+			key := fmt.Sprintf("%v/%v", keys[0], keys[1])
+			if !seen[key] {
+				seen[key] = true
+				deletes = append(deletes, keys)
+			}
+		}
+	}
+
+	_, err = table.Batch("id", "range").Write().Delete(deletes...).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to delete partition items", zap.String("HashKey", hashKey), zap.Error(err))
+		return 0, err
+	}
+
+	return len(items), nil
+}
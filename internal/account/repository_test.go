@@ -0,0 +1,33 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		item partitionItem
+		keys int
+		hash string
+	}{
+		{"APIToken", partitionItem{HashKey: "USERNAME_user:1", RangeKey: "API_TOKEN_1", TokenHash: "abc"}, 1, "API_TOKEN_abc"},
+		{"Share", partitionItem{HashKey: "USERNAME_user:1", RangeKey: "SHARE_slug"}, 1, "SLUG_slug"},
+		// Shared workspaces are left before the deletion, the others are deleted as a whole
+		{"Membership", partitionItem{HashKey: "USERNAME_user:1", RangeKey: "WORKSPACE_team"}, 0, ""},
+		{"Invitation", partitionItem{HashKey: "WORKSPACE_team", RangeKey: "INVITATION_jane@example.com"}, 1, "USERNAME_jane@example.com"},
+		{"Bookmark", partitionItem{HashKey: "USERNAME_user:1", RangeKey: "BOOKMARK_1"}, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := linkedKeys(test.item)
+			assert.Len(t, keys, test.keys)
+			if test.keys > 0 {
+				assert.Equal(t, test.hash, keys[0][0])
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package account
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/internal/workspace"
+)
+
+const (
+	// Items of a partition deleted by each message
+	deletionBatchSize = 100
+	// Time the progress of a deletion can be looked up after it is requested
+	deletionRetention = 30 * 24 * time.Hour
+	deletionIdBytes   = 32
+)
+
+type Service interface {
+	Profile(ctx context.Context, userId string) (user.Profile, error)
+	// Returns errors.ErrInvalidParam when any of the fields is invalid
+	UpdateProfile(ctx context.Context, userId string, update user.ProfileUpdate) (user.Profile, error)
+	// Writes a ZIP archive of the account, its bookmarks and their tags
+	Export(ctx context.Context, userId string, w io.Writer) error
+	// Signs the account out everywhere and queues the deletion of its items. Returns errors.ErrPreconditionFailed
+	// when the account is the only owner of a workspace which has other members
+	RequestDeletion(ctx context.Context, userId string) (Deletion, error)
+	Deletion(ctx context.Context, deletionId string) (Deletion, error)
+	// Deletes the next page of items of the deletion of the message, and queues the one after
+	ProcessDeletion(ctx context.Context, message entity.AccountMessage) error
+}
+
+type Deletion struct {
+	ID           string
+	Status       string
+	DeletedItems int
+	RequestedAt  time.Time
+	UpdatedAt    time.Time
+	CompletedAt  time.Time
+}
+
+func newDeletion(deletion entity.AccountDeletion) Deletion {
+	return Deletion{
+		ID:           deletion.ID,
+		Status:       deletion.Status,
+		DeletedItems: deletion.DeletedItems,
+		RequestedAt:  deletion.RequestedAt,
+		UpdatedAt:    deletion.UpdatedAt,
+		CompletedAt:  deletion.CompletedAt,
+	}
+}
+
+// Files of the export archive
+type ExportedUser struct {
+	UserID     string             `json:"user_id"`
+	Identities []ExportedIdentity `json:"identities"`
+}
+
+type ExportedIdentity struct {
+	Username    string    `json:"username"`
+	Method      string    `json:"method"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+type ExportedBookmark struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ExportedTag struct {
+	Tag         string   `json:"tag"`
+	BookmarkIDs []string `json:"bookmark_ids"`
+}
+
+type service struct {
+	repo             Repository
+	queue            Queue
+	userService      user.Service
+	workspaceService workspace.Service
+	logger           *zap.Logger
+}
+
+func NewService(repo Repository, queue Queue, userService user.Service, workspaceService workspace.Service, logger *zap.Logger) Service {
+	return &service{repo, queue, userService, workspaceService, logger}
+}
+
+func (s *service) Profile(ctx context.Context, userId string) (user.Profile, error) {
+	return s.userService.Profile(ctx, userId)
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userId string, update user.ProfileUpdate) (user.Profile, error) {
+	return s.userService.UpdateProfile(ctx, userId, update)
+}
+
+func (s *service) Export(ctx context.Context, userId string, w io.Writer) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	identities, err := s.userService.Identities(ctx, userId)
+	if err != nil {
+		logger.Errorw("Failed to export identities", zap.String("UserID", userId), zap.Error(err))
+		return err
+	}
+
+	bookmarks, tags, err := s.repo.ExportBookmarks(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	exportedUser := ExportedUser{UserID: userId, Identities: []ExportedIdentity{}}
+	for _, identity := range identities {
+		exportedUser.Identities = append(exportedUser.Identities, ExportedIdentity{
+			Username:    identity.Username,
+			Method:      identity.Method,
+			LastLoginAt: identity.LastLoginAt,
+		})
+	}
+
+	exportedBookmarks := []ExportedBookmark{}
+	for _, b := range bookmarks {
+		exportedBookmarks = append(exportedBookmarks, ExportedBookmark{
+			ID:        b.GetBookmarkId(),
+			Name:      b.Name,
+			Url:       b.Url,
+			Tags:      b.Tags,
+			CreatedAt: b.CreatedAt,
+			UpdatedAt: b.UpdatedAt,
+		})
+	}
+
+	exportedTags := []ExportedTag{}
+	for tag, bookmarkIds := range tags {
+		sort.Strings(bookmarkIds)
+		exportedTags = append(exportedTags, ExportedTag{Tag: tag, BookmarkIDs: bookmarkIds})
+	}
+	sort.Slice(exportedTags, func(i, j int) bool {
+		return exportedTags[i].Tag < exportedTags[j].Tag
+	})
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"user.json", exportedUser},
+		{"bookmarks.json", exportedBookmarks},
+		{"tags.json", exportedTags},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			logger.Errorw("Failed to write export", zap.String("File", file.name), zap.Error(err))
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (s *service) RequestDeletion(ctx context.Context, userId string) (Deletion, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	partitions, shared, err := s.partitions(ctx, userId)
+	if err != nil {
+		return Deletion{}, err
+	}
+
+	// Leaving through the workspace keeps its count of owners right, deleting the items would not
+	for _, workspaceId := range shared {
+		err = s.workspaceService.RemoveMember(ctx, workspaceId, userId)
+		if err != nil && err != errors.ErrNotFound {
+			logger.Errorw("Failed to leave workspace", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+			return Deletion{}, err
+		}
+	}
+
+	deletionId, err := newDeletionId()
+	if err != nil {
+		return Deletion{}, err
+	}
+
+	now := time.Now()
+	deletion := entity.AccountDeletion{
+		ID:          deletionId,
+		UserID:      userId,
+		Partitions:  partitions,
+		Status:      entity.DeletionPending,
+		RequestedAt: now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(deletionRetention),
+	}
+	err = s.repo.CreateDeletion(ctx, deletion)
+	if err != nil {
+		return Deletion{}, err
+	}
+
+	err = s.queue.Send(ctx, entity.AccountMessage{Type: MessageTypeDeletion, DeletionID: deletionId})
+	if err != nil {
+		return Deletion{}, err
+	}
+
+	// The identities go last, the account can still sign in and ask again when queueing fails
+	err = s.userService.DeleteAccount(ctx, userId)
+	if err != nil {
+		logger.Errorw("Failed to delete identities", zap.String("UserID", userId), zap.Error(err))
+		return Deletion{}, err
+	}
+
+	return newDeletion(deletion), nil
+}
+
+// Returns the hash keys of the partitions to delete, the personal one and those of the workspaces nobody else is a
+// member of, and the IDs of the other workspaces the user has to leave
+func (s *service) partitions(ctx context.Context, userId string) ([]string, []string, error) {
+	partitions := []string{entity.GetPartitionKey(userId)}
+	var shared []string
+
+	memberships, err := s.workspaceService.List(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, membership := range memberships {
+		members, err := s.workspaceService.Members(ctx, membership.WorkspaceID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		owners, others := 0, 0
+		for _, member := range members {
+			if member.Username == userId {
+				continue
+			}
+			others++
+			if member.Role == entity.RoleOwner {
+				owners++
+			}
+		}
+
+		switch {
+		case others == 0:
+			partitions = append(partitions, entity.GetPartitionKey(entity.WorkspaceOwner(membership.WorkspaceID)))
+		case membership.Role == entity.RoleOwner && owners == 0:
+			return nil, nil, errors.ErrPreconditionFailed
+		default:
+			shared = append(shared, membership.WorkspaceID)
+		}
+	}
+
+	return partitions, shared, nil
+}
+
+func (s *service) Deletion(ctx context.Context, deletionId string) (Deletion, error) {
+	deletion, err := s.repo.GetDeletion(ctx, deletionId)
+	if err != nil {
+		return Deletion{}, err
+	}
+
+	return newDeletion(deletion), nil
+}
+
+func (s *service) ProcessDeletion(ctx context.Context, message entity.AccountMessage) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	deletion, err := s.repo.GetDeletion(ctx, message.DeletionID)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			logger.Warnw("Dropping message of unknown deletion", zap.String("DeletionID", message.DeletionID))
+			return nil
+		}
+		return err
+	}
+	if deletion.Status == entity.DeletionCompleted {
+		return nil
+	}
+
+	if deletion.Partition < len(deletion.Partitions) {
+		deleted, err := s.repo.DeletePage(ctx, deletion.Partitions[deletion.Partition], deletionBatchSize)
+		if err != nil {
+			return err
+		}
+
+		deletion.DeletedItems += deleted
+		if deleted == 0 {
+			deletion.Partition++
+		}
+	}
+
+	now := time.Now()
+	deletion.UpdatedAt = now
+	deletion.Status = entity.DeletionRunning
+	if deletion.Partition >= len(deletion.Partitions) {
+		deletion.Status = entity.DeletionCompleted
+		deletion.CompletedAt = now
+	}
+
+	err = s.repo.UpdateDeletion(ctx, deletion)
+	if err != nil {
+		return err
+	}
+
+	if deletion.Status == entity.DeletionCompleted {
+		logger.Infow("Deleted account", zap.String("UserID", deletion.UserID), zap.Int("DeletedItems", deletion.DeletedItems))
+		return nil
+	}
+
+	return s.queue.Send(ctx, entity.AccountMessage{Type: MessageTypeDeletion, DeletionID: deletion.ID})
+}
+
+func newDeletionId() (string, error) {
+	bytes := make([]byte, deletionIdBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,249 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/account/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	userMocks "bookmark-api/internal/user/mocks"
+	"bookmark-api/internal/workspace"
+	workspaceMocks "bookmark-api/internal/workspace/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+type testService struct {
+	Service
+	repository          *mocks.MockRepository
+	queue               *mocks.MockQueue
+	userRepository      *userMocks.MockRepository
+	workspaceRepository *workspaceMocks.MockRepository
+}
+
+func newTestService(ctrl *gomock.Controller) testService {
+	zapLogger := logger.NewLogger()
+	s := testService{
+		repository:          mocks.NewMockRepository(ctrl),
+		queue:               mocks.NewMockQueue(ctrl),
+		userRepository:      userMocks.NewMockRepository(ctrl),
+		workspaceRepository: workspaceMocks.NewMockRepository(ctrl),
+	}
+	userService := user.NewService(s.userRepository, zapLogger)
+	s.Service = NewService(s.repository, s.queue, userService, workspace.NewService(s.workspaceRepository, userService, zapLogger), zapLogger)
+	return s
+}
+
+func TestExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+
+	s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+		Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+	s.repository.EXPECT().ExportBookmarks(gomock.Any(), "user:1").
+		Return([]entity.Bookmark{{Username: "USERNAME_user:1", ID: "BOOKMARK_2", Name: "Go", Url: "https://golang.org", Tags: []string{"go"}}},
+			map[string][]string{"go": {"2"}, "lang": {"3", "2"}}, nil).Times(1)
+
+	var archive bytes.Buffer
+	err := s.Export(context.Background(), "user:1", &archive)
+	assert.Nil(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	assert.Nil(t, err)
+	files := map[string]*zip.File{}
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+	assert.Len(t, files, 3)
+
+	read := func(name string, v interface{}) {
+		f, err := files[name].Open()
+		assert.Nil(t, err)
+		defer f.Close()
+		assert.Nil(t, json.NewDecoder(f).Decode(v))
+	}
+
+	var exportedUser ExportedUser
+	read("user.json", &exportedUser)
+	assert.Equal(t, "user:1", exportedUser.UserID)
+	assert.Equal(t, "google", exportedUser.Identities[0].Method)
+
+	var bookmarks []ExportedBookmark
+	read("bookmarks.json", &bookmarks)
+	assert.Equal(t, []ExportedBookmark{{ID: "2", Name: "Go", Url: "https://golang.org", Tags: []string{"go"}}}, bookmarks)
+
+	var tags []ExportedTag
+	read("tags.json", &tags)
+	assert.Equal(t, []ExportedTag{{Tag: "go", BookmarkIDs: []string{"2"}}, {Tag: "lang", BookmarkIDs: []string{"2", "3"}}}, tags)
+}
+
+func TestRequestDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		s.workspaceRepository.EXPECT().ListMemberships(gomock.Any(), "user:1").Return([]entity.WorkspaceMember{
+			{WorkspaceID: "alone", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "shared", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "joined", Username: "user:1", Role: entity.RoleEditor},
+		}, nil).Times(1)
+		s.workspaceRepository.EXPECT().ListMembers(gomock.Any(), "alone").
+			Return([]entity.WorkspaceMember{{WorkspaceID: "alone", Username: "user:1", Role: entity.RoleOwner}}, nil).Times(1)
+		s.workspaceRepository.EXPECT().ListMembers(gomock.Any(), "shared").Return([]entity.WorkspaceMember{
+			{WorkspaceID: "shared", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "shared", Username: "user:2", Role: entity.RoleOwner},
+		}, nil).Times(1)
+		s.workspaceRepository.EXPECT().ListMembers(gomock.Any(), "joined").Return([]entity.WorkspaceMember{
+			{WorkspaceID: "joined", Username: "user:1", Role: entity.RoleEditor},
+			{WorkspaceID: "joined", Username: "user:2", Role: entity.RoleOwner},
+		}, nil).Times(1)
+		// Workspaces with other members are left through the workspace, which counts their owners
+		for _, member := range []entity.WorkspaceMember{
+			{WorkspaceID: "shared", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "joined", Username: "user:1", Role: entity.RoleEditor},
+		} {
+			s.workspaceRepository.EXPECT().GetMember(gomock.Any(), member.WorkspaceID, "user:1").Return(member, nil).Times(1)
+			s.workspaceRepository.EXPECT().DeleteMember(gomock.Any(), member).Return(nil).Times(1)
+		}
+
+		var created entity.AccountDeletion
+		s.repository.EXPECT().CreateDeletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, deletion entity.AccountDeletion) error {
+				created = deletion
+				return nil
+			}).Times(1)
+		s.queue.EXPECT().Send(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, message entity.AccountMessage) error {
+				assert.Equal(t, MessageTypeDeletion, message.Type)
+				assert.Equal(t, created.ID, message.DeletionID)
+				return nil
+			}).Times(1)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+		s.userRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "google", "user:1").Return(nil).Times(1)
+		s.userRepository.EXPECT().DeleteProfile(gomock.Any(), "user:1").Return(nil).Times(1)
+
+		deletion, err := s.RequestDeletion(ctx, "user:1")
+		assert.Nil(t, err)
+		assert.Equal(t, entity.DeletionPending, deletion.Status)
+		assert.Len(t, deletion.ID, 43)
+		assert.Equal(t, []string{"USERNAME_user:1", "WORKSPACE_alone"}, created.Partitions)
+		assert.WithinDuration(t, time.Now().Add(deletionRetention), created.ExpiresAt, time.Minute)
+	})
+
+	t.Run("LastOwner", func(t *testing.T) {
+		s.workspaceRepository.EXPECT().ListMemberships(gomock.Any(), "user:1").
+			Return([]entity.WorkspaceMember{{WorkspaceID: "team", Username: "user:1", Role: entity.RoleOwner}}, nil).Times(1)
+		s.workspaceRepository.EXPECT().ListMembers(gomock.Any(), "team").Return([]entity.WorkspaceMember{
+			{WorkspaceID: "team", Username: "user:1", Role: entity.RoleOwner},
+			{WorkspaceID: "team", Username: "user:2", Role: entity.RoleEditor},
+		}, nil).Times(1)
+
+		_, err := s.RequestDeletion(ctx, "user:1")
+		assert.Equal(t, errors.ErrPreconditionFailed, err)
+	})
+
+	t.Run("QueueFails", func(t *testing.T) {
+		s.workspaceRepository.EXPECT().ListMemberships(gomock.Any(), "user:1").Return([]entity.WorkspaceMember{}, nil).Times(1)
+		s.repository.EXPECT().CreateDeletion(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		s.queue.EXPECT().Send(gomock.Any(), gomock.Any()).Return(fmt.Errorf("Timeout")).Times(1)
+
+		// The identities are kept so the account can ask again
+		_, err := s.RequestDeletion(ctx, "user:1")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestProcessDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+	message := entity.AccountMessage{Type: MessageTypeDeletion, DeletionID: "1"}
+	deletion := entity.AccountDeletion{
+		ID:         "1",
+		UserID:     "user:1",
+		Partitions: []string{"USERNAME_user:1", "WORKSPACE_alone"},
+		Status:     entity.DeletionPending,
+	}
+
+	t.Run("DeletesPage", func(t *testing.T) {
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(deletion, nil).Times(1)
+		s.repository.EXPECT().DeletePage(gomock.Any(), "USERNAME_user:1", deletionBatchSize).Return(100, nil).Times(1)
+		s.repository.EXPECT().UpdateDeletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, updated entity.AccountDeletion) error {
+				assert.Equal(t, entity.DeletionRunning, updated.Status)
+				assert.Equal(t, 100, updated.DeletedItems)
+				assert.Equal(t, 0, updated.Partition)
+				return nil
+			}).Times(1)
+		s.queue.EXPECT().Send(gomock.Any(), message).Return(nil).Times(1)
+
+		assert.Nil(t, s.ProcessDeletion(ctx, message))
+	})
+
+	t.Run("NextPartition", func(t *testing.T) {
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(deletion, nil).Times(1)
+		s.repository.EXPECT().DeletePage(gomock.Any(), "USERNAME_user:1", deletionBatchSize).Return(0, nil).Times(1)
+		s.repository.EXPECT().UpdateDeletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, updated entity.AccountDeletion) error {
+				assert.Equal(t, 1, updated.Partition)
+				return nil
+			}).Times(1)
+		s.queue.EXPECT().Send(gomock.Any(), message).Return(nil).Times(1)
+
+		assert.Nil(t, s.ProcessDeletion(ctx, message))
+	})
+
+	t.Run("Completes", func(t *testing.T) {
+		last := deletion
+		last.Partition = 1
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(last, nil).Times(1)
+		s.repository.EXPECT().DeletePage(gomock.Any(), "WORKSPACE_alone", deletionBatchSize).Return(0, nil).Times(1)
+		s.repository.EXPECT().UpdateDeletion(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, updated entity.AccountDeletion) error {
+				assert.Equal(t, entity.DeletionCompleted, updated.Status)
+				assert.False(t, updated.CompletedAt.IsZero())
+				return nil
+			}).Times(1)
+
+		assert.Nil(t, s.ProcessDeletion(ctx, message))
+	})
+
+	t.Run("AlreadyCompleted", func(t *testing.T) {
+		completed := deletion
+		completed.Status = entity.DeletionCompleted
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(completed, nil).Times(1)
+
+		assert.Nil(t, s.ProcessDeletion(ctx, message))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(entity.AccountDeletion{}, errors.ErrNotFound).Times(1)
+
+		assert.Nil(t, s.ProcessDeletion(ctx, message))
+	})
+
+	t.Run("DeleteFails", func(t *testing.T) {
+		s.repository.EXPECT().GetDeletion(gomock.Any(), "1").Return(deletion, nil).Times(1)
+		s.repository.EXPECT().DeletePage(gomock.Any(), gomock.Any(), gomock.Any()).Return(0, fmt.Errorf("Timeout")).Times(1)
+
+		// The message is received again
+		assert.NotNil(t, s.ProcessDeletion(ctx, message))
+	})
+}
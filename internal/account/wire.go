@@ -0,0 +1,7 @@
+package account
+
+import (
+	"github.com/google/wire"
+)
+
+var Inject = wire.NewSet(NewApi, NewRepository, NewService, NewQueue)
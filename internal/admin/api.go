@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	// The Lambda authorizer denies these paths to users without the admin role as well
+	admin := rg.Group("/admin", session.RequireAdmin())
+	admin.GET("/users", r.users)
+	admin.GET("/users/:id", r.account)
+	admin.POST("/users/:id/disable", r.disable)
+	admin.POST("/users/:id/enable", r.enable)
+	admin.POST("/users/:id/logout", r.logout)
+	admin.GET("/audit", r.auditLog)
+}
+
+type UserResponse struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	Method      string    `json:"method"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	Disabled    bool      `json:"disabled"`
+}
+
+func newUserResponse(user User) UserResponse {
+	return UserResponse{
+		UserID:      user.UserID,
+		Username:    user.Username,
+		Method:      user.Method,
+		LastLoginAt: user.LastLoginAt,
+		Disabled:    user.Disabled,
+	}
+}
+
+type AccountResponse struct {
+	UserID      string         `json:"user_id"`
+	DisplayName string         `json:"display_name"`
+	Identities  []UserResponse `json:"identities"`
+	Bookmarks   int            `json:"bookmarks"`
+	Disabled    bool           `json:"disabled"`
+}
+
+type AuditEntryResponse struct {
+	ID        string    `json:"id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Query     string    `json:"query,omitempty"`
+	Outcome   string    `json:"outcome,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAuditEntryResponse(entry AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		ID:        entry.ID,
+		Actor:     entry.Actor,
+		Action:    entry.Action,
+		Target:    entry.Target,
+		Query:     entry.Query,
+		Outcome:   entry.Outcome,
+		Error:     entry.Error,
+		CreatedAt: entry.CreatedAt,
+	}
+}
+
+// Returns the limit query parameter, writes the response and returns false when it is invalid
+func listLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultListLimit, true
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxListLimit {
+		c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Limit must be between 1 and %d", maxListLimit)))
+		return 0, false
+	}
+	return limit, true
+}
+
+func (r *resource) users(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	result, nextCursor, err := r.service.Users(c.Request.Context(), authUser.ID(), c.Query("q"), limit, c.Query("cursor"))
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Cursor is invalid"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list users"))
+		}
+		return
+	}
+
+	if nextCursor != "" {
+		c.Header("X-Next-Cursor", nextCursor)
+	}
+	c.JSON(http.StatusOK, funk.Map(result, newUserResponse))
+}
+
+func (r *resource) account(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	account, err := r.service.Account(c.Request.Context(), authUser.ID(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("User not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get user"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, AccountResponse{
+		UserID:      account.UserID,
+		DisplayName: account.DisplayName,
+		Identities:  funk.Map(account.Identities, newUserResponse).([]UserResponse),
+		Bookmarks:   account.Bookmarks,
+		Disabled:    account.Disabled,
+	})
+}
+
+func (r *resource) disable(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.Disable(c.Request.Context(), authUser.ID(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("User not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Admins can not disable their own account"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to disable user"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) enable(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.Enable(c.Request.Context(), authUser.ID(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("User not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to enable user"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) logout(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.Logout(c.Request.Context(), authUser.ID(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("User not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to logout user"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) auditLog(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+
+	result, nextCursor, err := r.service.AuditLog(c.Request.Context(), limit, c.Query("cursor"))
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Cursor is invalid"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list audit log"))
+		}
+		return
+	}
+
+	if nextCursor != "" {
+		c.Header("X-Next-Cursor", nextCursor)
+	}
+	c.JSON(http.StatusOK, funk.Map(result, newAuditEntryResponse))
+}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/logger"
+)
+
+func TestAdminRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterHandlers(r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "admin@example.com", Method: auth.Google, UserID: "user:admin", Roles: []string{auth.RoleAdmin}})
+	}))
+	api.RegisterHandlers(r.Group("/user", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: auth.Google, UserID: "user:1"})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	send := func(method, path string) *http.Response {
+		request, _ := http.NewRequest(method, fmt.Sprintf("%s%s", ts.URL, path), nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Users", func(t *testing.T) {
+		expectAudit(s, entity.AuditListUsers, "", entity.AuditSucceeded)
+		s.repository.EXPECT().ListUsers(gomock.Any(), "jane", 2, "").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1", Disabled: true}}, "next", nil).Times(1)
+
+		resp := send(http.MethodGet, "/api/admin/users?q=jane&limit=2")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "next", resp.Header.Get("X-Next-Cursor"))
+
+		var result []UserResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "user:1", result[0].UserID)
+		assert.True(t, result[0].Disabled)
+	})
+
+	t.Run("WrongLimit", func(t *testing.T) {
+		resp := send(http.MethodGet, "/api/admin/users?limit=1000")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		expectAudit(s, entity.AuditViewUser, "user:1", entity.AuditSucceeded)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+		s.userRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{}, errors.ErrNotFound).Times(1)
+		s.repository.EXPECT().CountBookmarks(gomock.Any(), "user:1").Return(3, nil).Times(1)
+
+		resp := send(http.MethodGet, "/api/admin/users/user:1")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result AccountResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, 3, result.Bookmarks)
+		assert.Len(t, result.Identities, 1)
+		assert.False(t, result.Disabled)
+	})
+
+	t.Run("DisableOwnAccount", func(t *testing.T) {
+		resp := send(http.MethodPost, "/api/admin/users/user:admin/disable")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("DisableUnknown", func(t *testing.T) {
+		expectAudit(s, entity.AuditDisableUser, "user:2", entity.AuditFailed)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:2").Return([]entity.User{}, nil).Times(1)
+
+		resp := send(http.MethodPost, "/api/admin/users/user:2/disable")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		s.repository.EXPECT().ListAuditEntries(gomock.Any(), 50, "").Return([]entity.AuditEntry{
+			{ID: "2", Actor: "user:admin", Action: entity.AuditDisableUser, Target: "user:1", CreatedAt: time.Now()},
+		}, "", nil).Times(1)
+
+		resp := send(http.MethodGet, "/api/admin/audit")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("X-Next-Cursor"))
+
+		var result []AuditEntryResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, entity.AuditDisableUser, result[0].Action)
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/user/admin/users").StatusCode)
+		assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/user/admin/users/user:2/disable").StatusCode)
+		assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/user/admin/audit").StatusCode)
+	})
+}
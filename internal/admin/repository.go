@@ -0,0 +1,220 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	// Lists the identities of the user table whose username or user ID contains the query, any identity when it is
+	// empty. Returns the cursor of the next page or empty string on the last page
+	ListUsers(ctx context.Context, query string, limit int, cursor string) ([]entity.User, string, error)
+	// Counts the bookmarks of the personal workspace of the account
+	CountBookmarks(ctx context.Context, userId string) (int, error)
+	CreateAuditEntry(ctx context.Context, entry entity.AuditEntry) error
+	// Sets the outcome and error of the pending entry, errors.ErrPreconditionFailed when it is not pending
+	SetAuditOutcome(ctx context.Context, entry entity.AuditEntry) error
+	// Lists the audit log most recent first, returns the cursor of the next page or empty string on the last page
+	ListAuditEntries(ctx context.Context, limit int, cursor string) ([]entity.AuditEntry, string, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+// The user table has no index to search, so the whole table is scanned until a page is full
+func (r *repository) ListUsers(ctx context.Context, query string, limit int, cursor string) ([]entity.User, string, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		logger.Errorw("Invalid cursor", zap.String("Cursor", cursor), zap.Error(err))
+		return nil, "", errors.ErrInvalidParam
+	}
+
+	table := r.db.Table(db.GetTableUser())
+
+	// Profiles share the table with the identities
+	scan := table.Scan().Filter("$ <> ?", "method", entity.ProfileMethod)
+	if query != "" {
+		scan = scan.Filter("contains($, ?) OR contains($, ?)", "username", query, "user_id", query)
+	}
+	if position.Hash != "" {
+		scan = scan.StartFrom(dynamo.PagingKey{
+			"username": {S: aws.String(position.Hash)},
+			"method":   {S: aws.String(position.Range)},
+		})
+	}
+
+	result := []entity.User{}
+	iter := scan.Iter()
+	var user entity.User
+	for iter.NextWithContext(ctx, &user) {
+		result = append(result, user)
+		if len(result) == limit {
+			return result, encodeCursor(cursorPosition{Hash: user.Username, Range: user.Method}), nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		logger.Errorw("Failed to list users", zap.String("Query", query), zap.Error(err))
+		return nil, "", err
+	}
+
+	return result, "", nil
+}
+
+func (r *repository) CountBookmarks(ctx context.Context, userId string) (int, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByID(userId, "")
+	count, err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		CountWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to count bookmarks", zap.String("UserID", userId), zap.Error(err))
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (r *repository) CreateAuditEntry(ctx context.Context, entry entity.AuditEntry) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(entry.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create audit entry", zap.String("Actor", entry.Actor), zap.String("Action", entry.Action), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) SetAuditOutcome(ctx context.Context, entry entity.AuditEntry) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	item := entry.GetItem()
+	update := table.Update("id", item.HashKey).
+		Range("range", item.RangeKey).
+		Set("outcome", entry.Outcome).
+		If("$ = ?", "outcome", entity.AuditPending)
+	if entry.Error != "" {
+		update = update.Set("error", entry.Error)
+	}
+
+	err := update.RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrPreconditionFailed
+		}
+		logger.Errorw("Failed to set outcome of audit entry", zap.String("ID", entry.ID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ListAuditEntries(ctx context.Context, limit int, cursor string) ([]entity.AuditEntry, string, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		logger.Errorw("Invalid cursor", zap.String("Cursor", cursor), zap.Error(err))
+		return nil, "", errors.ErrInvalidParam
+	}
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByAuditEntry("")
+	query := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		Order(dynamo.Descending).
+		SearchLimit(int64(limit))
+	if position.Range != "" {
+		query = query.StartFrom(dynamo.PagingKey{
+			"id":    {S: aws.String(hashId)},
+			"range": {S: aws.String(position.Range)},
+		})
+	}
+
+	result := []entity.AuditEntry{}
+	iter := query.Iter()
+	var entry entity.AuditEntry
+	for iter.NextWithContext(ctx, &entry) {
+		result = append(result, entry)
+		if len(result) == limit {
+			return result, encodeCursor(cursorPosition{Range: entry.RangeKey}), nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		logger.Errorw("Failed to list audit entries", zap.Error(err))
+		return nil, "", err
+	}
+
+	return result, "", nil
+}
+
+// Key of the last item of a page, the next one starts after it
+type cursorPosition struct {
+	Hash  string `json:"h,omitempty"`
+	Range string `json:"r"`
+}
+
+func encodeCursor(position cursorPosition) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (cursorPosition, error) {
+	var position cursorPosition
+	if cursor == "" {
+		return position, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position, err
+	}
+	err = json.Unmarshal(data, &position)
+	return position, err
+}
@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/pkg/db"
+)
+
+// Every action of an admin is recorded in the audit log before it is taken, an action which can not be recorded is
+// not taken. Its outcome is recorded once it has been taken. Reading the audit log is not recorded itself
+type Service interface {
+	// Lists the identities whose username or user ID contains the query
+	Users(ctx context.Context, actor, query string, limit int, cursor string) ([]User, string, error)
+	// Returns the account with its identities and bookmark count, errors.ErrNotFound when it has no identity
+	Account(ctx context.Context, actor, userId string) (Account, error)
+	// Refuses the sign ins and tokens of the account, errors.ErrPreconditionFailed when admins disable their own
+	Disable(ctx context.Context, actor, userId string) error
+	Enable(ctx context.Context, actor, userId string) error
+	// Signs the account out everywhere, errors.ErrNotFound when it has no identity
+	Logout(ctx context.Context, actor, userId string) error
+	AuditLog(ctx context.Context, limit int, cursor string) ([]AuditEntry, string, error)
+}
+
+// Identity of an account, as the admins see it
+type User struct {
+	UserID      string
+	Username    string
+	Method      string
+	LastLoginAt time.Time
+	Disabled    bool
+}
+
+type Account struct {
+	UserID      string
+	DisplayName string
+	Identities  []User
+	// Bookmarks of the personal workspace
+	Bookmarks int
+	Disabled  bool
+}
+
+type AuditEntry struct {
+	ID        string
+	Actor     string
+	Action    string
+	Target    string
+	Query     string
+	Outcome   string
+	Error     string
+	CreatedAt time.Time
+}
+
+func newAuditEntry(entry entity.AuditEntry) AuditEntry {
+	return AuditEntry{
+		ID:        entry.ID,
+		Actor:     entry.Actor,
+		Action:    entry.Action,
+		Target:    entry.Target,
+		Query:     entry.Query,
+		Outcome:   entry.Outcome,
+		Error:     entry.Error,
+		CreatedAt: entry.CreatedAt,
+	}
+}
+
+type service struct {
+	repo        Repository
+	userService user.Service
+	logger      *zap.Logger
+}
+
+func NewService(repo Repository, userService user.Service, logger *zap.Logger) Service {
+	return &service{repo, userService, logger}
+}
+
+// Records the action as pending before it is taken
+func (s *service) record(ctx context.Context, entry entity.AuditEntry) (entity.AuditEntry, error) {
+	entry.ID = db.GenerateID()
+	entry.Outcome = entity.AuditPending
+	entry.CreatedAt = time.Now()
+	err := s.repo.CreateAuditEntry(ctx, entry)
+	if err != nil {
+		return entity.AuditEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// Records the outcome of the action once it has been taken and returns the error of the action.
+// The action has been taken already, so an outcome which can not be recorded leaves the entry pending
+func (s *service) finish(ctx context.Context, entry entity.AuditEntry, actionErr error) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	entry.Outcome = entity.AuditSucceeded
+	if actionErr != nil {
+		entry.Outcome = entity.AuditFailed
+		entry.Error = actionErr.Error()
+	}
+	err := s.repo.SetAuditOutcome(ctx, entry)
+	if err != nil {
+		logger.Errorw("Failed to record outcome of admin action", zap.String("ID", entry.ID), zap.String("Outcome", entry.Outcome), zap.Error(err))
+	}
+
+	logger.Infow("Admin action", zap.String("Actor", entry.Actor), zap.String("Action", entry.Action), zap.String("Target", entry.Target),
+		zap.String("Outcome", entry.Outcome))
+	return actionErr
+}
+
+func (s *service) Users(ctx context.Context, actor, query string, limit int, cursor string) ([]User, string, error) {
+	entry, err := s.record(ctx, entity.AuditEntry{Actor: actor, Action: entity.AuditListUsers, Query: query})
+	if err != nil {
+		return nil, "", err
+	}
+
+	users, nextCursor, err := s.repo.ListUsers(ctx, query, limit, cursor)
+	if err = s.finish(ctx, entry, err); err != nil {
+		return nil, "", err
+	}
+
+	result := []User{}
+	for _, u := range users {
+		// Identities created before there were user IDs belong to the account of their username
+		userId := u.UserID
+		if userId == "" {
+			userId = u.Username
+		}
+		result = append(result, User{
+			UserID:      userId,
+			Username:    u.Username,
+			Method:      u.Method,
+			LastLoginAt: u.LastLoginAt,
+			Disabled:    u.Disabled,
+		})
+	}
+	return result, nextCursor, nil
+}
+
+func (s *service) Account(ctx context.Context, actor, userId string) (Account, error) {
+	entry, err := s.record(ctx, entity.AuditEntry{Actor: actor, Action: entity.AuditViewUser, Target: userId})
+	if err != nil {
+		return Account{}, err
+	}
+
+	account, err := s.account(ctx, userId)
+	if err = s.finish(ctx, entry, err); err != nil {
+		return Account{}, err
+	}
+
+	return account, nil
+}
+
+func (s *service) account(ctx context.Context, userId string) (Account, error) {
+	identities, err := s.userService.Identities(ctx, userId)
+	if err != nil {
+		return Account{}, err
+	}
+	if len(identities) == 0 {
+		return Account{}, errors.ErrNotFound
+	}
+
+	profile, err := s.userService.Profile(ctx, userId)
+	if err != nil {
+		return Account{}, err
+	}
+
+	bookmarks, err := s.repo.CountBookmarks(ctx, userId)
+	if err != nil {
+		return Account{}, err
+	}
+
+	account := Account{UserID: userId, DisplayName: profile.DisplayName, Identities: []User{}, Bookmarks: bookmarks}
+	for _, identity := range identities {
+		account.Identities = append(account.Identities, User{
+			UserID:      userId,
+			Username:    identity.Username,
+			Method:      identity.Method,
+			LastLoginAt: identity.LastLoginAt,
+			Disabled:    identity.Disabled,
+		})
+		account.Disabled = account.Disabled || identity.Disabled
+	}
+	return account, nil
+}
+
+func (s *service) Disable(ctx context.Context, actor, userId string) error {
+	// Admins would lock themselves out, with nobody left to enable them again
+	if actor == userId {
+		return errors.ErrPreconditionFailed
+	}
+
+	entry, err := s.record(ctx, entity.AuditEntry{Actor: actor, Action: entity.AuditDisableUser, Target: userId})
+	if err != nil {
+		return err
+	}
+
+	return s.finish(ctx, entry, s.userService.Disable(ctx, userId))
+}
+
+func (s *service) Enable(ctx context.Context, actor, userId string) error {
+	entry, err := s.record(ctx, entity.AuditEntry{Actor: actor, Action: entity.AuditEnableUser, Target: userId})
+	if err != nil {
+		return err
+	}
+
+	return s.finish(ctx, entry, s.userService.Enable(ctx, userId))
+}
+
+func (s *service) Logout(ctx context.Context, actor, userId string) error {
+	entry, err := s.record(ctx, entity.AuditEntry{Actor: actor, Action: entity.AuditLogoutUser, Target: userId})
+	if err != nil {
+		return err
+	}
+
+	return s.finish(ctx, entry, s.logout(ctx, userId))
+}
+
+func (s *service) logout(ctx context.Context, userId string) error {
+	identities, err := s.userService.Identities(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return errors.ErrNotFound
+	}
+
+	return s.userService.LogoutAll(ctx, userId)
+}
+
+func (s *service) AuditLog(ctx context.Context, limit int, cursor string) ([]AuditEntry, string, error) {
+	entries, nextCursor, err := s.repo.ListAuditEntries(ctx, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := []AuditEntry{}
+	for _, entry := range entries {
+		result = append(result, newAuditEntry(entry))
+	}
+	return result, nextCursor, nil
+}
@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/admin/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	userMocks "bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+type testService struct {
+	Service
+	repository     *mocks.MockRepository
+	userRepository *userMocks.MockRepository
+}
+
+func newTestService(ctrl *gomock.Controller) testService {
+	zapLogger := logger.NewLogger()
+	s := testService{
+		repository:     mocks.NewMockRepository(ctrl),
+		userRepository: userMocks.NewMockRepository(ctrl),
+	}
+	s.Service = NewService(s.repository, user.NewService(s.userRepository, zapLogger), zapLogger)
+	return s
+}
+
+// Expects the action to be recorded in the audit log before it is taken, and its outcome afterwards
+func expectAudit(s testService, action, target, outcome string) {
+	var id string
+	s.repository.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, entry entity.AuditEntry) error {
+			if entry.Actor != "user:admin" || entry.Action != action || entry.Target != target || entry.ID == "" ||
+				entry.Outcome != entity.AuditPending {
+				return fmt.Errorf("Unexpected audit entry %+v", entry)
+			}
+			id = entry.ID
+			return nil
+		}).Times(1)
+	// Errors of recording the outcome are only logged, so the entry is matched instead
+	s.repository.EXPECT().SetAuditOutcome(gomock.Any(), auditOutcome{id: &id, outcome: outcome}).Return(nil).Times(1)
+}
+
+// Matches the entry recorded with the ID and the outcome, failed ones with their error
+type auditOutcome struct {
+	id      *string
+	outcome string
+}
+
+func (m auditOutcome) Matches(x interface{}) bool {
+	entry, ok := x.(entity.AuditEntry)
+	return ok && entry.ID == *m.id && entry.Outcome == m.outcome && (m.outcome == entity.AuditFailed) == (entry.Error != "")
+}
+
+func (m auditOutcome) String() string {
+	return fmt.Sprintf("audit entry %s with outcome %s", *m.id, m.outcome)
+}
+
+func TestUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+
+	t.Run("Search", func(t *testing.T) {
+		s.repository.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry entity.AuditEntry) error {
+				assert.Equal(t, entity.AuditListUsers, entry.Action)
+				assert.Equal(t, "jane", entry.Query)
+				return nil
+			}).Times(1)
+		s.repository.EXPECT().SetAuditOutcome(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		s.repository.EXPECT().ListUsers(gomock.Any(), "jane", 10, "").Return([]entity.User{
+			{Username: "jane@example.com", Method: "google", UserID: "user:1"},
+			{Username: "jane", Method: "github"},
+		}, "next", nil).Times(1)
+
+		users, cursor, err := s.Users(ctx, "user:admin", "jane", 10, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "next", cursor)
+		assert.Equal(t, "user:1", users[0].UserID)
+		// Identities without user ID belong to the account of their username
+		assert.Equal(t, "jane", users[1].UserID)
+	})
+
+	t.Run("NotRecorded", func(t *testing.T) {
+		s.repository.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).Return(fmt.Errorf("Timeout")).Times(1)
+
+		_, _, err := s.Users(ctx, "user:admin", "", 10, "")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+
+	t.Run("WithBookmarkCount", func(t *testing.T) {
+		expectAudit(s, entity.AuditViewUser, "user:1", entity.AuditSucceeded)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return([]entity.User{
+			{Username: "jane@example.com", Method: "google", UserID: "user:1"},
+			{Username: "jane", Method: "github", UserID: "user:1", Disabled: true},
+		}, nil).Times(1)
+		s.userRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{DisplayName: "Jane"}, nil).Times(1)
+		s.repository.EXPECT().CountBookmarks(gomock.Any(), "user:1").Return(42, nil).Times(1)
+
+		account, err := s.Account(ctx, "user:admin", "user:1")
+		assert.Nil(t, err)
+		assert.Equal(t, "Jane", account.DisplayName)
+		assert.Equal(t, 42, account.Bookmarks)
+		assert.Len(t, account.Identities, 2)
+		assert.True(t, account.Disabled)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		expectAudit(s, entity.AuditViewUser, "user:2", entity.AuditFailed)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:2").Return([]entity.User{}, nil).Times(1)
+
+		_, err := s.Account(ctx, "user:admin", "user:2")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+}
+
+func TestDisable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+	identities := []entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}
+
+	t.Run("Disable", func(t *testing.T) {
+		expectAudit(s, entity.AuditDisableUser, "user:1", entity.AuditSucceeded)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(1)
+		s.userRepository.EXPECT().SetDisabled(gomock.Any(), "jane@example.com", "google", true).Return(nil).Times(1)
+		s.userRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane@example.com", "google").Return(entity.User{}, nil).Times(1)
+
+		assert.Nil(t, s.Disable(ctx, "user:admin", "user:1"))
+	})
+
+	t.Run("NotRecorded", func(t *testing.T) {
+		s.repository.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).Return(fmt.Errorf("Timeout")).Times(1)
+
+		// The account is left alone
+		assert.NotNil(t, s.Disable(ctx, "user:admin", "user:1"))
+	})
+
+	t.Run("OwnAccount", func(t *testing.T) {
+		assert.Equal(t, errors.ErrPreconditionFailed, s.Disable(ctx, "user:admin", "user:admin"))
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+		expectAudit(s, entity.AuditEnableUser, "user:1", entity.AuditSucceeded)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(1)
+		s.userRepository.EXPECT().SetDisabled(gomock.Any(), "jane@example.com", "google", false).Return(nil).Times(1)
+
+		assert.Nil(t, s.Enable(ctx, "user:admin", "user:1"))
+	})
+
+	t.Run("OutcomeNotRecorded", func(t *testing.T) {
+		s.repository.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(1)
+		s.userRepository.EXPECT().SetDisabled(gomock.Any(), "jane@example.com", "google", false).Return(nil).Times(1)
+		s.repository.EXPECT().SetAuditOutcome(gomock.Any(), gomock.Any()).Return(fmt.Errorf("Timeout")).Times(1)
+
+		// The account has been enabled already, the entry stays pending
+		assert.Nil(t, s.Enable(ctx, "user:admin", "user:1"))
+	})
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+
+	t.Run("EveryIdentity", func(t *testing.T) {
+		identities := []entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}
+		expectAudit(s, entity.AuditLogoutUser, "user:1", entity.AuditSucceeded)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(2)
+		s.userRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane@example.com", "google").Return(entity.User{}, nil).Times(1)
+
+		assert.Nil(t, s.Logout(ctx, "user:admin", "user:1"))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		expectAudit(s, entity.AuditLogoutUser, "user:2", entity.AuditFailed)
+		s.userRepository.EXPECT().ListByUserID(gomock.Any(), "user:2").Return([]entity.User{}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, s.Logout(ctx, "user:admin", "user:2"))
+	})
+}
@@ -2,10 +2,16 @@ package auth
 
 import (
 	"net/http"
+	"time"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
 )
 
 func NewApi(auth *Auth, logger *zap.Logger) Api {
@@ -21,7 +27,11 @@ type Api interface {
 	Session() gin.HandlerFunc
 	RegisterSigninHandlers(rg *gin.RouterGroup)
 	RegisterAuthHandlers(rg *gin.RouterGroup)
+	// Registers the handlers linking identities to the account of the user
+	RegisterHandlers(rg *gin.RouterGroup)
 	GetAuthMiddleware() *jwt.GinJWTMiddleware
+	// Authenticates API requests with JWTs or personal API tokens
+	Middleware() gin.HandlerFunc
 }
 
 func (r *resource) Session() gin.HandlerFunc {
@@ -32,17 +42,174 @@ func (r *resource) GetAuthMiddleware() *jwt.GinJWTMiddleware {
 	return r.auth.AuthMiddleware()
 }
 
+func (r *resource) Middleware() gin.HandlerFunc {
+	return r.auth.Middleware()
+}
+
 func (r *resource) RegisterSigninHandlers(rg *gin.RouterGroup) {
-	rg.GET("/signin/google", r.auth.Google.SigninHandler)
-	rg.GET("/callback", r.auth.Google.AuthCallbackMiddleware(), r.GetAuthMiddleware().LoginHandler)
+	loginHandler := r.GetAuthMiddleware().LoginHandler
+
+	rg.GET("/signin/:provider", func(c *gin.Context) {
+		provider, ok := r.auth.Providers.Get(AuthMethod(c.Param("provider")))
+		if !ok {
+			c.JSON(http.StatusNotFound, errors.NotFound("Sign in provider not found"))
+			return
+		}
+		provider.SigninHandler(c)
+	})
+	rg.GET("/callback/:provider", func(c *gin.Context) {
+		method := AuthMethod(c.Param("provider"))
+		callback, ok := r.callbackMiddleware(method)
+		if !ok {
+			c.JSON(http.StatusNotFound, errors.NotFound("Sign in provider not found"))
+			return
+		}
+
+		callback(c)
+		if c.IsAborted() {
+			return
+		}
+		c.Set(providerKey, method)
+		loginHandler(c)
+	})
+	if LocalAccountsEnabled() {
+		rg.POST("/signin/local", r.auth.LocalSigninMiddleware(), loginHandler)
+	}
+	// Google redirects here since before there were other providers
+	rg.GET("/callback", r.auth.Providers[Google].AuthCallbackMiddleware(), loginHandler)
+}
+
+// Magic links come back like the providers do, at /callback/magic-link
+func (r *resource) callbackMiddleware(method AuthMethod) (gin.HandlerFunc, bool) {
+	if method == MagicLink {
+		return r.auth.MagicLinkMiddleware(), MagicLinksEnabled()
+	}
+
+	provider, ok := r.auth.Providers.Get(method)
+	if !ok {
+		return nil, false
+	}
+	return provider.AuthCallbackMiddleware(), true
 }
 
 func (r *resource) RegisterAuthHandlers(rg *gin.RouterGroup) {
+	// The JWT has usually expired by the time it is refreshed, the refresh token is all it takes
+	rg.POST("/auth/refresh", r.auth.Refresh)
+
 	auth := rg.Group("/auth", r.GetAuthMiddleware().MiddlewareFunc())
 	{
-		auth.POST("/check", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"Status": "Success", "Message": "Token is valid"})
-		})
-		auth.POST("/refresh", r.GetAuthMiddleware().RefreshHandler)
+		auth.POST("/check", r.check)
+		auth.POST("/logout", r.auth.Logout)
+		auth.POST("/logout-all", r.auth.LogoutAll)
+	}
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	// A leaked API token must not be able to link an identity of someone else
+	admin := requireScope(entity.ScopeAdmin)
+	rg.GET("/identities", admin, r.identities)
+	rg.POST("/identities/:provider", admin, r.link)
+	rg.DELETE("/identities/:provider/:username", admin, r.unlink)
+}
+
+// The profile of the account comes with the check, so clients set up with a single request
+func (r *resource) check(c *gin.Context) {
+	authUser := r.auth.currentUser(c)
+	profile, err := r.auth.userService.Profile(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get profile"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"Status": "Success", "Message": "Token is valid", "Profile": profile})
+}
+
+type IdentityResponse struct {
+	Username    string    `json:"username"`
+	Method      string    `json:"method"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+func newIdentityResponse(identity user.User) IdentityResponse {
+	return IdentityResponse{
+		Username:    identity.Username,
+		Method:      identity.Method,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
+
+type LinkResponse struct {
+	// Sign in page of the provider, the identity signing in there is linked to the account
+	URL string `json:"url"`
+}
+
+func (r *resource) identities(c *gin.Context) {
+	authUser := r.auth.currentUser(c)
+	identities, err := r.auth.userService.Identities(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list identities"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(identities, newIdentityResponse))
+}
+
+// Only the providers of the registry can be linked, local users and magic links sign in to their own account
+func (r *resource) link(c *gin.Context) {
+	provider, ok := r.auth.Providers.Get(AuthMethod(c.Param("provider")))
+	if !ok {
+		c.JSON(http.StatusNotFound, errors.NotFound("Sign in provider not found"))
+		return
+	}
+
+	authUser := r.auth.currentUser(c)
+	state, err := startSignin(c, true)
+	if err != nil {
+		abortSignin(c, err)
+		return
+	}
+	err = r.auth.userService.StartLink(c.Request.Context(), authUser.ID(), state.State)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to start linking"))
+		return
+	}
+
+	url := provider.GetSigninURL(c.Request.Context(), state)
+	if url == "" {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to reach sign in provider"))
+		return
+	}
+
+	c.JSON(http.StatusOK, LinkResponse{URL: url})
+}
+
+func (r *resource) unlink(c *gin.Context) {
+	authUser := r.auth.currentUser(c)
+	err := r.auth.userService.Unlink(c.Request.Context(), authUser.ID(), c.Param("username"), c.Param("provider"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Identity not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Account must keep an identity"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to unlink identity"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Like session.RequireScope, which can not be used here since it depends on this package
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authUser, ok := c.Get("user")
+		if !ok || !authUser.(*AuthUser).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, errors.Forbidden("Token scope does not allow this"))
+			return
+		}
+
+		c.Next()
 	}
 }
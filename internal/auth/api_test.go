@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+// Intents are stored by the hash of the state, like the secrets of tokens
+func stateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLinkIdentity(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+	os.Setenv("SESSION_KEY", "test-session-key-of-at-least-32-bytes")
+	os.Setenv("SESSION_INSECURE", "true")
+	defer os.Unsetenv("SESSION_KEY")
+	defer os.Unsetenv("SESSION_INSECURE")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	emails := []GitHubEmail{{Email: "jane@example.com", Primary: true, Verified: true}}
+	idp := newFakeGitHub(t, &emails)
+	defer idp.Close()
+
+	conf := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   idp.URL + "/login/oauth/authorize",
+			TokenURL:  idp.URL + "/login/oauth/access_token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	providers := Providers{Google: NewGoogleOAuth(), GitHub: newGitHubOAuth(conf, idp.URL)}
+	tokens := fakeTokens{
+		"pat_write": {ID: "1", Owner: "user:1", Username: "jane@gmail.com", Method: "google", Scopes: []string{entity.ScopeWrite}, ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	a := NewAuth(providers, user.NewService(mockUserRepository, zapLogger), tokens, nil, nil, newFakeRefreshTokens(), zapLogger)
+
+	r := gin.Default()
+	r.Use(a.Session())
+	api := NewApi(a, zapLogger)
+	api.RegisterSigninHandlers(r.Group("/"))
+	api.RegisterHandlers(r.Group("/api/v1", a.Middleware()))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	jwtToken, _, _ := a.AuthMiddleware().TokenGenerator(&AuthUser{Username: "jane@gmail.com", Method: Google, UserID: "user:1"})
+	newClient := func() *http.Client {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	send := func(client *http.Client, method, path, token string) *http.Response {
+		request, _ := http.NewRequest(method, ts.URL+path, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	// Starts linking GitHub and returns the state the sign in page carries
+	link := func(client *http.Client) string {
+		expectNotRevoked(mockUserRepository, "jane@gmail.com")
+		mockUserRepository.EXPECT().CreateLinkIntent(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, intent entity.LinkIntent) error {
+				assert.Equal(t, "user:1", intent.UserID)
+				assert.WithinDuration(t, time.Now().Add(user.LinkTTL), intent.ExpiresAt, time.Minute)
+				return nil
+			}).Times(1)
+
+		resp := send(client, http.MethodPost, "/api/v1/identities/github", jwtToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body LinkResponse
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		location, err := url.Parse(body.URL)
+		if err != nil {
+			t.Fatalf("Expected sign in URL, got %v", err)
+		}
+		return location.Query().Get("state")
+	}
+
+	callback := func(client *http.Client, state string) *http.Response {
+		query := url.Values{"state": {state}, "code": {"valid-code"}}
+		resp, err := client.Get(ts.URL + "/callback/github?" + query.Encode())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	intent := entity.LinkIntent{UserID: "user:1", ExpiresAt: time.Now().Add(time.Minute)}
+
+	t.Run("Link", func(t *testing.T) {
+		client := newClient()
+		state := link(client)
+		mockUserRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), stateHash(state)).Return(intent, nil).Times(1)
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(entity.User{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, u entity.User) (entity.User, error) {
+				assert.Equal(t, "user:1", u.UserID)
+				return u, nil
+			}).Times(1)
+
+		resp := callback(client, state)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", claim.Username)
+		assert.Equal(t, "github", claim.Method)
+		assert.Equal(t, "user:1", claim.UserID)
+	})
+
+	t.Run("IdentityOfAnotherAccount", func(t *testing.T) {
+		client := newClient()
+		state := link(client)
+		mockUserRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), gomock.Any()).Return(intent, nil).Times(1)
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github", UserID: "user:2"}, nil).Times(1)
+
+		assert.Equal(t, http.StatusUnauthorized, callback(client, state).StatusCode)
+	})
+
+	t.Run("SigninDoesNotLink", func(t *testing.T) {
+		client := newClient()
+		resp, _ := client.Get(ts.URL + "/signin/github")
+		location, _ := url.Parse(resp.Header.Get("Location"))
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github", UserID: "user:2"}, nil).Times(1)
+		mockUserRepository.EXPECT().Update(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, u entity.User) (entity.User, error) { return u, nil }).Times(1)
+
+		assert.Equal(t, http.StatusOK, callback(client, location.Query().Get("state")).StatusCode)
+	})
+
+	t.Run("APITokenMayNotLink", func(t *testing.T) {
+		expectEnabled(mockUserRepository, "user:1")
+		resp := send(newClient(), http.MethodPost, "/api/v1/identities/github", "pat_write")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane@gmail.com")
+		resp := send(newClient(), http.MethodPost, "/api/v1/identities/local", jwtToken)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Identities", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane@gmail.com")
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@gmail.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+
+		resp := send(newClient(), http.MethodGet, "/api/v1/identities", jwtToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body []IdentityResponse
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		assert.Len(t, body, 1)
+		assert.Equal(t, "jane@gmail.com", body[0].Username)
+		assert.Equal(t, "google", body[0].Method)
+	})
+
+	t.Run("Unlink", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane@gmail.com")
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return([]entity.User{
+			{Username: "jane@gmail.com", Method: "google", UserID: "user:1"},
+			{Username: "jane@example.com", Method: "github", UserID: "user:1"},
+		}, nil).Times(1)
+		mockUserRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "github", "user:1").Return(nil).Times(1)
+
+		resp := send(newClient(), http.MethodDelete, "/api/v1/identities/github/jane@example.com", jwtToken)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("UnlinkLast", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane@gmail.com")
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@gmail.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+
+		resp := send(newClient(), http.MethodDelete, "/api/v1/identities/google/jane@gmail.com", jwtToken)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubApiURL = "https://api.github.com"
+
+type GitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func NewGitHubOAuth() *GitHubOAuth {
+	conf := &oauth2.Config{
+		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+		Scopes:       []string{"user:email"},
+		Endpoint:     github.Endpoint,
+	}
+
+	return newGitHubOAuth(conf, githubApiURL)
+}
+
+func newGitHubOAuth(conf *oauth2.Config, apiURL string) *GitHubOAuth {
+	return &GitHubOAuth{conf: conf, apiURL: apiURL}
+}
+
+type GitHubOAuth struct {
+	conf   *oauth2.Config
+	apiURL string
+}
+
+// GitHub sign in is optional, it is offered only when the client is configured
+func (o *GitHubOAuth) IsConfigured() bool {
+	return o.conf.ClientID != ""
+}
+
+func (o *GitHubOAuth) SigninHandler(ctx *gin.Context) {
+	state, err := startSignin(ctx, false)
+	if err != nil {
+		abortSignin(ctx, err)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, o.GetSigninURL(ctx.Request.Context(), state))
+}
+
+// GitHub OAuth apps redeem codes with their client secret, the code is bound to the sign in by the state
+func (o *GitHubOAuth) GetSigninURL(_ context.Context, state SigninState) string {
+	return o.conf.AuthCodeURL(state.State)
+}
+
+// GitHub users are known by their primary email, and only once they have verified it
+func (o *GitHubOAuth) VerifyToken(ctx context.Context, token *oauth2.Token) (*AuthUser, error) {
+	client := o.conf.Client(ctx, token)
+	resp, err := client.Get(o.apiURL + "/user/emails")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get GitHub emails: %s", resp.Status)
+	}
+
+	var emails []GitHubEmail
+	err = json.NewDecoder(resp.Body).Decode(&emails)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return &AuthUser{Username: strings.ToLower(email.Email), Method: GitHub}, nil
+		}
+	}
+
+	return nil, errors.New("GitHub user has no verified primary email")
+}
+
+func (o *GitHubOAuth) AuthCallbackMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := checkState(ctx); !ok {
+			_ = ctx.AbortWithError(http.StatusUnauthorized, errors.New("Invalid session state"))
+			return
+		}
+
+		tok, err := o.conf.Exchange(ctx.Request.Context(), ctx.Query("code"))
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ctx.Set("token", tok)
+	}
+}
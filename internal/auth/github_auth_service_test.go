@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+// Serves the token endpoint and the email API of GitHub
+func newFakeGitHub(t *testing.T, emails *[]GitHubEmail) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"gho_test","token_type":"bearer","scope":"user:email"}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(emails)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestNewProviders(t *testing.T) {
+	os.Unsetenv("GITHUB_CLIENT_ID")
+	providers := NewProviders(NewGoogleOAuth(), NewGitHubOAuth(), NewOIDCProvider())
+	_, ok := providers.Get(GitHub)
+	assert.False(t, ok)
+	_, ok = providers.Get(Google)
+	assert.True(t, ok)
+
+	os.Setenv("GITHUB_CLIENT_ID", "client")
+	defer os.Unsetenv("GITHUB_CLIENT_ID")
+	providers = NewProviders(NewGoogleOAuth(), NewGitHubOAuth(), NewOIDCProvider())
+	_, ok = providers.Get(GitHub)
+	assert.True(t, ok)
+}
+
+func TestGitHubSignin(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+	os.Setenv("SESSION_KEY", "test-session-key-of-at-least-32-bytes")
+	os.Setenv("SESSION_INSECURE", "true")
+	defer os.Unsetenv("SESSION_KEY")
+	defer os.Unsetenv("SESSION_INSECURE")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	emails := []GitHubEmail{}
+	idp := newFakeGitHub(t, &emails)
+	defer idp.Close()
+
+	conf := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   idp.URL + "/login/oauth/authorize",
+			TokenURL:  idp.URL + "/login/oauth/access_token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	providers := Providers{Google: NewGoogleOAuth(), GitHub: newGitHubOAuth(conf, idp.URL)}
+	a := NewAuth(providers, user.NewService(mockUserRepository, zapLogger), fakeTokens{}, nil, nil, newFakeRefreshTokens(), zapLogger)
+
+	r := gin.Default()
+	r.Use(a.Session())
+	NewApi(a, zapLogger).RegisterSigninHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	// Signs in up to the redirect to GitHub and returns the state it carries
+	signin := func(client *http.Client) string {
+		resp, err := client.Get(ts.URL + "/signin/github")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatalf("Expected redirect, got %v", err)
+		}
+		assert.Equal(t, idp.URL+"/login/oauth/authorize", fmt.Sprintf("%s://%s%s", location.Scheme, location.Host, location.Path))
+		return location.Query().Get("state")
+	}
+
+	callback := func(client *http.Client, state, code string) *http.Response {
+		query := url.Values{"state": {state}, "code": {code}}
+		resp, err := client.Get(ts.URL + "/callback/github?" + query.Encode())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		emails = []GitHubEmail{
+			{Email: "jane@users.noreply.github.com", Primary: false, Verified: true},
+			{Email: "Jane@Example.com", Primary: true, Verified: true},
+		}
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(entity.User{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, u entity.User) (entity.User, error) {
+				assert.Equal(t, "github", u.Method)
+				return u, nil
+			}).Times(1)
+
+		client := newClient()
+		resp := callback(client, signin(client), "valid-code")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", claim.Username)
+		assert.Equal(t, "github", claim.Method)
+	})
+
+	t.Run("UnverifiedEmail", func(t *testing.T) {
+		emails = []GitHubEmail{{Email: "jane@example.com", Primary: true, Verified: false}}
+
+		client := newClient()
+		resp := callback(client, signin(client), "valid-code")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("InvalidState", func(t *testing.T) {
+		client := newClient()
+		signin(client)
+		resp := callback(client, "forged", "valid-code")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("StateIsSingleUse", func(t *testing.T) {
+		emails = []GitHubEmail{{Email: "jane@example.com", Primary: true, Verified: false}}
+
+		client := newClient()
+		state := signin(client)
+		callback(client, state, "valid-code")
+		resp := callback(client, state, "valid-code")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("InvalidCode", func(t *testing.T) {
+		client := newClient()
+		resp := callback(client, signin(client), "invalid-code")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		client := newClient()
+		resp, err := client.Get(ts.URL + "/signin/myspace")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = callback(client, "state", "valid-code")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp, err = client.Get(ts.URL + "/callback/myspace")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Redirect", func(t *testing.T) {
+		os.Setenv("SIGNIN_REDIRECT_ALLOWLIST", "https://booklog.link/app, http://localhost:3000")
+		defer os.Unsetenv("SIGNIN_REDIRECT_ALLOWLIST")
+		emails = []GitHubEmail{{Email: "jane@example.com", Primary: true, Verified: true}}
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github", UserID: "user:1"}, nil).Times(1)
+		mockUserRepository.EXPECT().Update(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, u entity.User) (entity.User, error) { return u, nil }).Times(1)
+
+		client := newClient()
+		resp, err := client.Get(ts.URL + "/signin/github?redirect_to=" + url.QueryEscape("https://booklog.link/app/signin"))
+		assert.Nil(t, err)
+		location, _ := url.Parse(resp.Header.Get("Location"))
+
+		resp = callback(client, location.Query().Get("state"), "valid-code")
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+		redirect, err := url.Parse(resp.Header.Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, "https://booklog.link/app/signin", fmt.Sprintf("%s://%s%s", redirect.Scheme, redirect.Host, redirect.Path))
+		fragment, _ := url.ParseQuery(redirect.Fragment)
+		claim, err := a.VerifyToken(fragment.Get("token"))
+		assert.Nil(t, err)
+		assert.Equal(t, "user:1", claim.UserID)
+		// The refresh token stays out of the url, in a cookie only the refresh endpoint gets
+		assert.Empty(t, fragment.Get("refresh_token"))
+		var cookie *http.Cookie
+		for _, c := range resp.Cookies() {
+			if c.Name == refreshCookie {
+				cookie = c
+			}
+		}
+		if assert.NotNil(t, cookie) {
+			assert.NotEmpty(t, cookie.Value)
+			assert.Equal(t, "/auth/refresh", cookie.Path)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		}
+	})
+
+	t.Run("RedirectNotAllowed", func(t *testing.T) {
+		os.Setenv("SIGNIN_REDIRECT_ALLOWLIST", "https://booklog.link/app")
+		defer os.Unsetenv("SIGNIN_REDIRECT_ALLOWLIST")
+
+		resp, err := newClient().Get(ts.URL + "/signin/github?redirect_to=" + url.QueryEscape("https://evil.example.com/app"))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestIsAllowedRedirect(t *testing.T) {
+	os.Setenv("SIGNIN_REDIRECT_ALLOWLIST", "https://booklog.link/app,http://localhost:3000")
+	defer os.Unsetenv("SIGNIN_REDIRECT_ALLOWLIST")
+
+	assert.True(t, isAllowedRedirect("https://booklog.link/app"))
+	assert.True(t, isAllowedRedirect("https://booklog.link/app/signin?next=%2Fbookmarks"))
+	assert.True(t, isAllowedRedirect("http://localhost:3000/callback"))
+	assert.False(t, isAllowedRedirect("https://booklog.link/apple"))
+	assert.False(t, isAllowedRedirect("http://booklog.link/app"))
+	assert.False(t, isAllowedRedirect("https://booklog.link.evil.com/app"))
+	assert.False(t, isAllowedRedirect("https://jane@booklog.link/app"))
+	assert.False(t, isAllowedRedirect("https://booklog.link/app#token=forged"))
+	assert.False(t, isAllowedRedirect("/app"))
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	verifier "github.com/gbrlsnchs/jwt/v3"
+)
+
+// Unknown key IDs fetch the keys again at most this often, so forged tokens can not hammer the provider
+const keysRefreshInterval = time.Minute
+
+var errUnknownKey = errors.New("ID token is signed with an unknown key")
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet caches the public keys of a provider by their ID. Providers rotate keys, so keys it does not know are
+// fetched again, dropping the ones which have been rotated out
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mutex     sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, client *http.Client) *keySet {
+	return &keySet{uri: uri, client: client}
+}
+
+func (s *keySet) get(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if s.keys != nil && time.Since(s.fetchedAt) < keysRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	s.fetchedAt = time.Now()
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// Tokens without a key ID may only be signed by providers with a single key
+func (s *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *keySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get the keys of the provider: %s", resp.Status)
+	}
+
+	var body struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range body.Keys {
+		// Only RSA signing keys are supported, like the ones of Google and Keycloak
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("Invalid exponent of key %s", k.Kid)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// keyResolver verifies ID tokens with the key their header names, see verifier.Resolver
+type keyResolver struct {
+	ctx  context.Context
+	keys *keySet
+	alg  *verifier.RSASHA
+}
+
+func (r *keyResolver) Resolve(header verifier.Header) error {
+	key, err := r.keys.get(r.ctx, header.KeyID)
+	if err != nil {
+		return err
+	}
+
+	switch header.Algorithm {
+	case "RS256":
+		r.alg = verifier.NewRS256(verifier.RSAPublicKey(key))
+	case "RS384":
+		r.alg = verifier.NewRS384(verifier.RSAPublicKey(key))
+	case "RS512":
+		r.alg = verifier.NewRS512(verifier.RSAPublicKey(key))
+	default:
+		return fmt.Errorf("Unsupported ID token algorithm: %s", header.Algorithm)
+	}
+
+	return nil
+}
+
+func (r *keyResolver) Name() string {
+	if r.alg == nil {
+		return ""
+	}
+	return r.alg.Name()
+}
+
+func (r *keyResolver) Sign(headerPayload []byte) ([]byte, error) {
+	return nil, errors.New("ID tokens are only verified")
+}
+
+func (r *keyResolver) Size() int {
+	if r.alg == nil {
+		return 0
+	}
+	return r.alg.Size()
+}
+
+func (r *keyResolver) Verify(headerPayload, sig []byte) error {
+	if r.alg == nil {
+		return errUnknownKey
+	}
+	return r.alg.Verify(headerPayload, sig)
+}
@@ -1,38 +1,101 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	verifier "github.com/gbrlsnchs/jwt/v3"
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
+	gorillaSessions "github.com/gorilla/sessions"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 
+	"bookmark-api/internal/entity"
+	apiErrors "bookmark-api/internal/errors"
 	"bookmark-api/internal/user"
+	"bookmark-api/pkg/db"
 )
 
-func NewAuth(google *GoogleOAuth, userService user.Service, logger *zap.Logger) *Auth {
-	return &Auth{Google: google, userService: userService, logger: logger}
+const (
+	// JWTs are short lived, clients renew them with their refresh token
+	tokenTimeout = 15 * time.Minute
+	// Set on the request when the JWT has been revoked
+	revokedKey = "revoked"
+	// Set on the callback request to the method of the provider signing the user in
+	providerKey = "provider"
+	// Set on sign in requests to the user whose password or magic link has been checked
+	verifiedUserKey = "verified_user"
+	// Set on sign in requests to the first refresh token of the sign in
+	refreshTokenKey = "refresh_token"
+	// Cookie keeping the refresh token of browsers which signed in with redirect_to
+	refreshCookie = "refresh_token"
+	refreshPath   = "/auth/refresh"
+	// Lifetime of the refresh token family of a sign in, see refresh.FamilyTTL
+	refreshCookieMaxAge = 90 * 24 * time.Hour
+	// Time to finish a sign in with the provider
+	sessionTimeout      = 30 * time.Minute
+	minSessionKeyLength = 32
+)
+
+func NewAuth(providers Providers, userService user.Service, tokens APITokenVerifier, passwords PasswordVerifier, magicLinks MagicLinkVerifier, refreshTokens RefreshTokenRotator, logger *zap.Logger) *Auth {
+	return &Auth{Providers: providers, userService: userService, tokens: tokens, passwords: passwords, magicLinks: magicLinks, refreshTokens: refreshTokens, logger: logger}
 }
 
 type Auth struct {
-	Google      *GoogleOAuth
-	userService user.Service
-	logger      *zap.Logger
+	Providers     Providers
+	userService   user.Service
+	tokens        APITokenVerifier
+	passwords     PasswordVerifier
+	magicLinks    MagicLinkVerifier
+	refreshTokens RefreshTokenRotator
+	logger        *zap.Logger
 }
 
+// The session only carries sign ins in progress, signed with SESSION_KEY. Its cookie is only sent over HTTPS unless
+// SESSION_INSECURE is set for local development
 func (a *Auth) Session() gin.HandlerFunc {
-	store := sessions.NewCookieStore([]byte("sessionKey"))
+	key := os.Getenv("SESSION_KEY")
+	if len(key) < minSessionKeyLength {
+		log.Fatalf("SESSION_KEY must have at least %d characters", minSessionKeyLength)
+	}
+
+	store := &cookieStore{gorillaSessions.NewCookieStore([]byte(key))}
+	store.Options(sessions.Options{
+		Path:     "/",
+		MaxAge:   int(sessionTimeout.Seconds()),
+		Secure:   os.Getenv("SESSION_INSECURE") != "true",
+		HttpOnly: true,
+	})
 
 	return sessions.Sessions("auth_session", store)
 }
 
+// Cookie store of the sessions which also sets SameSite, the sessions package predates it
+type cookieStore struct {
+	*gorillaSessions.CookieStore
+}
+
+// Lax still sends the cookie on the redirect back from the provider, which is a top level navigation
+func (s *cookieStore) Options(options sessions.Options) {
+	s.CookieStore.Options = &gorillaSessions.Options{
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure,
+		HttpOnly: options.HttpOnly,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
 func (a *Auth) VerifyToken(token string) (Claim, error) {
 	logger := a.logger.Sugar()
 	defer func() {
@@ -52,12 +115,244 @@ func (a *Auth) VerifyToken(token string) (Claim, error) {
 	return payload, nil
 }
 
+// Returns the user of a personal API token or a JWT
+func (a *Auth) Verify(ctx context.Context, token string) (*AuthUser, error) {
+	if strings.HasPrefix(token, entity.APITokenPrefix) {
+		return a.verifyAPIToken(ctx, token)
+	}
+
+	claim, err := a.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	authUser := &AuthUser{
+		Username:   claim.Username,
+		Method:     AuthMethod(claim.Method),
+		UserID:     claim.UserID,
+		Roles:      claim.Roles,
+		TokenID:    claim.ID,
+		Generation: claim.Generation,
+		SessionID:  claim.SessionID,
+	}
+	if a.isRevoked(ctx, authUser) {
+		return nil, errors.New("Token has been revoked")
+	}
+
+	return authUser, nil
+}
+
+// Revoked JWTs are refused, as well as any JWT when revocation can not be checked
+func (a *Auth) isRevoked(ctx context.Context, authUser *AuthUser) bool {
+	logger := a.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	revoked, err := a.userService.IsRevoked(ctx, authUser.Username, string(authUser.Method), authUser.TokenID, authUser.Generation)
+	if err != nil {
+		logger.Errorw("Failed to check token revocation", zap.String("Username", authUser.Username), zap.Error(err))
+		return true
+	}
+
+	return revoked
+}
+
+// Revokes the JWT of the request and the refresh tokens of its sign in. The JWT stays revoked until it expires
+func (a *Auth) Logout(c *gin.Context) {
+	authUser := a.currentUser(c)
+	if authUser.TokenID == "" {
+		c.JSON(http.StatusBadRequest, apiErrors.BadRequest("Token can not be revoked alone, sign out everywhere instead"))
+		return
+	}
+
+	expiresAt := time.Now().Add(tokenTimeout)
+	if exp, ok := jwt.ExtractClaims(c)["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if authUser.SessionID != "" {
+		err := a.refreshTokens.Revoke(c.Request.Context(), authUser.SessionID)
+		if err != nil && err != apiErrors.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to logout"))
+			return
+		}
+	}
+
+	err := a.userService.Logout(c.Request.Context(), authUser.Username, authUser.TokenID, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to logout"))
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Spends the refresh token of the request for a new JWT and the next refresh token of its sign in.
+// Requests without a body spend the one of the refresh cookie, the next one replaces it then
+func (a *Auth) Refresh(c *gin.Context) {
+	request := RefreshRequest{}
+	cookie, _ := c.Cookie(refreshCookie)
+	fromCookie := c.Request.ContentLength == 0 && cookie != ""
+	if fromCookie {
+		request.RefreshToken = cookie
+	} else if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, apiErrors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	refreshToken, family, err := a.refreshTokens.Rotate(c.Request.Context(), request.RefreshToken)
+	if err != nil {
+		switch err {
+		case apiErrors.ErrNotFound, apiErrors.ErrExpired, apiErrors.ErrAborted:
+			c.JSON(http.StatusUnauthorized, apiErrors.Unauthorized("Refresh token is invalid, revoked or expired"))
+		default:
+			c.JSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to refresh token"))
+		}
+		return
+	}
+
+	authUser := &AuthUser{
+		Username:   family.Username,
+		Method:     AuthMethod(family.Method),
+		UserID:     family.UserID,
+		Generation: family.Generation,
+		SessionID:  family.ID,
+	}
+	// Roles are looked up again, so an account removed from ADMIN_USER_IDS loses them with its next JWT
+	authUser.Roles = rolesOf(authUser.ID())
+	// Signing out everywhere bumps the token generation, which ends the refresh tokens issued before as well
+	if a.isRevoked(c.Request.Context(), authUser) {
+		_ = a.refreshTokens.Revoke(c.Request.Context(), family.ID)
+		c.JSON(http.StatusUnauthorized, apiErrors.Unauthorized("Refresh token is invalid, revoked or expired"))
+		return
+	}
+
+	token, expire, err := a.AuthMiddleware().TokenGenerator(authUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to refresh token"))
+		return
+	}
+
+	if fromCookie {
+		setRefreshCookie(c, refreshToken)
+		c.JSON(http.StatusOK, gin.H{
+			"code":   http.StatusOK,
+			"token":  token,
+			"expire": expire.Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":          http.StatusOK,
+		"token":         token,
+		"expire":        expire.Format(time.RFC3339),
+		"refresh_token": refreshToken,
+	})
+}
+
+// The cookie is only sent to the refresh endpoint and scripts can not read it, so a leaked redirect or a script
+// on the page does not get the sign in. Like the session cookie it needs HTTPS unless SESSION_INSECURE is set
+func setRefreshCookie(c *gin.Context, refreshToken string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refreshToken,
+		Path:     refreshPath,
+		MaxAge:   int(refreshCookieMaxAge.Seconds()),
+		Secure:   os.Getenv("SESSION_INSECURE") != "true",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// Revokes every JWT of the user of the request
+func (a *Auth) LogoutAll(c *gin.Context) {
+	authUser := a.currentUser(c)
+	err := a.userService.LogoutAll(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to logout everywhere"))
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (a *Auth) currentUser(c *gin.Context) *AuthUser {
+	authUser, _ := c.Get("user")
+	return authUser.(*AuthUser)
+}
+
+func (a *Auth) verifyAPIToken(ctx context.Context, token string) (*AuthUser, error) {
+	logger := a.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	apiToken, err := a.tokens.Verify(ctx, token)
+	if err != nil {
+		// The secret is not logged, unlike JWTs it does not expire soon
+		logger.Errorw("Failed to verify API token", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Infow("Verify API token", zap.String("ID", apiToken.ID), zap.String("UserID", apiToken.Owner))
+
+	// Unlike JWTs, tokens are not revoked when the account is disabled, so it is checked on every request
+	disabled, err := a.userService.IsDisabled(ctx, apiToken.Owner)
+	if err != nil {
+		logger.Errorw("Failed to check account of API token", zap.String("UserID", apiToken.Owner), zap.Error(err))
+		return nil, err
+	}
+	if disabled {
+		return nil, apiErrors.ErrDisabled
+	}
+
+	// Tokens created before there were user IDs are owned by the username
+	username := apiToken.Username
+	if username == "" {
+		username = apiToken.Owner
+	}
+
+	return &AuthUser{Username: username, Method: AuthMethod(apiToken.Method), UserID: apiToken.Owner, Scopes: apiToken.Scopes}, nil
+}
+
+// Authenticates requests like the AuthMiddleware, also accepting personal API tokens in the Authorization header.
+// Requests with API tokens are limited to the paths the scopes of the token allow
+func (a *Auth) Middleware() gin.HandlerFunc {
+	jwtMiddleware := a.AuthMiddleware().MiddlewareFunc()
+
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, entity.APITokenPrefix) {
+			jwtMiddleware(c)
+			return
+		}
+
+		authUser, err := a.verifyAPIToken(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrors.Unauthorized("Token is invalid, revoked or expired"))
+			return
+		}
+		if !IsAllowedPath(authUser, c.Request.Method, strings.TrimPrefix(c.Request.URL.Path, APIRoot)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, apiErrors.Forbidden("Token scope does not allow this"))
+			return
+		}
+
+		c.Set("user", authUser)
+		c.Next()
+	}
+}
+
 func (a *Auth) AuthMiddleware() *jwt.GinJWTMiddleware {
 	middleware, err := jwt.New(&jwt.GinJWTMiddleware{
 		Realm:       os.Getenv("OAUTH_REALM"),
 		Key:         []byte(os.Getenv("OAUTH_KEY")),
-		Timeout:     time.Hour * 24,
-		MaxRefresh:  time.Hour * 24 * 7,
+		Timeout:     tokenTimeout,
 		IdentityKey: "username",
 
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
@@ -67,10 +362,20 @@ func (a *Auth) AuthMiddleware() *jwt.GinJWTMiddleware {
 			}()
 
 			if v, ok := data.(*AuthUser); ok {
-				return jwt.MapClaims{
-					"username": v.Username,
-					"method":   v.Method,
+				claims := jwt.MapClaims{
+					"username":   v.Username,
+					"method":     v.Method,
+					"user_id":    v.UserID,
+					"jti":        db.GenerateID(),
+					"generation": v.Generation,
+				}
+				if len(v.Roles) > 0 {
+					claims["roles"] = v.Roles
 				}
+				if v.SessionID != "" {
+					claims["sid"] = v.SessionID
+				}
+				return claims
 			}
 
 			logger.Errorw("Wrong token claim")
@@ -87,9 +392,19 @@ func (a *Auth) AuthMiddleware() *jwt.GinJWTMiddleware {
 			username := claims["username"].(string)
 			method := claims["method"].(string)
 
+			userId, _ := claims["user_id"].(string)
+			tokenId, _ := claims["jti"].(string)
+			generation, _ := claims["generation"].(float64)
+			sessionId, _ := claims["sid"].(string)
+
 			authUser := &AuthUser{
-				Username: username,
-				Method:   AuthMethod(method),
+				Username:   username,
+				Method:     AuthMethod(method),
+				UserID:     userId,
+				Roles:      claimRoles(claims),
+				TokenID:    tokenId,
+				Generation: int(generation),
+				SessionID:  sessionId,
 			}
 
 			// Save authUser in the context
@@ -99,31 +414,81 @@ func (a *Auth) AuthMiddleware() *jwt.GinJWTMiddleware {
 		},
 
 		Authenticator: func(c *gin.Context) (interface{}, error) {
-			token, exist := c.Get("token")
-
-			if !exist {
-				return nil, errors.New("Token does not exist")
+			authUser, err := a.authenticate(c)
+			if err != nil {
+				return nil, err
 			}
 
-			authUser, err := a.Google.VerifyToken(token.(*oauth2.Token))
+			// Update last login date, the token carries the account and the current token generation of the user
+			loggedInUser, err := a.signin(c, authUser)
 			if err != nil {
 				return nil, err
 			}
-
-			// Update last login date
-			_, _ = a.userService.UpdateLastLogin(c.Request.Context(), authUser.Username, string(authUser.Method))
+			authUser.UserID = loggedInUser.UserID
+			authUser.Generation = loggedInUser.TokenGeneration
+			authUser.Roles = rolesOf(authUser.ID())
+			a.fillProfile(c.Request.Context(), authUser)
+
+			// The sign in starts a family of refresh tokens, which the JWT is revoked with
+			refreshToken, family, err := a.refreshTokens.Issue(c.Request.Context(), entity.RefreshFamily{
+				Username:   authUser.Username,
+				Method:     string(authUser.Method),
+				UserID:     authUser.UserID,
+				Roles:      authUser.Roles,
+				Generation: authUser.Generation,
+			})
+			if err != nil {
+				return nil, err
+			}
+			authUser.SessionID = family.ID
+			c.Set(refreshTokenKey, refreshToken)
 
 			return authUser, nil
 		},
 
 		Authorizator: func(data interface{}, c *gin.Context) bool {
 			if u, ok := data.(*AuthUser); ok && u.Method != "" {
-				return a.Google.Authorize(c, u)
+				if a.isRevoked(c.Request.Context(), u) {
+					c.Set(revokedKey, true)
+					return false
+				}
+				// Users of a provider which has been removed may not sign in anymore
+				switch u.Method {
+				case Local:
+					return LocalAccountsEnabled()
+				case MagicLink:
+					return MagicLinksEnabled()
+				}
+				_, ok := a.Providers.Get(u.Method)
+				return ok
 			}
 
 			return false
 		},
+		// Clients which started the sign in with redirect_to get the JWT in the fragment of it, so it never
+		// reaches a server, and the refresh token in a cookie, since the fragment stays in the history. Others get
+		// both in the body
+		LoginResponse: func(c *gin.Context, code int, token string, expire time.Time) {
+			refreshToken := c.GetString(refreshTokenKey)
+			if redirectTo := c.GetString(redirectKey); redirectTo != "" {
+				setRefreshCookie(c, refreshToken)
+				fragment := url.Values{"token": {token}, "expire": {expire.Format(time.RFC3339)}}
+				c.Redirect(http.StatusFound, redirectTo+"#"+fragment.Encode())
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"code":          http.StatusOK,
+				"token":         token,
+				"expire":        expire.Format(time.RFC3339),
+				"refresh_token": refreshToken,
+			})
+		},
 		Unauthorized: func(c *gin.Context, code int, message string) {
+			// Revoked tokens are as invalid as expired ones, not just forbidden
+			if c.GetBool(revokedKey) {
+				code = http.StatusUnauthorized
+			}
 			c.JSON(code, gin.H{
 				"code":    "error",
 				"message": "failed",
@@ -153,3 +518,130 @@ func (a *Auth) AuthMiddleware() *jwt.GinJWTMiddleware {
 
 	return middleware
 }
+
+// Returns the user the sign in request proves to be, by the password or magic link checked already or by the
+// token of the provider otherwise
+func (a *Auth) authenticate(c *gin.Context) (*AuthUser, error) {
+	if verifiedUser, ok := c.Get(verifiedUserKey); ok {
+		return verifiedUser.(*AuthUser), nil
+	}
+
+	token, exist := c.Get("token")
+	if !exist {
+		return nil, errors.New("Token does not exist")
+	}
+
+	// Callbacks without a provider are the ones of Google, signing users in before there were others
+	method := Google
+	if provider, ok := c.Get(providerKey); ok {
+		method = provider.(AuthMethod)
+	}
+	provider, ok := a.Providers.Get(method)
+	if !ok {
+		return nil, errors.New("Sign in provider does not exist")
+	}
+
+	return provider.VerifyToken(c.Request.Context(), token.(*oauth2.Token))
+}
+
+// Takes the name and picture of the provider for the profile of the account, unless it has them already. The sign
+// in goes on without them when they can not be saved
+func (a *Auth) fillProfile(ctx context.Context, authUser *AuthUser) {
+	if authUser.DisplayName == "" && authUser.Picture == "" {
+		return
+	}
+
+	logger := a.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	err := a.userService.FillProfile(ctx, authUser.ID(), authUser.DisplayName, authUser.Picture)
+	if err != nil {
+		logger.Errorw("Failed to fill profile", zap.String("UserID", authUser.ID()), zap.Error(err))
+	}
+}
+
+// Links the identity to the account which started linking it, signs it in otherwise
+func (a *Auth) signin(c *gin.Context, authUser *AuthUser) (user.User, error) {
+	if !isLinking(c) {
+		return a.userService.UpdateLastLogin(c.Request.Context(), authUser.Username, string(authUser.Method))
+	}
+
+	loggedInUser, err := a.userService.CompleteLink(c.Request.Context(), c.Query(stateKey), authUser.Username, string(authUser.Method))
+	if err == apiErrors.ErrAlreadyExist {
+		return user.User{}, errors.New("Identity belongs to another account")
+	}
+	return loggedInUser, err
+}
+
+type LocalSigninRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Checks the password of a local user, the LoginHandler then issues the JWT
+func (a *Auth) LocalSigninMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := LocalSigninRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, apiErrors.BadRequest("Payload is in wrong format"))
+			return
+		}
+
+		err := a.passwords.Verify(c.Request.Context(), request.Username, request.Password)
+		if err != nil {
+			switch err {
+			case apiErrors.ErrNotFound:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrors.Unauthorized("Username or password is wrong"))
+			case apiErrors.ErrLocked:
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, apiErrors.TooManyRequests("Too many failed attempts, try again later"))
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to sign in"))
+			}
+			return
+		}
+
+		c.Set(verifiedUserKey, &AuthUser{Username: request.Username, Method: Local})
+	}
+}
+
+// Spends the magic link of the token query parameter, the LoginHandler then issues the JWT
+func (a *Auth) MagicLinkMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, err := a.magicLinks.Verify(c.Request.Context(), c.Query("token"))
+		if err != nil {
+			switch err {
+			case apiErrors.ErrNotFound, apiErrors.ErrExpired:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, apiErrors.Unauthorized("Link is invalid, used or expired"))
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to sign in"))
+			}
+			return
+		}
+
+		c.Set(verifiedUserKey, &AuthUser{Username: email, Method: MagicLink})
+	}
+}
+
+// Roles are decoded from JSON as a list of interfaces
+func claimRoles(claims jwt.MapClaims) []string {
+	values, _ := claims["roles"].([]interface{})
+	roles := []string{}
+	for _, value := range values {
+		if role, ok := value.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Accounts of ADMIN_USER_IDS, a comma separated list of user IDs, get the admin role in their JWTs
+func rolesOf(userId string) []string {
+	for _, value := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id := strings.TrimSpace(value); id != "" && id == userId {
+			return []string{RoleAdmin}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,698 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+type fakeTokens map[string]entity.APIToken
+
+func (f fakeTokens) Verify(_ context.Context, secret string) (entity.APIToken, error) {
+	if token, ok := f[secret]; ok {
+		return token, nil
+	}
+	return entity.APIToken{}, errors.ErrNotFound
+}
+
+// Refresh tokens kept in memory, rotated like refresh.Service does
+type fakeRefreshTokens struct {
+	families map[string]*entity.RefreshFamily
+	// Family of each token issued
+	tokens map[string]string
+	used   map[string]bool
+}
+
+func newFakeRefreshTokens() *fakeRefreshTokens {
+	return &fakeRefreshTokens{families: map[string]*entity.RefreshFamily{}, tokens: map[string]string{}, used: map[string]bool{}}
+}
+
+func (f *fakeRefreshTokens) Issue(_ context.Context, family entity.RefreshFamily) (string, entity.RefreshFamily, error) {
+	family.ID = fmt.Sprintf("family-%d", len(f.families)+1)
+	f.families[family.ID] = &family
+	return f.next(family.ID), family, nil
+}
+
+func (f *fakeRefreshTokens) next(familyId string) string {
+	secret := fmt.Sprintf("rt_%d", len(f.tokens)+1)
+	f.tokens[secret] = familyId
+	return secret
+}
+
+func (f *fakeRefreshTokens) Rotate(_ context.Context, secret string) (string, entity.RefreshFamily, error) {
+	familyId, ok := f.tokens[secret]
+	if !ok {
+		return "", entity.RefreshFamily{}, errors.ErrNotFound
+	}
+	family := f.families[familyId]
+	if f.used[secret] {
+		family.RevokedAt = time.Now()
+		return "", entity.RefreshFamily{}, errors.ErrAborted
+	}
+	if family.IsRevoked() {
+		return "", entity.RefreshFamily{}, errors.ErrExpired
+	}
+
+	f.used[secret] = true
+	return f.next(familyId), *family, nil
+}
+
+func (f *fakeRefreshTokens) Revoke(_ context.Context, familyId string) error {
+	family, ok := f.families[familyId]
+	if !ok {
+		return errors.ErrNotFound
+	}
+	family.RevokedAt = time.Now()
+	return nil
+}
+
+func newTestAuth(ctrl *gomock.Controller, tokens fakeTokens) (*Auth, *mocks.MockRepository) {
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	return NewAuth(NewProviders(NewGoogleOAuth(), NewGitHubOAuth(), NewOIDCProvider()), user.NewService(mockUserRepository, zapLogger), tokens, nil, nil, newFakeRefreshTokens(), zapLogger), mockUserRepository
+}
+
+// Expects the revocation check of a JWT which has not been revoked
+func expectNotRevoked(mockUserRepository *mocks.MockRepository, username string) {
+	mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{}, errors.ErrNotFound).Times(1)
+	mockUserRepository.EXPECT().Get(gomock.Any(), username, "google").Return(entity.User{Username: username, Method: "google"}, nil).Times(1)
+}
+
+// Expects the check of the account of an API token which has not been disabled. Accounts created before there
+// were user IDs are also looked up by their username
+func expectEnabled(mockUserRepository *mocks.MockRepository, userId string) {
+	identities := []entity.User{{Username: userId, Method: "google", UserID: userId}}
+	if strings.HasPrefix(userId, "user:") {
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), userId).Return(identities, nil).Times(1)
+		return
+	}
+	mockUserRepository.EXPECT().ListByUserID(gomock.Any(), userId).Return([]entity.User{}, nil).Times(1)
+	mockUserRepository.EXPECT().List(gomock.Any(), userId).Return(identities, nil).Times(1)
+}
+
+func TestMiddleware(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tokens := fakeTokens{
+		"pat_read":  {ID: "1", Owner: "reader", Method: "google", Scopes: []string{entity.ScopeRead}, ExpiresAt: time.Now().Add(time.Hour)},
+		"pat_write": {ID: "2", Owner: "writer", Method: "google", Scopes: []string{entity.ScopeWrite}, ExpiresAt: time.Now().Add(time.Hour)},
+		"pat_disabled": {ID: "3", Owner: "user:3", Username: "joe@example.com", Method: "google", Scopes: []string{entity.ScopeRead},
+			ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	a, mockUserRepository := newTestAuth(ctrl, tokens)
+
+	r := gin.Default()
+	api := r.Group(APIRoot, a.Middleware())
+	handler := func(c *gin.Context) {
+		authUser, _ := c.Get("user")
+		c.String(http.StatusOK, authUser.(*AuthUser).Username)
+	}
+	api.GET("/bookmarks", handler)
+	api.POST("/bookmarks", handler)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	send := func(method, token string) *http.Response {
+		request, _ := http.NewRequest(method, fmt.Sprintf("%s%s/bookmarks", ts.URL, APIRoot), nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	jwtToken, _, err := a.AuthMiddleware().TokenGenerator(&AuthUser{Username: "jane", Method: Google, Generation: 1})
+	if err != nil {
+		t.Fatalf("Expected token, got %v", err)
+	}
+
+	t.Run("JWT", func(t *testing.T) {
+		mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "google").Return(entity.User{Username: "jane", TokenGeneration: 1}, nil).Times(1)
+
+		assert.Equal(t, 200, send(http.MethodPost, jwtToken).StatusCode)
+	})
+
+	t.Run("RevokedJWT", func(t *testing.T) {
+		mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{Username: "jane"}, nil).Times(1)
+
+		assert.Equal(t, 401, send(http.MethodGet, jwtToken).StatusCode)
+	})
+
+	t.Run("JWTOfOlderGeneration", func(t *testing.T) {
+		mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "google").Return(entity.User{Username: "jane", TokenGeneration: 2}, nil).Times(1)
+
+		assert.Equal(t, 401, send(http.MethodGet, jwtToken).StatusCode)
+	})
+
+	t.Run("ReadToken", func(t *testing.T) {
+		expectEnabled(mockUserRepository, "reader")
+		expectEnabled(mockUserRepository, "reader")
+		assert.Equal(t, 200, send(http.MethodGet, "pat_read").StatusCode)
+		assert.Equal(t, 403, send(http.MethodPost, "pat_read").StatusCode)
+	})
+
+	t.Run("WriteToken", func(t *testing.T) {
+		expectEnabled(mockUserRepository, "writer")
+		expectEnabled(mockUserRepository, "writer")
+		assert.Equal(t, 200, send(http.MethodGet, "pat_write").StatusCode)
+		assert.Equal(t, 200, send(http.MethodPost, "pat_write").StatusCode)
+	})
+
+	t.Run("TokenOfDisabledAccount", func(t *testing.T) {
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), "user:3").
+			Return([]entity.User{{Username: "joe@example.com", Method: "google", UserID: "user:3", Disabled: true}}, nil).Times(1)
+
+		assert.Equal(t, 401, send(http.MethodGet, "pat_disabled").StatusCode)
+	})
+
+	t.Run("DisabledJWT", func(t *testing.T) {
+		mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "google").Return(entity.User{Username: "jane", TokenGeneration: 1, Disabled: true}, nil).Times(1)
+
+		assert.Equal(t, 401, send(http.MethodGet, jwtToken).StatusCode)
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		assert.Equal(t, 401, send(http.MethodGet, "pat_unknown").StatusCode)
+	})
+
+	t.Run("FeedToken", func(t *testing.T) {
+		assert.Equal(t, 401, send(http.MethodGet, "feed_token").StatusCode)
+	})
+}
+
+// Cases of the paths tokens may use, the authorizer is tested against them as well
+type scopeCase struct {
+	Name string `json:"name"`
+	// Scope of the API token, empty for a JWT
+	Scope   string `json:"scope"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Allowed bool   `json:"allowed"`
+}
+
+func TestMiddlewareScopes(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var cases []scopeCase
+	data, err := ioutil.ReadFile("testdata/scopes.json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tokens := fakeTokens{}
+	for _, test := range cases {
+		if test.Scope != "" {
+			tokens["pat_"+test.Scope] = entity.APIToken{ID: test.Scope, Owner: "jane", Method: "google", Scopes: []string{test.Scope}, ExpiresAt: time.Now().Add(time.Hour)}
+		}
+	}
+	a, mockUserRepository := newTestAuth(ctrl, tokens)
+
+	r := gin.Default()
+	r.Group(APIRoot, a.Middleware()).Any("/*path", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	jwtToken, _, err := a.AuthMiddleware().TokenGenerator(&AuthUser{Username: "jane", Method: Google})
+	if err != nil {
+		t.Fatalf("Expected token, got %v", err)
+	}
+
+	for _, test := range cases {
+		t.Run(test.Name, func(t *testing.T) {
+			token := jwtToken
+			if test.Scope != "" {
+				token = "pat_" + test.Scope
+				expectEnabled(mockUserRepository, "jane")
+			} else {
+				expectNotRevoked(mockUserRepository, "jane")
+			}
+
+			request, _ := http.NewRequest(test.Method, ts.URL+APIRoot+test.Path, nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expected := http.StatusForbidden
+			if test.Allowed {
+				expected = http.StatusOK
+			}
+			assert.Equal(t, expected, resp.StatusCode)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tokens := fakeTokens{"pat_read": {Owner: "reader", Method: "google", Scopes: []string{entity.ScopeRead}}}
+	a, mockUserRepository := newTestAuth(ctrl, tokens)
+	ctx := context.Background()
+
+	expectEnabled(mockUserRepository, "reader")
+	authUser, err := a.Verify(ctx, "pat_read")
+	assert.Nil(t, err)
+	assert.Equal(t, "reader", authUser.Username)
+	assert.False(t, authUser.HasScope(entity.ScopeWrite))
+
+	jwtToken, _, _ := a.AuthMiddleware().TokenGenerator(&AuthUser{Username: "jane", Method: Google})
+	expectNotRevoked(mockUserRepository, "jane")
+	authUser, err = a.Verify(ctx, jwtToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane", authUser.Username)
+	assert.True(t, authUser.HasScope(entity.ScopeAdmin))
+	assert.NotEmpty(t, authUser.TokenID)
+
+	mockUserRepository.EXPECT().GetRevoked(gomock.Any(), authUser.TokenID).Return(entity.RevokedToken{Username: "jane"}, nil).Times(1)
+	_, err = a.Verify(ctx, jwtToken)
+	assert.NotNil(t, err)
+
+	_, err = a.Verify(ctx, "feed_token")
+	assert.NotNil(t, err)
+}
+
+func TestLogout(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a, mockUserRepository := newTestAuth(ctrl, fakeTokens{})
+	api := NewApi(a, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterAuthHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	refreshTokens := a.refreshTokens.(*fakeRefreshTokens)
+	_, family, _ := refreshTokens.Issue(context.Background(), entity.RefreshFamily{Username: "jane", Method: "google"})
+	jwtToken, _, _ := a.AuthMiddleware().TokenGenerator(&AuthUser{Username: "jane", Method: Google, SessionID: family.ID})
+	send := func(path string) *http.Response {
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s%s", ts.URL, path), nil)
+		request.Header.Set("Authorization", "Bearer "+jwtToken)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Logout", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane")
+		mockUserRepository.EXPECT().Revoke(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, token entity.RevokedToken) error {
+				assert.NotEmpty(t, token.JTI)
+				assert.Equal(t, "jane", token.Username)
+				// Kept until the token expires
+				assert.WithinDuration(t, time.Now().Add(tokenTimeout), token.ExpiresAt, time.Minute)
+				return nil
+			}).Times(1)
+
+		assert.Equal(t, 200, send("/auth/logout").StatusCode)
+		// The refresh tokens of the sign in go with it
+		assert.True(t, refreshTokens.families[family.ID].IsRevoked())
+	})
+
+	t.Run("LogoutAll", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane")
+		// The JWT has no user ID, the account is the one of the username
+		mockUserRepository.EXPECT().ListByUserID(gomock.Any(), "jane").
+			Return([]entity.User{{Username: "jane", Method: "google", UserID: "jane"}}, nil).Times(1)
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane").
+			Return([]entity.User{{Username: "jane", Method: "google", UserID: "jane"}, {Username: "jane", Method: "github"}}, nil).Times(1)
+		mockUserRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane", "github").
+			Return(entity.User{Username: "jane", TokenGeneration: 1}, nil).Times(1)
+		mockUserRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane", "google").
+			Return(entity.User{Username: "jane", TokenGeneration: 1}, nil).Times(1)
+
+		assert.Equal(t, 200, send("/auth/logout-all").StatusCode)
+	})
+
+	t.Run("CheckReturnsProfile", func(t *testing.T) {
+		expectNotRevoked(mockUserRepository, "jane")
+		mockUserRepository.EXPECT().GetProfile(gomock.Any(), "jane").
+			Return(entity.Profile{UserID: "jane", DisplayName: "Jane", Timezone: "Europe/Berlin"}, nil).Times(1)
+
+		resp := send("/auth/check")
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var body struct {
+			Profile user.Profile
+		}
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "Jane", body.Profile.DisplayName)
+		assert.Equal(t, "Europe/Berlin", body.Profile.Timezone)
+		assert.Equal(t, user.SortNewest, body.Profile.DefaultSort)
+	})
+
+	t.Run("CheckRevoked", func(t *testing.T) {
+		mockUserRepository.EXPECT().GetRevoked(gomock.Any(), gomock.Any()).Return(entity.RevokedToken{Username: "jane"}, nil).Times(1)
+
+		assert.Equal(t, 401, send("/auth/check").StatusCode)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a, mockUserRepository := newTestAuth(ctrl, fakeTokens{})
+	refreshTokens := a.refreshTokens.(*fakeRefreshTokens)
+
+	r := gin.Default()
+	NewApi(a, logger.NewLogger()).RegisterAuthHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	type refreshResponse struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	refresh := func(refreshToken string) (*http.Response, refreshResponse) {
+		body := fmt.Sprintf(`{"refresh_token": %q}`, refreshToken)
+		resp, err := http.Post(fmt.Sprintf("%s/auth/refresh", ts.URL), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var result refreshResponse
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	signin := func() (string, entity.RefreshFamily) {
+		secret, family, _ := refreshTokens.Issue(context.Background(), entity.RefreshFamily{Username: "jane", Method: "google", UserID: "user:1", Generation: 1})
+		return secret, family
+	}
+	expectGeneration := func(generation int) {
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "google").Return(entity.User{Username: "jane", TokenGeneration: generation}, nil).Times(1)
+	}
+
+	t.Run("Rotate", func(t *testing.T) {
+		secret, family := signin()
+		expectGeneration(1)
+
+		resp, body := refresh(secret)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.NotEmpty(t, body.RefreshToken)
+		assert.NotEqual(t, secret, body.RefreshToken)
+
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane", claim.Username)
+		assert.Equal(t, "user:1", claim.UserID)
+		assert.Equal(t, 1, claim.Generation)
+		assert.Equal(t, family.ID, claim.SessionID)
+		assert.NotEmpty(t, claim.ID)
+
+		// The next token works once as well
+		expectGeneration(1)
+		resp, _ = refresh(body.RefreshToken)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("Reused", func(t *testing.T) {
+		secret, family := signin()
+		expectGeneration(1)
+		_, body := refresh(secret)
+
+		resp, _ := refresh(secret)
+		assert.Equal(t, 401, resp.StatusCode)
+		// Whoever got the rotated token is signed out as well
+		assert.True(t, refreshTokens.families[family.ID].IsRevoked())
+		resp, _ = refresh(body.RefreshToken)
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+
+	t.Run("LoggedOutEverywhere", func(t *testing.T) {
+		secret, family := signin()
+		expectGeneration(2)
+
+		resp, _ := refresh(secret)
+		assert.Equal(t, 401, resp.StatusCode)
+		assert.True(t, refreshTokens.families[family.ID].IsRevoked())
+	})
+
+	t.Run("AdminRole", func(t *testing.T) {
+		os.Setenv("ADMIN_USER_IDS", "user:2, user:1")
+		defer os.Unsetenv("ADMIN_USER_IDS")
+
+		secret, _ := signin()
+		expectGeneration(1)
+
+		_, body := refresh(secret)
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{RoleAdmin}, claim.Roles)
+
+		// Removing the account takes the role away with the next JWT
+		os.Unsetenv("ADMIN_USER_IDS")
+		expectGeneration(1)
+		_, body = refresh(body.RefreshToken)
+		claim, _ = a.VerifyToken(body.Token)
+		assert.Empty(t, claim.Roles)
+	})
+
+	t.Run("DisabledAccount", func(t *testing.T) {
+		secret, family := signin()
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "google").Return(entity.User{Username: "jane", TokenGeneration: 1, Disabled: true}, nil).Times(1)
+
+		resp, _ := refresh(secret)
+		assert.Equal(t, 401, resp.StatusCode)
+		assert.True(t, refreshTokens.families[family.ID].IsRevoked())
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		resp, _ := refresh("rt_unknown")
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+
+	t.Run("WrongPayload", func(t *testing.T) {
+		resp, _ := refresh("")
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("Cookie", func(t *testing.T) {
+		secret, _ := signin()
+		expectGeneration(1)
+
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/auth/refresh", ts.URL), nil)
+		request.AddCookie(&http.Cookie{Name: refreshCookie, Value: secret})
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		// The next refresh token replaces the cookie and is not readable from the body
+		var result refreshResponse
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		assert.NotEmpty(t, result.Token)
+		assert.Empty(t, result.RefreshToken)
+		cookies := resp.Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.NotEqual(t, secret, cookies[0].Value)
+			assert.Equal(t, "/auth/refresh", cookies[0].Path)
+		}
+	})
+}
+
+type fakePasswords map[string]string
+
+func (f fakePasswords) Verify(_ context.Context, username, password string) error {
+	if username == "locked" {
+		return errors.ErrLocked
+	}
+	if stored, ok := f[username]; ok && stored == password {
+		return nil
+	}
+	return errors.ErrNotFound
+}
+
+func TestLocalSignin(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	os.Setenv("LOCAL_ACCOUNTS", "true")
+	defer os.Unsetenv("OAUTH_KEY")
+	defer os.Unsetenv("LOCAL_ACCOUNTS")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	passwords := fakePasswords{"jane": "correct horse battery"}
+	a := NewAuth(Providers{Google: NewGoogleOAuth()}, user.NewService(mockUserRepository, zapLogger), fakeTokens{}, passwords, nil, newFakeRefreshTokens(), zapLogger)
+
+	r := gin.Default()
+	NewApi(a, zapLogger).RegisterSigninHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	signin := func(username, password string) *http.Response {
+		body := fmt.Sprintf(`{"username": %q, "password": %q}`, username, password)
+		resp, err := http.Post(fmt.Sprintf("%s/signin/local", ts.URL), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "local").Return(entity.User{Username: "jane", Method: "local", UserID: "user:1", TokenGeneration: 2}, nil).Times(1)
+		mockUserRepository.EXPECT().Update(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, u entity.User) (entity.User, error) { return u, nil }).Times(1)
+
+		resp := signin("jane", "correct horse battery")
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var body struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane", claim.Username)
+		assert.Equal(t, "local", claim.Method)
+		assert.Equal(t, "user:1", claim.UserID)
+		assert.Equal(t, 2, claim.Generation)
+
+		// The refresh token issues JWTs of the same sign in
+		family := a.refreshTokens.(*fakeRefreshTokens).families[claim.SessionID]
+		assert.NotNil(t, family)
+		assert.Equal(t, "user:1", family.UserID)
+		assert.Equal(t, 2, family.Generation)
+		assert.NotEmpty(t, body.RefreshToken)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		assert.Equal(t, 401, signin("jane", "wrong password").StatusCode)
+	})
+
+	t.Run("LockedOut", func(t *testing.T) {
+		assert.Equal(t, 429, signin("locked", "correct horse battery").StatusCode)
+	})
+
+	t.Run("DisabledAccount", func(t *testing.T) {
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "local").
+			Return(entity.User{Username: "jane", Method: "local", UserID: "user:1", Disabled: true}, nil).Times(1)
+
+		assert.Equal(t, 401, signin("jane", "correct horse battery").StatusCode)
+	})
+}
+
+type fakeMagicLinks map[string]string
+
+func (f fakeMagicLinks) Verify(_ context.Context, secret string) (string, error) {
+	email, ok := f[secret]
+	if !ok {
+		return "", errors.ErrNotFound
+	}
+	delete(f, secret)
+	return email, nil
+}
+
+func TestMagicLinkSignin(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	os.Setenv("MAGIC_LINK_URL", "https://api.booklog.link/callback/magic-link")
+	defer os.Unsetenv("OAUTH_KEY")
+	defer os.Unsetenv("MAGIC_LINK_URL")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	magicLinks := fakeMagicLinks{"secret": "jane@example.com"}
+	a := NewAuth(Providers{Google: NewGoogleOAuth()}, user.NewService(mockUserRepository, zapLogger), fakeTokens{}, nil, magicLinks, newFakeRefreshTokens(), zapLogger)
+
+	r := gin.Default()
+	NewApi(a, zapLogger).RegisterSigninHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	open := func(token string) *http.Response {
+		resp, err := http.Get(fmt.Sprintf("%s/callback/magic-link?token=%s", ts.URL, token))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "magic-link").Return(entity.User{}, errors.ErrNotFound).Times(1)
+		mockUserRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, u entity.User) (entity.User, error) { return u, nil }).Times(1)
+
+		resp := open("secret")
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		claim, err := a.VerifyToken(body.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", claim.Username)
+		assert.Equal(t, "magic-link", claim.Method)
+	})
+
+	t.Run("SingleUse", func(t *testing.T) {
+		assert.Equal(t, 401, open("secret").StatusCode)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		os.Unsetenv("MAGIC_LINK_URL")
+		defer os.Setenv("MAGIC_LINK_URL", "https://api.booklog.link/callback/magic-link")
+
+		assert.Equal(t, 404, open("secret").StatusCode)
+	})
+}
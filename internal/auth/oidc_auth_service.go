@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	verifier "github.com/gbrlsnchs/jwt/v3"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	oidcTimeout   = 10 * time.Second
+	// Clocks of providers may be a little ahead
+	oidcLeeway = time.Minute
+)
+
+// OIDCConfig configures an OpenID Connect provider, its endpoints are discovered from the issuer
+type OIDCConfig struct {
+	Method AuthMethod
+	Issuer string
+	// Issuers ID tokens may name besides the discovered one
+	AlternativeIssuers []string
+	ClientID           string
+	ClientSecret       string
+	RedirectURL        string
+	Scopes             []string
+	// Claim of the ID token users are known by, email by default
+	UsernameClaim string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Holds the registered claims of an ID token as well as all of its claims, to map any of them to the username
+type idToken struct {
+	verifier.Payload
+	Claims map[string]interface{} `json:"-"`
+}
+
+func (t *idToken) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, &t.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.Claims)
+}
+
+// Signs in with the OIDC provider configured by OIDC_ISSUER, like an identity provider of the company
+func NewOIDCProvider() *OIDCProvider {
+	scopes := []string{"openid", "email", "profile"}
+	if value := os.Getenv("OIDC_SCOPES"); value != "" {
+		scopes = strings.Fields(value)
+	}
+
+	return newOIDCProvider(OIDCConfig{
+		Method:        OIDC,
+		Issuer:        os.Getenv("OIDC_ISSUER"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:        scopes,
+		UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+	}, &http.Client{Timeout: oidcTimeout})
+}
+
+func newOIDCProvider(config OIDCConfig, client *http.Client) *OIDCProvider {
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "email"
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	return &OIDCProvider{config: config, client: client}
+}
+
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	// Discovered on first use, so an unreachable provider does not keep the API from starting
+	mutex  sync.Mutex
+	conf   *oauth2.Config
+	issuer string
+	keys   *keySet
+}
+
+// The provider is optional, it is offered only when it is configured
+func (o *OIDCProvider) IsConfigured() bool {
+	return o.config.Issuer != "" && o.config.ClientID != ""
+}
+
+func (o *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.conf != nil {
+		return o.conf, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, o.config.Issuer+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to discover the provider: %s", resp.Status)
+	}
+
+	var discovery oidcDiscovery
+	err = json.NewDecoder(resp.Body).Decode(&discovery)
+	if err != nil {
+		return nil, err
+	}
+	// Guards against a discovery document of another provider
+	if strings.TrimSuffix(discovery.Issuer, "/") != o.config.Issuer {
+		return nil, fmt.Errorf("Discovered issuer %s is not %s", discovery.Issuer, o.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("Discovery of the provider misses endpoints")
+	}
+
+	o.issuer = discovery.Issuer
+	o.keys = newKeySet(discovery.JWKSURI, o.client)
+	o.conf = &oauth2.Config{
+		ClientID:     o.config.ClientID,
+		ClientSecret: o.config.ClientSecret,
+		RedirectURL:  o.config.RedirectURL,
+		Scopes:       o.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+
+	return o.conf, nil
+}
+
+func (o *OIDCProvider) SigninHandler(ctx *gin.Context) {
+	_, err := o.discover(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	state, err := startSignin(ctx, false)
+	if err != nil {
+		abortSignin(ctx, err)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, o.GetSigninURL(ctx.Request.Context(), state))
+}
+
+// Returns an empty URL when the provider can not be discovered. The code is bound to the sign in by PKCE, so a
+// stolen code can not be redeemed without the verifier in the session
+func (o *OIDCProvider) GetSigninURL(ctx context.Context, state SigninState) string {
+	conf, err := o.discover(ctx)
+	if err != nil {
+		return ""
+	}
+
+	return conf.AuthCodeURL(state.State,
+		oauth2.SetAuthURLParam("code_challenge", state.Challenge()),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+}
+
+func (o *OIDCProvider) VerifyToken(ctx context.Context, token *oauth2.Token) (*AuthUser, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("Token has no ID token")
+	}
+
+	return o.VerifyIDToken(ctx, rawIDToken)
+}
+
+// Verifies the signature and the claims of the ID token, and maps it to the user it signs in
+func (o *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken string) (*AuthUser, error) {
+	_, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var token idToken
+	_, err = verifier.Verify([]byte(rawIDToken), &keyResolver{ctx: ctx, keys: o.keys}, &token,
+		verifier.ValidateHeader,
+		verifier.ValidatePayload(&token.Payload,
+			o.issuerValidator(),
+			verifier.AudienceValidator(verifier.Audience{o.config.ClientID}),
+			verifier.ExpirationTimeValidator(now),
+			verifier.NotBeforeValidator(now.Add(oidcLeeway)),
+			verifier.IssuedAtValidator(now.Add(oidcLeeway)),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := o.username(token.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The profile claims are optional, they only fill in the profile of the account
+	displayName, _ := token.Claims["name"].(string)
+	picture, _ := token.Claims["picture"].(string)
+
+	return &AuthUser{Username: username, Method: o.config.Method, DisplayName: displayName, Picture: picture}, nil
+}
+
+func (o *OIDCProvider) issuerValidator() verifier.Validator {
+	return func(pl *verifier.Payload) error {
+		if pl.Issuer == o.issuer {
+			return nil
+		}
+		for _, issuer := range o.config.AlternativeIssuers {
+			if pl.Issuer == issuer {
+				return nil
+			}
+		}
+		return verifier.ErrIssValidation
+	}
+}
+
+// Emails are only trusted once the provider has verified them
+func (o *OIDCProvider) username(claims map[string]interface{}) (string, error) {
+	username, _ := claims[o.config.UsernameClaim].(string)
+	if username == "" {
+		return "", fmt.Errorf("ID token has no %s claim", o.config.UsernameClaim)
+	}
+
+	if o.config.UsernameClaim == "email" {
+		// Some providers send it as a string
+		switch verified := claims["email_verified"].(type) {
+		case bool:
+			if verified {
+				return username, nil
+			}
+		case string:
+			if verified == "true" {
+				return username, nil
+			}
+		}
+		return "", errors.New("Email has not been verified")
+	}
+
+	return username, nil
+}
+
+func (o *OIDCProvider) AuthCallbackMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		state, ok := checkState(ctx)
+		if !ok {
+			_ = ctx.AbortWithError(http.StatusUnauthorized, errors.New("Invalid session state"))
+			return
+		}
+
+		conf, err := o.discover(ctx.Request.Context())
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+
+		exchangeCtx := context.WithValue(ctx.Request.Context(), oauth2.HTTPClient, o.client)
+		tok, err := conf.Exchange(exchangeCtx, ctx.Query("code"), oauth2.SetAuthURLParam("code_verifier", state.Verifier))
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ctx.Set("token", tok)
+	}
+}
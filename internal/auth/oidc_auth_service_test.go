@@ -0,0 +1,367 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	verifier "github.com/gbrlsnchs/jwt/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+// fakeIdP is an OpenID Connect provider signing ID tokens with the keys it publishes
+type fakeIdP struct {
+	server *httptest.Server
+
+	mutex    sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	jwksHits int
+	// Claims of the ID token the token endpoint returns
+	claims map[string]interface{}
+	kid    string
+	// PKCE challenge the code was issued for, which the token endpoint checks the verifier against
+	challenge string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/certs",
+		})
+	})
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		idp.mutex.Lock()
+		defer idp.mutex.Unlock()
+		idp.jwksHits++
+
+		keys := []jsonWebKey{}
+		for kid, key := range idp.keys {
+			keys = append(keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if idp.challenge != (SigninState{Verifier: r.PostForm.Get("code_verifier")}).Challenge() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, idp.kid, idp.claims),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	idp.rotate(t, "key-1")
+
+	return idp
+}
+
+// Replaces the published keys with a new one, which signs the ID tokens from now on
+func (p *fakeIdP) rotate(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Expected key, got %v", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.keys = map[string]*rsa.PrivateKey{kid: key}
+	p.kid = kid
+}
+
+func (p *fakeIdP) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	p.mutex.Lock()
+	key, ok := p.keys[kid]
+	p.mutex.Unlock()
+	if !ok {
+		key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	token, err := verifier.Sign(claims, verifier.NewRS256(verifier.RSAPrivateKey(key)), verifier.KeyID(kid))
+	if err != nil {
+		t.Fatalf("Expected ID token, got %v", err)
+	}
+	return string(token)
+}
+
+func (p *fakeIdP) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                p.server.URL,
+		"aud":                "client",
+		"sub":                "1234",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"email":              "jane@example.com",
+		"email_verified":     true,
+		"preferred_username": "jane",
+	}
+}
+
+func newTestOIDCProvider(idp *fakeIdP, usernameClaim string) *OIDCProvider {
+	return newOIDCProvider(OIDCConfig{
+		Method:        OIDC,
+		Issuer:        idp.server.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: usernameClaim,
+	}, http.DefaultClient)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.server.Close()
+
+	ctx := context.Background()
+	provider := newTestOIDCProvider(idp, "")
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := idp.validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	t.Run("Email", func(t *testing.T) {
+		authUser, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, idp.validClaims()))
+		assert.Nil(t, err)
+		assert.Equal(t, &AuthUser{Username: "jane@example.com", Method: OIDC}, authUser)
+	})
+
+	t.Run("UsernameClaim", func(t *testing.T) {
+		provider := newTestOIDCProvider(idp, "preferred_username")
+		authUser, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, with("email_verified", false)))
+		assert.Nil(t, err)
+		assert.Equal(t, "jane", authUser.Username)
+
+		_, err = provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, with("preferred_username", nil)))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ProfileClaims", func(t *testing.T) {
+		claims := with("name", "Jane Doe")
+		claims["picture"] = "https://example.com/jane.png"
+		authUser, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, claims))
+		assert.Nil(t, err)
+		assert.Equal(t, "Jane Doe", authUser.DisplayName)
+		assert.Equal(t, "https://example.com/jane.png", authUser.Picture)
+	})
+
+	t.Run("EmailVerifiedString", func(t *testing.T) {
+		_, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, with("email_verified", "true")))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			claims map[string]interface{}
+		}{
+			{"UnverifiedEmail", with("email_verified", false)},
+			{"MissingEmailVerified", with("email_verified", nil)},
+			{"Expired", with("exp", time.Now().Add(-time.Minute).Unix())},
+			{"MissingExpiration", with("exp", nil)},
+			{"NotYetValid", with("nbf", time.Now().Add(time.Hour).Unix())},
+			{"OtherAudience", with("aud", "other-client")},
+			{"OtherIssuer", with("iss", "https://evil.example.com")},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, test.claims))
+				assert.NotNil(t, err)
+			})
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := provider.VerifyIDToken(ctx, idp.sign(t, "forged", idp.validClaims()))
+		assert.Equal(t, errUnknownKey, err)
+	})
+
+	t.Run("TamperedToken", func(t *testing.T) {
+		parts := strings.Split(idp.sign(t, idp.kid, idp.validClaims()), ".")
+		claims, _ := json.Marshal(with("email", "admin@example.com"))
+		parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+
+		_, err := provider.VerifyIDToken(ctx, strings.Join(parts, "."))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("HMACWithPublicKey", func(t *testing.T) {
+		idp.mutex.Lock()
+		key := idp.keys[idp.kid]
+		idp.mutex.Unlock()
+		token, _ := verifier.Sign(idp.validClaims(), verifier.NewHS256(key.N.Bytes()), verifier.KeyID(idp.kid))
+
+		_, err := provider.VerifyIDToken(ctx, string(token))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("KeyRotation", func(t *testing.T) {
+		_, err := provider.VerifyIDToken(ctx, idp.sign(t, idp.kid, idp.validClaims()))
+		assert.Nil(t, err)
+		idp.rotate(t, "key-2")
+
+		// Unknown keys are not fetched again right away
+		hits := idp.jwksHits
+		_, err = provider.VerifyIDToken(ctx, idp.sign(t, "key-2", idp.validClaims()))
+		assert.Equal(t, errUnknownKey, err)
+		assert.Equal(t, hits, idp.jwksHits)
+
+		provider.keys.fetchedAt = time.Now().Add(-keysRefreshInterval)
+		_, err = provider.VerifyIDToken(ctx, idp.sign(t, "key-2", idp.validClaims()))
+		assert.Nil(t, err)
+		assert.Equal(t, hits+1, idp.jwksHits)
+	})
+}
+
+func TestDiscovery(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.server.Close()
+
+	t.Run("SigninURL", func(t *testing.T) {
+		state := SigninState{State: "state", Verifier: "verifier"}
+		signinURL, err := url.Parse(newTestOIDCProvider(idp, "").GetSigninURL(context.Background(), state))
+		assert.Nil(t, err)
+		assert.Equal(t, idp.server.URL+"/authorize", strings.Split(signinURL.String(), "?")[0])
+		assert.Equal(t, "openid profile", signinURL.Query().Get("scope"))
+		assert.Equal(t, "state", signinURL.Query().Get("state"))
+		assert.Equal(t, state.Challenge(), signinURL.Query().Get("code_challenge"))
+		assert.Equal(t, "S256", signinURL.Query().Get("code_challenge_method"))
+	})
+
+	t.Run("OtherIssuer", func(t *testing.T) {
+		provider := newTestOIDCProvider(idp, "")
+		provider.config.Issuer = idp.server.URL + "/realms/other"
+
+		_, err := provider.discover(context.Background())
+		assert.NotNil(t, err)
+		assert.Equal(t, "", provider.GetSigninURL(context.Background(), SigninState{State: "state"}))
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		assert.False(t, NewOIDCProvider().IsConfigured())
+
+		os.Setenv("OIDC_ISSUER", idp.server.URL)
+		os.Setenv("OIDC_CLIENT_ID", "client")
+		defer os.Unsetenv("OIDC_ISSUER")
+		defer os.Unsetenv("OIDC_CLIENT_ID")
+
+		providers := NewProviders(NewGoogleOAuth(), NewGitHubOAuth(), NewOIDCProvider())
+		_, ok := providers.Get(OIDC)
+		assert.True(t, ok)
+	})
+}
+
+func TestOIDCSignin(t *testing.T) {
+	os.Setenv("OAUTH_KEY", "test-key")
+	defer os.Unsetenv("OAUTH_KEY")
+	os.Setenv("SESSION_KEY", "test-session-key-of-at-least-32-bytes")
+	os.Setenv("SESSION_INSECURE", "true")
+	defer os.Unsetenv("SESSION_KEY")
+	defer os.Unsetenv("SESSION_INSECURE")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	idp := newFakeIdP(t)
+	defer idp.server.Close()
+	idp.claims = idp.validClaims()
+	idp.claims["picture"] = "https://example.com/jane.png"
+
+	zapLogger := logger.NewLogger()
+	mockUserRepository := mocks.NewMockRepository(ctrl)
+	providers := Providers{Google: NewGoogleOAuth(), OIDC: newTestOIDCProvider(idp, "preferred_username")}
+	a := NewAuth(providers, user.NewService(mockUserRepository, zapLogger), fakeTokens{}, nil, nil, newFakeRefreshTokens(), zapLogger)
+
+	r := gin.Default()
+	r.Use(a.Session())
+	NewApi(a, zapLogger).RegisterSigninHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(ts.URL + "/signin/oidc")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	assert.True(t, strings.HasPrefix(location.String(), idp.server.URL+"/authorize"))
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	idp.challenge = location.Query().Get("code_challenge")
+
+	mockUserRepository.EXPECT().Get(gomock.Any(), "jane", "oidc").Return(entity.User{}, errors.ErrNotFound).Times(1)
+	mockUserRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, u entity.User) (entity.User, error) { return u, nil }).Times(1)
+	// The picture becomes the avatar of the new account
+	mockUserRepository.EXPECT().GetProfile(gomock.Any(), gomock.Any()).Return(entity.Profile{}, errors.ErrNotFound).Times(1)
+	mockUserRepository.EXPECT().PutProfile(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ interface{}, profile entity.Profile) error {
+			assert.Equal(t, "https://example.com/jane.png", profile.AvatarURL)
+			assert.NotEmpty(t, profile.UserID)
+			return nil
+		}).Times(1)
+
+	query := url.Values{"state": {location.Query().Get("state")}, "code": {"valid-code"}}
+	resp, err = client.Get(ts.URL + "/callback/oidc?" + query.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	claim, err := a.VerifyToken(body.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane", claim.Username)
+	assert.Equal(t, "oidc", claim.Method)
+}
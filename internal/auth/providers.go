@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	apiErrors "bookmark-api/internal/errors"
+)
+
+// Providers holds the sign in services by the method they sign users in with
+type Providers map[AuthMethod]AuthService
+
+func NewProviders(google *GoogleOAuth, github *GitHubOAuth, oidc *OIDCProvider) Providers {
+	providers := Providers{Google: google}
+	if github.IsConfigured() {
+		providers[GitHub] = github
+	}
+	if oidc.IsConfigured() {
+		providers[OIDC] = oidc
+	}
+
+	return providers
+}
+
+func (p Providers) Get(method AuthMethod) (AuthService, bool) {
+	provider, ok := p[method]
+	return provider, ok
+}
+
+// Local users are only offered when LOCAL_ACCOUNTS is set, self-hosted installs without an identity provider need them
+func LocalAccountsEnabled() bool {
+	return os.Getenv("LOCAL_ACCOUNTS") == "true"
+}
+
+// Magic links are only offered when MAGIC_LINK_URL is set, they also need a mailer
+func MagicLinksEnabled() bool {
+	return os.Getenv("MAGIC_LINK_URL") != ""
+}
+
+// Keys of the sign in in the session and of what its callback finds out on the request
+const (
+	verifierKey = "code_verifier"
+	// Set while the user links another identity to the account
+	linkKey = "link"
+	// Page of the client the callback sends the user back to
+	redirectKey = "redirect_to"
+)
+
+var errInvalidRedirect = errors.New("Redirect is not allowed")
+
+// Secrets of a sign in started in the session, the callback of the provider has to send the state back
+type SigninState struct {
+	State string
+	// PKCE code verifier, the sign in URL only carries its S256 challenge
+	Verifier string
+}
+
+// Returns the S256 PKCE challenge of the code verifier
+func (s SigninState) Challenge() string {
+	sum := sha256.Sum256([]byte(s.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Starts a sign in in the session, linking the identity the provider signs in to the account when linking is set.
+// The redirect_to query parameter must be one of SIGNIN_REDIRECT_ALLOWLIST, errInvalidRedirect otherwise
+func startSignin(ctx *gin.Context, linking bool) (SigninState, error) {
+	redirectTo := ctx.Query(redirectKey)
+	if redirectTo != "" && !isAllowedRedirect(redirectTo) {
+		return SigninState{}, errInvalidRedirect
+	}
+
+	state, err := randToken()
+	if err != nil {
+		return SigninState{}, err
+	}
+	verifier, err := randToken()
+	if err != nil {
+		return SigninState{}, err
+	}
+
+	session := sessions.Default(ctx)
+	session.Set(stateKey, state)
+	session.Set(verifierKey, verifier)
+	session.Set(linkKey, linking)
+	session.Set(redirectKey, redirectTo)
+	err = session.Save()
+	if err != nil {
+		return SigninState{}, err
+	}
+
+	return SigninState{State: state, Verifier: verifier}, nil
+}
+
+// Answers sign ins which could not be started
+func abortSignin(ctx *gin.Context, err error) {
+	if err == errInvalidRedirect {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apiErrors.BadRequest("Redirect is not allowed"))
+		return
+	}
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, apiErrors.InternalServerError("Failed to start sign in"))
+}
+
+// Checks that the callback sent back the state of the session and returns the sign in, states are single use.
+// Whether it links the identity and where the user goes next are set on the request
+func checkState(ctx *gin.Context) (SigninState, bool) {
+	session := sessions.Default(ctx)
+	retrievedState, _ := session.Get(stateKey).(string)
+	verifier, _ := session.Get(verifierKey).(string)
+	linking, _ := session.Get(linkKey).(bool)
+	redirectTo, _ := session.Get(redirectKey).(string)
+	session.Clear()
+	_ = session.Save()
+
+	state := ctx.Query(stateKey)
+	if retrievedState == "" || subtle.ConstantTimeCompare([]byte(retrievedState), []byte(state)) != 1 {
+		return SigninState{}, false
+	}
+
+	ctx.Set(linkKey, linking)
+	if redirectTo != "" {
+		ctx.Set(redirectKey, redirectTo)
+	}
+	return SigninState{State: state, Verifier: verifier}, true
+}
+
+// Reports whether the callback of the provider links the identity. Only the account which started linking is
+// trusted, by the state, the session just tells the callback to look it up
+func isLinking(ctx *gin.Context) bool {
+	return ctx.GetBool(linkKey)
+}
+
+// Only clients of SIGNIN_REDIRECT_ALLOWLIST get the JWT, a comma separated list of URLs the redirect has to start
+// with, like https://booklog.link/app
+func isAllowedRedirect(redirectTo string) bool {
+	target, err := url.Parse(redirectTo)
+	if err != nil || target.User != nil || target.Fragment != "" {
+		return false
+	}
+
+	for _, value := range strings.Split(os.Getenv("SIGNIN_REDIRECT_ALLOWLIST"), ",") {
+		allowed, err := url.Parse(strings.TrimSpace(value))
+		if err != nil || allowed.Host == "" {
+			continue
+		}
+		if target.Scheme == allowed.Scheme && target.Host == allowed.Host && isPathUnder(target.Path, allowed.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths are compared by their segments, so /app does not allow /apple
+func isPathUnder(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
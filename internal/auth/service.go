@@ -1,29 +1,194 @@
 package auth
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
+
+	"bookmark-api/internal/entity"
 )
 
 type AuthMethod string
 
+// Role of the users who may use the admin paths of the API
+const RoleAdmin = "admin"
+
+// Root of the API behind API Gateway, the standalone server serves the API at /
+const APIRoot = "/api/v1"
+
+// Paths under the API root tokens with the read or write scope may use: the bookmarks with their sync and events,
+// of the user and of its workspaces. * matches any characters like in the resources of IAM policies
+var tokenPaths = []string{
+	"/bookmarks*", "/sync*", "/events*",
+	"/workspaces/*/bookmarks*", "/workspaces/*/sync*", "/workspaces/*/events*",
+}
+
 const (
 	Google AuthMethod = "google"
+	GitHub AuthMethod = "github"
+	// Any OpenID Connect provider, like an identity provider of the company
+	OIDC AuthMethod = "oidc"
+	// Username and password of an account of this API, for installs without any identity provider
+	Local AuthMethod = "local"
+	// Single use links mailed to the user
+	MagicLink AuthMethod = "magic-link"
 )
 
 type AuthUser struct {
 	Username string
 	Method   AuthMethod
+	// ID of the account the identity is linked to, see ID
+	UserID string
+	// Scopes of the API token the user signed the request with, nil for JWTs which may do anything
+	Scopes []string
+	Roles  []string
+	// ID and token generation of the JWT, to revoke it
+	TokenID    string
+	Generation int
+	// Family of the refresh tokens of the sign in, revoked with the JWT on logout
+	SessionID string
+	// Name and picture the provider knows the user by, only set while signing in
+	DisplayName string
+	Picture     string
+}
+
+// Returns the ID of the account, which owns the bookmarks. Accounts created before there were user IDs are
+// identified by their username, as well as the JWTs issued before
+func (u *AuthUser) ID() string {
+	if u.UserID == "" {
+		return u.Username
+	}
+	return u.UserID
+}
+
+func (u *AuthUser) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the username is an email the sign in method has verified. Local users register any name they like,
+// and OIDC providers only verify the username when it is the email claim
+func (u *AuthUser) HasVerifiedEmail() bool {
+	switch u.Method {
+	case Google, GitHub, MagicLink:
+		return true
+	case OIDC:
+		claim := os.Getenv("OIDC_USERNAME_CLAIM")
+		return claim == "" || claim == "email"
+	default:
+		return false
+	}
+}
+
+// Reports whether the request may do what the scope grants
+func (u *AuthUser) HasScope(scope string) bool {
+	return u.Scopes == nil || entity.HasScope(u.Scopes, scope)
+}
+
+// Returns the scope an API token needs to send a request with the HTTP method
+func RequiredScope(httpMethod string) string {
+	switch httpMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return entity.ScopeRead
+	default:
+		return entity.ScopeWrite
+	}
+}
+
+// Returns the paths under the API root the user may send requests with the HTTP method to.
+// JWTs and tokens with the admin scope may use the whole API, read and write tokens only the bookmarks.
+// The authorizer builds its policy from them and the middleware checks requests against them, so both agree
+func AllowedPaths(authUser *AuthUser, httpMethod string) []string {
+	switch {
+	case authUser.HasScope(entity.ScopeAdmin):
+		return []string{"*"}
+	case authUser.HasScope(RequiredScope(httpMethod)):
+		return tokenPaths
+	default:
+		return nil
+	}
+}
+
+// Reports whether the user may send a request with the HTTP method to the path under the API root
+func IsAllowedPath(authUser *AuthUser, httpMethod, path string) bool {
+	for _, pattern := range AllowedPaths(authUser, httpMethod) {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches the path against the pattern, * matches any characters including /
+func matchPath(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(path, part)
+		}
+		index := strings.Index(path, part)
+		if index < 0 {
+			return false
+		}
+		path = path[index+len(part):]
+	}
+	return path == ""
 }
 
 type Claim struct {
-	Username string `json:"username"`
-	Method   string `json:"method"`
+	Username string   `json:"username"`
+	Method   string   `json:"method"`
+	UserID   string   `json:"user_id,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	// Missing from JWTs issued before they could be revoked one by one
+	ID         string `json:"jti,omitempty"`
+	Generation int    `json:"generation"`
+	// Missing from JWTs issued before there were refresh tokens
+	SessionID string `json:"sid,omitempty"`
 }
 
 type AuthService interface {
 	SigninHandler(ctx *gin.Context)
-	GetSigninURL(state string) string
-	VerifyToken(token *oauth2.Token) (*AuthUser, error)
+	GetSigninURL(ctx context.Context, state SigninState) string
+	VerifyToken(ctx context.Context, token *oauth2.Token) (*AuthUser, error)
 	AuthCallbackMiddleware() gin.HandlerFunc
 }
+
+// Checks passwords of local users, see password.Service
+type PasswordVerifier interface {
+	// Returns errors.ErrNotFound for wrong usernames or passwords and errors.ErrLocked when the user is locked out
+	Verify(ctx context.Context, username, password string) error
+}
+
+// Spends magic links, see magiclink.Service
+type MagicLinkVerifier interface {
+	// Returns the email the link was sent to, errors.ErrNotFound when it is unknown or used and errors.ErrExpired
+	// when it has expired
+	Verify(ctx context.Context, secret string) (string, error)
+}
+
+// Issues and rotates the refresh tokens of sign ins, see refresh.Service
+type RefreshTokenRotator interface {
+	Issue(ctx context.Context, family entity.RefreshFamily) (string, entity.RefreshFamily, error)
+	// Returns errors.ErrNotFound, errors.ErrExpired or errors.ErrAborted for tokens which may not be refreshed
+	Rotate(ctx context.Context, secret string) (string, entity.RefreshFamily, error)
+	Revoke(ctx context.Context, familyId string) error
+}
+
+// Looks up personal API tokens, see token.Service
+type APITokenVerifier interface {
+	Verify(ctx context.Context, secret string) (entity.APIToken, error)
+}
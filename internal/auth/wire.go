@@ -4,4 +4,4 @@ import (
 	"github.com/google/wire"
 )
 
-var Inject = wire.NewSet(NewApi, NewAuth, NewGoogleOAuth)
+var Inject = wire.NewSet(NewApi, NewAuth, NewGoogleOAuth, NewGitHubOAuth, NewOIDCProvider, NewProviders)
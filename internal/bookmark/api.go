@@ -1,37 +1,67 @@
 package bookmark
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
 
+	"bookmark-api/internal/entity"
 	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
 	"bookmark-api/internal/session"
 )
 
-func NewApi(service Service, logger *zap.Logger) Api {
-	return &resource{service, logger}
+func NewApi(service Service, bus event.Bus, logger *zap.Logger) Api {
+	return &resource{
+		service:   service,
+		bus:       bus,
+		heartbeat: heartbeatInterval,
+		// Lambda sends the response once the handler returns, and other instances publish on their own bus
+		poll:   os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "",
+		logger: logger,
+	}
 }
 
 type Api interface {
 	RegisterHandlers(rg *gin.RouterGroup)
 }
 
+// Routes work on the workspace of the request, members need at least the viewer role to read and the editor role to write
 func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
-	// Search bookmarks
-	rg.GET("/bookmarks", r.searchByName)
+	read := session.RequireRole(entity.RoleViewer)
+	write := session.RequireRole(entity.RoleEditor)
+
+	// Search bookmarks, list the most recent ones without query
+	rg.GET("/bookmarks", read, r.searchByName)
 
 	// Crud operations
-	rg.POST("/bookmarks", r.create)
-	rg.GET("/bookmarks/:id", r.get)
-	rg.PUT("/bookmarks/:id", r.update)
-	rg.DELETE("/bookmarks/:id", r.delete)
+	rg.POST("/bookmarks", write, r.create)
+	rg.GET("/bookmarks/:id", read, r.get)
+	rg.PUT("/bookmarks/:id", write, r.update)
+	rg.PATCH("/bookmarks/:id", write, r.patch)
+	rg.DELETE("/bookmarks/:id", write, r.delete)
+
+	// Bulk operations, gin does not allow /bookmarks/batch next to /bookmarks/:id
+	rg.POST("/bookmarks/:id", write, r.bookmarkAction)
 
 	// Add remove tags
-	rg.POST("/bookmarks/:id/tags/:tag", r.addTag)
-	rg.DELETE("/bookmarks/:id/tags/:tag", r.removeTag)
+	rg.POST("/bookmarks/:id/tags/:tag", write, r.addTag)
+	rg.DELETE("/bookmarks/:id/tags/:tag", write, r.removeTag)
+
+	// Changes since the last sync
+	rg.GET("/sync", read, r.changes)
+	rg.POST("/sync/push", write, r.push)
+
+	// Live changes as server-sent events
+	rg.GET("/events", read, r.events)
 }
 
 type CreateBookmarkRequest struct {
@@ -45,16 +75,125 @@ type UpdateBookmarkRequest struct {
 	Url  string `json:"url"`
 }
 
+type PatchBookmarkRequest struct {
+	Name *string `json:"name"`
+	Url  *string `json:"url"`
+}
+
 type BookmarkResponse struct {
-	ID   string   `json:"id"`
-	Name string   `json:"name"`
-	Url  string   `json:"url"`
-	Tags []string `json:"tags"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Url     string   `json:"url"`
+	Tags    []string `json:"tags"`
+	Version int      `json:"version"`
+}
+
+func newBookmarkResponse(bookmark Bookmark) BookmarkResponse {
+	return BookmarkResponse{
+		ID:      bookmark.ID,
+		Name:    bookmark.Name,
+		Url:     bookmark.Url,
+		Tags:    bookmark.Tags,
+		Version: bookmark.Version,
+	}
+}
+
+const (
+	// Upper limit of operations in a batch request
+	maxBatchOperations = 100
+	defaultListLimit   = 50
+	maxListLimit       = 100
+	defaultSyncLimit   = 100
+	maxSyncLimit       = 1000
+	maxPushMutations   = 100
+	// Keeps proxies from closing idle event streams
+	heartbeatInterval = 15 * time.Second
+	// Delay before browsers poll the change log for events again
+	pollInterval = 5 * time.Second
+)
+
+type BatchRequest struct {
+	// All operations are applied or none
+	Atomic     bool                    `json:"atomic"`
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+type BatchOperationRequest struct {
+	Op      string   `json:"op"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Url     string   `json:"url"`
+	Tags    []string `json:"tags"`
+	Tag     string   `json:"tag"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Version int      `json:"version"`
+}
+
+type BatchResultResponse struct {
+	Index    int               `json:"index"`
+	Status   int               `json:"status"`
+	Bookmark *BookmarkResponse `json:"bookmark,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResultResponse `json:"results"`
+}
+
+// Returns the operation or a message describing what is wrong with it
+func (o *BatchOperationRequest) getOperation(username string) (BatchOperation, string) {
+	operation := BatchOperation{
+		Type: entity.OperationType(o.Op),
+		Bookmark: Bookmark{
+			Username: username,
+			ID:       o.ID,
+			Name:     o.Name,
+			Url:      o.Url,
+			Tags:     o.Tags,
+			Version:  o.Version,
+		},
+		Tag: o.Tag,
+	}
+
+	if operation.Type != entity.OperationCreate && o.ID == "" {
+		return operation, "id is missing"
+	}
+
+	switch operation.Type {
+	case entity.OperationCreate:
+		if o.Name == "" || o.Url == "" {
+			return operation, "name and url are required"
+		}
+	case entity.OperationUpdate:
+		if o.Name == "" && o.Url == "" {
+			return operation, "name or url is required"
+		}
+	case entity.OperationDelete:
+	case entity.OperationAddTag, entity.OperationRemoveTag:
+		if o.Tag == "" {
+			return operation, "tag is required"
+		}
+	case entity.OperationMove:
+		if o.From == "" || o.To == "" {
+			return operation, "from and to are required"
+		}
+		operation.Tag = o.From
+		operation.TargetTag = o.To
+	default:
+		return operation, fmt.Sprintf("unknown op %s", o.Op)
+	}
+
+	return operation, ""
 }
 
 type resource struct {
-	service Service
-	logger  *zap.Logger
+	service   Service
+	bus       event.Bus
+	heartbeat time.Duration
+	// Events are read from the change log instead of streamed from the bus
+	poll   bool
+	logger *zap.Logger
 }
 
 func (r *resource) create(c *gin.Context) {
@@ -63,7 +202,7 @@ func (r *resource) create(c *gin.Context) {
 		_ = logger.Sync()
 	}()
 
-	authUser := session.GetCurrentUser(c)
+	workspace := session.GetWorkspace(c)
 
 	request := CreateBookmarkRequest{}
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -74,23 +213,25 @@ func (r *resource) create(c *gin.Context) {
 
 	result, err := r.service.Create(c.Request.Context(), Bookmark{
 		Name:     request.Name,
-		Username: authUser.Username,
+		Username: workspace.Owner,
 		Url:      request.Url,
 		Tags:     request.Tags,
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create bookmark"))
+		switch err {
+		case errors.ErrTooManyItems:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Bookmark has too many tags"))
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create bookmark"))
+		}
 		return
 	}
 
-	response := &BookmarkResponse{
-		ID:   result.ID,
-		Name: result.Name,
-		Url:  result.Url,
-		Tags: result.Tags,
-	}
-	c.JSON(http.StatusCreated, response)
+	c.Header("ETag", formatETag(result.Version))
+	c.JSON(http.StatusCreated, newBookmarkResponse(result))
 }
 
 func (r *resource) get(c *gin.Context) {
@@ -100,8 +241,8 @@ func (r *resource) get(c *gin.Context) {
 		return
 	}
 
-	authUser := session.GetCurrentUser(c)
-	result, err := r.service.Get(c.Request.Context(), authUser.Username, id)
+	workspace := session.GetWorkspace(c)
+	result, err := r.service.Get(c.Request.Context(), workspace.Owner, id)
 	if err != nil {
 		switch err {
 		case errors.ErrNotFound:
@@ -112,13 +253,13 @@ func (r *resource) get(c *gin.Context) {
 		return
 	}
 
-	response := &BookmarkResponse{
-		ID:   result.ID,
-		Name: result.Name,
-		Url:  result.Url,
-		Tags: result.Tags,
+	c.Header("ETag", formatETag(result.Version))
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && matchesETag(ifNoneMatch, result.Version) {
+		c.Status(http.StatusNotModified)
+		return
 	}
-	c.JSON(http.StatusOK, response)
+
+	c.JSON(http.StatusOK, newBookmarkResponse(result))
 }
 
 func (r *resource) update(c *gin.Context) {
@@ -140,26 +281,80 @@ func (r *resource) update(c *gin.Context) {
 		return
 	}
 
-	authUser := session.GetCurrentUser(c)
-	result, err := r.service.Update(c.Request.Context(), Bookmark{
-		Username: authUser.Username,
+	workspace := session.GetWorkspace(c)
+	r.updateBookmark(c, Bookmark{
+		Username: workspace.Owner,
 		ID:       id,
 		Name:     request.Name,
 		Url:      request.Url,
 	})
+}
+
+func (r *resource) patch(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	id := c.Param("id")
+	if len(id) < 0 {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Parameter(id) is missing"))
+		return
+	}
+
+	request := PatchBookmarkRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	if (request.Name != nil && *request.Name == "") || (request.Url != nil && *request.Url == "") {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Fields can not be empty"))
+		return
+	}
+
+	workspace := session.GetWorkspace(c)
+	bookmark := Bookmark{
+		Username: workspace.Owner,
+		ID:       id,
+	}
+	if request.Name != nil {
+		bookmark.Name = *request.Name
+	}
+	if request.Url != nil {
+		bookmark.Url = *request.Url
+	}
 
+	r.updateBookmark(c, bookmark)
+}
+
+// Updates the bookmark guarded by If-Match header and writes the response
+func (r *resource) updateBookmark(c *gin.Context, bookmark Bookmark) {
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to update bookmark"))
+		c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("If-Match is not a valid ETag"))
 		return
 	}
+	bookmark.Version = version
 
-	response := &BookmarkResponse{
-		ID:   result.ID,
-		Name: result.Name,
-		Url:  result.Url,
-		Tags: result.Tags,
+	result, err := r.service.Update(c.Request.Context(), bookmark)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Bookmark has been modified"))
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to update bookmark"))
+		}
+		return
 	}
-	c.JSON(http.StatusOK, response)
+
+	c.Header("ETag", formatETag(result.Version))
+	c.JSON(http.StatusOK, newBookmarkResponse(result))
 }
 
 func (r *resource) delete(c *gin.Context) {
@@ -169,10 +364,25 @@ func (r *resource) delete(c *gin.Context) {
 		return
 	}
 
-	authUser := session.GetCurrentUser(c)
-	err := r.service.Delete(c.Request.Context(), authUser.Username, id)
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete bookmark"))
+		c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("If-Match is not a valid ETag"))
+		return
+	}
+
+	workspace := session.GetWorkspace(c)
+	err = r.service.Delete(c.Request.Context(), workspace.Owner, id, version)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Bookmark has been modified"))
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete bookmark"))
+		}
 		return
 	}
 
@@ -181,30 +391,59 @@ func (r *resource) delete(c *gin.Context) {
 
 func (r *resource) searchByName(c *gin.Context) {
 	query := c.Query("query")
-	if len(query) < 0 {
-		c.JSON(http.StatusBadRequest, errors.BadRequest("Query is missing"))
+	if len(query) == 0 {
+		r.list(c)
 		return
 	}
 
-	authUser := session.GetCurrentUser(c)
-	result, err := r.service.SearchByName(c.Request.Context(), authUser.Username, query)
+	workspace := session.GetWorkspace(c)
+	result, err := r.service.SearchByName(c.Request.Context(), workspace.Owner, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete bookmark"))
-		return
+		partialErr, ok := errors.IsPartial(err)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to search bookmarks"))
+			return
+		}
+
+		// Let the client know the result is incomplete instead of silently dropping bookmarks
+		c.Header("X-Partial-Result", "true")
+		c.Header("X-Failed-Bookmarks", strings.Join(partialErr.Failed, ","))
 	}
 
-	response := funk.Map(result, func(bookmark Bookmark) BookmarkResponse {
-		return BookmarkResponse{
-			ID:   bookmark.ID,
-			Name: bookmark.Name,
-			Url:  bookmark.Url,
-			Tags: bookmark.Tags,
-		}
-	})
+	response := funk.Map(result, newBookmarkResponse)
 
 	c.JSON(http.StatusOK, response)
 }
 
+func (r *resource) list(c *gin.Context) {
+	limit := defaultListLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Limit must be between 1 and %d", maxListLimit)))
+			return
+		}
+	}
+
+	workspace := session.GetWorkspace(c)
+	result, nextCursor, err := r.service.List(c.Request.Context(), workspace.Owner, limit, c.Query("cursor"))
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Cursor is invalid"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list bookmarks"))
+		}
+		return
+	}
+
+	if nextCursor != "" {
+		c.Header("X-Next-Cursor", nextCursor)
+	}
+	c.JSON(http.StatusOK, funk.Map(result, newBookmarkResponse))
+}
+
 func (r *resource) addTag(c *gin.Context) {
 	logger := r.logger.Sugar()
 	defer func() {
@@ -214,11 +453,20 @@ func (r *resource) addTag(c *gin.Context) {
 	bookmarkId := c.Param("id")
 	tag := c.Param("tag")
 
-	authUser := session.GetCurrentUser(c)
-	err := r.service.AddTag(c.Request.Context(), authUser.Username, bookmarkId, tag)
+	workspace := session.GetWorkspace(c)
+	err := r.service.AddTag(c.Request.Context(), workspace.Owner, bookmarkId, tag)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to add tag"))
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Not found"))
+		case errors.ErrTooManyItems:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Bookmark has too many tags"))
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to add tag"))
+		}
 		return
 	}
 
@@ -234,13 +482,459 @@ func (r *resource) removeTag(c *gin.Context) {
 	bookmarkId := c.Param("id")
 	tag := c.Param("tag")
 
-	authUser := session.GetCurrentUser(c)
-	err := r.service.RemoveTag(c.Request.Context(), authUser.Username, bookmarkId, tag)
+	workspace := session.GetWorkspace(c)
+	err := r.service.RemoveTag(c.Request.Context(), workspace.Owner, bookmarkId, tag)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to remove tag"))
+		switch err {
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to remove tag"))
+		}
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
+
+func (r *resource) bookmarkAction(c *gin.Context) {
+	switch c.Param("id") {
+	case "batch":
+		r.batch(c)
+	default:
+		c.JSON(http.StatusNotFound, errors.NotFound(""))
+	}
+}
+
+func (r *resource) batch(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := BatchRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	if len(request.Operations) == 0 || len(request.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Batch must have between 1 and %d operations", maxBatchOperations)))
+		return
+	}
+
+	workspace := session.GetWorkspace(c)
+	operations := make([]BatchOperation, len(request.Operations))
+	for i, operationRequest := range request.Operations {
+		operation, invalid := operationRequest.getOperation(workspace.Owner)
+		if invalid != "" {
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Operation %d: %s", i, invalid)))
+			return
+		}
+		operations[i] = operation
+	}
+
+	results, err := r.service.Batch(c.Request.Context(), workspace.Owner, operations, request.Atomic)
+	if err != nil {
+		switch err {
+		case errors.ErrTooManyItems:
+			message := fmt.Sprintf("Atomic batches are limited to %d written items, split the batch or run it without atomic", maxTransactionItems)
+			c.JSON(http.StatusBadRequest, errors.BadRequest(message))
+		case errors.ErrConflict:
+			c.JSON(http.StatusConflict, errors.Conflict("Bookmarks are being modified by another request, try again"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to run batch"))
+		}
+		return
+	}
+
+	status := http.StatusOK
+	response := BatchResponse{Results: make([]BatchResultResponse, len(results))}
+	for i, result := range results {
+		response.Results[i] = BatchResultResponse{Index: i, Status: batchResultStatus(operations[i], result.Err)}
+		if result.Err != nil {
+			response.Results[i].Error = result.Err.Error()
+			status = http.StatusMultiStatus
+		} else {
+			bookmark := newBookmarkResponse(result.Bookmark)
+			response.Results[i].Bookmark = &bookmark
+		}
+	}
+
+	c.JSON(status, response)
+}
+
+type PushRequest struct {
+	Mutations []MutationRequest `json:"mutations"`
+}
+
+// Fields left out are not changed, tags are sent as the ones added and removed since the base version
+type MutationRequest struct {
+	// Echoed back so the client can match bookmarks it created offline.
+	// A create pushed again with the same client ID returns the bookmark created the first time
+	ClientID    string    `json:"client_id"`
+	Op          string    `json:"op"`
+	ID          string    `json:"id"`
+	BaseVersion int       `json:"base_version"`
+	Name        *string   `json:"name"`
+	Url         *string   `json:"url"`
+	Tags        []string  `json:"tags"`
+	AddTags     []string  `json:"add_tags"`
+	RemoveTags  []string  `json:"remove_tags"`
+	ModifiedAt  time.Time `json:"modified_at"`
+}
+
+type ConflictResponse struct {
+	Field       string `json:"field"`
+	ClientValue string `json:"client_value"`
+	ServerValue string `json:"server_value"`
+	Resolution  string `json:"resolution"`
+}
+
+type MutationResultResponse struct {
+	Index    int    `json:"index"`
+	ClientID string `json:"client_id,omitempty"`
+	// applied, merged, conflict or failed
+	Status    string             `json:"status"`
+	Bookmark  *BookmarkResponse  `json:"bookmark,omitempty"`
+	Deleted   bool               `json:"deleted"`
+	Conflicts []ConflictResponse `json:"conflicts,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+type PushResponse struct {
+	Results []MutationResultResponse `json:"results"`
+}
+
+// Returns the mutation or a message describing what is wrong with it
+func (m *MutationRequest) getMutation() (Mutation, string) {
+	mutation := Mutation{
+		Type:        entity.OperationType(m.Op),
+		ClientID:    m.ClientID,
+		ID:          m.ID,
+		BaseVersion: m.BaseVersion,
+		Name:        m.Name,
+		Url:         m.Url,
+		Tags:        m.Tags,
+		AddTags:     m.AddTags,
+		RemoveTags:  m.RemoveTags,
+		ModifiedAt:  m.ModifiedAt,
+	}
+
+	switch mutation.Type {
+	case entity.OperationCreate:
+		if m.Name == nil || *m.Name == "" || m.Url == nil || *m.Url == "" {
+			return mutation, "name and url are required"
+		}
+	case entity.OperationUpdate, entity.OperationDelete:
+		if m.ID == "" {
+			return mutation, "id is missing"
+		}
+		if (m.Name != nil && *m.Name == "") || (m.Url != nil && *m.Url == "") {
+			return mutation, "name and url can not be empty"
+		}
+	default:
+		return mutation, fmt.Sprintf("unknown op %s", m.Op)
+	}
+
+	return mutation, ""
+}
+
+func (r *resource) push(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := PushRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	if len(request.Mutations) == 0 || len(request.Mutations) > maxPushMutations {
+		c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Push must have between 1 and %d mutations", maxPushMutations)))
+		return
+	}
+
+	mutations := make([]Mutation, len(request.Mutations))
+	for i, mutationRequest := range request.Mutations {
+		mutation, invalid := mutationRequest.getMutation()
+		if invalid != "" {
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Mutation %d: %s", i, invalid)))
+			return
+		}
+		mutations[i] = mutation
+	}
+
+	workspace := session.GetWorkspace(c)
+	results, err := r.service.Push(c.Request.Context(), workspace.Owner, mutations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to push mutations"))
+		return
+	}
+
+	status := http.StatusOK
+	response := PushResponse{Results: make([]MutationResultResponse, len(results))}
+	for i, result := range results {
+		resultResponse := MutationResultResponse{
+			Index:    i,
+			ClientID: request.Mutations[i].ClientID,
+			Status:   mutationResultStatus(result),
+			Deleted:  result.Deleted,
+		}
+		if result.Err != nil {
+			resultResponse.Error = result.Err.Error()
+			status = http.StatusMultiStatus
+		} else if !result.Deleted {
+			bookmark := newBookmarkResponse(result.Bookmark)
+			resultResponse.Bookmark = &bookmark
+		}
+		for _, conflict := range result.Conflicts {
+			resultResponse.Conflicts = append(resultResponse.Conflicts, ConflictResponse{
+				Field:       conflict.Field,
+				ClientValue: conflict.ClientValue,
+				ServerValue: conflict.ServerValue,
+				Resolution:  conflict.Resolution,
+			})
+		}
+		response.Results[i] = resultResponse
+	}
+
+	c.JSON(status, response)
+}
+
+func mutationResultStatus(result MutationResult) string {
+	switch {
+	case result.Err != nil:
+		return "failed"
+	case len(result.Conflicts) > 0:
+		return "conflict"
+	case result.Merged:
+		return "merged"
+	default:
+		return "applied"
+	}
+}
+
+type EventResponse struct {
+	ID       string            `json:"id"`
+	Bookmark *BookmarkResponse `json:"bookmark,omitempty"`
+	Tag      string            `json:"tag,omitempty"`
+}
+
+func newEventResponse(data interface{}) EventResponse {
+	bookmarkEvent, _ := data.(BookmarkEvent)
+	response := EventResponse{ID: bookmarkEvent.ID, Tag: bookmarkEvent.Tag}
+	if bookmarkEvent.Bookmark != nil {
+		bookmark := newBookmarkResponse(*bookmarkEvent.Bookmark)
+		response.Bookmark = &bookmark
+	}
+	return response
+}
+
+func (r *resource) events(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+
+	// Browsers send the header on reconnect, the query parameter is for the first connection
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("last_event_id")
+	}
+	if r.poll {
+		r.pollEvents(c, lastEventId)
+		return
+	}
+
+	subscription := r.bus.Subscribe(workspace.Owner, lastEventId)
+	defer subscription.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	write := func(e event.Event) {
+		c.Render(-1, sse.Event{Id: e.ID, Event: e.Type, Data: newEventResponse(e.Data)})
+	}
+
+	// Events have been missed, the client has to load the bookmarks again
+	if subscription.Reset {
+		c.Render(-1, sse.Event{Event: "reset", Data: ""})
+	}
+	for _, e := range subscription.Replay {
+		write(e)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(r.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-subscription.Events:
+			if !ok {
+				// Fell behind, the client reconnects with the last event ID
+				return
+			}
+			write(e)
+		case <-heartbeat.C:
+			_, _ = c.Writer.WriteString(": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Writes the changes after the last event ID, which is the sequence number of the last change, as events and ends
+// the response. Browsers reconnect after the retry delay, so they poll the change log shared by every instance.
+// The first connection only gets the current sequence number, an unknown one a reset event.
+func (r *resource) pollEvents(c *gin.Context, lastEventId string) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	workspace := session.GetWorkspace(c)
+	ctx := c.Request.Context()
+
+	since, err := strconv.ParseInt(lastEventId, 10, 64)
+	known := err == nil && since >= 0
+	var changeSet ChangeSet
+	if known {
+		changeSet, err = r.service.Changes(ctx, workspace.Owner, since, maxSyncLimit)
+	}
+	// IDs of the stream of the long running server are unknown here
+	reset := lastEventId != "" && (!known || err == errors.ErrExpired || err == errors.ErrInvalidParam)
+	if !known || err == errors.ErrInvalidParam {
+		// Only the latest sequence number is of interest
+		changeSet, err = r.service.Changes(ctx, workspace.Owner, 0, 1)
+		changeSet = ChangeSet{Sequence: changeSet.Latest}
+	}
+	if err != nil && err != errors.ErrExpired {
+		logger.Errorw("Failed to poll events", zap.String("LastEventID", lastEventId), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get events"))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	if reset {
+		c.Render(-1, sse.Event{Event: "reset", Data: ""})
+	}
+	for _, change := range changeSet.Changes {
+		eventType, data := newOperationEvent(change.Type, change.Bookmark, change.Tag)
+		c.Render(-1, sse.Event{Id: strconv.FormatInt(change.Sequence, 10), Event: eventType, Data: newEventResponse(data)})
+	}
+	// Without data the ID is taken over but no event is dispatched
+	_, _ = c.Writer.WriteString(fmt.Sprintf("id:%d\nretry:%d\n\n", changeSet.Sequence, pollInterval.Milliseconds()))
+}
+
+// Sync token is the sequence number of the last change the client has seen
+type ChangeResponse struct {
+	Sequence  int64                `json:"seq"`
+	Type      entity.OperationType `json:"type"`
+	ID        string               `json:"id"`
+	Deleted   bool                 `json:"deleted"`
+	Bookmark  BookmarkResponse     `json:"bookmark"`
+	Tag       string               `json:"tag,omitempty"`
+	TargetTag string               `json:"target_tag,omitempty"`
+	ChangedAt time.Time            `json:"changed_at"`
+}
+
+type ChangesResponse struct {
+	Changes []ChangeResponse `json:"changes"`
+	Token   string           `json:"token"`
+	HasMore bool             `json:"has_more"`
+}
+
+// Details of 410 Gone, the token to continue with after listing all bookmarks again
+type ExpiredTokenResponse struct {
+	Token string `json:"token"`
+}
+
+func (r *resource) changes(c *gin.Context) {
+	var since int64
+	if token := c.Query("since"); token != "" {
+		var err error
+		since, err = strconv.ParseInt(token, 10, 64)
+		if err != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Sync token is invalid"))
+			return
+		}
+	}
+
+	limit := defaultSyncLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxSyncLimit {
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Limit must be between 1 and %d", maxSyncLimit)))
+			return
+		}
+	}
+
+	workspace := session.GetWorkspace(c)
+	changeSet, err := r.service.Changes(c.Request.Context(), workspace.Owner, since, limit)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Sync token is invalid"))
+		case errors.ErrExpired:
+			response := errors.Gone("Sync token has expired, a full resync is required")
+			response.Details = ExpiredTokenResponse{Token: strconv.FormatInt(changeSet.Sequence, 10)}
+			c.JSON(http.StatusGone, response)
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get changes"))
+		}
+		return
+	}
+
+	response := ChangesResponse{
+		Changes: []ChangeResponse{},
+		Token:   strconv.FormatInt(changeSet.Sequence, 10),
+		HasMore: changeSet.HasMore,
+	}
+	for _, change := range changeSet.Changes {
+		response.Changes = append(response.Changes, ChangeResponse{
+			Sequence:  change.Sequence,
+			Type:      change.Type,
+			ID:        change.Bookmark.ID,
+			Deleted:   change.Deleted,
+			Bookmark:  newBookmarkResponse(change.Bookmark),
+			Tag:       change.Tag,
+			TargetTag: change.TargetTag,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func batchResultStatus(operation BatchOperation, err error) int {
+	switch err {
+	case nil:
+		if operation.Type == entity.OperationCreate {
+			return http.StatusCreated
+		}
+		return http.StatusOK
+	case errors.ErrNotFound:
+		return http.StatusNotFound
+	case errors.ErrAlreadyExist, errors.ErrConflict:
+		return http.StatusConflict
+	case errors.ErrInvalidParam, errors.ErrTooManyItems:
+		return http.StatusBadRequest
+	case errors.ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case errors.ErrAborted:
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"bookmark-api/internal/auth"
 	"bookmark-api/internal/bookmark/mocks"
 	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
 	"bookmark-api/pkg/logger"
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -37,9 +43,10 @@ func TestCreateRoute(t *testing.T) {
 	zapLogger := logger.NewLogger()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	bookmarkService := NewService(mockRepository, zapLogger)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
 
-	api := NewApi(bookmarkService, zapLogger)
+	api := NewApi(bookmarkService, bus, zapLogger)
 
 	r := gin.Default()
 
@@ -112,9 +119,10 @@ func TestTagRoute(t *testing.T) {
 	zapLogger := logger.NewLogger()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	bookmarkService := NewService(mockRepository, zapLogger)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
 
-	api := NewApi(bookmarkService, zapLogger)
+	api := NewApi(bookmarkService, bus, zapLogger)
 
 	r := gin.Default()
 
@@ -161,3 +169,613 @@ func TestTagRoute(t *testing.T) {
 		assert.Equal(t, 200, resp.StatusCode)
 	})
 }
+
+func TestVersionRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := &http.Client{}
+
+	t.Run("GetReturnsETag", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		bookmark.Version = 3
+		mockRepository.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(bookmark, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, bookmark.GetBookmarkId()))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("GetNotModified", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		bookmark.Version = 3
+		mockRepository.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(bookmark, nil).Times(1)
+
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, bookmark.GetBookmarkId()), nil)
+		req.Header.Set("If-None-Match", `"3"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 304, resp.StatusCode)
+	})
+
+	t.Run("UpdateWithMatchingVersion", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		bookmark.Version = 4
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, b entity.Bookmark) (entity.Bookmark, error) {
+			assert.Equal(t, 3, b.Version)
+			return bookmark, nil
+		}).Times(1)
+
+		requestBody, _ := json.Marshal(UpdateBookmarkRequest{Url: bookmark.Url})
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, bookmark.GetBookmarkId()), bytes.NewBuffer(requestBody))
+		req.Header.Set("If-Match", `"3"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("PatchWithStaleVersion", func(t *testing.T) {
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).Return(entity.Bookmark{}, errors.ErrPreconditionFailed).Times(1)
+
+		requestBody, _ := json.Marshal(map[string]string{"name": "renamed"})
+		req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, "2"), bytes.NewBuffer(requestBody))
+		req.Header.Set("If-Match", `"1"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 412, resp.StatusCode)
+	})
+
+	t.Run("UpdateUnversioned", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		bookmark.Version = 1
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, b entity.Bookmark) (entity.Bookmark, error) {
+			assert.Equal(t, entity.NoVersion, b.Version)
+			return bookmark, nil
+		}).Times(1)
+
+		requestBody, _ := json.Marshal(UpdateBookmarkRequest{Url: bookmark.Url})
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, bookmark.GetBookmarkId()), bytes.NewBuffer(requestBody))
+		req.Header.Set("If-Match", `"0"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("DeleteWithStaleVersion", func(t *testing.T) {
+		mockRepository.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Eq("2"), gomock.Eq(1)).Return(errors.ErrPreconditionFailed).Times(1)
+
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, "2"), nil)
+		req.Header.Set("If-Match", `"1"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 412, resp.StatusCode)
+	})
+
+	t.Run("DeleteWhileWritten", func(t *testing.T) {
+		mockRepository.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Eq("2"), gomock.Eq(0)).Return(errors.ErrConflict).Times(1)
+
+		// Without If-Match a commit running out of retries is no failed precondition
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, "2"), nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 409, resp.StatusCode)
+	})
+
+	t.Run("DeleteWithInvalidETag", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, "2"), nil)
+		req.Header.Set("If-Match", `"-1"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 412, resp.StatusCode)
+	})
+
+	t.Run("PatchWithInvalidETag", func(t *testing.T) {
+		requestBody, _ := json.Marshal(map[string]string{"name": "renamed"})
+		req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/api/bookmarks/%s", ts.URL, "2"), bytes.NewBuffer(requestBody))
+		req.Header.Set("If-Match", `"abc"`)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 412, resp.StatusCode)
+	})
+}
+
+func TestBatchRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("BatchWithPartialFailure", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		mockRepository.EXPECT().Batch(gomock.Any(), gomock.Eq("USERNAME_1"), gomock.Any(), gomock.Eq(false)).
+			DoAndReturn(func(_ interface{}, _ string, operations []entity.BookmarkOperation, _ bool) ([]entity.BookmarkOperationResult, error) {
+				assert.Len(t, operations, 2)
+				assert.Equal(t, entity.OperationMove, operations[1].Type)
+				assert.Equal(t, "old", operations[1].Tag)
+				assert.Equal(t, "new", operations[1].TargetTag)
+				return []entity.BookmarkOperationResult{
+					{Bookmark: bookmark},
+					{Err: errors.ErrNotFound},
+				}, nil
+			}).Times(1)
+
+		requestBody, _ := json.Marshal(BatchRequest{
+			Operations: []BatchOperationRequest{
+				{Op: "create", Name: bookmark.Name, Url: bookmark.Url},
+				{Op: "move", ID: "3", From: "old", To: "new"},
+			},
+		})
+		resp, err := http.Post(fmt.Sprintf("%s/api/bookmarks/batch", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 207, resp.StatusCode)
+
+		var result BatchResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected batch response, got %v", err)
+		}
+
+		assert.Len(t, result.Results, 2)
+		assert.Equal(t, 201, result.Results[0].Status)
+		assert.Equal(t, bookmark.Name, result.Results[0].Bookmark.Name)
+		assert.Equal(t, 404, result.Results[1].Status)
+		assert.NotEmpty(t, result.Results[1].Error)
+	})
+
+	t.Run("BatchWithInvalidOperation", func(t *testing.T) {
+		requestBody, _ := json.Marshal(BatchRequest{
+			Operations: []BatchOperationRequest{{Op: "rename", ID: "3"}},
+		})
+		resp, err := http.Post(fmt.Sprintf("%s/api/bookmarks/batch", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("AtomicBatchTooLarge", func(t *testing.T) {
+		mockRepository.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Eq(true)).Return(nil, errors.ErrTooManyItems).Times(1)
+
+		requestBody, _ := json.Marshal(BatchRequest{
+			Atomic:     true,
+			Operations: []BatchOperationRequest{{Op: "delete", ID: "3"}},
+		})
+		resp, err := http.Post(fmt.Sprintf("%s/api/bookmarks/batch", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+
+		var result errors.ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		assert.Nil(t, err)
+		assert.Contains(t, result.Message, "limited to 25 written items")
+	})
+}
+
+func TestSearchRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("SearchWithPartialResult", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		mockRepository.EXPECT().SearchByName(gomock.Any(), gomock.Any(), gomock.Eq("go")).
+			Return([]entity.Bookmark{bookmark}, &errors.PartialError{Failed: []string{"3", "4"}}).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/bookmarks?query=go", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get("X-Partial-Result"))
+		assert.Equal(t, "3,4", resp.Header.Get("X-Failed-Bookmarks"))
+
+		var result []BookmarkResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected bookmark response, got %v", err)
+		}
+		assert.Len(t, result, 1)
+	})
+}
+
+func TestSyncRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("ChangesSinceToken", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		changes := []entity.BookmarkChange{
+			entity.NewBookmarkChange("1", 5, entity.BookmarkOperation{Type: entity.OperationUpdate}, &bookmark, &bookmark),
+			entity.NewBookmarkChange("1", 6, entity.BookmarkOperation{Type: entity.OperationDelete}, &bookmark, nil),
+		}
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(4)), gomock.Eq(2)).Return(changes, int64(8), nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/sync?since=4&limit=2", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result ChangesResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected changes response, got %v", err)
+		}
+		assert.Equal(t, "6", result.Token)
+		assert.True(t, result.HasMore)
+		assert.Len(t, result.Changes, 2)
+		assert.Equal(t, "2", result.Changes[0].ID)
+		assert.False(t, result.Changes[0].Deleted)
+		assert.True(t, result.Changes[1].Deleted)
+	})
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(1)), gomock.Any()).Return(nil, int64(8), errors.ErrExpired).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/sync?since=1", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 410, resp.StatusCode)
+
+		// The client resyncs and continues from the current token
+		var result struct {
+			Details ExpiredTokenResponse `json:"details"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected error response, got %v", err)
+		}
+		assert.Equal(t, "8", result.Details.Token)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/sync?since=abc", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
+
+func TestPushRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("PushReportsConflicts", func(t *testing.T) {
+		bookmark := getFakeBookmark()
+		mockRepository.EXPECT().Push(gomock.Any(), gomock.Any(), gomock.Len(2)).Return([]entity.BookmarkMutationResult{
+			{Bookmark: bookmark},
+			{Bookmark: bookmark, Merged: true, Conflicts: []entity.FieldConflict{{Field: entity.FieldUrl, ClientValue: "a", ServerValue: "b", Resolution: entity.ResolutionServer}}},
+		}, nil).Times(1)
+
+		requestBody := `{"mutations": [
+			{"client_id": "local-1", "op": "create", "name": "Go", "url": "https://golang.org"},
+			{"op": "update", "id": "2", "base_version": 1, "url": "a", "add_tags": ["go"]}
+		]}`
+		resp, err := http.Post(fmt.Sprintf("%s/api/sync/push", ts.URL), "application/json", strings.NewReader(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result PushResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected push response, got %v", err)
+		}
+		assert.Len(t, result.Results, 2)
+		assert.Equal(t, "local-1", result.Results[0].ClientID)
+		assert.Equal(t, "applied", result.Results[0].Status)
+		assert.Equal(t, "conflict", result.Results[1].Status)
+		assert.Equal(t, "url", result.Results[1].Conflicts[0].Field)
+		assert.Equal(t, "server", result.Results[1].Conflicts[0].Resolution)
+	})
+
+	t.Run("PushInvalidMutation", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("%s/api/sync/push", ts.URL), "application/json", strings.NewReader(`{"mutations": [{"op": "update", "name": "Go"}]}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
+
+func TestEventsRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+	api.(*resource).heartbeat = 50 * time.Millisecond
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// Reads the stream until the line with the prefix
+	readLine := func(t *testing.T, reader *bufio.Reader, prefix string) string {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Expected %s, got %v", prefix, err)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+
+	var lastEventId string
+	t.Run("StreamsChanges", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/events", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		mockRepository.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Eq("2"), 0).Return(nil).Times(1)
+		mockRepository.EXPECT().AddTag(gomock.Any(), gomock.Any(), gomock.Eq("3"), gomock.Eq("go")).Return(nil).Times(1)
+		assert.Nil(t, bookmarkService.Delete(context.Background(), "USERNAME_1", "2", 0))
+		assert.Nil(t, bookmarkService.AddTag(context.Background(), "USERNAME_1", "3", "go"))
+
+		reader := bufio.NewReader(resp.Body)
+		lastEventId = readLine(t, reader, "id:")
+		assert.Equal(t, "bookmark.deleted", readLine(t, reader, "event:"))
+		assert.JSONEq(t, `{"id":"2"}`, readLine(t, reader, "data:"))
+		assert.Equal(t, "tag.added", readLine(t, reader, "event:"))
+		assert.JSONEq(t, `{"id":"3","tag":"go"}`, readLine(t, reader, "data:"))
+	})
+
+	t.Run("ResumesFromLastEventID", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/events", ts.URL), nil)
+		request.Header.Set("Last-Event-ID", lastEventId)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, "tag.added", readLine(t, reader, "event:"))
+	})
+
+	t.Run("ResetsUnknownEventID", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/events", ts.URL), nil)
+		request.Header.Set("Last-Event-ID", "1-1")
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, "reset", readLine(t, reader, "event:"))
+	})
+
+	t.Run("SendsHeartbeats", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/events", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, "heartbeat", readLine(t, reader, ":"))
+	})
+}
+
+func TestPollEventsRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkService := NewService(mockRepository, bus, zapLogger)
+
+	api := NewApi(bookmarkService, bus, zapLogger)
+	api.(*resource).poll = true
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "USERNAME_1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// The response ends after the events
+	poll := func(t *testing.T, lastEventId string) string {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/events", ts.URL), nil)
+		if lastEventId != "" {
+			request.Header.Set("Last-Event-ID", lastEventId)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	bookmark := getFakeBookmark()
+	t.Run("StartsAtLatestChange", func(t *testing.T) {
+		changes := []entity.BookmarkChange{
+			entity.NewBookmarkChange("1", 1, entity.BookmarkOperation{Type: entity.OperationCreate}, nil, &bookmark),
+		}
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(0)), gomock.Eq(1)).Return(changes, int64(7), nil).Times(1)
+
+		body := poll(t, "")
+		assert.NotContains(t, body, "event:")
+		assert.Contains(t, body, "id:7\nretry:5000\n\n")
+	})
+
+	t.Run("ChangesSinceLastEventID", func(t *testing.T) {
+		changes := []entity.BookmarkChange{
+			entity.NewBookmarkChange("1", 6, entity.BookmarkOperation{Type: entity.OperationAddTag, Tag: "go"}, &bookmark, &bookmark),
+			entity.NewBookmarkChange("1", 7, entity.BookmarkOperation{Type: entity.OperationDelete}, &bookmark, nil),
+		}
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(5)), gomock.Eq(maxSyncLimit)).Return(changes, int64(7), nil).Times(1)
+
+		body := poll(t, "5")
+		assert.Contains(t, body, "id:6\nevent:tag.added\ndata:{\"id\":\"2\",\"tag\":\"go\"}\n\n")
+		assert.Contains(t, body, "id:7\nevent:bookmark.deleted\ndata:{\"id\":\"2\"}\n\n")
+		assert.Contains(t, body, "id:7\nretry")
+	})
+
+	t.Run("ResetsExpiredEventID", func(t *testing.T) {
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(1)), gomock.Any()).Return(nil, int64(9), errors.ErrExpired).Times(1)
+
+		body := poll(t, "1")
+		assert.Contains(t, body, "event:reset\n")
+		assert.Contains(t, body, "id:9\nretry")
+	})
+
+	t.Run("ResetsEventIDOfStream", func(t *testing.T) {
+		mockRepository.EXPECT().Changes(gomock.Any(), gomock.Any(), gomock.Eq(int64(0)), gomock.Eq(1)).Return([]entity.BookmarkChange{}, int64(9), nil).Times(1)
+
+		body := poll(t, "1-1")
+		assert.Contains(t, body, "event:reset\n")
+		assert.Contains(t, body, "id:9\nretry")
+	})
+}
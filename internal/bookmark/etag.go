@@ -0,0 +1,46 @@
+package bookmark
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bookmark-api/internal/entity"
+)
+
+// ETag of a bookmark is its version
+func formatETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// Returns the version given in If-Match header. Missing header or "*" returns 0 which means any version,
+// "0" is the ETag of bookmarks created before versioning and returns entity.NoVersion
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, "\""))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("Invalid ETag: %s", header)
+	}
+	if version == 0 {
+		return entity.NoVersion, nil
+	}
+
+	return version, nil
+}
+
+// Checks If-None-Match header against the version, weak tags are compared like strong ones
+func matchesETag(header string, version int) bool {
+	etag := formatETag(version)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
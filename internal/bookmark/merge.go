@@ -0,0 +1,162 @@
+package bookmark
+
+import (
+	"time"
+
+	"github.com/thoas/go-funk"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+)
+
+const (
+	conflictValueDeleted = "deleted"
+	conflictValueChanged = "changed"
+	conflictValueAdded   = "added"
+	conflictValueRemoved = "removed"
+)
+
+// Merges a mutation made offline against its base version into the current state of the bookmark, nil when it does not exist.
+// Fields changed on the server since the base version are genuine conflicts, the last writer wins per field.
+// Tags are merged as sets, additions always apply and removals only lose to a later addition on the server.
+// Returns the change to write, nil when there is nothing to write.
+func mergeMutation(username string, current *entity.Bookmark, mutation entity.BookmarkMutation) (*bookmarkChange, entity.BookmarkMutationResult) {
+	result := entity.BookmarkMutationResult{}
+	operation := entity.BookmarkOperation{Type: mutation.Type}
+
+	if mutation.Type == entity.OperationCreate {
+		operation.Bookmark = entity.Bookmark{Tags: mutation.Tags}
+		if mutation.Name != nil {
+			operation.Bookmark.Name = *mutation.Name
+		}
+		if mutation.Url != nil {
+			operation.Bookmark.Url = *mutation.Url
+		}
+		after, _ := applyOperation(username, nil, operation)
+		return &bookmarkChange{operation: operation, after: after, clientId: mutation.ClientID}, result
+	}
+
+	if mutation.Type != entity.OperationUpdate && mutation.Type != entity.OperationDelete {
+		result.Err = errors.ErrInvalidParam
+		return nil, result
+	}
+
+	if current == nil {
+		result.Deleted = true
+		if mutation.Type == entity.OperationUpdate {
+			result.Conflicts = append(result.Conflicts, entity.FieldConflict{
+				Field:       entity.FieldBookmark,
+				ClientValue: conflictValueChanged,
+				ServerValue: conflictValueDeleted,
+				Resolution:  entity.ResolutionServer,
+			})
+		}
+		return nil, result
+	}
+
+	if mutation.BaseVersion > current.Version {
+		result.Err = errors.ErrInvalidParam
+		return nil, result
+	}
+	result.Merged = current.Version > mutation.BaseVersion
+	result.Bookmark = *current
+	operation.Bookmark = *current
+
+	if mutation.Type == entity.OperationDelete {
+		if result.Merged {
+			conflict := entity.FieldConflict{
+				Field:       entity.FieldBookmark,
+				ClientValue: conflictValueDeleted,
+				ServerValue: conflictValueChanged,
+				Resolution:  entity.ResolutionServer,
+			}
+			if mutation.ModifiedAt.After(current.UpdatedAt) {
+				conflict.Resolution = entity.ResolutionClient
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+			if conflict.Resolution == entity.ResolutionServer {
+				return nil, result
+			}
+		}
+
+		result.Deleted = true
+		return &bookmarkChange{operation: operation, before: current}, result
+	}
+
+	after := *current
+	after.Tags = append([]string{}, current.Tags...)
+	after.Fields = make(map[string]entity.FieldClock, len(current.Fields))
+	for field, clock := range current.Fields {
+		after.Fields[field] = clock
+	}
+	changed := false
+
+	// Clock of a field the client wins keeps the time of the edit
+	accept := func(field string) {
+		clock, _ := current.GetFieldClock(field)
+		clock.UpdatedAt = mutation.ModifiedAt
+		after.Fields[field] = clock
+		changed = true
+	}
+
+	mergeValue := func(field string, clientValue *string, serverValue *string) {
+		if clientValue == nil || *clientValue == *serverValue {
+			return
+		}
+
+		clock, _ := current.GetFieldClock(field)
+		if clock.Version > mutation.BaseVersion {
+			conflict := entity.FieldConflict{
+				Field:       field,
+				ClientValue: *clientValue,
+				ServerValue: *serverValue,
+				Resolution:  entity.ResolutionServer,
+			}
+			if mutation.ModifiedAt.After(clock.UpdatedAt) {
+				conflict.Resolution = entity.ResolutionClient
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+			if conflict.Resolution == entity.ResolutionServer {
+				return
+			}
+		}
+
+		*serverValue = *clientValue
+		accept(field)
+	}
+	mergeValue(entity.FieldName, mutation.Name, &after.Name)
+	mergeValue(entity.FieldUrl, mutation.Url, &after.Url)
+
+	for _, tag := range funk.UniqString(mutation.AddTags) {
+		if !funk.ContainsString(after.Tags, tag) {
+			after.Tags = append(after.Tags, tag)
+			accept(entity.TagField(tag))
+		}
+	}
+	for _, tag := range funk.UniqString(mutation.RemoveTags) {
+		if !funk.ContainsString(after.Tags, tag) || funk.ContainsString(mutation.AddTags, tag) {
+			continue
+		}
+
+		clock, _ := current.GetFieldClock(entity.TagField(tag))
+		if clock.Version > mutation.BaseVersion && clock.UpdatedAt.After(mutation.ModifiedAt) {
+			result.Conflicts = append(result.Conflicts, entity.FieldConflict{
+				Field:       entity.TagField(tag),
+				ClientValue: conflictValueRemoved,
+				ServerValue: conflictValueAdded,
+				Resolution:  entity.ResolutionServer,
+			})
+			continue
+		}
+
+		after.Tags = funk.FilterString(after.Tags, func(s string) bool { return s != tag })
+		accept(entity.TagField(tag))
+	}
+
+	if !changed {
+		return nil, result
+	}
+	after.UpdatedAt = time.Now()
+
+	return &bookmarkChange{operation: operation, before: current, after: &after}, result
+}
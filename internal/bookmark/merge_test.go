@@ -0,0 +1,161 @@
+package bookmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+)
+
+func TestMergeMutation(t *testing.T) {
+	base := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	// Version 2 set the name and the tags, version 4 changed the url and added the tag web on the server
+	current := entity.Bookmark{
+		Username:  "USERNAME_1",
+		ID:        "BOOKMARK_2",
+		Name:      "Go",
+		Url:       "https://go.dev",
+		Tags:      []string{"go", "web"},
+		UpdatedAt: base.Add(2 * time.Hour),
+		Version:   4,
+		Fields: map[string]entity.FieldClock{
+			entity.FieldName:          {Version: 2, UpdatedAt: base},
+			entity.FieldUrl:           {Version: 4, UpdatedAt: base.Add(2 * time.Hour)},
+			entity.TagField("go"):     {Version: 2, UpdatedAt: base},
+			entity.TagField("web"):    {Version: 4, UpdatedAt: base.Add(2 * time.Hour)},
+			entity.TagField("golang"): {Version: 3, UpdatedAt: base.Add(time.Hour)},
+		},
+	}
+	name := func(s string) *string { return &s }
+	early, late := base.Add(30*time.Minute), base.Add(3*time.Hour)
+
+	tests := []struct {
+		name      string
+		current   *entity.Bookmark
+		mutation  entity.BookmarkMutation
+		write     bool
+		deleted   bool
+		merged    bool
+		expected  *entity.Bookmark
+		conflicts []entity.FieldConflict
+		err       error
+	}{
+		{
+			name:     "UpdateWithoutServerChanges",
+			current:  &current,
+			mutation: entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 4, Name: name("Golang"), ModifiedAt: early},
+			write:    true,
+			expected: &entity.Bookmark{Name: "Golang", Url: "https://go.dev", Tags: []string{"go", "web"}},
+		},
+		{
+			name:     "UpdateUntouchedField",
+			current:  &current,
+			mutation: entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, Name: name("Golang"), ModifiedAt: early},
+			write:    true,
+			merged:   true,
+			expected: &entity.Bookmark{Name: "Golang", Url: "https://go.dev", Tags: []string{"go", "web"}},
+		},
+		{
+			name:      "ConflictServerWins",
+			current:   &current,
+			mutation:  entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, Url: name("https://golang.org"), ModifiedAt: early},
+			merged:    true,
+			conflicts: []entity.FieldConflict{{Field: entity.FieldUrl, ClientValue: "https://golang.org", ServerValue: "https://go.dev", Resolution: entity.ResolutionServer}},
+		},
+		{
+			name:      "ConflictClientWins",
+			current:   &current,
+			mutation:  entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, Url: name("https://golang.org"), ModifiedAt: late},
+			write:     true,
+			merged:    true,
+			expected:  &entity.Bookmark{Name: "Go", Url: "https://golang.org", Tags: []string{"go", "web"}},
+			conflicts: []entity.FieldConflict{{Field: entity.FieldUrl, ClientValue: "https://golang.org", ServerValue: "https://go.dev", Resolution: entity.ResolutionClient}},
+		},
+		{
+			name:     "TagsUnionAndRemoval",
+			current:  &current,
+			mutation: entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, AddTags: []string{"lang"}, RemoveTags: []string{"go"}, ModifiedAt: early},
+			write:    true,
+			merged:   true,
+			expected: &entity.Bookmark{Name: "Go", Url: "https://go.dev", Tags: []string{"web", "lang"}},
+		},
+		{
+			name:      "RemovalOfTagAddedLaterOnServer",
+			current:   &current,
+			mutation:  entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, RemoveTags: []string{"web"}, ModifiedAt: early},
+			merged:    true,
+			conflicts: []entity.FieldConflict{{Field: entity.TagField("web"), ClientValue: "removed", ServerValue: "added", Resolution: entity.ResolutionServer}},
+		},
+		{
+			name:      "UpdateDeletedOnServer",
+			mutation:  entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 2, Name: name("Golang"), ModifiedAt: late},
+			deleted:   true,
+			conflicts: []entity.FieldConflict{{Field: entity.FieldBookmark, ClientValue: "changed", ServerValue: "deleted", Resolution: entity.ResolutionServer}},
+		},
+		{
+			name:      "DeleteChangedOnServer",
+			current:   &current,
+			mutation:  entity.BookmarkMutation{Type: entity.OperationDelete, BaseVersion: 2, ModifiedAt: early},
+			merged:    true,
+			conflicts: []entity.FieldConflict{{Field: entity.FieldBookmark, ClientValue: "deleted", ServerValue: "changed", Resolution: entity.ResolutionServer}},
+		},
+		{
+			name:     "Delete",
+			current:  &current,
+			mutation: entity.BookmarkMutation{Type: entity.OperationDelete, BaseVersion: 4, ModifiedAt: early},
+			write:    true,
+			deleted:  true,
+		},
+		{
+			name:     "BaseVersionFromFuture",
+			current:  &current,
+			mutation: entity.BookmarkMutation{Type: entity.OperationUpdate, BaseVersion: 5, Name: name("Golang")},
+			err:      errors.ErrInvalidParam,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			change, result := mergeMutation("1", test.current, test.mutation)
+			assert.Equal(t, test.err, result.Err)
+			assert.Equal(t, test.write, change != nil)
+			assert.Equal(t, test.deleted, result.Deleted)
+			assert.Equal(t, test.merged, result.Merged)
+			assert.Equal(t, test.conflicts, result.Conflicts)
+
+			if test.expected != nil {
+				assert.Equal(t, test.expected.Name, change.after.Name)
+				assert.Equal(t, test.expected.Url, change.after.Url)
+				assert.Equal(t, test.expected.Tags, change.after.Tags)
+			}
+		})
+	}
+
+	// The current bookmark is not modified
+	assert.Equal(t, []string{"go", "web"}, current.Tags)
+	assert.Equal(t, base, current.Fields[entity.FieldName].UpdatedAt)
+}
+
+func TestStampFields(t *testing.T) {
+	updatedAt := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	modifiedAt := updatedAt.Add(-time.Hour)
+	before := entity.Bookmark{Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, Version: 1}
+
+	after := before
+	after.Name = "Golang"
+	after.Tags = []string{"lang"}
+	after.UpdatedAt = updatedAt
+	after.Version = 2
+	after.Fields = map[string]entity.FieldClock{entity.TagField("lang"): {Version: 1, UpdatedAt: modifiedAt}}
+	stampFields(&before, &after)
+
+	assert.Equal(t, entity.FieldClock{Version: 2, UpdatedAt: updatedAt}, after.Fields[entity.FieldName])
+	assert.Equal(t, entity.FieldClock{Version: 2, UpdatedAt: updatedAt}, after.Fields[entity.TagField("go")])
+	// Clocks set by the caller keep their time
+	assert.Equal(t, entity.FieldClock{Version: 2, UpdatedAt: modifiedAt}, after.Fields[entity.TagField("lang")])
+	_, ok := after.Fields[entity.FieldUrl]
+	assert.False(t, ok)
+}
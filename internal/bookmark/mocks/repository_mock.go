@@ -48,6 +48,37 @@ func (mr *MockRepositoryMockRecorder) AddTag(arg0, arg1, arg2, arg3 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTag", reflect.TypeOf((*MockRepository)(nil).AddTag), arg0, arg1, arg2, arg3)
 }
 
+// Batch mocks base method
+func (m *MockRepository) Batch(arg0 context.Context, arg1 string, arg2 []entity.BookmarkOperation, arg3 bool) ([]entity.BookmarkOperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]entity.BookmarkOperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Batch indicates an expected call of Batch
+func (mr *MockRepositoryMockRecorder) Batch(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockRepository)(nil).Batch), arg0, arg1, arg2, arg3)
+}
+
+// Changes mocks base method
+func (m *MockRepository) Changes(arg0 context.Context, arg1 string, arg2 int64, arg3 int) ([]entity.BookmarkChange, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]entity.BookmarkChange)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Changes indicates an expected call of Changes
+func (mr *MockRepositoryMockRecorder) Changes(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockRepository)(nil).Changes), arg0, arg1, arg2, arg3)
+}
+
 // Create mocks base method
 func (m *MockRepository) Create(arg0 context.Context, arg1 entity.Bookmark) (entity.Bookmark, error) {
 	m.ctrl.T.Helper()
@@ -64,17 +95,17 @@ func (mr *MockRepositoryMockRecorder) Create(arg0, arg1 interface{}) *gomock.Cal
 }
 
 // Delete mocks base method
-func (m *MockRepository) Delete(arg0 context.Context, arg1, arg2 string) error {
+func (m *MockRepository) Delete(arg0 context.Context, arg1, arg2 string, arg3 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockRepositoryMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), arg0, arg1, arg2, arg3)
 }
 
 // Get mocks base method
@@ -92,6 +123,52 @@ func (mr *MockRepositoryMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetMany mocks base method
+func (m *MockRepository) GetMany(arg0 context.Context, arg1 string, arg2 []string) ([]entity.Bookmark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMany", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]entity.Bookmark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMany indicates an expected call of GetMany
+func (mr *MockRepositoryMockRecorder) GetMany(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMany", reflect.TypeOf((*MockRepository)(nil).GetMany), arg0, arg1, arg2)
+}
+
+// List mocks base method
+func (m *MockRepository) List(arg0 context.Context, arg1 string, arg2 int, arg3 string) ([]entity.Bookmark, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]entity.Bookmark)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// List indicates an expected call of List
+func (mr *MockRepositoryMockRecorder) List(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepository)(nil).List), arg0, arg1, arg2, arg3)
+}
+
+// Push mocks base method
+func (m *MockRepository) Push(arg0 context.Context, arg1 string, arg2 []entity.BookmarkMutation) ([]entity.BookmarkMutationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Push", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]entity.BookmarkMutationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Push indicates an expected call of Push
+func (mr *MockRepositoryMockRecorder) Push(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockRepository)(nil).Push), arg0, arg1, arg2)
+}
+
 // RemoveTag mocks base method
 func (m *MockRepository) RemoveTag(arg0 context.Context, arg1, arg2, arg3 string) error {
 	m.ctrl.T.Helper()
@@ -121,6 +198,21 @@ func (mr *MockRepositoryMockRecorder) SearchByName(arg0, arg1, arg2 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchByName", reflect.TypeOf((*MockRepository)(nil).SearchByName), arg0, arg1, arg2)
 }
 
+// SearchByTag mocks base method
+func (m *MockRepository) SearchByTag(arg0 context.Context, arg1, arg2 string) ([]entity.Bookmark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchByTag", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]entity.Bookmark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchByTag indicates an expected call of SearchByTag
+func (mr *MockRepositoryMockRecorder) SearchByTag(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchByTag", reflect.TypeOf((*MockRepository)(nil).SearchByTag), arg0, arg1, arg2)
+}
+
 // Update mocks base method
 func (m *MockRepository) Update(arg0 context.Context, arg1 entity.Bookmark) (entity.Bookmark, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,216 @@
+package bookmark
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/pkg/db"
+)
+
+// Repairer detects and fixes drift between bookmarks and the projections on their index items
+type Repairer interface {
+	// Returns every user having bookmarks
+	Usernames(ctx context.Context) ([]string, error)
+	Repair(ctx context.Context, username string, fix bool) (RepairReport, error)
+}
+
+type RepairReport struct {
+	Username  string
+	Bookmarks int
+	// Index items the bookmark should have but does not
+	Missing int
+	// Index items whose projection differs from the bookmark
+	Stale int
+	// Index items of deleted bookmarks, names or tags
+	Orphaned int
+	Fixed    bool
+}
+
+func (r RepairReport) HasDrift() bool {
+	return r.Missing+r.Stale+r.Orphaned > 0
+}
+
+type repairer struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepairer(logger *zap.Logger) Repairer {
+	return &repairer{db: db.GetDynamoDb(), logger: logger}
+}
+
+type indexItem struct {
+	rangeKey   string
+	bookmarkId string
+	projection entity.BookmarkProjection
+}
+
+func (r *repairer) Usernames(ctx context.Context) ([]string, error) {
+	table := r.db.Table(db.GetTableBookmark())
+
+	var items []entity.Bookmark
+	err := table.Scan().
+		Filter("begins_with($, ?)", "range", "BOOKMARK_").
+		Project("id").
+		AllWithContext(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, item := range items {
+		username := item.GetUsername()
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+
+	return usernames, nil
+}
+
+func (r *repairer) Repair(ctx context.Context, username string, fix bool) (RepairReport, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	report := RepairReport{Username: username}
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId := entity.GetPartitionKey(username)
+	bookmarks := make(map[string]entity.Bookmark)
+	var indexItems []indexItem
+
+	iter := table.Get("id", hashId).Iter()
+	var item map[string]*dynamodb.AttributeValue
+	for iter.NextWithContext(ctx, &item) {
+		rangeKey := ""
+		if item["range"] != nil && item["range"].S != nil {
+			rangeKey = *item["range"].S
+		}
+
+		switch {
+		case strings.HasPrefix(rangeKey, "BOOKMARK_"):
+			var bookmark entity.Bookmark
+			if err := dynamo.UnmarshalItem(item, &bookmark); err != nil {
+				return report, err
+			}
+			bookmarks[bookmark.GetBookmarkId()] = bookmark
+		case strings.HasPrefix(rangeKey, "NAME_"), strings.HasPrefix(rangeKey, "TAG_"), strings.HasPrefix(rangeKey, "CREATED_"):
+			var projection entity.BookmarkProjection
+			if err := dynamo.UnmarshalItem(item, &projection); err != nil {
+				return report, err
+			}
+			indexItems = append(indexItems, indexItem{
+				rangeKey:   rangeKey,
+				bookmarkId: rangeKey[strings.LastIndex(rangeKey, "_")+1:],
+				projection: projection,
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		logger.Errorw("Failed to read bookmarks", zap.String("Username", username), zap.Error(err))
+		return report, err
+	}
+	report.Bookmarks = len(bookmarks)
+
+	var puts []interface{}
+	var deletes []dynamo.Keyed
+
+	// Index items must belong to a bookmark and carry its current projection
+	present := make(map[string]bool)
+	for _, indexItem := range indexItems {
+		bookmark, ok := bookmarks[indexItem.bookmarkId]
+		if !ok || !expectedIndexKeys(bookmark)[indexItem.rangeKey] {
+			report.Orphaned++
+			deletes = append(deletes, dynamo.Keys{hashId, indexItem.rangeKey})
+			continue
+		}
+
+		present[indexItem.rangeKey] = true
+		if !indexItem.projection.Matches(bookmark) {
+			report.Stale++
+			puts = append(puts, indexItemOf(bookmark, indexItem.rangeKey))
+		}
+	}
+
+	// Every bookmark must have its index items
+	for _, bookmark := range bookmarks {
+		for rangeKey := range expectedIndexKeys(bookmark) {
+			if !present[rangeKey] {
+				report.Missing++
+				puts = append(puts, indexItemOf(bookmark, rangeKey))
+			}
+		}
+	}
+
+	if !fix || !report.HasDrift() {
+		return report, nil
+	}
+
+	// Conditional puts, so a bookmark changed in the meantime keeps its newer projection
+	for _, put := range puts {
+		var version int
+		switch v := put.(type) {
+		case entity.BookmarkSearchByName:
+			version = v.Version
+		case entity.BookmarkSearchByTag:
+			version = v.Version
+		case entity.BookmarkSearchByCreated:
+			version = v.Version
+		}
+
+		err := table.Put(put).
+			If("attribute_not_exists($) OR $ <= ?", "version", "version", version).
+			RunWithContext(ctx)
+		if err != nil && !db.IsConditionalCheckFailed(err) {
+			logger.Errorw("Failed to repair index item", zap.String("Username", username), zap.Error(err))
+			return report, err
+		}
+	}
+
+	if len(deletes) > 0 {
+		_, err := table.Batch("id", "range").Write().Delete(deletes...).RunWithContext(ctx)
+		if err != nil {
+			logger.Errorw("Failed to delete orphaned index items", zap.String("Username", username), zap.Error(err))
+			return report, err
+		}
+	}
+
+	report.Fixed = true
+	return report, nil
+}
+
+func expectedIndexKeys(bookmark entity.Bookmark) map[string]bool {
+	keys := map[string]bool{bookmark.GetSearchByName().Name: true}
+	for _, searchByTag := range bookmark.GetSearchByTag() {
+		keys[searchByTag.Tag] = true
+	}
+	if !db.IsSortableID(bookmark.GetBookmarkId()) {
+		keys[bookmark.GetSearchByCreated().Created] = true
+	}
+	return keys
+}
+
+func indexItemOf(bookmark entity.Bookmark, rangeKey string) interface{} {
+	if strings.HasPrefix(rangeKey, "NAME_") {
+		return bookmark.GetSearchByName()
+	}
+	if strings.HasPrefix(rangeKey, "CREATED_") {
+		return bookmark.GetSearchByCreated()
+	}
+
+	for _, searchByTag := range bookmark.GetSearchByTag() {
+		if searchByTag.Tag == rangeKey {
+			return searchByTag
+		}
+	}
+	return nil
+}
@@ -4,8 +4,16 @@ package bookmark
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/guregu/dynamo"
 	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
@@ -15,14 +23,86 @@ import (
 	"bookmark-api/pkg/db"
 )
 
+const (
+	// DynamoDB API limit, 25 items per transaction
+	maxTransactionItems = 25
+	// DynamoDB API limit, 25 items per BatchWriteItem
+	maxBatchWriteItems = 25
+	// DynamoDB API limit, 100 keys per BatchGetItem
+	maxBatchGetItems = 100
+	// Number of BatchGetItem requests running at the same time
+	maxConcurrentBatchGets = 4
+	maxBatchGetRetries     = 5
+	batchGetBackoff        = 50 * time.Millisecond
+	// Commits retried when another write took the next sequence numbers of the change log
+	// or was running at the same time, after a random delay of up to 20ms, 40ms and 80ms
+	maxSequenceRetries = 3
+	commitBackoff      = 20 * time.Millisecond
+	// Merges retried when the bookmark has changed between reading and writing it
+	maxMergeRetries = 3
+	// Changes older than this expire, clients behind have to resync from scratch
+	changeRetention = 90 * 24 * time.Hour
+)
+
+// Bookmark IDs are ordered by creation since then, older ones are listed by SearchByCreated
+var sortableIDsSince = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 type Repository interface {
 	Create(ctx context.Context, bookmark entity.Bookmark) (entity.Bookmark, error)
 	Get(ctx context.Context, username, id string) (entity.Bookmark, error)
 	Update(ctx context.Context, bookmark entity.Bookmark) (entity.Bookmark, error)
-	Delete(ctx context.Context, username, id string) error
+	Delete(ctx context.Context, username, id string, version int) error
 	SearchByName(ctx context.Context, username, name string) ([]entity.Bookmark, error)
+	// Returns the bookmarks having exactly the tag
+	SearchByTag(ctx context.Context, username, tag string) ([]entity.Bookmark, error)
+	// Returns the bookmarks in the order of the IDs, missing ones are skipped
+	GetMany(ctx context.Context, username string, bookmarkIds []string) ([]entity.Bookmark, error)
+	// Lists bookmarks most recent first, returns the cursor of the next page or empty string on the last page
+	List(ctx context.Context, username string, limit int, cursor string) ([]entity.Bookmark, string, error)
 	AddTag(ctx context.Context, username, bookmarkId, tag string) error
 	RemoveTag(ctx context.Context, username, bookmarkId, tag string) error
+	Batch(ctx context.Context, username string, operations []entity.BookmarkOperation, atomic bool) ([]entity.BookmarkOperationResult, error)
+	// Merges edits made offline into the current bookmarks, one result per mutation
+	Push(ctx context.Context, username string, mutations []entity.BookmarkMutation) ([]entity.BookmarkMutationResult, error)
+	// Returns at most limit changes after the sequence number since in order, and the last sequence number.
+	// Returns errors.ErrExpired when some of the changes are not retained anymore
+	Changes(ctx context.Context, username string, since int64, limit int) ([]entity.BookmarkChange, int64, error)
+}
+
+// Write of a single bookmark, before is nil on create and after is nil on delete
+type bookmarkChange struct {
+	operation entity.BookmarkOperation
+	before    *entity.Bookmark
+	after     *entity.Bookmark
+	// ID the client gave a created bookmark, recorded so a replay does not create it twice
+	clientId string
+}
+
+// Write of an index item, nil item deletes it
+type indexWrite struct {
+	keys dynamo.Keys
+	item interface{}
+}
+
+// Index writes of several commits. A later write of an item replaces the earlier one,
+// so the writes may run in any order.
+type indexWrites struct {
+	writes    []indexWrite
+	positions map[string]int
+}
+
+func (w *indexWrites) add(write indexWrite) {
+	key := fmt.Sprintf("%v/%v", write.keys[0], write.keys[1])
+	if i, ok := w.positions[key]; ok {
+		w.writes[i] = write
+		return
+	}
+
+	if w.positions == nil {
+		w.positions = make(map[string]int)
+	}
+	w.positions[key] = len(w.writes)
+	w.writes = append(w.writes, write)
 }
 
 type repository struct {
@@ -40,30 +120,21 @@ func (r *repository) Create(ctx context.Context, bookmark entity.Bookmark) (enti
 		_ = logger.Sync()
 	}()
 
-	tx := r.db.WriteTx()
-
-	tableBookmark := r.db.Table(db.GetTableBookmark())
-
-	// Create Bookmark
+	// Create Bookmark, SearchByName and SearchByTag
 	bookmark.ID = db.GenerateID()
 	bookmark.CreatedAt = time.Now()
 	bookmark.UpdatedAt = time.Now()
-	tx.Put(tableBookmark.Put(bookmark.GetEntity()))
-
-	// Create SearchByName
-	tx.Put(tableBookmark.Put(bookmark.GetSearchByName()))
-
-	// Create SearchByTag
-	for _, searchByTag := range bookmark.GetSearchByTag() {
-		tx.Put(tableBookmark.Put(searchByTag))
-	}
-
-	err := tx.Run()
+	createdBookmark := bookmark.GetEntity()
+	err := r.commit(ctx, bookmark.Username, []bookmarkChange{{
+		operation: entity.BookmarkOperation{Type: entity.OperationCreate, Bookmark: bookmark},
+		after:     &createdBookmark,
+	}}, nil)
 	if err != nil {
-		logger.Errorw("Failed to create bookmark", zap.Error(err))
+		logger.Errorw("Failed to create bookmark", zap.Int("Tags", len(bookmark.Tags)), zap.Error(err))
 		return entity.Bookmark{}, err
 	}
 
+	bookmark.Version = createdBookmark.Version
 	return bookmark, nil
 }
 
@@ -94,150 +165,1096 @@ func (r *repository) Get(ctx context.Context, username, bookmarkId string) (enti
 }
 
 func (r *repository) Update(ctx context.Context, bookmark entity.Bookmark) (entity.Bookmark, error) {
+	updatedBookmark, err := r.apply(ctx, bookmark.Username, entity.BookmarkOperation{
+		Type:     entity.OperationUpdate,
+		Bookmark: bookmark,
+	})
+	if err != nil {
+		return entity.Bookmark{}, err
+	}
+
+	return *updatedBookmark, nil
+}
+
+func (r *repository) Delete(ctx context.Context, username, bookmarkId string, version int) error {
+	_, err := r.apply(ctx, username, entity.BookmarkOperation{
+		Type:     entity.OperationDelete,
+		Bookmark: entity.Bookmark{ID: bookmarkId, Version: version},
+	})
+
+	return err
+}
+
+func (r *repository) SearchByName(ctx context.Context, username string, name string) ([]entity.Bookmark, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	tx := r.db.WriteTx()
+	tableBookmark := r.db.Table(db.GetTableBookmark())
+
+	// Search by name
+	hashId, rangeId := entity.GetSearchKeyByName(username, name)
+	var searchByNameResult []entity.BookmarkSearchByName
+	err := tableBookmark.Get("id", hashId).
+		Range("range", "BEGINS_WITH", rangeId).
+		AllWithContext(ctx, &searchByNameResult)
 
-	freshBookmark, err := r.Get(ctx, bookmark.Username, bookmark.ID)
 	if err != nil {
-		return entity.Bookmark{}, err
+		logger.Errorw("Failed to search bookmark", zap.String("HashId", hashId), zap.String("RangeId", rangeId), zap.Error(err))
+		return []entity.Bookmark{}, err
 	}
 
-	table := r.db.Table((db.GetTableBookmark()))
+	// Index items carry a projection of the bookmark, only the ones written before projections need a fetch
+	var legacyIds []string
+	for _, searchByName := range searchByNameResult {
+		if !searchByName.HasProjection() {
+			legacyIds = append(legacyIds, searchByName.GetBookmarkId())
+		}
+	}
+	bookmarks, err := r.getMany(ctx, username, legacyIds)
 
-	// Update URL only
-	freshBookmark.Url = bookmark.Url
-	freshBookmark.UpdatedAt = time.Now()
-	tx.Put(table.Put(freshBookmark))
+	// Keep the order of the search
+	result := []entity.Bookmark{}
+	for _, searchByName := range searchByNameResult {
+		if searchByName.HasProjection() {
+			result = append(result, searchByName.GetBookmark())
+			continue
+		}
 
-	err = tx.Run()
-	if err != nil {
-		logger.Errorw("Failed to update bookmark", zap.String("ID", freshBookmark.ID), zap.Error(err))
-		return entity.Bookmark{}, err
+		bookmarkId := searchByName.GetBookmarkId()
+		if bookmark, ok := bookmarks[bookmarkId]; ok {
+			result = append(result, *bookmark)
+		} else if !funk.ContainsString(failedIds(err), bookmarkId) {
+			logger.Warnw("Search by name refers to missing bookmark", zap.String("HashId", hashId), zap.String("BookmarkID", bookmarkId))
+		}
 	}
 
-	return entity.Bookmark{}, nil
+	return result, err
 }
 
-func (r *repository) Delete(ctx context.Context, username, bookmarkId string) error {
+func (r *repository) SearchByTag(ctx context.Context, username, tag string) ([]entity.Bookmark, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	// TODO: Implement this
+	tableBookmark := r.db.Table(db.GetTableBookmark())
 
-	return nil
+	// Range keys of other tags starting with the same prefix match too, like TAG_go_lang_{ID} for go
+	hashId, rangeId := entity.GetSearchKeyByTag(username, tag)
+	var searchByTagResult []entity.BookmarkSearchByTag
+	err := tableBookmark.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId+"_").
+		AllWithContext(ctx, &searchByTagResult)
+	if err != nil {
+		logger.Errorw("Failed to search bookmark by tag", zap.String("HashId", hashId), zap.String("RangeId", rangeId), zap.Error(err))
+		return []entity.Bookmark{}, err
+	}
+
+	var legacyIds []string
+	for _, searchByTag := range searchByTagResult {
+		if !searchByTag.HasProjection() {
+			legacyIds = append(legacyIds, searchByTag.GetBookmarkId())
+		}
+	}
+	bookmarks, err := r.getMany(ctx, username, legacyIds)
+
+	result := []entity.Bookmark{}
+	for _, searchByTag := range searchByTagResult {
+		bookmark := searchByTag.GetBookmark()
+		if !searchByTag.HasProjection() {
+			fetched, ok := bookmarks[searchByTag.GetBookmarkId()]
+			if !ok {
+				continue
+			}
+			bookmark = *fetched
+		}
+
+		if funk.ContainsString(bookmark.Tags, tag) {
+			result = append(result, bookmark)
+		}
+	}
+
+	return result, err
 }
 
-func (r *repository) SearchByName(ctx context.Context, username string, name string) ([]entity.Bookmark, error) {
+func (r *repository) GetMany(ctx context.Context, username string, bookmarkIds []string) ([]entity.Bookmark, error) {
+	bookmarks, err := r.getMany(ctx, username, bookmarkIds)
+
+	result := []entity.Bookmark{}
+	for _, bookmarkId := range funk.UniqString(bookmarkIds) {
+		if bookmark, ok := bookmarks[bookmarkId]; ok {
+			result = append(result, *bookmark)
+		}
+	}
+
+	return result, err
+}
+
+func (r *repository) List(ctx context.Context, username string, limit int, cursor string) ([]entity.Bookmark, string, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	tableBookmark := r.db.Table(db.GetTableBookmark())
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		logger.Errorw("Invalid cursor", zap.String("Cursor", cursor), zap.Error(err))
+		return nil, "", errors.ErrInvalidParam
+	}
 
-	var result []entity.Bookmark
+	table := r.db.Table(db.GetTableBookmark())
+	result := []entity.Bookmark{}
 
-	// Search by name
-	hashId, rangeId := entity.GetSearchKeyByName(username, name)
-	var searchByNameResult []entity.BookmarkSearchByName
-	err := tableBookmark.Get("id", hashId).
-		Range("range", "BEGINS_WITH", rangeId).
-		All(&searchByNameResult)
+	// Bookmarks with sortable IDs are newer than every legacy bookmark, so they come first
+	if position.Phase != cursorPhaseCreated {
+		hashId, _ := entity.GetSearchKeyByID(username, "")
+		var startKey dynamo.PagingKey
+		if position.Key != "" {
+			startKey = pagingKey(hashId, position.Key)
+		}
+
+		// Legacy IDs might fall into the range by chance, the page is filled up from the following ones then
+		for {
+			query := table.Get("id", hashId).
+				Range("range", dynamo.Between, "BOOKMARK_"+db.SortableIDPrefix(sortableIDsSince), "BOOKMARK_"+db.SortableIDPrefix(time.Now().Add(time.Minute))).
+				Order(dynamo.Descending).
+				SearchLimit(int64(limit - len(result)))
+			if startKey != nil {
+				query = query.StartFrom(startKey)
+			}
+
+			iter := query.Iter()
+			var bookmark entity.Bookmark
+			for iter.NextWithContext(ctx, &bookmark) {
+				if !db.IsSortableID(bookmark.GetBookmarkId()) {
+					continue
+				}
+
+				result = append(result, bookmark)
+				if len(result) == limit {
+					return result, encodeCursor(cursorPosition{Phase: cursorPhaseID, Key: bookmark.ID}), nil
+				}
+			}
+			if err := iter.Err(); err != nil {
+				logger.Errorw("Failed to list bookmarks", zap.String("Username", username), zap.Error(err))
+				return nil, "", err
+			}
+
+			startKey = iter.LastEvaluatedKey()
+			if startKey == nil {
+				break
+			}
+		}
+
+		position = cursorPosition{Phase: cursorPhaseCreated}
+	}
+
+	// Legacy bookmarks in the order of SearchByCreated
+	hashId, rangeId := entity.GetSearchKeyByCreated(username)
+	query := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		Order(dynamo.Descending).
+		SearchLimit(int64(limit - len(result)))
+	if position.Key != "" {
+		query = query.StartFrom(pagingKey(hashId, position.Key))
+	}
 
+	iter := query.Iter()
+	var searchByCreated entity.BookmarkSearchByCreated
+	for iter.NextWithContext(ctx, &searchByCreated) {
+		result = append(result, searchByCreated.GetBookmark())
+		if len(result) == limit {
+			return result, encodeCursor(cursorPosition{Phase: cursorPhaseCreated, Key: searchByCreated.Created}), nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		logger.Errorw("Failed to list legacy bookmarks", zap.String("Username", username), zap.Error(err))
+		return nil, "", err
+	}
+
+	return result, "", nil
+}
+
+func (r *repository) AddTag(ctx context.Context, username, bookmarkId, tag string) error {
+	_, err := r.apply(ctx, username, entity.BookmarkOperation{
+		Type:     entity.OperationAddTag,
+		Bookmark: entity.Bookmark{ID: bookmarkId},
+		Tag:      tag,
+	})
+
+	return err
+}
+
+func (r *repository) RemoveTag(ctx context.Context, username, bookmarkId, tag string) error {
+	_, err := r.apply(ctx, username, entity.BookmarkOperation{
+		Type:     entity.OperationRemoveTag,
+		Bookmark: entity.Bookmark{ID: bookmarkId},
+		Tag:      tag,
+	})
+
+	return err
+}
+
+func (r *repository) Batch(ctx context.Context, username string, operations []entity.BookmarkOperation, atomic bool) ([]entity.BookmarkOperationResult, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	// Fetch every bookmark referenced by the operations at once
+	var bookmarkIds []string
+	for _, operation := range operations {
+		if operation.Type != entity.OperationCreate {
+			bookmarkIds = append(bookmarkIds, operation.Bookmark.GetBookmarkId())
+		}
+	}
+	bookmarks, err := r.getMany(ctx, username, bookmarkIds)
 	if err != nil {
-		logger.Errorw("Failed to search bookmark", zap.String("HashId", hashId), zap.String("RangeId", rangeId), zap.Error(err))
-		return []entity.Bookmark{}, err
+		logger.Errorw("Failed to fetch bookmarks of batch", zap.Error(err))
+		return nil, err
 	}
 
-	// Fetch every bookmark by ID
-	for _, bookmarkName := range searchByNameResult {
-		hashId, rangeId = entity.GetSearchKeyByID(username, bookmarkName.GetBookmarkId())
-		var bookmark entity.Bookmark
-		err = tableBookmark.Get("id", hashId).
-			Range("range", "EQ", rangeId).One(&bookmark)
+	if atomic {
+		return r.batchAtomic(ctx, username, operations, bookmarks)
+	}
+
+	// Each operation commits the bookmark with its change log entry, the index items are written
+	// afterwards in batches. Operations keep working on bookmarks with many tags this way.
+	results := make([]entity.BookmarkOperationResult, len(operations))
+	indexes := &indexWrites{}
+	for i, operation := range operations {
+		bookmarkId := operation.Bookmark.GetBookmarkId()
+		before := bookmarks[bookmarkId]
+
+		after, err := applyOperation(username, before, operation)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
 
+		err = r.commit(ctx, username, []bookmarkChange{{operation: operation, before: before, after: after}}, indexes)
 		if err != nil {
-			logger.Errorw("Could not fetch bookmark", zap.String("HashId", hashId), zap.String("RangeId", rangeId))
+			logger.Errorw("Failed to run batch operation", zap.Int("Index", i), zap.String("Type", string(operation.Type)), zap.Error(err))
+			results[i].Err = err
+			continue
+		}
+
+		if after == nil {
+			delete(bookmarks, bookmarkId)
+			results[i].Bookmark = *before
 		} else {
-			result = append(result, bookmark)
+			bookmarks[after.GetBookmarkId()] = after
+			results[i].Bookmark = *after
 		}
 	}
 
-	return result, nil
+	// The bookmarks are written already, so failed index items are left to the repair command
+	if err := r.writeIndexes(ctx, indexes.writes); err != nil {
+		logger.Errorw("Failed to write index items of batch", zap.String("Username", username), zap.Error(err))
+	}
+
+	return results, nil
 }
 
-func (r *repository) AddTag(ctx context.Context, username, bookmarkId, tag string) error {
+func (r *repository) Push(ctx context.Context, username string, mutations []entity.BookmarkMutation) ([]entity.BookmarkMutationResult, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	bookmark, err := r.Get(ctx, username, bookmarkId)
+	var bookmarkIds []string
+	for _, mutation := range mutations {
+		if mutation.Type != entity.OperationCreate {
+			bookmarkIds = append(bookmarkIds, mutation.BookmarkID)
+		}
+	}
+	bookmarks, err := r.getMany(ctx, username, bookmarkIds)
 	if err != nil {
-		return err
+		logger.Errorw("Failed to fetch bookmarks of push", zap.Error(err))
+		return nil, err
 	}
 
-	if funk.Contains(bookmark.Tags, tag) {
-		logger.Errorw("Already has tag", zap.String("BookmarkID", bookmarkId), zap.String("Tag", tag), zap.Error(err))
-		return errors.ErrAlreadyExist
+	now := time.Now()
+	results := make([]entity.BookmarkMutationResult, len(mutations))
+	for i, mutation := range mutations {
+		// Clocks of clients can not be trusted to be in the past
+		if mutation.ModifiedAt.IsZero() || mutation.ModifiedAt.After(now) {
+			mutation.ModifiedAt = now
+		}
+
+		for attempt := 0; ; attempt++ {
+			if mutation.Type == entity.OperationCreate && mutation.ClientID != "" {
+				result, replayed, err := r.replayCreate(ctx, username, mutation.ClientID)
+				if err != nil {
+					results[i].Err = err
+					break
+				}
+				if replayed {
+					results[i] = result
+					break
+				}
+			}
+
+			change, result := mergeMutation(username, bookmarks[mutation.BookmarkID], mutation)
+			if result.Err != nil || change == nil {
+				results[i] = result
+				break
+			}
+
+			err := r.commit(ctx, username, []bookmarkChange{*change}, nil)
+			if err == errors.ErrPreconditionFailed && attempt < maxMergeRetries && mutation.Type == entity.OperationCreate {
+				// Created by a replay in the meantime
+				continue
+			}
+			if err == errors.ErrPreconditionFailed && attempt < maxMergeRetries {
+				// Changed in the meantime, merge again into the latest state
+				latest, err := r.Get(ctx, username, mutation.BookmarkID)
+				if err != nil && err != errors.ErrNotFound {
+					results[i].Err = err
+					break
+				}
+				if err == errors.ErrNotFound {
+					delete(bookmarks, mutation.BookmarkID)
+				} else {
+					bookmarks[mutation.BookmarkID] = &latest
+				}
+				continue
+			}
+			if err != nil {
+				logger.Errorw("Failed to push mutation", zap.Int("Index", i), zap.String("Type", string(mutation.Type)), zap.Error(err))
+				results[i].Err = err
+				break
+			}
+
+			if change.after == nil {
+				delete(bookmarks, mutation.BookmarkID)
+			} else {
+				bookmarks[change.after.GetBookmarkId()] = change.after
+				result.Bookmark = *change.after
+			}
+			result.Applied = true
+			results[i] = result
+			break
+		}
 	}
 
-	tx := r.db.WriteTx()
+	return results, nil
+}
+
+// Returns the result of the create with the client ID when it has already been pushed
+func (r *repository) replayCreate(ctx context.Context, username, clientId string) (entity.BookmarkMutationResult, bool, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
 
-	// Update Bookmark
 	table := r.db.Table(db.GetTableBookmark())
-	bookmark.Tags = append(bookmark.Tags, tag)
-	tx.Put(table.Put(bookmark))
 
-	// Add Tag
-	tx.Put(table.Put(entity.NewBookmarkSearchByTag(username, bookmarkId, tag)))
+	hashId, rangeId := entity.GetSearchKeyByClientID(username, clientId)
+	var created entity.BookmarkClientID
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		Consistent(true).
+		OneWithContext(ctx, &created)
+	if err == dynamo.ErrNotFound {
+		return entity.BookmarkMutationResult{}, false, nil
+	}
+	if err != nil {
+		logger.Errorw("Failed to get client ID", zap.String("ClientID", clientId), zap.Error(err))
+		return entity.BookmarkMutationResult{}, false, err
+	}
+
+	result := entity.BookmarkMutationResult{}
+	bookmark, err := r.Get(ctx, username, created.BookmarkID)
+	switch err {
+	case nil:
+		result.Bookmark = bookmark
+	case errors.ErrNotFound:
+		// Deleted since it was created
+		result.Deleted = true
+	default:
+		return result, false, err
+	}
+
+	return result, true, nil
+}
+
+// Applies every operation in memory and writes the final state in one transaction
+func (r *repository) batchAtomic(ctx context.Context, username string, operations []entity.BookmarkOperation, bookmarks map[string]*entity.Bookmark) ([]entity.BookmarkOperationResult, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	originals := make(map[string]*entity.Bookmark, len(bookmarks))
+	for id, bookmark := range bookmarks {
+		originals[id] = bookmark
+	}
+
+	results := make([]entity.BookmarkOperationResult, len(operations))
+	resultIds := make([]string, len(operations))
+	var touchedIds []string
+	// The change log records the last operation on each bookmark
+	lastOperations := make(map[string]entity.BookmarkOperation)
+	failed := false
+	for i, operation := range operations {
+		bookmarkId := operation.Bookmark.GetBookmarkId()
+		before := bookmarks[bookmarkId]
+
+		after, err := applyOperation(username, before, operation)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+
+		if after == nil {
+			results[i].Bookmark = *before
+			delete(bookmarks, bookmarkId)
+		} else {
+			bookmarkId = after.GetBookmarkId()
+			bookmarks[bookmarkId] = after
+		}
+		resultIds[i] = bookmarkId
+		lastOperations[bookmarkId] = operation
+		if !funk.ContainsString(touchedIds, bookmarkId) {
+			touchedIds = append(touchedIds, bookmarkId)
+		}
+	}
+
+	if failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = errors.ErrAborted
+			}
+		}
+		return results, nil
+	}
+
+	// Several operations on the same bookmark collapse into one write per item
+	var changes []bookmarkChange
+	for _, bookmarkId := range touchedIds {
+		before, after := originals[bookmarkId], bookmarks[bookmarkId]
+		// Created and deleted in the same batch
+		if before == nil && after == nil {
+			continue
+		}
+		changes = append(changes, bookmarkChange{operation: lastOperations[bookmarkId], before: before, after: after})
+	}
+
+	if len(changes) > 0 {
+		err := r.commit(ctx, username, changes, nil)
+		if err != nil {
+			logger.Errorw("Failed to run atomic batch", zap.Error(err))
+			if err != errors.ErrPreconditionFailed {
+				return nil, err
+			}
+			for i := range results {
+				results[i].Err = errors.ErrPreconditionFailed
+			}
+			return results, nil
+		}
+	}
+
+	for i, bookmarkId := range resultIds {
+		if bookmark, ok := bookmarks[bookmarkId]; ok {
+			results[i].Bookmark = *bookmark
+		}
+	}
+
+	return results, nil
+}
+
+// Fetches the bookmark, applies the operation and writes the changes
+func (r *repository) apply(ctx context.Context, username string, operation entity.BookmarkOperation) (*entity.Bookmark, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	bookmarkId := operation.Bookmark.GetBookmarkId()
+	before, err := r.Get(ctx, username, bookmarkId)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := applyOperation(username, &before, operation)
+	if err != nil {
+		logger.Errorw("Could not apply operation", zap.String("Type", string(operation.Type)), zap.String("BookmarkID", bookmarkId), zap.Error(err))
+		return nil, err
+	}
 
-	err = tx.Run()
+	err = r.commit(ctx, username, []bookmarkChange{{operation: operation, before: &before, after: after}}, nil)
+	if err != nil {
+		logger.Errorw("Failed to write bookmark", zap.String("Type", string(operation.Type)), zap.String("BookmarkID", bookmarkId), zap.Error(err))
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// Writes the changes together with their entries in the change log in one transaction.
+// The entries take the next sequence numbers of the user, guarded by a condition on the sequence item.
+// When another write took them in the meantime, or DynamoDB cancels the transaction for a write running at the same
+// time, the commit is retried with the new sequence number. Returns errors.ErrConflict when the retries run out.
+// Index items are written in the transaction as well, unless deferred is given. Their writes are added to it
+// once the commit has succeeded then, for the caller to run with writeIndexes.
+func (r *repository) commit(ctx context.Context, username string, changes []bookmarkChange, deferred *indexWrites) error {
+	table := r.db.Table(db.GetTableBookmark())
+
+	sequence, err := r.getSequence(ctx, username)
 	if err != nil {
-		logger.Errorw("Failed to create tag", zap.Error(err))
 		return err
 	}
 
+	for attempt := 0; ; attempt++ {
+		tx := r.db.WriteTx()
+		count := 0
+		var (
+			indexes []indexWrite
+			kept    []entity.BookmarkSearchByTag
+		)
+		for i, change := range changes {
+			written, changeIndexes, keptTags := writeChanges(tx, table, change.before, change.after)
+			count += written
+			indexes = append(indexes, changeIndexes...)
+			kept = append(kept, keptTags...)
+
+			entry := entity.NewBookmarkChange(username, sequence+int64(i)+1, change.operation, change.before, change.after)
+			entry.ExpiresAt = entry.ChangedAt.Add(changeRetention)
+			tx.Put(table.Put(entry))
+			count++
+
+			if change.clientId != "" && change.after != nil {
+				// Fails the commit when a replay of the create got there first
+				created := entity.NewBookmarkClientID(username, change.clientId, change.after.GetBookmarkId())
+				created.ExpiresAt = entry.ExpiresAt
+				tx.Put(table.Put(created).If("attribute_not_exists($)", "id"))
+				count++
+			}
+		}
+
+		hashId, rangeId := entity.GetSequenceKey(username)
+		tx.Update(table.Update("id", hashId).
+			Range("range", rangeId).
+			Set("seq", sequence+int64(len(changes))).
+			If("attribute_not_exists($) OR $ = ?", "seq", "seq", sequence))
+		count++
+
+		if deferred == nil {
+			for _, write := range indexes {
+				if write.item == nil {
+					tx.Delete(table.Delete("id", write.keys[0]).Range("range", write.keys[1]))
+				} else {
+					tx.Put(table.Put(write.item))
+				}
+				count++
+			}
+		}
+
+		if count > maxTransactionItems {
+			return errors.ErrTooManyItems
+		}
+		// Projections of kept tags fill up the transaction, the others are rewritten once it has succeeded
+		for deferred == nil && len(kept) > 0 && count < maxTransactionItems {
+			tx.Put(table.Put(kept[0]))
+			kept = kept[1:]
+			count++
+		}
+
+		err := tx.RunWithContext(ctx)
+		if err == nil && deferred != nil {
+			for _, write := range indexes {
+				deferred.add(write)
+			}
+			for _, searchByTag := range kept {
+				deferred.add(indexWrite{keys: dynamo.Keys{searchByTag.Username, searchByTag.Tag}, item: searchByTag})
+			}
+			return nil
+		}
+		if err == nil {
+			r.refreshProjections(ctx, table, kept)
+			return nil
+		}
+		conflict := db.IsTransactionConflict(err)
+		if !conflict && !db.IsConditionalCheckFailed(err) {
+			return err
+		}
+
+		// Either a bookmark or the sequence has changed, only the latter is worth a retry
+		current, err := r.getSequence(ctx, username)
+		if err != nil {
+			return err
+		}
+		if !conflict && current == sequence {
+			return errors.ErrPreconditionFailed
+		}
+		if attempt == maxSequenceRetries {
+			return errors.ErrConflict
+		}
+
+		// Writes colliding at once would collide again without jitter
+		if err := aws.SleepWithContext(ctx, time.Duration(rand.Int63n(int64(commitBackoff<<uint(attempt))))); err != nil {
+			return err
+		}
+		sequence = current
+	}
+}
+
+// Rewrites index items with the projection of a bookmark which has already been written.
+// Each is only written when it has an older version, so a concurrent change is not overwritten.
+// Failures leave stale projections behind, which the repair command finds and fixes.
+func (r *repository) refreshProjections(ctx context.Context, table dynamo.Table, items []entity.BookmarkSearchByTag) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	for _, item := range items {
+		err := table.Put(item).
+			If("attribute_not_exists($) OR $ < ?", "version", "version", item.Version).
+			RunWithContext(ctx)
+		if err != nil && !db.IsConditionalCheckFailed(err) {
+			logger.Warnw("Failed to refresh projection", zap.String("Range", item.Tag), zap.Error(err))
+		}
+	}
+}
+
+// Writes index items in batches of 25. The items are written as they are, without conditions on their version.
+func (r *repository) writeIndexes(ctx context.Context, writes []indexWrite) error {
+	table := r.db.Table(db.GetTableBookmark())
+
+	for start := 0; start < len(writes); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		batch := table.Batch("id", "range").Write()
+		for _, write := range writes[start:end] {
+			if write.item == nil {
+				batch.Delete(write.keys)
+			} else {
+				batch.Put(write.item)
+			}
+		}
+		if _, err := batch.RunWithContext(ctx); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *repository) RemoveTag(ctx context.Context, username, bookmarkId, tag string) error {
+// Returns the last sequence number of the change log, zero when nothing has been written yet
+func (r *repository) getSequence(ctx context.Context, username string) (int64, error) {
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSequenceKey(username)
+	var sequence entity.ChangeSequence
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		Consistent(true).
+		OneWithContext(ctx, &sequence)
+	if err == dynamo.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return sequence.Sequence, nil
+}
+
+func (r *repository) Changes(ctx context.Context, username string, since int64, limit int) ([]entity.BookmarkChange, int64, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	bookmark, err := r.Get(ctx, username, bookmarkId)
+	sequence, err := r.getSequence(ctx, username)
 	if err != nil {
-		return err
+		logger.Errorw("Failed to get sequence", zap.String("Username", username), zap.Error(err))
+		return nil, 0, err
+	}
+	if since < 0 || since > sequence {
+		return nil, 0, errors.ErrInvalidParam
 	}
 
-	if !funk.Contains(bookmark.Tags, tag) {
-		logger.Errorw("Bookmark has not tag", zap.String("BookmarkID", bookmarkId), zap.String("Tag", tag), zap.Error(err))
-		return errors.ErrInvalidParam
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, fromId := entity.GetSearchKeyByChange(username, since+1)
+	_, toId := entity.GetSearchKeyByChange(username, sequence)
+
+	changes := []entity.BookmarkChange{}
+	if since == sequence {
+		return changes, sequence, nil
 	}
 
-	tx := r.db.WriteTx()
+	err = table.Get("id", hashId).
+		Range("range", dynamo.Between, fromId, toId).
+		Consistent(true).
+		SearchLimit(int64(limit)).
+		AllWithContext(ctx, &changes)
+	if err != nil {
+		logger.Errorw("Failed to get changes", zap.String("Username", username), zap.Int64("Since", since), zap.Error(err))
+		return nil, 0, err
+	}
 
-	// Update Bookmark
-	table := r.db.Table(db.GetTableBookmark())
-	bookmark.Tags = funk.FilterString(bookmark.Tags, func(s string) bool { return s != tag })
-	tx.Put(table.Put(bookmark))
+	// Expired entries leave a gap right after since
+	if len(changes) == 0 || changes[0].Sequence != since+1 {
+		return nil, sequence, errors.ErrExpired
+	}
+
+	return changes, sequence, nil
+}
+
+// Fetches bookmarks by ID in chunks of 100 keys, several chunks at a time.
+// Missing bookmarks are not in the result. IDs which could not be fetched are returned with *errors.PartialError
+func (r *repository) getMany(ctx context.Context, username string, bookmarkIds []string) (map[string]*entity.Bookmark, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	result := make(map[string]*entity.Bookmark)
+	bookmarkIds = funk.UniqString(bookmarkIds)
+
+	var (
+		mutex     sync.Mutex
+		waitGroup sync.WaitGroup
+		failed    []string
+		lastErr   error
+	)
+	semaphore := make(chan struct{}, maxConcurrentBatchGets)
+	for start := 0; start < len(bookmarkIds); start += maxBatchGetItems {
+		end := start + maxBatchGetItems
+		if end > len(bookmarkIds) {
+			end = len(bookmarkIds)
+		}
+		chunk := bookmarkIds[start:end]
+
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer func() {
+				<-semaphore
+				waitGroup.Done()
+			}()
+
+			bookmarks, unprocessed, err := r.batchGet(ctx, username, chunk)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			for i := range bookmarks {
+				result[bookmarks[i].GetBookmarkId()] = &bookmarks[i]
+			}
+			if err != nil {
+				logger.Errorw("Failed to fetch bookmarks", zap.Int("Count", len(unprocessed)), zap.Error(err))
+				failed = append(failed, unprocessed...)
+				lastErr = err
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	if len(failed) > 0 {
+		return result, &errors.PartialError{Failed: failed, Err: lastErr}
+	}
+
+	return result, nil
+}
 
-	// Delete Tag
-	searchTag := entity.NewBookmarkSearchByTag(username, bookmarkId, tag)
-	tx.Delete(table.Delete("id", searchTag.Username).Range("range", searchTag.Tag))
+// Runs a single BatchGetItem for at most 100 keys and retries unprocessed keys with backoff.
+// Returns the IDs which are still unprocessed when it gives up.
+func (r *repository) batchGet(ctx context.Context, username string, bookmarkIds []string) ([]entity.Bookmark, []string, error) {
+	tableName := db.GetTableBookmark()
 
-	err = tx.Run()
+	keys := make([]map[string]*dynamodb.AttributeValue, len(bookmarkIds))
+	for i, bookmarkId := range bookmarkIds {
+		hashId, rangeId := entity.GetSearchKeyByID(username, bookmarkId)
+		keys[i] = map[string]*dynamodb.AttributeValue{
+			"id":    {S: aws.String(hashId)},
+			"range": {S: aws.String(rangeId)},
+		}
+	}
+	input := &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			tableName: {Keys: keys},
+		},
+	}
+
+	var bookmarks []entity.Bookmark
+	backoff := batchGetBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := r.db.Client().BatchGetItemWithContext(ctx, input)
+		if err != nil {
+			return bookmarks, unprocessedIds(input.RequestItems[tableName]), err
+		}
+
+		for _, item := range output.Responses[tableName] {
+			var bookmark entity.Bookmark
+			if err := dynamo.UnmarshalItem(item, &bookmark); err != nil {
+				return bookmarks, unprocessedIds(input.RequestItems[tableName]), err
+			}
+			bookmarks = append(bookmarks, bookmark)
+		}
+
+		unprocessed := output.UnprocessedKeys[tableName]
+		if unprocessed == nil || len(unprocessed.Keys) == 0 {
+			return bookmarks, nil, nil
+		}
+		if attempt == maxBatchGetRetries {
+			return bookmarks, unprocessedIds(unprocessed), fmt.Errorf("%d keys are still unprocessed after %d retries", len(unprocessed.Keys), attempt)
+		}
+
+		// Unprocessed keys should be retried with exponential backoff
+		input.RequestItems = output.UnprocessedKeys
+		if err := aws.SleepWithContext(ctx, backoff); err != nil {
+			return bookmarks, unprocessedIds(unprocessed), err
+		}
+		backoff *= 2
+	}
+}
+
+func unprocessedIds(keys *dynamodb.KeysAndAttributes) []string {
+	if keys == nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(keys.Keys))
+	for _, key := range keys.Keys {
+		if rangeKey, ok := key["range"]; ok && rangeKey.S != nil {
+			ids = append(ids, strings.TrimPrefix(*rangeKey.S, "BOOKMARK_"))
+		}
+	}
+	return ids
+}
+
+// Returns the state of the bookmark after the operation, nil when it is deleted.
+// Before is nil when the bookmark does not exist. Neither before nor the operation is modified.
+func applyOperation(username string, before *entity.Bookmark, operation entity.BookmarkOperation) (*entity.Bookmark, error) {
+	if operation.Type == entity.OperationCreate {
+		bookmark := entity.Bookmark{
+			Username:  username,
+			ID:        db.GenerateID(),
+			Name:      operation.Bookmark.Name,
+			Url:       operation.Bookmark.Url,
+			Tags:      funk.UniqString(operation.Bookmark.Tags),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		after := bookmark.GetEntity()
+		return &after, nil
+	}
+
+	if before == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	// Version is the one the caller has seen, zero means unconditional
+	switch expected := operation.Bookmark.Version; {
+	case expected == entity.NoVersion:
+		if before.Version != 0 {
+			return nil, errors.ErrPreconditionFailed
+		}
+	case expected != 0 && expected != before.Version:
+		return nil, errors.ErrPreconditionFailed
+	}
+
+	after := *before
+	after.Tags = append([]string{}, before.Tags...)
+	after.UpdatedAt = time.Now()
+
+	switch operation.Type {
+	case entity.OperationUpdate:
+		if operation.Bookmark.Name != "" {
+			after.Name = operation.Bookmark.Name
+		}
+		if operation.Bookmark.Url != "" {
+			after.Url = operation.Bookmark.Url
+		}
+	case entity.OperationDelete:
+		return nil, nil
+	case entity.OperationAddTag:
+		if funk.ContainsString(after.Tags, operation.Tag) {
+			return nil, errors.ErrAlreadyExist
+		}
+		after.Tags = append(after.Tags, operation.Tag)
+	case entity.OperationRemoveTag:
+		if !funk.ContainsString(after.Tags, operation.Tag) {
+			return nil, errors.ErrInvalidParam
+		}
+		after.Tags = funk.FilterString(after.Tags, func(s string) bool { return s != operation.Tag })
+	case entity.OperationMove:
+		if !funk.ContainsString(after.Tags, operation.Tag) {
+			return nil, errors.ErrInvalidParam
+		}
+		if funk.ContainsString(after.Tags, operation.TargetTag) {
+			return nil, errors.ErrAlreadyExist
+		}
+		for i, tag := range after.Tags {
+			if tag == operation.Tag {
+				after.Tags[i] = operation.TargetTag
+			}
+		}
+	default:
+		return nil, errors.ErrInvalidParam
+	}
+
+	return &after, nil
+}
+
+// Adds the write of the bookmark turning before into after to the transaction and returns the number of items written.
+// Nil before creates the bookmark, nil after deletes it. The version of after is set here.
+// Bookmarks created before versioning have no version attribute at all.
+// Writes of the index items are returned for the caller to add. Every index item carries a projection of
+// the bookmark, so all of them are rewritten on each change.
+// Index items of the tags the bookmark keeps are returned apart, their keys stay the same and only the projection
+// has to be rewritten, so they may be left out when the transaction runs out of items.
+func writeChanges(tx *dynamo.WriteTx, table dynamo.Table, before, after *entity.Bookmark) (int, []indexWrite, []entity.BookmarkSearchByTag) {
+	var (
+		indexes []indexWrite
+		kept    []entity.BookmarkSearchByTag
+	)
+
+	switch {
+	case before == nil && after == nil:
+		return 0, nil, nil
+	case before == nil:
+		after.Version = 1
+		// Put marshals the item right away, so the clocks have to be stamped first
+		stampFields(before, after)
+		tx.Put(table.Put(after).If("attribute_not_exists($)", "id"))
+	case after == nil:
+		tx.Delete(table.Delete("id", before.Username).Range("range", before.ID).
+			If("attribute_not_exists($) OR $ = ?", "version", "version", before.Version))
+		searchByName := before.GetSearchByName()
+		indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByName.Username, searchByName.Name}})
+	default:
+		after.Version = before.Version + 1
+		stampFields(before, after)
+		tx.Put(table.Put(after).If("attribute_not_exists($) OR $ = ?", "version", "version", before.Version))
+		if before.Name != after.Name {
+			searchByName := before.GetSearchByName()
+			indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByName.Username, searchByName.Name}})
+		}
+	}
+
+	// SearchByName, SearchByTag and SearchByCreated of legacy IDs with the projection
+	if after != nil {
+		searchByName := after.GetSearchByName()
+		indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByName.Username, searchByName.Name}, item: searchByName})
+		for i, searchByTag := range after.GetSearchByTag() {
+			if before != nil && funk.ContainsString(before.Tags, after.Tags[i]) {
+				kept = append(kept, searchByTag)
+				continue
+			}
+			indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByTag.Username, searchByTag.Tag}, item: searchByTag})
+		}
+		if !db.IsSortableID(after.GetBookmarkId()) {
+			searchByCreated := after.GetSearchByCreated()
+			indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByCreated.Username, searchByCreated.Created}, item: searchByCreated})
+		}
+	}
+	if before != nil {
+		if after == nil && !db.IsSortableID(before.GetBookmarkId()) {
+			searchByCreated := before.GetSearchByCreated()
+			indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByCreated.Username, searchByCreated.Created}})
+		}
+		for _, tag := range before.Tags {
+			if after == nil || !funk.ContainsString(after.Tags, tag) {
+				searchByTag := entity.NewBookmarkSearchByTag(before.GetUsername(), before.GetBookmarkId(), tag)
+				indexes = append(indexes, indexWrite{keys: dynamo.Keys{searchByTag.Username, searchByTag.Tag}})
+			}
+		}
+	}
+
+	return 1, indexes, kept
+}
+
+// Advances the clocks of the fields changed from before to after to the version of after.
+// Clocks already changed by the caller keep their time, the others get the update time of after.
+func stampFields(before, after *entity.Bookmark) {
+	var beforeFields map[string]entity.FieldClock
+	changed := map[string]bool{entity.FieldName: true, entity.FieldUrl: true}
+	var tags []string
+	if before != nil {
+		beforeFields = before.Fields
+		changed[entity.FieldName] = before.Name != after.Name
+		changed[entity.FieldUrl] = before.Url != after.Url
+		tags = before.Tags
+	}
+	for _, tag := range funk.UniqString(append(append([]string{}, tags...), after.Tags...)) {
+		changed[entity.TagField(tag)] = !funk.ContainsString(tags, tag) || !funk.ContainsString(after.Tags, tag)
+	}
+
+	fields := make(map[string]entity.FieldClock, len(after.Fields)+len(changed))
+	for field, clock := range after.Fields {
+		fields[field] = clock
+	}
+	for field, isChanged := range changed {
+		if !isChanged {
+			continue
+		}
+
+		clock := fields[field]
+		if clock == beforeFields[field] {
+			clock.UpdatedAt = after.UpdatedAt
+		}
+		clock.Version = after.Version
+		fields[field] = clock
+	}
+	after.Fields = fields
+}
+
+const (
+	cursorPhaseID      = "id"
+	cursorPhaseCreated = "created"
+)
+
+// Position of a listing, the phase tells which index the range key belongs to
+type cursorPosition struct {
+	Phase string `json:"p"`
+	Key   string `json:"k"`
+}
+
+func encodeCursor(position cursorPosition) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (cursorPosition, error) {
+	var position cursorPosition
+	if cursor == "" {
+		return position, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		logger.Errorw("Failed to create tag", zap.Error(err))
-		return err
+		return position, err
+	}
+	if err := json.Unmarshal(data, &position); err != nil {
+		return position, err
+	}
+	if position.Phase != cursorPhaseID && position.Phase != cursorPhaseCreated {
+		return position, fmt.Errorf("Unknown phase: %s", position.Phase)
+	}
+
+	return position, nil
+}
+
+func pagingKey(hashId, rangeId string) dynamo.PagingKey {
+	return dynamo.PagingKey{
+		"id":    {S: aws.String(hashId)},
+		"range": {S: aws.String(rangeId)},
+	}
+}
+
+func failedIds(err error) []string {
+	if partialErr, ok := errors.IsPartial(err); ok {
+		return partialErr.Failed
 	}
 
 	return nil
@@ -0,0 +1,674 @@
+package bookmark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/guregu/dynamo"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+	"bookmark-api/pkg/logger"
+)
+
+func TestApplyOperation(t *testing.T) {
+	existing := entity.Bookmark{
+		Username: "USERNAME_1",
+		ID:       "BOOKMARK_2",
+		Name:     "Go",
+		Url:      "https://golang.org",
+		Tags:     []string{"go", "lang"},
+		Version:  3,
+	}
+	legacy := entity.Bookmark{
+		Username: "USERNAME_1",
+		ID:       "BOOKMARK_2",
+		Name:     "Old",
+		Url:      "https://example.com",
+		Tags:     []string{"go"},
+	}
+
+	tests := []struct {
+		name      string
+		before    *entity.Bookmark
+		operation entity.BookmarkOperation
+		tags      []string
+		deleted   bool
+		err       error
+	}{
+		{"Update", &existing, entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{Name: "Golang"}}, []string{"go", "lang"}, false, nil},
+		{"UpdateMissing", nil, entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{Name: "Golang"}}, nil, false, errors.ErrNotFound},
+		{"UpdateStaleVersion", &existing, entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{Url: "x", Version: 2}}, nil, false, errors.ErrPreconditionFailed},
+		{"UpdateUnversioned", &legacy, entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{Url: "x", Version: entity.NoVersion}}, []string{"go"}, false, nil},
+		{"UpdateVersionedAsUnversioned", &existing, entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{Url: "x", Version: entity.NoVersion}}, nil, false, errors.ErrPreconditionFailed},
+		{"Delete", &existing, entity.BookmarkOperation{Type: entity.OperationDelete, Bookmark: entity.Bookmark{Version: 3}}, nil, true, nil},
+		{"DeleteStaleVersion", &existing, entity.BookmarkOperation{Type: entity.OperationDelete, Bookmark: entity.Bookmark{Version: 2}}, nil, false, errors.ErrPreconditionFailed},
+		{"AddTag", &existing, entity.BookmarkOperation{Type: entity.OperationAddTag, Tag: "web"}, []string{"go", "lang", "web"}, false, nil},
+		{"AddExistingTag", &existing, entity.BookmarkOperation{Type: entity.OperationAddTag, Tag: "go"}, nil, false, errors.ErrAlreadyExist},
+		{"RemoveTag", &existing, entity.BookmarkOperation{Type: entity.OperationRemoveTag, Tag: "go"}, []string{"lang"}, false, nil},
+		{"RemoveMissingTag", &existing, entity.BookmarkOperation{Type: entity.OperationRemoveTag, Tag: "web"}, nil, false, errors.ErrInvalidParam},
+		{"Move", &existing, entity.BookmarkOperation{Type: entity.OperationMove, Tag: "lang", TargetTag: "language"}, []string{"go", "language"}, false, nil},
+		{"MoveToExistingTag", &existing, entity.BookmarkOperation{Type: entity.OperationMove, Tag: "lang", TargetTag: "go"}, nil, false, errors.ErrAlreadyExist},
+		{"Unknown", &existing, entity.BookmarkOperation{Type: "rename"}, nil, false, errors.ErrInvalidParam},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			after, err := applyOperation("1", test.before, test.operation)
+			assert.Equal(t, test.err, err)
+			if test.err != nil || test.deleted {
+				assert.Nil(t, after)
+				return
+			}
+
+			assert.Equal(t, test.tags, after.Tags)
+			assert.Equal(t, existing.ID, after.ID)
+		})
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		after, err := applyOperation("1", nil, entity.BookmarkOperation{
+			Type:     entity.OperationCreate,
+			Bookmark: entity.Bookmark{Name: "Go", Url: "https://golang.org", Tags: []string{"go", "go"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "USERNAME_1", after.Username)
+		assert.NotEmpty(t, after.GetBookmarkId())
+		assert.Equal(t, []string{"go"}, after.Tags)
+	})
+
+	t.Run("BeforeIsNotModified", func(t *testing.T) {
+		_, _ = applyOperation("1", &existing, entity.BookmarkOperation{Type: entity.OperationMove, Tag: "go", TargetTag: "golang"})
+		assert.Equal(t, []string{"go", "lang"}, existing.Tags)
+	})
+}
+
+type fakeBatchGetClient struct {
+	dynamodbiface.DynamoDBAPI
+	mutex sync.Mutex
+	calls int
+	// Keys returned as unprocessed on the first call
+	unprocessed int
+	// Range keys failing with an error
+	failing string
+}
+
+func (c *fakeBatchGetClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls++
+
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]*dynamodb.AttributeValue{},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}
+	for tableName, keys := range input.RequestItems {
+		if len(keys.Keys) > maxBatchGetItems {
+			return nil, fmt.Errorf("Too many keys: %d", len(keys.Keys))
+		}
+		for _, key := range keys.Keys {
+			if *key["range"].S == c.failing {
+				return nil, fmt.Errorf("Throttled")
+			}
+		}
+
+		processed := keys.Keys
+		if c.unprocessed > 0 {
+			output.UnprocessedKeys[tableName] = &dynamodb.KeysAndAttributes{Keys: keys.Keys[:c.unprocessed]}
+			processed = keys.Keys[c.unprocessed:]
+			c.unprocessed = 0
+		}
+		for _, key := range processed {
+			output.Responses[tableName] = append(output.Responses[tableName], key)
+		}
+	}
+
+	return output, nil
+}
+
+func TestGetMany(t *testing.T) {
+	bookmarkIds := make([]string, 250)
+	for i := range bookmarkIds {
+		bookmarkIds[i] = fmt.Sprintf("%03d", i)
+	}
+
+	t.Run("ChunksAndRetriesUnprocessedKeys", func(t *testing.T) {
+		client := &fakeBatchGetClient{unprocessed: 10}
+		r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+
+		result, err := r.getMany(context.Background(), "1", bookmarkIds)
+		assert.Nil(t, err)
+		assert.Len(t, result, 250)
+		assert.Equal(t, 4, client.calls)
+	})
+
+	t.Run("ReportsFailedChunk", func(t *testing.T) {
+		client := &fakeBatchGetClient{failing: "BOOKMARK_150"}
+		r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+
+		result, err := r.getMany(context.Background(), "1", bookmarkIds)
+		partialErr, ok := errors.IsPartial(err)
+		assert.True(t, ok)
+		assert.Len(t, partialErr.Failed, 100)
+		assert.Contains(t, partialErr.Failed, "150")
+		assert.Len(t, result, 150)
+	})
+}
+
+type fakeQueryClient struct {
+	dynamodbiface.DynamoDBAPI
+	items []interface{}
+}
+
+func (c *fakeQueryClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	output := &dynamodb.QueryOutput{}
+	for _, item := range c.items {
+		av, err := dynamo.MarshalItem(item)
+		if err != nil {
+			return nil, err
+		}
+		output.Items = append(output.Items, av)
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	return output, nil
+}
+
+func TestSearchByNameUsesProjection(t *testing.T) {
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://golang.org", Tags: []string{"go"}, UpdatedAt: time.Now(), Version: 2}
+	client := &fakeQueryClient{items: []interface{}{bookmark.GetSearchByName()}}
+	r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+
+	// Fetching the bookmark would fail as the fake client does not support BatchGetItem
+	result, err := r.SearchByName(context.Background(), "1", "Go")
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "2", result[0].GetBookmarkId())
+	assert.Equal(t, bookmark.Url, result[0].Url)
+	assert.Equal(t, bookmark.Tags, result[0].Tags)
+	assert.Equal(t, bookmark.Version, result[0].Version)
+}
+
+func TestSearchByTagMatchesExactTag(t *testing.T) {
+	goBookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, UpdatedAt: time.Now(), Version: 1}
+	goLangBookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_3", Name: "Private", Url: "https://example.com", Tags: []string{"go_lang"}, UpdatedAt: time.Now(), Version: 1}
+	golangBookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_4", Name: "Golang", Url: "https://golang.org", Tags: []string{"golang"}, UpdatedAt: time.Now(), Version: 1}
+	// Index item left behind after the tag was removed
+	staleTag := goLangBookmark.GetSearchByTag()[0]
+	staleTag.Tag = "TAG_go_5"
+
+	var items []interface{}
+	for _, bookmark := range []entity.Bookmark{goBookmark, goLangBookmark, golangBookmark} {
+		for _, searchByTag := range bookmark.GetSearchByTag() {
+			items = append(items, searchByTag)
+		}
+	}
+	items = append(items, staleTag)
+	r := &repository{db: dynamo.NewFromIface(newFakeRangeQueryClient(items...)), logger: logger.NewLogger()}
+
+	result, err := r.SearchByTag(context.Background(), "1", "go")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, bookmarkIdsOf(result))
+}
+
+func TestRepairDetectsDrift(t *testing.T) {
+	updatedAt := time.Now().UTC()
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, UpdatedAt: updatedAt, Version: 3}
+	withoutIndex := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_5", Name: "Rust", Url: "https://rust-lang.org", UpdatedAt: updatedAt, Version: 1}
+
+	staleTag := bookmark.GetSearchByTag()[0]
+	staleTag.Url = "https://golang.org"
+	staleTag.Version = 2
+
+	removedTag := entity.NewBookmarkSearchByTag("1", "2", "old")
+	deletedBookmarkName := entity.BookmarkSearchByName{Username: "USERNAME_1", Name: "NAME_Java_3"}
+
+	client := &fakeQueryClient{items: []interface{}{
+		bookmark,
+		withoutIndex,
+		bookmark.GetSearchByName(),
+		staleTag,
+		removedTag,
+		deletedBookmarkName,
+	}}
+	r := &repairer{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+
+	report, err := r.Repair(context.Background(), "1", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Bookmarks)
+	// Both bookmarks have legacy IDs, so they also miss SearchByCreated
+	assert.Equal(t, 3, report.Missing)
+	assert.Equal(t, 1, report.Stale)
+	assert.Equal(t, 2, report.Orphaned)
+	assert.False(t, report.Fixed)
+}
+
+// Keeps items of a single hash key sorted by range key and answers range queries
+type fakeRangeQueryClient struct {
+	dynamodbiface.DynamoDBAPI
+	items       []map[string]*dynamodb.AttributeValue
+	batchWrites int
+}
+
+func newFakeRangeQueryClient(items ...interface{}) *fakeRangeQueryClient {
+	client := &fakeRangeQueryClient{}
+	for _, item := range items {
+		av, _ := dynamo.MarshalItem(item)
+		client.items = append(client.items, av)
+	}
+	sort.Slice(client.items, func(i, j int) bool {
+		return *client.items[i]["range"].S < *client.items[j]["range"].S
+	})
+	return client
+}
+
+func (c *fakeRangeQueryClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	condition := input.KeyConditions["range"]
+	matches := func(rangeKey string) bool {
+		values := condition.AttributeValueList
+		switch *condition.ComparisonOperator {
+		case "BETWEEN":
+			return rangeKey >= *values[0].S && rangeKey <= *values[1].S
+		case "BEGINS_WITH":
+			return strings.HasPrefix(rangeKey, *values[0].S)
+		}
+		return false
+	}
+
+	items := append([]map[string]*dynamodb.AttributeValue{}, c.items...)
+	if input.ScanIndexForward != nil && !*input.ScanIndexForward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	output := &dynamodb.QueryOutput{}
+	started := input.ExclusiveStartKey == nil
+	for _, item := range items {
+		rangeKey := *item["range"].S
+		if !started {
+			started = rangeKey == *input.ExclusiveStartKey["range"].S
+			continue
+		}
+		if !matches(rangeKey) {
+			continue
+		}
+
+		output.Items = append(output.Items, item)
+		if input.Limit != nil && int64(len(output.Items)) == *input.Limit {
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": item["id"], "range": item["range"]}
+			break
+		}
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	return output, nil
+}
+
+func (c *fakeRangeQueryClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	for _, item := range c.items {
+		if *item["range"].S == *input.Key["range"].S {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+// Writes items of a transaction, conditions are not evaluated
+func (c *fakeRangeQueryClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	if len(input.TransactItems) > maxTransactionItems {
+		return nil, fmt.Errorf("Too many items: %d", len(input.TransactItems))
+	}
+	for _, item := range input.TransactItems {
+		switch {
+		case item.Put != nil:
+			c.delete(*item.Put.Item["range"].S)
+			c.put(item.Put.Item)
+		case item.Delete != nil:
+			c.delete(*item.Delete.Key["range"].S)
+		case item.Update != nil:
+			updated := map[string]*dynamodb.AttributeValue{"id": item.Update.Key["id"], "range": item.Update.Key["range"]}
+			if output, _ := c.GetItemWithContext(ctx, &dynamodb.GetItemInput{Key: item.Update.Key}); output.Item != nil {
+				updated = output.Item
+			}
+			// Only plain assignments like SET #a = :b are supported
+			for _, assignment := range strings.Split(strings.TrimPrefix(*item.Update.UpdateExpression, "SET "), ", ") {
+				parts := strings.SplitN(assignment, " = ", 2)
+				name := parts[0]
+				if alias, ok := item.Update.ExpressionAttributeNames[name]; ok {
+					name = *alias
+				}
+				updated[name] = item.Update.ExpressionAttributeValues[parts[1]]
+			}
+			c.delete(*item.Update.Key["range"].S)
+			c.put(updated)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (c *fakeRangeQueryClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	c.delete(*input.Item["range"].S)
+	c.put(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *fakeRangeQueryClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	for tableName, keys := range input.RequestItems {
+		for _, key := range keys.Keys {
+			if item, _ := c.GetItemWithContext(ctx, &dynamodb.GetItemInput{Key: key}); item.Item != nil {
+				output.Responses[tableName] = append(output.Responses[tableName], item.Item)
+			}
+		}
+	}
+	return output, nil
+}
+
+func (c *fakeRangeQueryClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	c.batchWrites++
+	for _, requests := range input.RequestItems {
+		if len(requests) > maxBatchWriteItems {
+			return nil, fmt.Errorf("Too many items: %d", len(requests))
+		}
+		for _, writeRequest := range requests {
+			if writeRequest.PutRequest != nil {
+				c.delete(*writeRequest.PutRequest.Item["range"].S)
+				c.put(writeRequest.PutRequest.Item)
+			} else {
+				c.delete(*writeRequest.DeleteRequest.Key["range"].S)
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (c *fakeRangeQueryClient) put(item map[string]*dynamodb.AttributeValue) {
+	c.items = append(c.items, item)
+	sort.Slice(c.items, func(i, j int) bool {
+		return *c.items[i]["range"].S < *c.items[j]["range"].S
+	})
+}
+
+func (c *fakeRangeQueryClient) delete(rangeKey string) {
+	for i, item := range c.items {
+		if *item["range"].S == rangeKey {
+			c.items = append(c.items[:i], c.items[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	var items []interface{}
+	var sortableIds []string
+	for i := 0; i < 3; i++ {
+		bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_" + db.GenerateID(), Name: fmt.Sprintf("New %d", i), UpdatedAt: time.Now(), Version: 1}
+		sortableIds = append(sortableIds, bookmark.GetBookmarkId())
+		items = append(items, bookmark, bookmark.GetSearchByName())
+	}
+	legacyIds := []string{"8f14e45f-ceea-467f-a0e6-3f1b1a0a1a01", "0a5b3c1d-2e3f-4a5b-8c6d-7e8f9a0b1c2d"}
+	for i, legacyId := range legacyIds {
+		bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_" + legacyId, Name: fmt.Sprintf("Old %d", i), CreatedAt: time.Date(2019, time.March, i+1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Now(), Version: 1}
+		items = append(items, bookmark, bookmark.GetSearchByCreated())
+	}
+
+	r := &repository{db: dynamo.NewFromIface(newFakeRangeQueryClient(items...)), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	page, cursor, err := r.List(ctx, "1", 2, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sortableIds[2], sortableIds[1]}, bookmarkIdsOf(page))
+	assert.NotEmpty(t, cursor)
+
+	page, cursor, err = r.List(ctx, "1", 2, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sortableIds[0], legacyIds[1]}, bookmarkIdsOf(page))
+	assert.NotEmpty(t, cursor)
+
+	page, cursor, err = r.List(ctx, "1", 2, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{legacyIds[0]}, bookmarkIdsOf(page))
+	assert.Empty(t, cursor)
+
+	_, _, err = r.List(ctx, "1", 2, "not a cursor")
+	assert.Equal(t, errors.ErrInvalidParam, err)
+}
+
+func TestListSkipsLegacyIDsInRange(t *testing.T) {
+	sortableBookmark := func(i int) entity.Bookmark {
+		time.Sleep(2 * time.Millisecond)
+		return entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_" + db.GenerateID(), Name: fmt.Sprintf("New %d", i), UpdatedAt: time.Now(), Version: 1}
+	}
+	first, second := sortableBookmark(0), sortableBookmark(1)
+	time.Sleep(2 * time.Millisecond)
+	// Random UUIDv4 which happens to sort between the sortable IDs
+	inRange := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_" + db.SortableIDPrefix(time.Now()) + "-4000-8000-000000000001", Name: "Old in range", CreatedAt: time.Date(2019, time.March, 2, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Now(), Version: 1}
+	third := sortableBookmark(2)
+	legacy := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_8f14e45f-ceea-467f-a0e6-3f1b1a0a1a01", Name: "Old", CreatedAt: time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Now(), Version: 1}
+
+	client := newFakeRangeQueryClient(first, second, third, inRange, inRange.GetSearchByCreated(), legacy, legacy.GetSearchByCreated())
+	r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	page, cursor, err := r.List(ctx, "1", 2, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{third.GetBookmarkId(), second.GetBookmarkId()}, bookmarkIdsOf(page))
+
+	page, cursor, err = r.List(ctx, "1", 2, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{first.GetBookmarkId(), inRange.GetBookmarkId()}, bookmarkIdsOf(page))
+
+	page, cursor, err = r.List(ctx, "1", 2, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{legacy.GetBookmarkId()}, bookmarkIdsOf(page))
+	assert.Empty(t, cursor)
+}
+
+func TestUpdateStampsFieldClocks(t *testing.T) {
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, UpdatedAt: time.Now().Add(-time.Hour), Version: 1}
+	r := &repository{db: dynamo.NewFromIface(newFakeRangeQueryClient(bookmark)), logger: logger.NewLogger()}
+	ctx := context.Background()
+	editedAt := time.Now()
+
+	updated, err := r.Update(ctx, entity.Bookmark{Username: "1", ID: "2", Name: "Golang"})
+	assert.Nil(t, err)
+
+	saved, err := r.Get(ctx, "1", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, saved.Version)
+	clock, ok := saved.GetFieldClock(entity.FieldName)
+	assert.True(t, ok)
+	assert.Equal(t, 2, clock.Version)
+	assert.True(t, updated.UpdatedAt.Equal(clock.UpdatedAt))
+	_, ok = saved.GetFieldClock(entity.FieldUrl)
+	assert.False(t, ok)
+
+	// Edited offline against version 1 before the update above
+	name := "Go language"
+	results, err := r.Push(ctx, "1", []entity.BookmarkMutation{{
+		Type:        entity.OperationUpdate,
+		BookmarkID:  "2",
+		BaseVersion: 1,
+		Name:        &name,
+		ModifiedAt:  editedAt.Add(-time.Minute),
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.True(t, results[0].Merged)
+	assert.Equal(t, []entity.FieldConflict{{
+		Field:       entity.FieldName,
+		ClientValue: name,
+		ServerValue: "Golang",
+		Resolution:  entity.ResolutionServer,
+	}}, results[0].Conflicts)
+
+	saved, err = r.Get(ctx, "1", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "Golang", saved.Name)
+}
+
+func TestUpdateBookmarkWithManyTags(t *testing.T) {
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", UpdatedAt: time.Now(), Version: 1}
+	for i := 0; i < 30; i++ {
+		bookmark.Tags = append(bookmark.Tags, fmt.Sprintf("tag%d", i))
+	}
+	items := []interface{}{bookmark, bookmark.GetSearchByName()}
+	for _, searchByTag := range bookmark.GetSearchByTag() {
+		items = append(items, searchByTag)
+	}
+	r := &repository{db: dynamo.NewFromIface(newFakeRangeQueryClient(items...)), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	_, err := r.Update(ctx, entity.Bookmark{Username: "1", ID: "2", Name: "Golang"})
+	assert.Nil(t, err)
+	err = r.AddTag(ctx, "1", "2", "lang")
+	assert.Nil(t, err)
+
+	for _, tag := range []string{"tag0", "tag29", "lang"} {
+		found, err := r.SearchByTag(ctx, "1", tag)
+		assert.Nil(t, err)
+		assert.Len(t, found, 1, tag)
+		assert.Equal(t, "Golang", found[0].Name, tag)
+		assert.Equal(t, 3, found[0].Version, tag)
+	}
+}
+
+func TestBatchWritesIndexesInChunks(t *testing.T) {
+	client := newFakeRangeQueryClient()
+	r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	bookmark := entity.Bookmark{Name: "Go", Url: "https://go.dev"}
+	for i := 0; i < 30; i++ {
+		bookmark.Tags = append(bookmark.Tags, fmt.Sprintf("tag%d", i))
+	}
+	create := entity.BookmarkOperation{Type: entity.OperationCreate, Bookmark: bookmark}
+
+	_, err := r.Batch(ctx, "1", []entity.BookmarkOperation{create}, true)
+	assert.Equal(t, errors.ErrTooManyItems, err)
+
+	results, err := r.Batch(ctx, "1", []entity.BookmarkOperation{create}, false)
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	// Name and 30 tags
+	assert.Equal(t, 2, client.batchWrites)
+
+	bookmarkId := results[0].Bookmark.GetBookmarkId()
+	for _, tag := range []string{"tag0", "tag29"} {
+		found, err := r.SearchByTag(ctx, "1", tag)
+		assert.Nil(t, err)
+		assert.Len(t, found, 1, tag)
+		assert.Equal(t, bookmarkId, found[0].GetBookmarkId(), tag)
+	}
+
+	// Renamed and deleted in the same batch, the index items end up deleted
+	rename := entity.BookmarkOperation{Type: entity.OperationUpdate, Bookmark: entity.Bookmark{ID: bookmarkId, Name: "Golang"}}
+	remove := entity.BookmarkOperation{Type: entity.OperationDelete, Bookmark: entity.Bookmark{ID: bookmarkId}}
+	results, err = r.Batch(ctx, "1", []entity.BookmarkOperation{rename, remove}, false)
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	found, err := r.SearchByTag(ctx, "1", "tag0")
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	found, err = r.SearchByName(ctx, "1", "Go")
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+}
+
+func TestPushReplaysCreate(t *testing.T) {
+	client := newFakeRangeQueryClient()
+	r := &repository{db: dynamo.NewFromIface(client), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	name, url := "Go", "https://go.dev"
+	create := entity.BookmarkMutation{Type: entity.OperationCreate, ClientID: "local-1", Name: &name, Url: &url, Tags: []string{"go"}}
+	results, err := r.Push(ctx, "1", []entity.BookmarkMutation{create, create})
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	bookmarkId := results[0].Bookmark.GetBookmarkId()
+	assert.NotEmpty(t, bookmarkId)
+	assert.Equal(t, bookmarkId, results[1].Bookmark.GetBookmarkId())
+	assert.True(t, results[0].Applied)
+	assert.False(t, results[1].Applied)
+
+	// The response of the first push got lost
+	results, err = r.Push(ctx, "1", []entity.BookmarkMutation{create})
+	assert.Nil(t, err)
+	assert.Equal(t, bookmarkId, results[0].Bookmark.GetBookmarkId())
+
+	bookmarks, _, err := r.List(ctx, "1", 10, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{bookmarkId}, bookmarkIdsOf(bookmarks))
+
+	// Creates without a client ID are not deduplicated
+	create.ClientID = ""
+	results, err = r.Push(ctx, "1", []entity.BookmarkMutation{create})
+	assert.Nil(t, err)
+	assert.NotEqual(t, bookmarkId, results[0].Bookmark.GetBookmarkId())
+
+	err = r.Delete(ctx, "1", bookmarkId, 0)
+	assert.Nil(t, err)
+	create.ClientID = "local-1"
+	results, err = r.Push(ctx, "1", []entity.BookmarkMutation{create})
+	assert.Nil(t, err)
+	assert.True(t, results[0].Deleted)
+}
+
+func TestChanges(t *testing.T) {
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go"}, UpdatedAt: time.Now(), Version: 2}
+	hashId, rangeId := entity.GetSequenceKey("1")
+	items := []interface{}{
+		bookmark,
+		entity.ChangeSequence{Username: hashId, Range: rangeId, Sequence: 3},
+		// The first change has expired
+		entity.NewBookmarkChange("1", 2, entity.BookmarkOperation{Type: entity.OperationAddTag, Tag: "go"}, &bookmark, &bookmark),
+		entity.NewBookmarkChange("1", 3, entity.BookmarkOperation{Type: entity.OperationDelete}, &bookmark, nil),
+	}
+
+	r := &repository{db: dynamo.NewFromIface(newFakeRangeQueryClient(items...)), logger: logger.NewLogger()}
+	ctx := context.Background()
+
+	changes, sequence, err := r.Changes(ctx, "1", 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), sequence)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, entity.OperationAddTag, changes[0].Type)
+	assert.Equal(t, "2", changes[0].BookmarkID)
+	assert.Equal(t, bookmark.Url, changes[0].GetBookmark().Url)
+	assert.True(t, changes[1].Deleted)
+
+	changes, _, err = r.Changes(ctx, "1", 2, 1)
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, int64(3), changes[0].Sequence)
+
+	changes, _, err = r.Changes(ctx, "1", 3, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+
+	_, _, err = r.Changes(ctx, "1", 0, 10)
+	assert.Equal(t, errors.ErrExpired, err)
+
+	_, _, err = r.Changes(ctx, "1", 4, 10)
+	assert.Equal(t, errors.ErrInvalidParam, err)
+}
+
+func bookmarkIdsOf(bookmarks []entity.Bookmark) []string {
+	ids := make([]string, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		ids[i] = bookmark.GetBookmarkId()
+	}
+	return ids
+}
@@ -2,7 +2,10 @@ package bookmark
 
 import (
 	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
 	"context"
+	"time"
 
 	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
@@ -12,18 +15,31 @@ type Service interface {
 	Create(ctx context.Context, bookmark Bookmark) (Bookmark, error)
 	Get(ctx context.Context, username, bookmarkId string) (Bookmark, error)
 	Update(ctx context.Context, bookmark Bookmark) (Bookmark, error)
-	Delete(ctx context.Context, username, bookmarkId string) error
+	Delete(ctx context.Context, username, bookmarkId string, version int) error
 	SearchByName(ctx context.Context, username, name string) ([]Bookmark, error)
+	SearchByTag(ctx context.Context, username, tag string) ([]Bookmark, error)
+	// Returns the bookmarks in the order of the IDs, missing ones are skipped
+	GetMany(ctx context.Context, username string, bookmarkIds []string) ([]Bookmark, error)
+	List(ctx context.Context, username string, limit int, cursor string) ([]Bookmark, string, error)
 	AddTag(ctx context.Context, username, bookmarkId, tag string) error
 	RemoveTag(ctx context.Context, username, bookmarkId, tag string) error
+	Batch(ctx context.Context, username string, operations []BatchOperation, atomic bool) ([]BatchResult, error)
+	// Merges edits made offline, conflicts are reported in the results
+	Push(ctx context.Context, username string, mutations []Mutation) ([]MutationResult, error)
+	// Returns the changes after the sequence number since, errors.ErrExpired when the client has to resync.
+	// The sequence number of the change set is the one to resync from then
+	Changes(ctx context.Context, username string, since int64, limit int) (ChangeSet, error)
 }
 
 type Bookmark struct {
-	ID       string
-	Username string
-	Name     string
-	Url      string
-	Tags     []string
+	ID       string   `json:"id"`
+	Username string   `json:"-"`
+	Name     string   `json:"name"`
+	Url      string   `json:"url"`
+	Tags     []string `json:"tags"`
+	Version  int      `json:"version"`
+	// Set by the repository, ignored on writes
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (b *Bookmark) getEntity() entity.Bookmark {
@@ -33,26 +49,90 @@ func (b *Bookmark) getEntity() entity.Bookmark {
 		Name:     b.Name,
 		Url:      b.Url,
 		Tags:     b.Tags,
+		Version:  b.Version,
 	}
 }
 
 func newBookmark(bookmark entity.Bookmark) Bookmark {
 	return Bookmark{
-		ID:       bookmark.GetBookmarkId(),
-		Username: bookmark.GetUsername(),
-		Name:     bookmark.Name,
-		Url:      bookmark.Url,
-		Tags:     bookmark.Tags,
+		ID:        bookmark.GetBookmarkId(),
+		Username:  bookmark.GetUsername(),
+		Name:      bookmark.Name,
+		Url:       bookmark.Url,
+		Tags:      bookmark.Tags,
+		Version:   bookmark.Version,
+		UpdatedAt: bookmark.UpdatedAt,
 	}
 }
 
+type BatchOperation struct {
+	Type      entity.OperationType
+	Bookmark  Bookmark
+	Tag       string
+	TargetTag string
+}
+
+type BatchResult struct {
+	Bookmark Bookmark
+	Err      error
+}
+
+// Edit made offline against the base version of the bookmark, nil fields are not changed
+type Mutation struct {
+	Type        entity.OperationType
+	ClientID    string
+	ID          string
+	BaseVersion int
+	Name        *string
+	Url         *string
+	Tags        []string
+	AddTags     []string
+	RemoveTags  []string
+	ModifiedAt  time.Time
+}
+
+type MutationResult struct {
+	Bookmark  Bookmark
+	Deleted   bool
+	Merged    bool
+	Conflicts []entity.FieldConflict
+	Err       error
+}
+
+type Change struct {
+	Sequence  int64
+	Type      entity.OperationType
+	Bookmark  Bookmark
+	Deleted   bool
+	Tag       string
+	TargetTag string
+	ChangedAt time.Time
+}
+
+type ChangeSet struct {
+	Changes []Change
+	// Sequence number to continue from
+	Sequence int64
+	// Sequence number of the last change of the user
+	Latest  int64
+	HasMore bool
+}
+
+// Data of the events published on the bus, Bookmark is nil for deletes and tag changes
+type BookmarkEvent struct {
+	ID       string    `json:"id"`
+	Bookmark *Bookmark `json:"bookmark,omitempty"`
+	Tag      string    `json:"tag,omitempty"`
+}
+
 type service struct {
 	repo   Repository
+	bus    event.Bus
 	logger *zap.Logger
 }
 
-func NewService(repo Repository, logger *zap.Logger) Service {
-	return &service{repo, logger}
+func NewService(repo Repository, bus event.Bus, logger *zap.Logger) Service {
+	return &service{repo, bus, logger}
 }
 
 func (s *service) Create(ctx context.Context, bookmark Bookmark) (Bookmark, error) {
@@ -68,7 +148,9 @@ func (s *service) Create(ctx context.Context, bookmark Bookmark) (Bookmark, erro
 		return Bookmark{}, err
 	}
 
-	return newBookmark(createdBookmark), nil
+	result := newBookmark(createdBookmark)
+	s.bus.Publish(bookmark.Username, event.BookmarkCreated, BookmarkEvent{ID: result.ID, Bookmark: &result})
+	return result, nil
 }
 
 func (s *service) Get(ctx context.Context, username, bookmarkId string) (Bookmark, error) {
@@ -98,21 +180,24 @@ func (s *service) Update(ctx context.Context, bookmark Bookmark) (Bookmark, erro
 		return Bookmark{}, err
 	}
 
-	return newBookmark(updatedBookmark), nil
+	result := newBookmark(updatedBookmark)
+	s.bus.Publish(bookmark.Username, event.BookmarkUpdated, BookmarkEvent{ID: result.ID, Bookmark: &result})
+	return result, nil
 }
 
-func (s *service) Delete(ctx context.Context, username, bookmarkId string) error {
+func (s *service) Delete(ctx context.Context, username, bookmarkId string, version int) error {
 	logger := s.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	err := s.repo.Delete(ctx, username, bookmarkId)
+	err := s.repo.Delete(ctx, username, bookmarkId, version)
 	if err != nil {
 		logger.Errorw("Failed to delete", zap.String("ID", bookmarkId))
 		return err
 	}
 
+	s.bus.Publish(username, event.BookmarkDeleted, BookmarkEvent{ID: bookmarkId})
 	return nil
 }
 
@@ -125,12 +210,70 @@ func (s *service) SearchByName(ctx context.Context, username, name string) ([]Bo
 	result, err := s.repo.SearchByName(ctx, username, name)
 	if err != nil {
 		logger.Errorw("Failed to search bookmark by name", zap.Error(err))
-		return []Bookmark{}, err
+		if _, ok := errors.IsPartial(err); !ok {
+			return []Bookmark{}, err
+		}
+	}
+
+	// Partial results are returned along with the error
+	return funk.Map(result, func(b entity.Bookmark) Bookmark {
+		return newBookmark(b)
+	}).([]Bookmark), err
+}
+
+func (s *service) SearchByTag(ctx context.Context, username, tag string) ([]Bookmark, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	result, err := s.repo.SearchByTag(ctx, username, tag)
+	if err != nil {
+		logger.Errorw("Failed to search bookmark by tag", zap.String("Tag", tag), zap.Error(err))
+		if _, ok := errors.IsPartial(err); !ok {
+			return []Bookmark{}, err
+		}
+	}
+
+	return funk.Map(result, func(b entity.Bookmark) Bookmark {
+		return newBookmark(b)
+	}).([]Bookmark), err
+}
+
+func (s *service) GetMany(ctx context.Context, username string, bookmarkIds []string) ([]Bookmark, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	result, err := s.repo.GetMany(ctx, username, bookmarkIds)
+	if err != nil {
+		logger.Errorw("Failed to fetch bookmarks", zap.Int("Count", len(bookmarkIds)), zap.Error(err))
+		if _, ok := errors.IsPartial(err); !ok {
+			return []Bookmark{}, err
+		}
+	}
+
+	return funk.Map(result, func(b entity.Bookmark) Bookmark {
+		return newBookmark(b)
+	}).([]Bookmark), err
+}
+
+func (s *service) List(ctx context.Context, username string, limit int, cursor string) ([]Bookmark, string, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	result, nextCursor, err := s.repo.List(ctx, username, limit, cursor)
+	if err != nil {
+		logger.Errorw("Failed to list bookmarks", zap.Error(err))
+		return []Bookmark{}, "", err
 	}
 
 	return funk.Map(result, func(b entity.Bookmark) Bookmark {
 		return newBookmark(b)
-	}).([]Bookmark), nil
+	}).([]Bookmark), nextCursor, nil
 }
 
 func (s *service) AddTag(ctx context.Context, username, bookmarkId, tag string) error {
@@ -145,6 +288,7 @@ func (s *service) AddTag(ctx context.Context, username, bookmarkId, tag string)
 		return err
 	}
 
+	s.bus.Publish(username, event.TagAdded, BookmarkEvent{ID: bookmarkId, Tag: tag})
 	return nil
 }
 
@@ -160,5 +304,139 @@ func (s *service) RemoveTag(ctx context.Context, username, bookmarkId, tag strin
 		return err
 	}
 
+	s.bus.Publish(username, event.TagRemoved, BookmarkEvent{ID: bookmarkId, Tag: tag})
 	return nil
 }
+
+func (s *service) Batch(ctx context.Context, username string, operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	entityOperations := funk.Map(operations, func(operation BatchOperation) entity.BookmarkOperation {
+		return entity.BookmarkOperation{
+			Type:      operation.Type,
+			Bookmark:  operation.Bookmark.getEntity(),
+			Tag:       operation.Tag,
+			TargetTag: operation.TargetTag,
+		}
+	}).([]entity.BookmarkOperation)
+
+	results, err := s.repo.Batch(ctx, username, entityOperations, atomic)
+	if err != nil {
+		logger.Errorw("Failed to run batch", zap.Int("Count", len(operations)), zap.Error(err))
+		return []BatchResult{}, err
+	}
+
+	for i, result := range results {
+		if result.Err == nil {
+			eventType, data := newOperationEvent(operations[i].Type, newBookmark(result.Bookmark), operations[i].Tag)
+			s.bus.Publish(username, eventType, data)
+		}
+	}
+
+	return funk.Map(results, func(result entity.BookmarkOperationResult) BatchResult {
+		return BatchResult{
+			Bookmark: newBookmark(result.Bookmark),
+			Err:      result.Err,
+		}
+	}).([]BatchResult), nil
+}
+
+func (s *service) Push(ctx context.Context, username string, mutations []Mutation) ([]MutationResult, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	entityMutations := funk.Map(mutations, func(mutation Mutation) entity.BookmarkMutation {
+		return entity.BookmarkMutation{
+			Type:        mutation.Type,
+			ClientID:    mutation.ClientID,
+			BookmarkID:  mutation.ID,
+			BaseVersion: mutation.BaseVersion,
+			Name:        mutation.Name,
+			Url:         mutation.Url,
+			Tags:        mutation.Tags,
+			AddTags:     mutation.AddTags,
+			RemoveTags:  mutation.RemoveTags,
+			ModifiedAt:  mutation.ModifiedAt,
+		}
+	}).([]entity.BookmarkMutation)
+
+	results, err := s.repo.Push(ctx, username, entityMutations)
+	if err != nil {
+		logger.Errorw("Failed to push mutations", zap.Int("Count", len(mutations)), zap.Error(err))
+		return []MutationResult{}, err
+	}
+
+	for i, result := range results {
+		if result.Applied {
+			eventType, data := newOperationEvent(mutations[i].Type, newBookmark(result.Bookmark), "")
+			s.bus.Publish(username, eventType, data)
+		}
+	}
+
+	return funk.Map(results, func(result entity.BookmarkMutationResult) MutationResult {
+		return MutationResult{
+			Bookmark:  newBookmark(result.Bookmark),
+			Deleted:   result.Deleted,
+			Merged:    result.Merged,
+			Conflicts: result.Conflicts,
+			Err:       result.Err,
+		}
+	}).([]MutationResult), nil
+}
+
+func (s *service) Changes(ctx context.Context, username string, since int64, limit int) (ChangeSet, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	result, sequence, err := s.repo.Changes(ctx, username, since, limit)
+	if err == errors.ErrExpired {
+		// Listing all bookmarks again catches up to the current sequence number
+		return ChangeSet{Changes: []Change{}, Sequence: sequence, Latest: sequence}, err
+	}
+	if err != nil {
+		logger.Errorw("Failed to get changes", zap.Int64("Since", since), zap.Error(err))
+		return ChangeSet{}, err
+	}
+
+	changeSet := ChangeSet{Changes: []Change{}, Sequence: since}
+	for _, change := range result {
+		changeSet.Changes = append(changeSet.Changes, Change{
+			Sequence:  change.Sequence,
+			Type:      change.Type,
+			Bookmark:  newBookmark(change.GetBookmark()),
+			Deleted:   change.Deleted,
+			Tag:       change.Tag,
+			TargetTag: change.TargetTag,
+			ChangedAt: change.ChangedAt,
+		})
+		changeSet.Sequence = change.Sequence
+	}
+	changeSet.Latest = sequence
+	changeSet.HasMore = changeSet.Sequence < sequence
+
+	return changeSet, nil
+}
+
+// Returns the event published for an operation, bookmark is the state after it or the deleted one
+func newOperationEvent(operationType entity.OperationType, bookmark Bookmark, tag string) (string, BookmarkEvent) {
+	switch operationType {
+	case entity.OperationCreate:
+		return event.BookmarkCreated, BookmarkEvent{ID: bookmark.ID, Bookmark: &bookmark}
+	case entity.OperationDelete:
+		return event.BookmarkDeleted, BookmarkEvent{ID: bookmark.ID}
+	case entity.OperationAddTag:
+		return event.TagAdded, BookmarkEvent{ID: bookmark.ID, Tag: tag}
+	case entity.OperationRemoveTag:
+		return event.TagRemoved, BookmarkEvent{ID: bookmark.ID, Tag: tag}
+	default:
+		// Updates, and moves from one tag to another
+		return event.BookmarkUpdated, BookmarkEvent{ID: bookmark.ID, Bookmark: &bookmark}
+	}
+}
@@ -3,6 +3,8 @@ package bookmark
 import (
 	"bookmark-api/internal/bookmark/mocks"
 	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
 	"bookmark-api/pkg/logger"
 	"context"
 	"testing"
@@ -17,7 +19,7 @@ func TestService_CreateBookmark(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	s := NewService(mockRepository, logger.NewLogger())
+	s := NewService(mockRepository, event.NewBus(logger.NewLogger()), logger.NewLogger())
 
 	bookmark := entity.Bookmark{
 		ID:        "ID",
@@ -35,3 +37,81 @@ func TestService_CreateBookmark(t *testing.T) {
 	assert.NotNil(t, createdBookmark)
 	assert.NotEmpty(t, createdBookmark.ID)
 }
+
+func TestService_BatchPublishesEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(logger.NewLogger())
+	s := NewService(mockRepository, bus, logger.NewLogger())
+
+	subscription := bus.Subscribe("1", "")
+	defer subscription.Close()
+
+	bookmark := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Tags: []string{"go"}, Version: 2}
+	mockRepository.EXPECT().Batch(gomock.Any(), "1", gomock.Any(), false).Return([]entity.BookmarkOperationResult{
+		{Bookmark: bookmark},
+		{Err: errors.ErrNotFound},
+		{Bookmark: bookmark},
+		{Bookmark: bookmark},
+	}, nil).Times(1)
+
+	_, err := s.Batch(context.Background(), "1", []BatchOperation{
+		{Type: entity.OperationUpdate, Bookmark: Bookmark{ID: "2", Name: "Go"}},
+		{Type: entity.OperationDelete, Bookmark: Bookmark{ID: "3"}},
+		{Type: entity.OperationAddTag, Bookmark: Bookmark{ID: "2"}, Tag: "go"},
+		{Type: entity.OperationDelete, Bookmark: Bookmark{ID: "2"}},
+	}, false)
+	assert.Nil(t, err)
+
+	// The failed operation publishes nothing
+	expected := []struct {
+		eventType string
+		tag       string
+	}{{event.BookmarkUpdated, ""}, {event.TagAdded, "go"}, {event.BookmarkDeleted, ""}}
+	for _, e := range expected {
+		published := <-subscription.Events
+		assert.Equal(t, e.eventType, published.Type)
+		assert.Equal(t, "2", published.Data.(BookmarkEvent).ID)
+		assert.Equal(t, e.tag, published.Data.(BookmarkEvent).Tag)
+	}
+	assert.Len(t, subscription.Events, 0)
+}
+
+func TestService_PushPublishesEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	bus := event.NewBus(logger.NewLogger())
+	s := NewService(mockRepository, bus, logger.NewLogger())
+
+	subscription := bus.Subscribe("1", "")
+	defer subscription.Close()
+
+	created := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_4", Name: "Go", Version: 1}
+	current := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Version: 3}
+	mockRepository.EXPECT().Push(gomock.Any(), "1", gomock.Any()).Return([]entity.BookmarkMutationResult{
+		{Bookmark: created, Applied: true},
+		// Lost the conflict, nothing is written
+		{Bookmark: current, Merged: true},
+		{Bookmark: current, Deleted: true, Applied: true},
+	}, nil).Times(1)
+
+	name := "Go"
+	_, err := s.Push(context.Background(), "1", []Mutation{
+		{Type: entity.OperationCreate, Name: &name},
+		{Type: entity.OperationUpdate, ID: "2", Name: &name, BaseVersion: 1},
+		{Type: entity.OperationDelete, ID: "2", BaseVersion: 3},
+	})
+	assert.Nil(t, err)
+
+	published := <-subscription.Events
+	assert.Equal(t, event.BookmarkCreated, published.Type)
+	assert.Equal(t, "4", published.Data.(BookmarkEvent).Bookmark.ID)
+	published = <-subscription.Events
+	assert.Equal(t, event.BookmarkDeleted, published.Type)
+	assert.Equal(t, "2", published.Data.(BookmarkEvent).ID)
+	assert.Len(t, subscription.Events, 0)
+}
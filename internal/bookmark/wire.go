@@ -4,4 +4,4 @@ import (
 	"github.com/google/wire"
 )
 
-var Inject = wire.NewSet(NewApi, NewRepository, NewService)
+var Inject = wire.NewSet(NewApi, NewRepository, NewService, NewRepairer)
@@ -0,0 +1,15 @@
+package di
+
+import (
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/webhook"
+)
+
+// Events of the bookmark service are streamed to the open tabs and queued for the webhooks
+func NewEventBus(webhookService webhook.Service, logger *zap.Logger) event.Bus {
+	bus := event.NewBus(logger)
+	bus.Listen(webhookService.Notify)
+	return bus
+}
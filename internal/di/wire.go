@@ -3,15 +3,26 @@
 package di
 
 import (
+	"bookmark-api/internal/account"
+	"bookmark-api/internal/admin"
 	"bookmark-api/internal/auth"
 	"bookmark-api/internal/bookmark"
+	"bookmark-api/internal/feed"
+	"bookmark-api/internal/magiclink"
+	"bookmark-api/internal/password"
+	"bookmark-api/internal/refresh"
+	"bookmark-api/internal/share"
+	"bookmark-api/internal/token"
 	"bookmark-api/internal/user"
+	"bookmark-api/internal/webhook"
+	"bookmark-api/internal/workspace"
 	"bookmark-api/pkg/logger"
+	"bookmark-api/pkg/mailer"
 
 	"github.com/google/wire"
 )
 
-var inject = wire.NewSet(logger.Inject, bookmark.Inject, auth.Inject, user.Inject)
+var inject = wire.NewSet(logger.Inject, bookmark.Inject, auth.Inject, user.Inject, webhook.Inject, share.Inject, feed.Inject, workspace.Inject, token.Inject, password.Inject, magiclink.Inject, refresh.Inject, account.Inject, admin.Inject, mailer.Inject, wire.Bind(new(auth.APITokenVerifier), new(token.Service)), wire.Bind(new(auth.PasswordVerifier), new(password.Service)), wire.Bind(new(auth.MagicLinkVerifier), new(magiclink.Service)), wire.Bind(new(auth.RefreshTokenRotator), new(refresh.Service)), NewEventBus)
 var injectAuthorizer = wire.NewSet(logger.Inject, auth.Inject)
 
 func CreateBookmarkApi() (bookmark.Api, error) {
@@ -29,3 +40,55 @@ func CreateBookmarkService() (bookmark.Service, error) {
 func CreateAuth() (*auth.Auth, error) {
 	panic(wire.Build(inject))
 }
+
+func CreateBookmarkRepairer() (bookmark.Repairer, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateWebhookApi() (webhook.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateWebhookDispatcher() (webhook.Dispatcher, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateShareApi() (share.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateFeedApi() (feed.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateWorkspaceApi() (workspace.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateTokenApi() (token.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreatePasswordApi() (password.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreatePasswordService() (password.Service, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateMagicLinkApi() (magiclink.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateAccountApi() (account.Api, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateAccountService() (account.Service, error) {
+	panic(wire.Build(inject))
+}
+
+func CreateAdminApi() (admin.Api, error) {
+	panic(wire.Build(inject))
+}
@@ -6,10 +6,21 @@
 package di
 
 import (
+	"bookmark-api/internal/account"
+	"bookmark-api/internal/admin"
 	"bookmark-api/internal/auth"
 	"bookmark-api/internal/bookmark"
+	"bookmark-api/internal/feed"
+	"bookmark-api/internal/magiclink"
+	"bookmark-api/internal/password"
+	"bookmark-api/internal/refresh"
+	"bookmark-api/internal/share"
+	"bookmark-api/internal/token"
 	"bookmark-api/internal/user"
+	"bookmark-api/internal/webhook"
+	"bookmark-api/internal/workspace"
 	"bookmark-api/pkg/logger"
+	"bookmark-api/pkg/mailer"
 	"github.com/google/wire"
 )
 
@@ -18,17 +29,33 @@ import (
 func CreateBookmarkApi() (bookmark.Api, error) {
 	zapLogger := logger.NewLogger()
 	repository := bookmark.NewRepository(zapLogger)
-	service := bookmark.NewService(repository, zapLogger)
-	api := bookmark.NewApi(service, zapLogger)
+	webhookRepository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	service := webhook.NewService(webhookRepository, queue, zapLogger)
+	bus := NewEventBus(service, zapLogger)
+	bookmarkService := bookmark.NewService(repository, bus, zapLogger)
+	api := bookmark.NewApi(bookmarkService, bus, zapLogger)
 	return api, nil
 }
 
 func CreateAuthApi() (auth.Api, error) {
 	googleOAuth := auth.NewGoogleOAuth()
+	gitHubOAuth := auth.NewGitHubOAuth()
+	oidcProvider := auth.NewOIDCProvider()
+	providers := auth.NewProviders(googleOAuth, gitHubOAuth, oidcProvider)
 	zapLogger := logger.NewLogger()
 	repository := user.NewRepository(zapLogger)
 	service := user.NewService(repository, zapLogger)
-	authAuth := auth.NewAuth(googleOAuth, service, zapLogger)
+	tokenRepository := token.NewRepository(zapLogger)
+	tokenService := token.NewService(tokenRepository, zapLogger)
+	passwordRepository := password.NewRepository(zapLogger)
+	passwordService := password.NewService(passwordRepository, zapLogger)
+	magiclinkRepository := magiclink.NewRepository(zapLogger)
+	mailerMailer := mailer.NewMailer()
+	magiclinkService := magiclink.NewService(magiclinkRepository, mailerMailer, zapLogger)
+	refreshRepository := refresh.NewRepository(zapLogger)
+	refreshService := refresh.NewService(refreshRepository, zapLogger)
+	authAuth := auth.NewAuth(providers, service, tokenService, passwordService, magiclinkService, refreshService, zapLogger)
 	api := auth.NewApi(authAuth, zapLogger)
 	return api, nil
 }
@@ -36,21 +63,167 @@ func CreateAuthApi() (auth.Api, error) {
 func CreateBookmarkService() (bookmark.Service, error) {
 	zapLogger := logger.NewLogger()
 	repository := bookmark.NewRepository(zapLogger)
-	service := bookmark.NewService(repository, zapLogger)
-	return service, nil
+	webhookRepository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	service := webhook.NewService(webhookRepository, queue, zapLogger)
+	bus := NewEventBus(service, zapLogger)
+	bookmarkService := bookmark.NewService(repository, bus, zapLogger)
+	return bookmarkService, nil
 }
 
 func CreateAuth() (*auth.Auth, error) {
 	googleOAuth := auth.NewGoogleOAuth()
+	gitHubOAuth := auth.NewGitHubOAuth()
+	oidcProvider := auth.NewOIDCProvider()
+	providers := auth.NewProviders(googleOAuth, gitHubOAuth, oidcProvider)
 	zapLogger := logger.NewLogger()
 	repository := user.NewRepository(zapLogger)
 	service := user.NewService(repository, zapLogger)
-	authAuth := auth.NewAuth(googleOAuth, service, zapLogger)
+	tokenRepository := token.NewRepository(zapLogger)
+	tokenService := token.NewService(tokenRepository, zapLogger)
+	passwordRepository := password.NewRepository(zapLogger)
+	passwordService := password.NewService(passwordRepository, zapLogger)
+	magiclinkRepository := magiclink.NewRepository(zapLogger)
+	mailerMailer := mailer.NewMailer()
+	magiclinkService := magiclink.NewService(magiclinkRepository, mailerMailer, zapLogger)
+	refreshRepository := refresh.NewRepository(zapLogger)
+	refreshService := refresh.NewService(refreshRepository, zapLogger)
+	authAuth := auth.NewAuth(providers, service, tokenService, passwordService, magiclinkService, refreshService, zapLogger)
 	return authAuth, nil
 }
 
+func CreateBookmarkRepairer() (bookmark.Repairer, error) {
+	zapLogger := logger.NewLogger()
+	repairer := bookmark.NewRepairer(zapLogger)
+	return repairer, nil
+}
+
+func CreateWebhookApi() (webhook.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	service := webhook.NewService(repository, queue, zapLogger)
+	api := webhook.NewApi(service, zapLogger)
+	return api, nil
+}
+
+func CreateWebhookDispatcher() (webhook.Dispatcher, error) {
+	zapLogger := logger.NewLogger()
+	repository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	dispatcher := webhook.NewDispatcher(repository, queue, zapLogger)
+	return dispatcher, nil
+}
+
+func CreateShareApi() (share.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := share.NewRepository(zapLogger)
+	bookmarkRepository := bookmark.NewRepository(zapLogger)
+	webhookRepository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	service := webhook.NewService(webhookRepository, queue, zapLogger)
+	bus := NewEventBus(service, zapLogger)
+	bookmarkService := bookmark.NewService(bookmarkRepository, bus, zapLogger)
+	shareService := share.NewService(repository, bookmarkService, zapLogger)
+	api := share.NewApi(shareService, zapLogger)
+	return api, nil
+}
+
+func CreateFeedApi() (feed.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := feed.NewRepository(zapLogger)
+	bookmarkRepository := bookmark.NewRepository(zapLogger)
+	webhookRepository := webhook.NewRepository(zapLogger)
+	queue := webhook.NewQueue(zapLogger)
+	service := webhook.NewService(webhookRepository, queue, zapLogger)
+	bus := NewEventBus(service, zapLogger)
+	bookmarkService := bookmark.NewService(bookmarkRepository, bus, zapLogger)
+	shareRepository := share.NewRepository(zapLogger)
+	shareService := share.NewService(shareRepository, bookmarkService, zapLogger)
+	feedService := feed.NewService(repository, bookmarkService, shareService, zapLogger)
+	api := feed.NewApi(feedService, zapLogger)
+	return api, nil
+}
+
+func CreateWorkspaceApi() (workspace.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := workspace.NewRepository(zapLogger)
+	userRepository := user.NewRepository(zapLogger)
+	service := user.NewService(userRepository, zapLogger)
+	workspaceService := workspace.NewService(repository, service, zapLogger)
+	api := workspace.NewApi(workspaceService, zapLogger)
+	return api, nil
+}
+
+func CreateTokenApi() (token.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := token.NewRepository(zapLogger)
+	service := token.NewService(repository, zapLogger)
+	api := token.NewApi(service, zapLogger)
+	return api, nil
+}
+
+func CreatePasswordApi() (password.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := password.NewRepository(zapLogger)
+	service := password.NewService(repository, zapLogger)
+	api := password.NewApi(service, zapLogger)
+	return api, nil
+}
+
+func CreatePasswordService() (password.Service, error) {
+	zapLogger := logger.NewLogger()
+	repository := password.NewRepository(zapLogger)
+	service := password.NewService(repository, zapLogger)
+	return service, nil
+}
+
+func CreateMagicLinkApi() (magiclink.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := magiclink.NewRepository(zapLogger)
+	mailerMailer := mailer.NewMailer()
+	service := magiclink.NewService(repository, mailerMailer, zapLogger)
+	api := magiclink.NewApi(service, zapLogger)
+	return api, nil
+}
+
+func CreateAccountApi() (account.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := account.NewRepository(zapLogger)
+	queue := account.NewQueue(zapLogger)
+	userRepository := user.NewRepository(zapLogger)
+	service := user.NewService(userRepository, zapLogger)
+	workspaceRepository := workspace.NewRepository(zapLogger)
+	workspaceService := workspace.NewService(workspaceRepository, service, zapLogger)
+	accountService := account.NewService(repository, queue, service, workspaceService, zapLogger)
+	api := account.NewApi(accountService, zapLogger)
+	return api, nil
+}
+
+func CreateAccountService() (account.Service, error) {
+	zapLogger := logger.NewLogger()
+	repository := account.NewRepository(zapLogger)
+	queue := account.NewQueue(zapLogger)
+	userRepository := user.NewRepository(zapLogger)
+	service := user.NewService(userRepository, zapLogger)
+	workspaceRepository := workspace.NewRepository(zapLogger)
+	workspaceService := workspace.NewService(workspaceRepository, service, zapLogger)
+	accountService := account.NewService(repository, queue, service, workspaceService, zapLogger)
+	return accountService, nil
+}
+
+func CreateAdminApi() (admin.Api, error) {
+	zapLogger := logger.NewLogger()
+	repository := admin.NewRepository(zapLogger)
+	userRepository := user.NewRepository(zapLogger)
+	service := user.NewService(userRepository, zapLogger)
+	adminService := admin.NewService(repository, service, zapLogger)
+	api := admin.NewApi(adminService, zapLogger)
+	return api, nil
+}
+
 // wire.go:
 
-var inject = wire.NewSet(logger.Inject, bookmark.Inject, auth.Inject, user.Inject)
+var inject = wire.NewSet(logger.Inject, bookmark.Inject, auth.Inject, user.Inject, webhook.Inject, share.Inject, feed.Inject, workspace.Inject, token.Inject, password.Inject, magiclink.Inject, refresh.Inject, account.Inject, admin.Inject, mailer.Inject, wire.Bind(new(auth.APITokenVerifier), new(token.Service)), wire.Bind(new(auth.PasswordVerifier), new(password.Service)), wire.Bind(new(auth.MagicLinkVerifier), new(magiclink.Service)), wire.Bind(new(auth.RefreshTokenRotator), new(refresh.Service)), NewEventBus)
 
 var injectAuthorizer = wire.NewSet(logger.Inject, auth.Inject)
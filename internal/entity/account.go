@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Progress of an account deletion
+const (
+	DeletionPending   = "pending"
+	DeletionRunning   = "running"
+	DeletionCompleted = "completed"
+)
+
+// Returns ID and Range keys of an account deletion
+func GetSearchKeyByAccountDeletion(deletionId string) (string, string) {
+	return fmt.Sprintf("ACCOUNT_DELETION_%s", deletionId), "ACCOUNT_DELETION"
+}
+
+// Deletion of the items of an account, run by the worker a page at a time. It is kept outside of the partitions it
+// deletes, so its progress can be looked up by its unguessable ID until it expires
+type AccountDeletion struct {
+	HashKey  string `json:"hash_key" dynamo:"id"`
+	RangeKey string `json:"range_key" dynamo:"range"`
+	ID       string `json:"deletion_id" dynamo:"deletion_id"`
+	UserID   string `json:"user_id" dynamo:"user_id"`
+	// Hash keys of the partitions to delete in order, the personal one and those of workspaces the user is the only
+	// member of
+	Partitions []string `json:"partitions" dynamo:"partitions"`
+	// Index of the partition being deleted
+	Partition    int       `json:"partition" dynamo:"partition"`
+	Status       string    `json:"status" dynamo:"status"`
+	DeletedItems int       `json:"deleted_items" dynamo:"deleted_items"`
+	RequestedAt  time.Time `json:"requested_at" dynamo:"requested_at"`
+	UpdatedAt    time.Time `json:"updated_at" dynamo:"updated_at"`
+	CompletedAt  time.Time `json:"completed_at" dynamo:"completed_at,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (d AccountDeletion) GetItem() AccountDeletion {
+	d.HashKey, d.RangeKey = GetSearchKeyByAccountDeletion(d.ID)
+	return d
+}
+
+// Message on the processing queue deleting the next page of an account
+type AccountMessage struct {
+	Type       string `json:"type"`
+	DeletionID string `json:"deletion_id"`
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Actions of admins recorded in the audit log
+const (
+	AuditListUsers   = "list_users"
+	AuditViewUser    = "view_user"
+	AuditDisableUser = "disable_user"
+	AuditEnableUser  = "enable_user"
+	AuditLogoutUser  = "logout_user"
+)
+
+// Outcomes of the actions in the audit log. Entries are pending until the action has been taken
+const (
+	AuditPending   = "pending"
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+// Returns ID and Range keys of an entry of the audit log. Entries share one partition, ordered by their UUIDv7
+func GetSearchKeyByAuditEntry(entryId string) (string, string) {
+	return "AUDIT", fmt.Sprintf("ENTRY_%s", entryId)
+}
+
+// Action an admin took on the users. Entries are never deleted, not even with the accounts they are about, and only
+// their outcome is set once the action has been taken. Entries recorded before there were outcomes have none
+type AuditEntry struct {
+	HashKey  string `json:"hash_key" dynamo:"id"`
+	RangeKey string `json:"range_key" dynamo:"range"`
+	ID       string `json:"entry_id" dynamo:"entry_id"`
+	// User ID of the admin
+	Actor  string `json:"actor" dynamo:"actor"`
+	Action string `json:"action" dynamo:"action"`
+	// User ID of the account acted on, empty when listing users
+	Target string `json:"target" dynamo:"target,omitempty"`
+	// Search of the users listed
+	Query   string `json:"query" dynamo:"query,omitempty"`
+	Outcome string `json:"outcome" dynamo:"outcome,omitempty"`
+	// Error the action failed with
+	Error     string    `json:"error" dynamo:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+}
+
+func (e AuditEntry) GetItem() AuditEntry {
+	e.HashKey, e.RangeKey = GetSearchKeyByAuditEntry(e.ID)
+	return e
+}
@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scopes of personal API tokens, each one grants everything the previous ones do
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeRanks = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+func IsScope(scope string) bool {
+	return scopeRanks[scope] > 0
+}
+
+// Reports whether any of the scopes grants the required one
+func HasScope(scopes []string, required string) bool {
+	for _, scope := range scopes {
+		if scopeRanks[scope] > 0 && scopeRanks[scope] >= scopeRanks[required] {
+			return true
+		}
+	}
+	return false
+}
+
+// API tokens are told apart from JWTs and feed tokens by their prefix
+const APITokenPrefix = "pat_"
+
+// Returns ID and Range keys of an API token under its owner, ID is empty to search all of them
+func GetSearchKeyByAPIToken(username, id string) (string, string) {
+	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("API_TOKEN_%s", id)
+}
+
+// Returns ID and Range keys of the copy of an API token looked up by its hash
+func GetSearchKeyByAPITokenHash(tokenHash string) (string, string) {
+	return fmt.Sprintf("API_TOKEN_%s", tokenHash), "API_TOKEN"
+}
+
+// Personal access token of a user for scripts. Only its hash is stored, once under the user to list and revoke it
+// and once under the hash to authenticate requests
+type APIToken struct {
+	HashKey  string `json:"hash_key" dynamo:"id"`
+	RangeKey string `json:"range_key" dynamo:"range"`
+	ID       string `json:"id" dynamo:"token_id"`
+	// User ID of the owner, without prefix
+	Owner string `json:"owner" dynamo:"owner"`
+	// Identity which created the token, missing from tokens created before there were user IDs which are owned by
+	// the username
+	Username string `json:"username" dynamo:"username,omitempty"`
+	// Sign in method of the owner when the token was created
+	Method    string    `json:"method" dynamo:"method"`
+	Name      string    `json:"name" dynamo:"name"`
+	Scopes    []string  `json:"scopes" dynamo:"scopes"`
+	TokenHash string    `json:"token_hash" dynamo:"token_hash"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+// Returns the copy of the token stored under the owner
+func (t APIToken) GetOwnerItem() APIToken {
+	t.HashKey, t.RangeKey = GetSearchKeyByAPIToken(t.Owner, t.ID)
+	return t
+}
+
+// Returns the copy of the token stored under its hash
+func (t APIToken) GetHashItem() APIToken {
+	t.HashKey, t.RangeKey = GetSearchKeyByAPITokenHash(t.TokenHash)
+	return t
+}
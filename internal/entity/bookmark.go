@@ -16,81 +16,152 @@ type Bookmark struct {
 	Tags      []string  `json:"tags" dynamo:"tags"`
 	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" dynamo:"updated_at"`
+	Version   int       `json:"version" dynamo:"version"`
+	// Clocks of the fields for merging offline edits, missing on bookmarks not changed since
+	Fields map[string]FieldClock `json:"fields" dynamo:"fields,omitempty"`
 }
 
+// Expected version of a bookmark created before versioning, it has no version attribute.
+// Zero as the expected version means any version.
+const NoVersion = -1
+
 // Returns ID and Range keys
 func GetSearchKeyByID(username, bookmarkId string) (string, string) {
-	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("BOOKMARK_%s", bookmarkId)
+	return GetPartitionKey(username), fmt.Sprintf("BOOKMARK_%s", bookmarkId)
 }
 
 // Returns ID and Range keys
 func GetSearchKeyByName(username, name string) (string, string) {
-	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("NAME_%s", name)
+	return GetPartitionKey(username), fmt.Sprintf("NAME_%s", name)
 }
 
 // Returns ID and Range keys
 func GetSearchKeyByTag(username, tag string) (string, string) {
-	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("TAG_%s", tag)
+	return GetPartitionKey(username), fmt.Sprintf("TAG_%s", tag)
+}
+
+// Returns ID and Range keys
+func GetSearchKeyByCreated(username string) (string, string) {
+	return GetPartitionKey(username), "CREATED_"
 }
 
 func (b *Bookmark) GetEntity() Bookmark {
 	return Bookmark{
-		Username:  fmt.Sprintf("USERNAME_%s", b.Username),
+		Username:  GetPartitionKey(b.Username),
 		ID:        fmt.Sprintf("BOOKMARK_%s", b.ID),
 		Name:      b.Name,
 		Url:       b.Url,
 		Tags:      b.Tags,
 		CreatedAt: b.CreatedAt,
 		UpdatedAt: b.UpdatedAt,
+		Version:   b.Version,
 	}
 }
 
 func (b *Bookmark) GetUsername() string {
-	if strings.HasPrefix(b.Username, "USERNAME_") {
-		return strings.Split(b.Username, "_")[1]
-	}
-
-	return b.Username
+	return GetPartitionOwner(b.Username)
 }
 
 func (b *Bookmark) GetBookmarkId() string {
-	if strings.HasPrefix(b.ID, "BOOKMARK_") {
-		return strings.Split(b.ID, "_")[1]
-	}
-
-	return b.ID
+	return strings.TrimPrefix(b.ID, "BOOKMARK_")
 }
 
 func (b *Bookmark) GetSearchByName() BookmarkSearchByName {
 	return BookmarkSearchByName{
-		Username: fmt.Sprintf("USERNAME_%s", b.Username),
-		Name:     fmt.Sprintf("NAME_%s_%s", b.Name, b.ID),
+		Username:           GetPartitionKey(b.GetUsername()),
+		Name:               fmt.Sprintf("NAME_%s_%s", b.Name, b.GetBookmarkId()),
+		BookmarkProjection: b.GetProjection(),
 	}
 }
 
 func (b *Bookmark) GetSearchByTag() []BookmarkSearchByTag {
 	return funk.Map(b.Tags, func(tag string) BookmarkSearchByTag {
-		return BookmarkSearchByTag{
-			Username: fmt.Sprintf("USERNAME_%s", b.Username),
-			Tag:      fmt.Sprintf("TAG_%s_%s", tag, b.ID),
-		}
+		searchByTag := NewBookmarkSearchByTag(b.GetUsername(), b.GetBookmarkId(), tag)
+		searchByTag.BookmarkProjection = b.GetProjection()
+		return searchByTag
 	}).([]BookmarkSearchByTag)
 }
 
+func (b *Bookmark) GetSearchByCreated() BookmarkSearchByCreated {
+	return BookmarkSearchByCreated{
+		Username:           GetPartitionKey(b.GetUsername()),
+		Created:            fmt.Sprintf("CREATED_%013d_%s", b.CreatedAt.UnixNano()/int64(time.Millisecond), b.GetBookmarkId()),
+		BookmarkProjection: b.GetProjection(),
+	}
+}
+
+func (b *Bookmark) GetProjection() BookmarkProjection {
+	return BookmarkProjection{
+		BookmarkName: b.Name,
+		Url:          b.Url,
+		Tags:         b.Tags,
+		UpdatedAt:    b.UpdatedAt,
+		Version:      b.Version,
+	}
+}
+
+// Copy of the bookmark stored on the index items, so search does not need to fetch the bookmark itself
+type BookmarkProjection struct {
+	BookmarkName string    `json:"bookmark_name" dynamo:"name"`
+	Url          string    `json:"url" dynamo:"url"`
+	Tags         []string  `json:"tags" dynamo:"tags"`
+	UpdatedAt    time.Time `json:"updated_at" dynamo:"updated_at"`
+	Version      int       `json:"version" dynamo:"version"`
+}
+
+// Index items written before projections were introduced have only keys
+func (p *BookmarkProjection) HasProjection() bool {
+	return !p.UpdatedAt.IsZero()
+}
+
+// Reports whether the projection is up to date with the bookmark
+func (p *BookmarkProjection) Matches(bookmark Bookmark) bool {
+	return p.BookmarkName == bookmark.Name &&
+		p.Url == bookmark.Url &&
+		p.UpdatedAt.Equal(bookmark.UpdatedAt) &&
+		p.Version == bookmark.Version &&
+		funk.Equal(normalizeTags(p.Tags), normalizeTags(bookmark.Tags))
+}
+
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+func (p *BookmarkProjection) getBookmark(username, bookmarkId string) Bookmark {
+	return Bookmark{
+		Username:  username,
+		ID:        fmt.Sprintf("BOOKMARK_%s", bookmarkId),
+		Name:      p.BookmarkName,
+		Url:       p.Url,
+		Tags:      p.Tags,
+		UpdatedAt: p.UpdatedAt,
+		Version:   p.Version,
+	}
+}
+
 // SearchByName
 type BookmarkSearchByName struct {
 	Username string `json:"username" dynamo:"id"`
 	Name     string `json:"name" dynamo:"range"`
+	BookmarkProjection
 }
 
 // Returns bookmarkId. Range key format NAME_{NAME}_{BOOKMARK_ID}
 func (b *BookmarkSearchByName) GetBookmarkId() string {
-	return strings.Split(b.Name, "_")[2]
+	return b.Name[strings.LastIndex(b.Name, "_")+1:]
+}
+
+// Returns the bookmark from the projection, CreatedAt is not projected
+func (b *BookmarkSearchByName) GetBookmark() Bookmark {
+	return b.getBookmark(b.Username, b.GetBookmarkId())
 }
 
 func NewBookmarkSearchByTag(username, bookmarkId, tag string) BookmarkSearchByTag {
 	return BookmarkSearchByTag{
-		Username: fmt.Sprintf("USERNAME_%s", username),
+		Username: GetPartitionKey(username),
 		Tag:      fmt.Sprintf("TAG_%s_%s", tag, bookmarkId),
 	}
 }
@@ -99,9 +170,32 @@ func NewBookmarkSearchByTag(username, bookmarkId, tag string) BookmarkSearchByTa
 type BookmarkSearchByTag struct {
 	Username string `json:"username" dynamo:"id"`
 	Tag      string `json:"tag" dynamo:"range"`
+	BookmarkProjection
 }
 
 // Returns bookmarkId. Range key format TAG_{NAME}_{BOOKMARK_ID}
 func (b *BookmarkSearchByTag) GetBookmarkId() string {
-	return strings.Split(b.Tag, "_")[2]
+	return b.Tag[strings.LastIndex(b.Tag, "_")+1:]
+}
+
+// Returns the bookmark from the projection, CreatedAt is not projected
+func (b *BookmarkSearchByTag) GetBookmark() Bookmark {
+	return b.getBookmark(b.Username, b.GetBookmarkId())
+}
+
+// SearchByCreated, only bookmarks whose IDs are not ordered by creation have it
+type BookmarkSearchByCreated struct {
+	Username string `json:"username" dynamo:"id"`
+	Created  string `json:"created" dynamo:"range"`
+	BookmarkProjection
+}
+
+// Returns bookmarkId. Range key format CREATED_{UNIX_MILLIS}_{BOOKMARK_ID}
+func (b *BookmarkSearchByCreated) GetBookmarkId() string {
+	return b.Created[strings.LastIndex(b.Created, "_")+1:]
+}
+
+// Returns the bookmark from the projection, CreatedAt is not projected
+func (b *BookmarkSearchByCreated) GetBookmark() Bookmark {
+	return b.getBookmark(b.Username, b.GetBookmarkId())
 }
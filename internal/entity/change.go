@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Returns ID and Range keys
+func GetSearchKeyByChange(username string, sequence int64) (string, string) {
+	return GetPartitionKey(username), fmt.Sprintf("CHANGE_%020d", sequence)
+}
+
+// Returns ID and Range keys
+func GetSequenceKey(username string) (string, string) {
+	return GetPartitionKey(username), "SEQUENCE"
+}
+
+// Entry of the change log of a user, written in the same transaction as the change itself
+type BookmarkChange struct {
+	Username   string        `json:"username" dynamo:"id"`
+	Change     string        `json:"change" dynamo:"range"`
+	Sequence   int64         `json:"seq" dynamo:"seq"`
+	Type       OperationType `json:"type" dynamo:"type"`
+	BookmarkID string        `json:"bookmark_id" dynamo:"bookmark_id"`
+	Tag        string        `json:"tag" dynamo:"tag"`
+	TargetTag  string        `json:"target_tag" dynamo:"target_tag"`
+	// Tombstone of a deleted bookmark, the projection is the last state of it
+	Deleted   bool      `json:"deleted" dynamo:"deleted"`
+	ChangedAt time.Time `json:"changed_at" dynamo:"changed_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+	BookmarkProjection
+}
+
+func NewBookmarkChange(username string, sequence int64, operation BookmarkOperation, before, after *Bookmark) BookmarkChange {
+	hashId, rangeId := GetSearchKeyByChange(username, sequence)
+	change := BookmarkChange{
+		Username:  hashId,
+		Change:    rangeId,
+		Sequence:  sequence,
+		Type:      operation.Type,
+		Tag:       operation.Tag,
+		TargetTag: operation.TargetTag,
+		ChangedAt: time.Now(),
+	}
+
+	if after != nil {
+		change.BookmarkID = after.GetBookmarkId()
+		change.BookmarkProjection = after.GetProjection()
+	} else {
+		change.BookmarkID = before.GetBookmarkId()
+		change.BookmarkProjection = before.GetProjection()
+		change.Deleted = true
+	}
+
+	return change
+}
+
+// Returns the bookmark of the change, CreatedAt is not projected
+func (c *BookmarkChange) GetBookmark() Bookmark {
+	return c.getBookmark(c.Username, c.BookmarkID)
+}
+
+// Last sequence number of the change log of a user
+type ChangeSequence struct {
+	Username string `json:"username" dynamo:"id"`
+	Range    string `json:"range" dynamo:"range"`
+	Sequence int64  `json:"seq" dynamo:"seq"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Returns ID and Range keys of the feed token of a user
+func GetSearchKeyByFeedToken(username string) (string, string) {
+	return fmt.Sprintf("USERNAME_%s", username), "FEED_TOKEN"
+}
+
+// Returns ID and Range keys of the copy of a feed token looked up by its hash
+func GetSearchKeyByFeedTokenHash(tokenHash string) (string, string) {
+	return fmt.Sprintf("FEED_%s", tokenHash), "FEED_TOKEN"
+}
+
+// Secret of the private feeds of a user. Only its hash is stored, once under the user to rotate it
+// and once under the hash to find the user of a feed request
+type FeedToken struct {
+	Username string `json:"username" dynamo:"id"`
+	ID       string `json:"id" dynamo:"range"`
+	// Username of the owner, without prefix
+	Owner     string    `json:"owner" dynamo:"owner"`
+	TokenHash string    `json:"token_hash" dynamo:"token_hash"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+}
+
+// Returns the copy of the token stored under the owner
+func (t FeedToken) GetOwnerItem() FeedToken {
+	t.Username, t.ID = GetSearchKeyByFeedToken(t.Owner)
+	return t
+}
+
+// Returns the copy of the token stored under its hash
+func (t FeedToken) GetHashItem() FeedToken {
+	t.Username, t.ID = GetSearchKeyByFeedTokenHash(t.TokenHash)
+	return t
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Returns ID and Range keys of a magic link by the hash of its secret
+func GetSearchKeyByMagicLink(tokenHash string) (string, string) {
+	return fmt.Sprintf("MAGIC_LINK_%s", tokenHash), "MAGIC_LINK"
+}
+
+// Returns ID and Range keys of the count of magic links sent to the email in the window starting at windowStart
+func GetSearchKeyByMagicLinkCount(email string, windowStart time.Time) (string, string) {
+	return fmt.Sprintf("MAGIC_LINK_COUNT_%s", email), fmt.Sprintf("WINDOW_%d", windowStart.Unix())
+}
+
+// Single use link signing the owner of the email in. Only the hash of its secret is stored
+type MagicLink struct {
+	HashKey   string    `json:"hash_key" dynamo:"id"`
+	RangeKey  string    `json:"range_key" dynamo:"range"`
+	TokenHash string    `json:"token_hash" dynamo:"token_hash"`
+	Email     string    `json:"email" dynamo:"email"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (l MagicLink) GetItem() MagicLink {
+	l.HashKey, l.RangeKey = GetSearchKeyByMagicLink(l.TokenHash)
+	return l
+}
@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	FieldName = "name"
+	FieldUrl  = "url"
+	// Conflict on the bookmark as a whole, e.g. deleted on one side and changed on the other
+	FieldBookmark = "bookmark"
+
+	ResolutionClient = "client"
+	ResolutionServer = "server"
+)
+
+// Field key of a tag, removed tags keep their clock so a later removal is not undone
+func TagField(tag string) string {
+	return "tag:" + tag
+}
+
+// Version of the bookmark which last changed the field and the time it was changed at
+type FieldClock struct {
+	Version   int       `json:"version" dynamo:"version"`
+	UpdatedAt time.Time `json:"updated_at" dynamo:"updated_at"`
+}
+
+// Returns the clock of the field. Fields written before clocks existed are assumed to be changed by the current version
+func (b *Bookmark) GetFieldClock(field string) (FieldClock, bool) {
+	if clock, ok := b.Fields[field]; ok {
+		return clock, true
+	}
+
+	return FieldClock{Version: b.Version, UpdatedAt: b.UpdatedAt}, false
+}
+
+// Returns ID and Range keys
+func GetSearchKeyByClientID(username, clientId string) (string, string) {
+	return GetPartitionKey(username), fmt.Sprintf("CLIENT_%s", clientId)
+}
+
+// Bookmark created by a pushed mutation, a replay of the mutation returns it instead of creating another one
+type BookmarkClientID struct {
+	Username   string    `json:"username" dynamo:"id"`
+	ClientID   string    `json:"client_id" dynamo:"range"`
+	BookmarkID string    `json:"bookmark_id" dynamo:"bookmark_id"`
+	ExpiresAt  time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func NewBookmarkClientID(username, clientId, bookmarkId string) BookmarkClientID {
+	hashId, rangeId := GetSearchKeyByClientID(username, clientId)
+	return BookmarkClientID{
+		Username:   hashId,
+		ClientID:   rangeId,
+		BookmarkID: bookmarkId,
+	}
+}
+
+// Edit made by a client against the version of the bookmark it has seen
+type BookmarkMutation struct {
+	// Create, update or delete
+	Type OperationType
+	// ID the client gave a created bookmark, empty when the client does not retry creates
+	ClientID    string
+	BookmarkID  string
+	BaseVersion int
+	// Nil when the field is not changed
+	Name *string
+	Url  *string
+	// Tags of a created bookmark
+	Tags       []string
+	AddTags    []string
+	RemoveTags []string
+	// Time of the edit on the client
+	ModifiedAt time.Time
+}
+
+// Field changed on both sides with different values
+type FieldConflict struct {
+	Field       string
+	ClientValue string
+	ServerValue string
+	// Side whose value is kept
+	Resolution string
+}
+
+type BookmarkMutationResult struct {
+	Bookmark Bookmark
+	Deleted  bool
+	// The mutation has been written, it is not when it changes nothing or loses every conflict
+	Applied bool
+	// The bookmark has been changed on the server since the base version
+	Merged    bool
+	Conflicts []FieldConflict
+	Err       error
+}
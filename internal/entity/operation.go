@@ -0,0 +1,27 @@
+package entity
+
+type OperationType string
+
+const (
+	OperationCreate    OperationType = "create"
+	OperationUpdate    OperationType = "update"
+	OperationDelete    OperationType = "delete"
+	OperationAddTag    OperationType = "add_tag"
+	OperationRemoveTag OperationType = "remove_tag"
+	OperationMove      OperationType = "move"
+)
+
+// Single change on a bookmark. Bookmark carries the ID, the new values and the expected version
+type BookmarkOperation struct {
+	Type     OperationType
+	Bookmark Bookmark
+	// Tag to add or remove, source tag for move
+	Tag string
+	// Target tag for move
+	TargetTag string
+}
+
+type BookmarkOperationResult struct {
+	Bookmark Bookmark
+	Err      error
+}
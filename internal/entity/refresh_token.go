@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Returns ID and Range keys of a refresh token by the hash of its secret
+func GetSearchKeyByRefreshToken(tokenHash string) (string, string) {
+	return fmt.Sprintf("REFRESH_%s", tokenHash), "REFRESH_TOKEN"
+}
+
+// Returns ID and Range keys of a family of refresh tokens
+func GetSearchKeyByRefreshFamily(familyId string) (string, string) {
+	return fmt.Sprintf("REFRESH_FAMILY_%s", familyId), "REFRESH_FAMILY"
+}
+
+// Single use token issuing a new JWT and the next token of its family. Only the hash of its secret is stored, used
+// tokens are kept until they expire to notice when they are used again
+type RefreshToken struct {
+	HashKey   string    `json:"hash_key" dynamo:"id"`
+	RangeKey  string    `json:"range_key" dynamo:"range"`
+	TokenHash string    `json:"token_hash" dynamo:"token_hash"`
+	FamilyID  string    `json:"family_id" dynamo:"family_id"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+	UsedAt    time.Time `json:"used_at" dynamo:"used_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (t RefreshToken) GetItem() RefreshToken {
+	t.HashKey, t.RangeKey = GetSearchKeyByRefreshToken(t.TokenHash)
+	return t
+}
+
+// Refresh tokens rotated from the same sign in. They carry the identity the JWTs are issued to, and are revoked
+// together when one of them is used twice
+type RefreshFamily struct {
+	HashKey  string   `json:"hash_key" dynamo:"id"`
+	RangeKey string   `json:"range_key" dynamo:"range"`
+	ID       string   `json:"family_id" dynamo:"family_id"`
+	Username string   `json:"username" dynamo:"username"`
+	Method   string   `json:"method" dynamo:"method"`
+	UserID   string   `json:"user_id" dynamo:"user_id,omitempty"`
+	Roles    []string `json:"roles" dynamo:"roles,omitempty"`
+	// Token generation of the user at sign in, signing out everywhere revokes the family as well
+	Generation int       `json:"generation" dynamo:"generation"`
+	CreatedAt  time.Time `json:"created_at" dynamo:"created_at"`
+	RevokedAt  time.Time `json:"revoked_at" dynamo:"revoked_at,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (f RefreshFamily) GetItem() RefreshFamily {
+	f.HashKey, f.RangeKey = GetSearchKeyByRefreshFamily(f.ID)
+	return f
+}
+
+func (f RefreshFamily) IsRevoked() bool {
+	return !f.RevokedAt.IsZero()
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kinds of bookmark sets which can be shared
+const (
+	ShareTag        = "tag"
+	ShareCollection = "collection"
+	ShareSearch     = "search"
+)
+
+// Returns ID and Range keys of a share in the partition of its owner, slug is empty to search all of them
+func GetSearchKeyByShare(username, slug string) (string, string) {
+	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("SHARE_%s", slug)
+}
+
+// Returns ID and Range keys of the public copy of a share, looked up without knowing the owner
+func GetSearchKeyBySlug(slug string) (string, string) {
+	return fmt.Sprintf("SLUG_%s", slug), "SHARE"
+}
+
+// Set of bookmarks published under an unguessable slug. It is stored twice, under the owner to list
+// and revoke it, and under the slug to serve it
+type Share struct {
+	Username string `json:"username" dynamo:"id"`
+	ID       string `json:"id" dynamo:"range"`
+	// Username of the owner, without prefix
+	Owner string `json:"owner" dynamo:"owner"`
+	Slug  string `json:"slug" dynamo:"slug"`
+	Type  string `json:"type" dynamo:"type"`
+	Title string `json:"title" dynamo:"title"`
+	// Bookmarks having the tag, for tag shares
+	Tag string `json:"tag" dynamo:"tag,omitempty"`
+	// Bookmarks picked by ID, for collection shares
+	BookmarkIDs []string `json:"bookmark_ids" dynamo:"bookmark_ids,omitempty"`
+	// Bookmarks whose names start with the query, for search shares
+	Query     string    `json:"query" dynamo:"query,omitempty"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+}
+
+// Returns the copy of the share stored under the owner
+func (s Share) GetOwnerItem() Share {
+	s.Username, s.ID = GetSearchKeyByShare(s.Owner, s.Slug)
+	return s
+}
+
+// Returns the copy of the share stored under the slug
+func (s Share) GetSlugItem() Share {
+	s.Username, s.ID = GetSearchKeyBySlug(s.Slug)
+	return s
+}
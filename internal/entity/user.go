@@ -1,11 +1,106 @@
 package entity
 
 import (
+	"fmt"
 	"time"
 )
 
+// Identity of a user at a sign in method. Every identity belongs to a user account by its user ID, the account
+// owning the bookmarks, so a user signing in with several providers has one set of bookmarks
 type User struct {
-	Username    string    `json:"username" dynamo:"username"`
-	Method      string    `json:"method" dynamo:"method"`
+	Username string `json:"username" dynamo:"username"`
+	Method   string `json:"method" dynamo:"method"`
+	// ID of the account, missing from identities created before they could be linked until they sign in again
+	UserID      string    `json:"user_id" dynamo:"user_id,omitempty"`
 	LastLoginAt time.Time `json:"last_login_at" dynamo:"last_login_at"`
+	// Bumped to sign the user out everywhere, JWTs issued with an older generation are revoked
+	TokenGeneration int `json:"token_generation" dynamo:"token_generation"`
+	// Argon2id hash of the password of local users, see AuthMethod local
+	PasswordHash string    `json:"-" dynamo:"password_hash,omitempty"`
+	FailedLogins int       `json:"-" dynamo:"failed_logins,omitempty"`
+	LockedUntil  time.Time `json:"-" dynamo:"locked_until,omitempty"`
+	// Set on every identity of an account disabled by an admin, which may neither sign in nor use its tokens
+	Disabled bool `json:"disabled" dynamo:"disabled,omitempty"`
+}
+
+// Returns ID and Range keys of a password reset token by the hash of its secret
+func GetSearchKeyByPasswordReset(tokenHash string) (string, string) {
+	return fmt.Sprintf("PASSWORD_RESET_%s", tokenHash), "PASSWORD_RESET"
+}
+
+// Single use token setting a new password of a local user. Only the hash of its secret is stored
+type PasswordResetToken struct {
+	HashKey   string    `json:"hash_key" dynamo:"id"`
+	RangeKey  string    `json:"range_key" dynamo:"range"`
+	TokenHash string    `json:"token_hash" dynamo:"token_hash"`
+	Username  string    `json:"username" dynamo:"username"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (t PasswordResetToken) GetItem() PasswordResetToken {
+	t.HashKey, t.RangeKey = GetSearchKeyByPasswordReset(t.TokenHash)
+	return t
+}
+
+// Returns ID and Range keys of a revoked JWT
+func GetSearchKeyByRevokedToken(jti string) (string, string) {
+	return fmt.Sprintf("REVOKED_%s", jti), "JWT"
+}
+
+// JWT signed out before it expired. It is kept until the token could not be refreshed anymore
+type RevokedToken struct {
+	HashKey   string    `json:"hash_key" dynamo:"id"`
+	RangeKey  string    `json:"range_key" dynamo:"range"`
+	JTI       string    `json:"jti" dynamo:"jti"`
+	Username  string    `json:"username" dynamo:"username"`
+	RevokedAt time.Time `json:"revoked_at" dynamo:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (t RevokedToken) GetItem() RevokedToken {
+	t.HashKey, t.RangeKey = GetSearchKeyByRevokedToken(t.JTI)
+	return t
+}
+
+// Returns ID and Range keys of an identity being linked, by the hash of the state sent to the provider
+func GetSearchKeyByLinkIntent(stateHash string) (string, string) {
+	return fmt.Sprintf("LINK_%s", stateHash), "LINK"
+}
+
+// Single use intent of a user to link the identity the provider signs in next to its account
+type LinkIntent struct {
+	HashKey   string    `json:"hash_key" dynamo:"id"`
+	RangeKey  string    `json:"range_key" dynamo:"range"`
+	StateHash string    `json:"state_hash" dynamo:"state_hash"`
+	UserID    string    `json:"user_id" dynamo:"user_id"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (i LinkIntent) GetItem() LinkIntent {
+	i.HashKey, i.RangeKey = GetSearchKeyByLinkIntent(i.StateHash)
+	return i
+}
+
+// Range key of the profile of an account in the user table, no sign in method is named like it
+const ProfileMethod = "profile"
+
+// Preferences of an account, kept in the user table under its user ID next to the identities. It has no user_id,
+// so the user_id-index never lists it as an identity
+type Profile struct {
+	UserID      string          `json:"user_id" dynamo:"username"`
+	Method      string          `json:"method" dynamo:"method"`
+	DisplayName string          `json:"display_name" dynamo:"display_name,omitempty"`
+	AvatarURL   string          `json:"avatar_url" dynamo:"avatar_url,omitempty"`
+	DefaultSort string          `json:"default_sort" dynamo:"default_sort,omitempty"`
+	DefaultTags []string        `json:"default_tags" dynamo:"default_tags,omitempty"`
+	Timezone    string          `json:"timezone" dynamo:"timezone,omitempty"`
+	Features    map[string]bool `json:"features" dynamo:"features,omitempty"`
+	UpdatedAt   time.Time       `json:"updated_at" dynamo:"updated_at"`
+}
+
+func (p Profile) GetItem() Profile {
+	p.Method = ProfileMethod
+	return p
 }
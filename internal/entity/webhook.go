@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Returns ID and Range keys. Webhooks are kept with the bookmarks they deliver the events of, the username
+// is the owner of a workspace
+func GetSearchKeyByWebhook(username, webhookId string) (string, string) {
+	return GetPartitionKey(username), fmt.Sprintf("WEBHOOK_%s", webhookId)
+}
+
+// Returns ID and Range keys of the deliveries of a webhook, delivery ID is empty to search all of them
+func GetSearchKeyByDelivery(username, webhookId, deliveryId string) (string, string) {
+	return GetPartitionKey(username), fmt.Sprintf("DELIVERY_%s_%s", webhookId, deliveryId)
+}
+
+type Webhook struct {
+	Username string `json:"username" dynamo:"id"`
+	ID       string `json:"id" dynamo:"range"`
+	Url      string `json:"url" dynamo:"url"`
+	// Event types to deliver, empty for all
+	Events []string `json:"events" dynamo:"events"`
+	// Key of the HMAC-SHA256 signature of the payloads
+	Secret string `json:"secret" dynamo:"secret"`
+	// Failed attempts since the last successful delivery
+	Failures   int       `json:"failures" dynamo:"failures"`
+	Disabled   bool      `json:"disabled" dynamo:"disabled"`
+	DisabledAt time.Time `json:"disabled_at" dynamo:"disabled_at"`
+	CreatedAt  time.Time `json:"created_at" dynamo:"created_at"`
+}
+
+func (w *Webhook) GetUsername() string {
+	return GetPartitionOwner(w.Username)
+}
+
+func (w *Webhook) GetWebhookId() string {
+	return strings.TrimPrefix(w.ID, "WEBHOOK_")
+}
+
+// Reports whether the webhook subscribes to the event type
+func (w *Webhook) Accepts(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, accepted := range w.Events {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Single attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	Username    string    `json:"username" dynamo:"id"`
+	ID          string    `json:"id" dynamo:"range"`
+	WebhookID   string    `json:"webhook_id" dynamo:"webhook_id"`
+	DeliveryID  string    `json:"delivery_id" dynamo:"delivery_id"`
+	EventID     string    `json:"event_id" dynamo:"event_id"`
+	EventType   string    `json:"event_type" dynamo:"event_type"`
+	Attempt     int       `json:"attempt" dynamo:"attempt"`
+	StatusCode  int       `json:"status_code" dynamo:"status_code"`
+	Error       string    `json:"error" dynamo:"error"`
+	Success     bool      `json:"success" dynamo:"success"`
+	Duration    int64     `json:"duration_ms" dynamo:"duration_ms"`
+	DeliveredAt time.Time `json:"delivered_at" dynamo:"delivered_at"`
+	ExpiresAt   time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+// Event to deliver to the webhooks of a user, or a single delivery attempt of it to one webhook,
+// sent through the processing queue
+type WebhookMessage struct {
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	WebhookID  string `json:"webhook_id"`
+	DeliveryID string `json:"delivery_id"`
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	// Time of the event, only on event messages
+	CreatedAt time.Time `json:"created_at"`
+	// Data of the event on event messages, the body to post on deliveries
+	Payload json.RawMessage `json:"payload"`
+	Attempt int             `json:"attempt"`
+}
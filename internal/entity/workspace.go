@@ -0,0 +1,137 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Roles of the members of a workspace, each one can do everything the previous ones can
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Reports whether the role grants everything the required role does
+func HasRole(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+func IsRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// Usernames never contain a colon, so owners of bookmarks starting with it are team workspaces
+const workspaceOwnerPrefix = "workspace:"
+
+// Returns the owner of the bookmarks of a team workspace, used wherever a username owns bookmarks
+func WorkspaceOwner(workspaceId string) string {
+	return workspaceOwnerPrefix + workspaceId
+}
+
+// Returns the hash key of the bookmarks of the owner. Personal workspaces keep the keys of their user,
+// team workspaces are keyed by their ID
+func GetPartitionKey(owner string) string {
+	if strings.HasPrefix(owner, workspaceOwnerPrefix) {
+		return fmt.Sprintf("WORKSPACE_%s", strings.TrimPrefix(owner, workspaceOwnerPrefix))
+	}
+	return fmt.Sprintf("USERNAME_%s", owner)
+}
+
+// Returns the owner of the bookmarks stored under the hash key
+func GetPartitionOwner(hashKey string) string {
+	if strings.HasPrefix(hashKey, "WORKSPACE_") {
+		return WorkspaceOwner(strings.TrimPrefix(hashKey, "WORKSPACE_"))
+	}
+	return strings.TrimPrefix(hashKey, "USERNAME_")
+}
+
+// Returns ID and Range keys
+func GetSearchKeyByWorkspace(workspaceId string) (string, string) {
+	return fmt.Sprintf("WORKSPACE_%s", workspaceId), "WORKSPACE"
+}
+
+// Returns ID and Range keys of a member under the workspace, username is empty to search all of them
+func GetSearchKeyByMember(workspaceId, username string) (string, string) {
+	return fmt.Sprintf("WORKSPACE_%s", workspaceId), fmt.Sprintf("MEMBER_%s", username)
+}
+
+// Returns ID and Range keys of a membership under the user, workspace ID is empty to search all of them
+func GetSearchKeyByMembership(username, workspaceId string) (string, string) {
+	return fmt.Sprintf("USERNAME_%s", username), fmt.Sprintf("WORKSPACE_%s", workspaceId)
+}
+
+// Returns ID and Range keys of an invitation under the workspace, email is empty to search all of them
+func GetSearchKeyByInvitation(workspaceId, email string) (string, string) {
+	return fmt.Sprintf("WORKSPACE_%s", workspaceId), fmt.Sprintf("INVITATION_%s", email)
+}
+
+// Returns ID and Range keys of an invitation under the invited user, workspace ID is empty to search all of them
+func GetSearchKeyByInvitationOf(email, workspaceId string) (string, string) {
+	return fmt.Sprintf("USERNAME_%s", email), fmt.Sprintf("INVITATION_%s", workspaceId)
+}
+
+// Bookmark space shared by its members. Its bookmarks are stored in its own partition
+type Workspace struct {
+	HashKey  string `json:"hash_key" dynamo:"id"`
+	RangeKey string `json:"range_key" dynamo:"range"`
+	ID       string `json:"id" dynamo:"workspace_id"`
+	Name     string `json:"name" dynamo:"name"`
+	// Members with the owner role, a workspace always keeps at least one
+	Owners    int       `json:"owners" dynamo:"owners"`
+	CreatedBy string    `json:"created_by" dynamo:"created_by"`
+	CreatedAt time.Time `json:"created_at" dynamo:"created_at"`
+}
+
+func (w Workspace) GetItem() Workspace {
+	w.HashKey, w.RangeKey = GetSearchKeyByWorkspace(w.ID)
+	return w
+}
+
+// Membership of a user in a workspace. It is stored under the workspace to list its members
+// and check roles, and under the user to list the workspaces of the user. Members are named by their user ID
+type WorkspaceMember struct {
+	HashKey       string    `json:"hash_key" dynamo:"id"`
+	RangeKey      string    `json:"range_key" dynamo:"range"`
+	WorkspaceID   string    `json:"workspace_id" dynamo:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name" dynamo:"workspace_name"`
+	Username      string    `json:"username" dynamo:"username"`
+	Role          string    `json:"role" dynamo:"role"`
+	JoinedAt      time.Time `json:"joined_at" dynamo:"joined_at"`
+}
+
+func (m WorkspaceMember) GetWorkspaceItem() WorkspaceMember {
+	m.HashKey, m.RangeKey = GetSearchKeyByMember(m.WorkspaceID, m.Username)
+	return m
+}
+
+func (m WorkspaceMember) GetUserItem() WorkspaceMember {
+	m.HashKey, m.RangeKey = GetSearchKeyByMembership(m.Username, m.WorkspaceID)
+	return m
+}
+
+// Pending invitation of an email to a workspace, stored under the workspace and under the invited user
+type WorkspaceInvitation struct {
+	HashKey       string    `json:"hash_key" dynamo:"id"`
+	RangeKey      string    `json:"range_key" dynamo:"range"`
+	WorkspaceID   string    `json:"workspace_id" dynamo:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name" dynamo:"workspace_name"`
+	Email         string    `json:"email" dynamo:"email"`
+	Role          string    `json:"role" dynamo:"role"`
+	InvitedBy     string    `json:"invited_by" dynamo:"invited_by"`
+	CreatedAt     time.Time `json:"created_at" dynamo:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at" dynamo:"expires_at,unixtime"`
+}
+
+func (i WorkspaceInvitation) GetWorkspaceItem() WorkspaceInvitation {
+	i.HashKey, i.RangeKey = GetSearchKeyByInvitation(i.WorkspaceID, i.Email)
+	return i
+}
+
+func (i WorkspaceInvitation) GetUserItem() WorkspaceInvitation {
+	i.HashKey, i.RangeKey = GetSearchKeyByInvitationOf(i.Email, i.WorkspaceID)
+	return i
+}
@@ -5,3 +5,10 @@ import "errors"
 var ErrNotFound = errors.New("Not found")
 var ErrAlreadyExist = errors.New("Has already")
 var ErrInvalidParam = errors.New("Invalid paramter")
+var ErrPreconditionFailed = errors.New("Precondition failed")
+var ErrConflict = errors.New("Conflict")
+var ErrAborted = errors.New("Aborted")
+var ErrTooManyItems = errors.New("Too many items")
+var ErrExpired = errors.New("Expired")
+var ErrLocked = errors.New("Locked")
+var ErrDisabled = errors.New("Disabled")
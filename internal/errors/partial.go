@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PartialError is returned along with the results that could be fetched
+type PartialError struct {
+	// IDs of the items which could not be fetched
+	Failed []string
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("Failed to fetch %d items (%s): %v", len(e.Failed), strings.Join(e.Failed, ","), e.Err)
+}
+
+func IsPartial(err error) (*PartialError, bool) {
+	partialErr, ok := err.(*PartialError)
+	return partialErr, ok
+}
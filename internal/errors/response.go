@@ -59,3 +59,43 @@ func BadRequest(msg string) ErrorResponse {
 		Message: msg,
 	}
 }
+
+func PreconditionFailed(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "Precondition failed."
+	}
+	return ErrorResponse{
+		Status:  http.StatusPreconditionFailed,
+		Message: msg,
+	}
+}
+
+func Gone(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "The requested resource is no longer available."
+	}
+	return ErrorResponse{
+		Status:  http.StatusGone,
+		Message: msg,
+	}
+}
+
+func Conflict(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "The request conflicts with the current state of the resource."
+	}
+	return ErrorResponse{
+		Status:  http.StatusConflict,
+		Message: msg,
+	}
+}
+
+func TooManyRequests(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "Too many requests, try again later."
+	}
+	return ErrorResponse{
+		Status:  http.StatusTooManyRequests,
+		Message: msg,
+	}
+}
@@ -0,0 +1,247 @@
+package event
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	BookmarkCreated = "bookmark.created"
+	BookmarkUpdated = "bookmark.updated"
+	BookmarkDeleted = "bookmark.deleted"
+	TagAdded        = "tag.added"
+	TagRemoved      = "tag.removed"
+
+	// Events kept per user to resume streams from
+	historySize = 100
+	historyTTL  = 5 * time.Minute
+	// Events a subscriber may fall behind before it is dropped
+	subscriberBuffer = 32
+)
+
+type Event struct {
+	// Unique within the process, made of the start time of the bus and a sequence number
+	ID       string
+	Username string
+	Type     string
+	Data     interface{}
+	Time     time.Time
+}
+
+// Bus delivers events to the subscribers of the same user in this process
+type Bus interface {
+	Publish(username, eventType string, data interface{})
+	// Subscribes to the events of the user. Events after lastEventId are replayed when they are still kept,
+	// otherwise the subscription is marked as reset and the subscriber has to reload
+	Subscribe(username, lastEventId string) *Subscription
+	// Registers a listener called with the events of every user, right after they are published
+	Listen(listener func(Event))
+}
+
+type Subscription struct {
+	Replay []Event
+	Reset  bool
+	// Closed when the subscriber falls too far behind, it should resume with the last event ID it has seen
+	Events <-chan Event
+	close  func()
+}
+
+func (s *Subscription) Close() {
+	s.close()
+}
+
+type subscriber struct {
+	events chan Event
+	closed bool
+}
+
+type history struct {
+	events []Event
+	// Sequence number of the last event dropped from the history
+	evicted uint64
+}
+
+type bus struct {
+	mutex     sync.Mutex
+	epoch     int64
+	sequence  uint64
+	histories map[string]*history
+	// Highest sequence number evicted with a history dropped entirely
+	swept       uint64
+	subscribers map[string]map[*subscriber]bool
+	listeners   []func(Event)
+	lastSweep   time.Time
+	logger      *zap.Logger
+}
+
+func NewBus(logger *zap.Logger) Bus {
+	return &bus{
+		epoch:       time.Now().UnixNano(),
+		histories:   make(map[string]*history),
+		subscribers: make(map[string]map[*subscriber]bool),
+		lastSweep:   time.Now(),
+		logger:      logger,
+	}
+}
+
+func (b *bus) Publish(username, eventType string, data interface{}) {
+	logger := b.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	b.mutex.Lock()
+
+	b.sequence++
+	event := Event{
+		ID:       fmt.Sprintf("%d-%d", b.epoch, b.sequence),
+		Username: username,
+		Type:     eventType,
+		Data:     data,
+		Time:     time.Now(),
+	}
+
+	userHistory, ok := b.histories[username]
+	if !ok {
+		userHistory = &history{}
+		b.histories[username] = userHistory
+	}
+	userHistory.events = append(userHistory.events, event)
+	if len(userHistory.events) > historySize {
+		userHistory.evicted = sequenceOf(userHistory.events[0].ID)
+		userHistory.events = userHistory.events[1:]
+	}
+	b.sweep(event.Time)
+
+	for sub := range b.subscribers[username] {
+		select {
+		case sub.events <- event:
+		default:
+			// Slow subscribers must not block the writers, they resume from the history
+			logger.Warnw("Dropping slow subscriber", zap.String("Username", username))
+			b.unsubscribe(username, sub)
+		}
+	}
+	listeners := b.listeners
+	b.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func (b *bus) Listen(listener func(Event)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.listeners = append(b.listeners, listener)
+}
+
+func (b *bus) Subscribe(username, lastEventId string) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sub := &subscriber{events: make(chan Event, subscriberBuffer)}
+	if b.subscribers[username] == nil {
+		b.subscribers[username] = make(map[*subscriber]bool)
+	}
+	b.subscribers[username][sub] = true
+
+	subscription := &Subscription{
+		Events: sub.events,
+		close: func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			b.unsubscribe(username, sub)
+		},
+	}
+	if lastEventId == "" {
+		return subscription
+	}
+
+	epoch, sequence, ok := parseID(lastEventId)
+	userHistory := b.histories[username]
+	if !ok || epoch != b.epoch || sequence > b.sequence {
+		subscription.Reset = true
+		return subscription
+	}
+	if userHistory == nil {
+		// Events of the user might have been dropped with the whole history
+		subscription.Reset = sequence < b.swept
+		return subscription
+	}
+	if sequence < userHistory.evicted {
+		subscription.Reset = true
+		return subscription
+	}
+
+	for _, event := range userHistory.events {
+		if sequenceOf(event.ID) > sequence {
+			subscription.Replay = append(subscription.Replay, event)
+		}
+	}
+	return subscription
+}
+
+// Must be called with the lock held
+func (b *bus) unsubscribe(username string, sub *subscriber) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.events)
+	delete(b.subscribers[username], sub)
+	if len(b.subscribers[username]) == 0 {
+		delete(b.subscribers, username)
+	}
+}
+
+// Drops expired events of all users at most once a minute. Must be called with the lock held
+func (b *bus) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < time.Minute {
+		return
+	}
+	b.lastSweep = now
+
+	for username, userHistory := range b.histories {
+		for len(userHistory.events) > 0 && now.Sub(userHistory.events[0].Time) > historyTTL {
+			userHistory.evicted = sequenceOf(userHistory.events[0].ID)
+			userHistory.events = userHistory.events[1:]
+		}
+		if len(userHistory.events) == 0 {
+			if userHistory.evicted > b.swept {
+				b.swept = userHistory.evicted
+			}
+			delete(b.histories, username)
+		}
+	}
+}
+
+func parseID(id string) (int64, uint64, bool) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	epoch, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	sequence, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return epoch, sequence, true
+}
+
+func sequenceOf(id string) uint64 {
+	_, sequence, _ := parseID(id)
+	return sequence
+}
@@ -0,0 +1,94 @@
+package event
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/pkg/logger"
+)
+
+func TestPublishAndSubscribe(t *testing.T) {
+	b := NewBus(logger.NewLogger())
+
+	subscription := b.Subscribe("1", "")
+	defer subscription.Close()
+	other := b.Subscribe("2", "")
+	defer other.Close()
+
+	b.Publish("1", BookmarkCreated, "a")
+
+	event := <-subscription.Events
+	assert.Equal(t, BookmarkCreated, event.Type)
+	assert.Equal(t, "a", event.Data)
+	assert.Len(t, other.Events, 0)
+}
+
+func TestResume(t *testing.T) {
+	b := NewBus(logger.NewLogger())
+
+	b.Publish("1", BookmarkCreated, "a")
+	first := b.Subscribe("1", "")
+	b.Publish("1", TagAdded, "b")
+	b.Publish("2", TagAdded, "x")
+	b.Publish("1", TagRemoved, "c")
+	seen := <-first.Events
+	first.Close()
+
+	// Reconnects after the first event it has seen
+	subscription := b.Subscribe("1", seen.ID)
+	defer subscription.Close()
+	assert.False(t, subscription.Reset)
+	assert.Len(t, subscription.Replay, 1)
+	assert.Equal(t, "c", subscription.Replay[0].Data)
+
+	// IDs of another process can not be resumed
+	assert.True(t, b.Subscribe("1", "1-1").Reset)
+	assert.True(t, b.Subscribe("1", "garbage").Reset)
+}
+
+func TestResumeAfterEviction(t *testing.T) {
+	b := NewBus(logger.NewLogger())
+
+	subscription := b.Subscribe("1", "")
+	b.Publish("1", BookmarkCreated, "first")
+	first := <-subscription.Events
+	subscription.Close()
+
+	for i := 0; i < historySize+1; i++ {
+		b.Publish("1", BookmarkUpdated, fmt.Sprint(i))
+	}
+
+	assert.True(t, b.Subscribe("1", first.ID).Reset)
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := NewBus(logger.NewLogger())
+
+	subscription := b.Subscribe("1", "")
+	defer subscription.Close()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish("1", BookmarkUpdated, i)
+	}
+
+	count := 0
+	for range subscription.Events {
+		count++
+	}
+	assert.Equal(t, subscriberBuffer, count)
+}
+
+func TestListen(t *testing.T) {
+	b := NewBus(logger.NewLogger())
+
+	var heard []Event
+	b.Listen(func(e Event) {
+		heard = append(heard, e)
+	})
+	b.Publish("1", TagAdded, "a")
+	b.Publish("2", TagRemoved, "b")
+
+	assert.Len(t, heard, 2)
+	assert.Equal(t, "2", heard[1].Username)
+}
@@ -0,0 +1,176 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+	// Routes served without authentication, private feeds are authorized by the feed token in the path
+	RegisterPublicHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	rg.POST("/feed-token", r.rotateToken)
+	rg.DELETE("/feed-token", r.deleteToken)
+}
+
+func (r *resource) RegisterPublicHandlers(rg *gin.RouterGroup) {
+	rg.GET("/feeds/:token/tag/:file", r.tagFeed)
+	rg.GET("/public/:slug/feed.atom", r.shareFeed(formatAtom))
+	rg.GET("/public/:slug/feed.rss", r.shareFeed(formatRss))
+}
+
+type FeedTokenResponse struct {
+	// Only returned when the token is rotated
+	Token string `json:"token"`
+	// Feed of a tag, {tag} is to be replaced
+	AtomPath  string    `json:"atom_path"`
+	RssPath   string    `json:"rss_path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *resource) rotateToken(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	token, err := r.service.RotateToken(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to rotate feed token"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, FeedTokenResponse{
+		Token:     token.Token,
+		AtomPath:  fmt.Sprintf("/feeds/%s/tag/{tag}.atom", token.Token),
+		RssPath:   fmt.Sprintf("/feeds/%s/tag/{tag}.rss", token.Token),
+		CreatedAt: token.CreatedAt,
+	})
+}
+
+func (r *resource) deleteToken(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.DeleteToken(c.Request.Context(), authUser.ID())
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Feed token not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete feed token"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) tagFeed(c *gin.Context) {
+	// Feed tokens must not leave the feed reader through links
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	tag, format := splitFormat(c.Param("file"))
+	if tag == "" || format == "" {
+		c.JSON(http.StatusNotFound, errors.NotFound("Feed not found"))
+		return
+	}
+
+	feed, err := r.service.TagFeed(c.Request.Context(), c.Param("token"), tag)
+	r.render(c, feed, err, format, "")
+}
+
+func (r *resource) shareFeed(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Header("X-Robots-Tag", "noindex")
+
+		feed, err := r.service.ShareFeed(c.Request.Context(), c.Param("slug"))
+		r.render(c, feed, err, format, fmt.Sprintf("%s/public/%s", baseUrl(c), c.Param("slug")))
+	}
+}
+
+func (r *resource) render(c *gin.Context, feed Feed, err error, format, alternateUrl string) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Feed not found"))
+			return
+		default:
+			if _, ok := errors.IsPartial(err); !ok {
+				c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get feed"))
+				return
+			}
+			c.Header("X-Partial-Result", "true")
+		}
+	}
+
+	// Feed readers poll, an unchanged feed is not sent again
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Last-Modified", feed.Updated.UTC().Format(http.TimeFormat))
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !feed.Updated.Truncate(time.Second).After(since) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	selfUrl := baseUrl(c) + c.Request.URL.Path
+	var body []byte
+	var contentType string
+	switch format {
+	case formatAtom:
+		body, err = renderAtom(feed, selfUrl, alternateUrl)
+		contentType = mimeAtom
+	default:
+		body, err = renderRss(feed, selfUrl, alternateUrl)
+		contentType = mimeRss
+	}
+	if err != nil {
+		logger.Errorw("Failed to render feed", zap.String("Format", format), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to render feed"))
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// Splits tag.atom and tag.rss into the tag and the format, format is empty for anything else
+func splitFormat(file string) (string, string) {
+	for _, format := range []string{formatAtom, formatRss} {
+		if strings.HasSuffix(file, "."+format) {
+			return strings.TrimSuffix(file, "."+format), format
+		}
+	}
+	return file, ""
+}
+
+// Returns the scheme and host the request was sent to, behind API Gateway the request arrives over http
+func baseUrl(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
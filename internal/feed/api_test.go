@@ -0,0 +1,175 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+	"bookmark-api/pkg/logger"
+)
+
+func TestFeedRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+
+	authenticated := r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "1", Method: "google"})
+	})
+	api.RegisterHandlers(authenticated)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	updatedAt := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	bookmarkId := db.GenerateID()
+	bookmarks := []entity.Bookmark{
+		{Username: "USERNAME_1", ID: "BOOKMARK_" + bookmarkId, Name: "OWASP <Top 10>", Url: "https://owasp.org/Top10/?a=1&b=2", Tags: []string{"security"}, UpdatedAt: updatedAt},
+	}
+	token := entity.FeedToken{Owner: "1", TokenHash: hashToken("feed_token"), CreatedAt: updatedAt}
+
+	t.Run("RotateToken", func(t *testing.T) {
+		s.repository.EXPECT().GetToken(gomock.Any(), "1").Return(entity.FeedToken{}, errors.ErrNotFound).Times(1)
+		s.repository.EXPECT().ReplaceToken(gomock.Any(), gomock.Any(), nil).Return(nil).Times(1)
+
+		resp, err := http.Post(fmt.Sprintf("%s/api/feed-token", ts.URL), "application/json", nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 201, resp.StatusCode)
+
+		var result FeedTokenResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected feed token response, got %v", err)
+		}
+		assert.Equal(t, fmt.Sprintf("/feeds/%s/tag/{tag}.atom", result.Token), result.AtomPath)
+	})
+
+	t.Run("TagFeedAsAtom", func(t *testing.T) {
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), token.TokenHash).Return(token, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "security").Return(bookmarks, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/feeds/feed_token/tag/security.atom", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, mimeAtom, resp.Header.Get("Content-Type"))
+		assert.Equal(t, updatedAt.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+		var result atomFeed
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected atom feed, got %v", err)
+		}
+		assert.Equal(t, "#security", result.Title)
+		assert.Equal(t, "2020-05-01T12:00:00Z", result.Updated)
+		assert.Equal(t, ts.URL+"/feeds/feed_token/tag/security.atom", result.ID)
+		assert.Len(t, result.Entries, 1)
+		assert.Equal(t, "OWASP <Top 10>", result.Entries[0].Title)
+		assert.Equal(t, "https://owasp.org/Top10/?a=1&b=2", result.Entries[0].Link.Href)
+		assert.Equal(t, "urn:uuid:"+bookmarkId, result.Entries[0].ID)
+		assert.NotEmpty(t, result.Entries[0].Published)
+	})
+
+	t.Run("TagFeedAsRss", func(t *testing.T) {
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), token.TokenHash).Return(token, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "security").Return(bookmarks, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/feeds/feed_token/tag/security.rss", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, mimeRss, resp.Header.Get("Content-Type"))
+
+		var result rssFeed
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected rss feed, got %v", err)
+		}
+		assert.Equal(t, "2.0", result.Version)
+		assert.Len(t, result.Channel.Items, 1)
+		assert.Equal(t, "https://owasp.org/Top10/?a=1&b=2", result.Channel.Items[0].Link)
+		assert.Equal(t, "false", result.Channel.Items[0].Guid.IsPermaLink)
+	})
+
+	t.Run("TagFeedNotModified", func(t *testing.T) {
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), token.TokenHash).Return(token, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "security").Return(bookmarks, nil).Times(1)
+
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/feeds/feed_token/tag/security.atom", ts.URL), nil)
+		request.Header.Set("If-Modified-Since", updatedAt.Format(http.TimeFormat))
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 304, resp.StatusCode)
+	})
+
+	t.Run("RotatedToken", func(t *testing.T) {
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), hashToken("feed_old")).Return(entity.FeedToken{}, errors.ErrNotFound).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/feeds/feed_old/tag/security.atom", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/feeds/feed_token/tag/security.json", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("ShareFeed", func(t *testing.T) {
+		s.shareRepository.EXPECT().GetBySlug(gomock.Any(), "slug").
+			Return(entity.Share{Owner: "1", Slug: "slug", Type: entity.ShareTag, Title: "Security reading", Tag: "security"}, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "security").Return(bookmarks, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/public/slug/feed.atom", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result atomFeed
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected atom feed, got %v", err)
+		}
+		assert.Equal(t, "Security reading", result.Title)
+		assert.Contains(t, result.Links, atomLink{Href: ts.URL + "/public/slug", Rel: "alternate", Type: "text/html"})
+	})
+
+	t.Run("RevokedShareFeed", func(t *testing.T) {
+		s.shareRepository.EXPECT().GetBySlug(gomock.Any(), "slug").Return(entity.Share{}, errors.ErrNotFound).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/public/slug/feed.rss", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+}
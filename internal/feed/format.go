@@ -0,0 +1,157 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+const (
+	formatAtom = "atom"
+	formatRss  = "rss"
+
+	mimeAtom = "application/atom+xml; charset=utf-8"
+	mimeRss  = "application/rss+xml; charset=utf-8"
+
+	// Atom requires an author, bookmarks are published on behalf of the service
+	feedAuthor = "Booklog"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published,omitempty"`
+	Updated   string   `xml:"updated"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title   string  `xml:"title"`
+	Link    string  `xml:"link"`
+	Guid    rssGuid `xml:"guid"`
+	PubDate string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Renders the feed as Atom, selfUrl is the url the feed was requested at and alternateUrl its page if any
+func renderAtom(feed Feed, selfUrl, alternateUrl string) ([]byte, error) {
+	atom := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		ID:      selfUrl,
+		Title:   feed.Title,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: selfUrl, Rel: "self", Type: "application/atom+xml"}},
+		Author:  atomAuthor{Name: feedAuthor},
+	}
+	if alternateUrl != "" {
+		atom.Links = append(atom.Links, atomLink{Href: alternateUrl, Rel: "alternate", Type: "text/html"})
+	}
+
+	for _, entry := range feed.Entries {
+		atomEntry := atomEntry{
+			ID:      entryId(entry),
+			Title:   entryTitle(entry),
+			Link:    atomLink{Href: entry.Url},
+			Updated: entry.Updated.UTC().Format(time.RFC3339),
+		}
+		if !entry.Published.IsZero() {
+			atomEntry.Published = entry.Published.UTC().Format(time.RFC3339)
+		}
+		atom.Entries = append(atom.Entries, atomEntry)
+	}
+
+	return marshal(atom)
+}
+
+// Renders the feed as RSS 2.0, selfUrl is the url the feed was requested at and alternateUrl its page if any
+func renderRss(feed Feed, selfUrl, alternateUrl string) ([]byte, error) {
+	link := alternateUrl
+	if link == "" {
+		link = selfUrl
+	}
+
+	rss := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          link,
+			Description:   fmt.Sprintf("Bookmarks of %s", feed.Title),
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, entry := range feed.Entries {
+		// RSS has a single date, the creation is used when it is known
+		pubDate := entry.Published
+		if pubDate.IsZero() {
+			pubDate = entry.Updated
+		}
+		rss.Channel.Items = append(rss.Channel.Items, rssItem{
+			Title:   entryTitle(entry),
+			Link:    entry.Url,
+			Guid:    rssGuid{IsPermaLink: "false", Value: entryId(entry)},
+			PubDate: pubDate.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return marshal(rss)
+}
+
+// Bookmark IDs are UUIDs, which make permanent entry IDs
+func entryId(entry Entry) string {
+	return fmt.Sprintf("urn:uuid:%s", entry.ID)
+}
+
+func entryTitle(entry Entry) string {
+	if entry.Title == "" {
+		return entry.Url
+	}
+	return entry.Title
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: bookmark-api/internal/feed (interfaces: Repository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	entity "bookmark-api/internal/entity"
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockRepository is a mock of Repository interface
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteToken mocks base method
+func (m *MockRepository) DeleteToken(arg0 context.Context, arg1 entity.FeedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteToken indicates an expected call of DeleteToken
+func (mr *MockRepositoryMockRecorder) DeleteToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteToken", reflect.TypeOf((*MockRepository)(nil).DeleteToken), arg0, arg1)
+}
+
+// GetToken mocks base method
+func (m *MockRepository) GetToken(arg0 context.Context, arg1 string) (entity.FeedToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetToken", arg0, arg1)
+	ret0, _ := ret[0].(entity.FeedToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetToken indicates an expected call of GetToken
+func (mr *MockRepositoryMockRecorder) GetToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToken", reflect.TypeOf((*MockRepository)(nil).GetToken), arg0, arg1)
+}
+
+// GetTokenByHash mocks base method
+func (m *MockRepository) GetTokenByHash(arg0 context.Context, arg1 string) (entity.FeedToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTokenByHash", arg0, arg1)
+	ret0, _ := ret[0].(entity.FeedToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTokenByHash indicates an expected call of GetTokenByHash
+func (mr *MockRepositoryMockRecorder) GetTokenByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTokenByHash", reflect.TypeOf((*MockRepository)(nil).GetTokenByHash), arg0, arg1)
+}
+
+// ReplaceToken mocks base method
+func (m *MockRepository) ReplaceToken(arg0 context.Context, arg1 entity.FeedToken, arg2 *entity.FeedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceToken indicates an expected call of ReplaceToken
+func (mr *MockRepositoryMockRecorder) ReplaceToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceToken", reflect.TypeOf((*MockRepository)(nil).ReplaceToken), arg0, arg1, arg2)
+}
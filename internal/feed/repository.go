@@ -0,0 +1,123 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package feed
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	GetToken(ctx context.Context, username string) (entity.FeedToken, error)
+	GetTokenByHash(ctx context.Context, tokenHash string) (entity.FeedToken, error)
+	// Replaces the previous token of the user, which is nil when there is none.
+	// Returns errors.ErrPreconditionFailed when the token has been replaced in the meantime
+	ReplaceToken(ctx context.Context, token entity.FeedToken, previous *entity.FeedToken) error
+	DeleteToken(ctx context.Context, token entity.FeedToken) error
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) GetToken(ctx context.Context, username string) (entity.FeedToken, error) {
+	hashId, rangeId := entity.GetSearchKeyByFeedToken(username)
+	return r.get(ctx, hashId, rangeId)
+}
+
+func (r *repository) GetTokenByHash(ctx context.Context, tokenHash string) (entity.FeedToken, error) {
+	hashId, rangeId := entity.GetSearchKeyByFeedTokenHash(tokenHash)
+	return r.get(ctx, hashId, rangeId)
+}
+
+func (r *repository) get(ctx context.Context, hashId, rangeId string) (entity.FeedToken, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	var result entity.FeedToken
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.FeedToken{}, errors.ErrNotFound
+		default:
+			logger.Errorw("Failed to get feed token", zap.Error(err))
+			return entity.FeedToken{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) ReplaceToken(ctx context.Context, token entity.FeedToken, previous *entity.FeedToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	tx := r.db.WriteTx()
+	ownerPut := table.Put(token.GetOwnerItem())
+	if previous == nil {
+		ownerPut = ownerPut.If("attribute_not_exists($)", "id")
+	} else {
+		ownerPut = ownerPut.If("$ = ?", "token_hash", previous.TokenHash)
+		hashItem := previous.GetHashItem()
+		tx.Delete(table.Delete("id", hashItem.Username).Range("range", hashItem.ID))
+	}
+	tx.Put(ownerPut)
+	tx.Put(table.Put(token.GetHashItem()).If("attribute_not_exists($)", "id"))
+
+	err := tx.RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrPreconditionFailed
+		}
+		logger.Errorw("Failed to replace feed token", zap.String("Username", token.Owner), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteToken(ctx context.Context, token entity.FeedToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	ownerItem, hashItem := token.GetOwnerItem(), token.GetHashItem()
+	err := r.db.WriteTx().
+		Delete(table.Delete("id", ownerItem.Username).Range("range", ownerItem.ID).If("$ = ?", "token_hash", token.TokenHash)).
+		Delete(table.Delete("id", hashItem.Username).Range("range", hashItem.ID)).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrPreconditionFailed
+		}
+		logger.Errorw("Failed to delete feed token", zap.String("Username", token.Owner), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package feed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/bookmark"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/share"
+	"bookmark-api/pkg/db"
+)
+
+const (
+	// Feed tokens are told apart from every other credential by their prefix
+	tokenPrefix = "feed_"
+	tokenBytes  = 24
+	// Rotations retried when another rotation replaced the token in the meantime
+	maxRotateRetries = 3
+	// Most recently updated bookmarks in a feed
+	maxEntries = 50
+)
+
+type Service interface {
+	// Issues a new feed token for the user, the previous one stops working. The token is only returned here
+	RotateToken(ctx context.Context, username string) (Token, error)
+	// Disables the private feeds of the user
+	DeleteToken(ctx context.Context, username string) error
+	// Returns the feed of the bookmarks having the tag, errors.ErrNotFound when the token is unknown
+	TagFeed(ctx context.Context, token, tag string) (Feed, error)
+	// Returns the feed of a public share, errors.ErrNotFound when the slug is unknown or revoked
+	ShareFeed(ctx context.Context, slug string) (Feed, error)
+}
+
+type Token struct {
+	Token     string
+	CreatedAt time.Time
+}
+
+type Feed struct {
+	Title string
+	// Last update of the entries, creation of the feed when it has none
+	Updated time.Time
+	Entries []Entry
+}
+
+type Entry struct {
+	ID    string
+	Title string
+	Url   string
+	// Zero for bookmarks created before their IDs were ordered by time
+	Published time.Time
+	Updated   time.Time
+}
+
+type service struct {
+	repo            Repository
+	bookmarkService bookmark.Service
+	shareService    share.Service
+	logger          *zap.Logger
+}
+
+func NewService(repo Repository, bookmarkService bookmark.Service, shareService share.Service, logger *zap.Logger) Service {
+	return &service{repo, bookmarkService, shareService, logger}
+}
+
+func (s *service) RotateToken(ctx context.Context, username string) (Token, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	token, err := newToken()
+	if err != nil {
+		logger.Errorw("Failed to generate feed token", zap.Error(err))
+		return Token{}, err
+	}
+	feedToken := entity.FeedToken{Owner: username, TokenHash: hashToken(token), CreatedAt: time.Now()}
+
+	for attempt := 0; ; attempt++ {
+		var previous *entity.FeedToken
+		current, err := s.repo.GetToken(ctx, username)
+		switch err {
+		case nil:
+			previous = &current
+		case errors.ErrNotFound:
+		default:
+			return Token{}, err
+		}
+
+		err = s.repo.ReplaceToken(ctx, feedToken, previous)
+		if err == errors.ErrPreconditionFailed && attempt < maxRotateRetries {
+			continue
+		}
+		if err != nil {
+			logger.Errorw("Failed to rotate feed token", zap.Error(err))
+			return Token{}, err
+		}
+
+		return Token{Token: token, CreatedAt: feedToken.CreatedAt}, nil
+	}
+}
+
+func (s *service) DeleteToken(ctx context.Context, username string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	current, err := s.repo.GetToken(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteToken(ctx, current)
+	if err != nil {
+		logger.Errorw("Failed to delete feed token", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) TagFeed(ctx context.Context, token, tag string) (Feed, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return Feed{}, errors.ErrNotFound
+	}
+
+	feedToken, err := s.repo.GetTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		return Feed{}, err
+	}
+
+	bookmarks, err := s.bookmarkService.SearchByTag(ctx, feedToken.Owner, tag)
+	if err != nil {
+		if _, ok := errors.IsPartial(err); !ok {
+			logger.Errorw("Failed to fetch bookmarks of feed", zap.String("Tag", tag), zap.Error(err))
+			return Feed{}, err
+		}
+	}
+
+	return newFeed("#"+tag, feedToken.CreatedAt, bookmarks), err
+}
+
+func (s *service) ShareFeed(ctx context.Context, slug string) (Feed, error) {
+	publicShare, bookmarks, err := s.shareService.Public(ctx, slug)
+	if err != nil {
+		if _, ok := errors.IsPartial(err); !ok {
+			return Feed{}, err
+		}
+	}
+
+	return newFeed(publicShare.Title, publicShare.CreatedAt, bookmarks), err
+}
+
+func newFeed(title string, created time.Time, bookmarks []bookmark.Bookmark) Feed {
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].UpdatedAt.After(bookmarks[j].UpdatedAt)
+	})
+	if len(bookmarks) > maxEntries {
+		bookmarks = bookmarks[:maxEntries]
+	}
+
+	feed := Feed{Title: title, Entries: []Entry{}}
+	for _, b := range bookmarks {
+		published, _ := db.SortableIDTime(b.ID)
+		feed.Entries = append(feed.Entries, Entry{
+			ID:        b.ID,
+			Title:     b.Name,
+			Url:       b.Url,
+			Published: published,
+			Updated:   b.UpdatedAt,
+		})
+		if b.UpdatedAt.After(feed.Updated) {
+			feed.Updated = b.UpdatedAt
+		}
+	}
+	if feed.Updated.IsZero() {
+		feed.Updated = created
+	}
+
+	return feed
+}
+
+func newToken() (string, error) {
+	bytes := make([]byte, tokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/bookmark"
+	bookmarkMocks "bookmark-api/internal/bookmark/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/feed/mocks"
+	"bookmark-api/internal/share"
+	shareMocks "bookmark-api/internal/share/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+type testService struct {
+	Service
+	repository         *mocks.MockRepository
+	bookmarkRepository *bookmarkMocks.MockRepository
+	shareRepository    *shareMocks.MockRepository
+}
+
+func newTestService(ctrl *gomock.Controller) testService {
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockBookmarkRepository := bookmarkMocks.NewMockRepository(ctrl)
+	mockShareRepository := shareMocks.NewMockRepository(ctrl)
+	bookmarkService := bookmark.NewService(mockBookmarkRepository, event.NewBus(zapLogger), zapLogger)
+	shareService := share.NewService(mockShareRepository, bookmarkService, zapLogger)
+	return testService{
+		Service:            NewService(mockRepository, bookmarkService, shareService, zapLogger),
+		repository:         mockRepository,
+		bookmarkRepository: mockBookmarkRepository,
+		shareRepository:    mockShareRepository,
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+	previous := entity.FeedToken{Owner: "1", TokenHash: "old"}
+
+	t.Run("ReplacesPreviousToken", func(t *testing.T) {
+		var replaced entity.FeedToken
+		s.repository.EXPECT().GetToken(gomock.Any(), "1").Return(previous, nil).Times(1)
+		s.repository.EXPECT().ReplaceToken(gomock.Any(), gomock.Any(), &previous).
+			DoAndReturn(func(_ context.Context, token entity.FeedToken, _ *entity.FeedToken) error {
+				replaced = token
+				return nil
+			}).Times(1)
+
+		token, err := s.RotateToken(ctx, "1")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(token.Token, tokenPrefix))
+		// Only the hash of the token is stored
+		assert.Equal(t, hashToken(token.Token), replaced.TokenHash)
+		assert.NotContains(t, replaced.TokenHash, token.Token)
+	})
+
+	t.Run("RetriesConcurrentRotation", func(t *testing.T) {
+		s.repository.EXPECT().GetToken(gomock.Any(), "1").Return(entity.FeedToken{}, errors.ErrNotFound).Times(1)
+		s.repository.EXPECT().GetToken(gomock.Any(), "1").Return(previous, nil).Times(1)
+		gomock.InOrder(
+			s.repository.EXPECT().ReplaceToken(gomock.Any(), gomock.Any(), nil).Return(errors.ErrPreconditionFailed).Times(1),
+			s.repository.EXPECT().ReplaceToken(gomock.Any(), gomock.Any(), &previous).Return(nil).Times(1),
+		)
+
+		_, err := s.RotateToken(ctx, "1")
+		assert.Nil(t, err)
+	})
+}
+
+func TestTagFeed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	ctx := context.Background()
+	created := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("LatestUpdatesFirst", func(t *testing.T) {
+		var bookmarks []entity.Bookmark
+		for i := 0; i < maxEntries+10; i++ {
+			bookmarks = append(bookmarks, entity.Bookmark{
+				Username:  "USERNAME_1",
+				ID:        fmt.Sprintf("BOOKMARK_%d", i),
+				Name:      fmt.Sprint(i),
+				Tags:      []string{"security"},
+				UpdatedAt: created.Add(time.Duration(i) * time.Hour),
+			})
+		}
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), hashToken("feed_token")).
+			Return(entity.FeedToken{Owner: "1", CreatedAt: created}, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "security").Return(bookmarks, nil).Times(1)
+
+		feed, err := s.TagFeed(ctx, "feed_token", "security")
+		assert.Nil(t, err)
+		assert.Equal(t, "#security", feed.Title)
+		assert.Len(t, feed.Entries, maxEntries)
+		assert.Equal(t, fmt.Sprint(maxEntries+9), feed.Entries[0].Title)
+		assert.Equal(t, bookmarks[maxEntries+9].UpdatedAt, feed.Updated)
+	})
+
+	t.Run("EmptyFeedUpdatedAtCreation", func(t *testing.T) {
+		s.repository.EXPECT().GetTokenByHash(gomock.Any(), gomock.Any()).
+			Return(entity.FeedToken{Owner: "1", CreatedAt: created}, nil).Times(1)
+		s.bookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "empty").Return([]entity.Bookmark{}, nil).Times(1)
+
+		feed, err := s.TagFeed(ctx, "feed_token", "empty")
+		assert.Nil(t, err)
+		assert.Empty(t, feed.Entries)
+		assert.Equal(t, created, feed.Updated)
+	})
+
+	t.Run("OtherCredentialsAreNotFeedTokens", func(t *testing.T) {
+		_, err := s.TagFeed(ctx, "eyJhbGciOiJIUzI1NiJ9", "security")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+}
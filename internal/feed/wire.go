@@ -0,0 +1,7 @@
+package feed
+
+import (
+	"github.com/google/wire"
+)
+
+var Inject = wire.NewSet(NewApi, NewRepository, NewService)
@@ -0,0 +1,60 @@
+package magiclink
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/errors"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterPublicHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+// Nothing is registered unless magic links are enabled. Opening the link signs in, see auth.Api
+func (r *resource) RegisterPublicHandlers(rg *gin.RouterGroup) {
+	if !auth.MagicLinksEnabled() {
+		return
+	}
+
+	rg.POST("/signin/email", r.send)
+}
+
+type SendRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *resource) send(c *gin.Context) {
+	request := SendRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	err := r.service.Send(c.Request.Context(), request.Email)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Email is invalid"))
+		case errors.ErrTooManyItems:
+			c.JSON(http.StatusTooManyRequests, errors.TooManyRequests("Too many sign in links sent to this email, try again later"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to send sign in link"))
+		}
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,62 @@
+package magiclink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/magiclink/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestMagicLinkRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	os.Setenv("MAGIC_LINK_URL", "https://api.booklog.link/callback/magic-link")
+	defer os.Unsetenv("MAGIC_LINK_URL")
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	fakeMailer := &fakeMailer{}
+	s := NewService(mockRepository, fakeMailer, logger.NewLogger())
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	send := func(body string) *http.Response {
+		resp, err := http.Post(fmt.Sprintf("%s/signin/email", ts.URL), "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Send", func(t *testing.T) {
+		mockRepository.EXPECT().CountSent(gomock.Any(), "jane@example.com", gomock.Any(), gomock.Any()).Return(1, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		assert.Equal(t, 202, send(`{"email": "jane@example.com"}`).StatusCode)
+		assert.Len(t, fakeMailer.sent, 1)
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		mockRepository.EXPECT().CountSent(gomock.Any(), "jane@example.com", gomock.Any(), gomock.Any()).Return(maxLinksPerWindow+1, nil).Times(1)
+
+		assert.Equal(t, 429, send(`{"email": "jane@example.com"}`).StatusCode)
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		assert.Equal(t, 400, send(`{"email": "jane"}`).StatusCode)
+	})
+}
@@ -0,0 +1,104 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package magiclink
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	Create(ctx context.Context, link entity.MagicLink) error
+	// Deletes the link and returns it, errors.ErrNotFound when it has been used already
+	Consume(ctx context.Context, tokenHash string) (entity.MagicLink, error)
+	// Counts a link sent to the email in the window, returns how many have been sent in it
+	CountSent(ctx context.Context, email string, windowStart, windowEnd time.Time) (int, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, link entity.MagicLink) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(link.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create magic link", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Consume(ctx context.Context, tokenHash string) (entity.MagicLink, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByMagicLink(tokenHash)
+
+	// Deleting it is what spends the link, so two requests can not both use it
+	var result entity.MagicLink
+	err := table.Delete("id", hashId).
+		Range("range", rangeId).
+		If("attribute_exists($)", "id").
+		OldValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.MagicLink{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to consume magic link", zap.Error(err))
+		return entity.MagicLink{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) CountSent(ctx context.Context, email string, windowStart, windowEnd time.Time) (int, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByMagicLinkCount(email, windowStart)
+
+	// The count removes itself once the window is over
+	var result struct {
+		Count int `dynamo:"count"`
+	}
+	err := table.Update("id", hashId).
+		Range("range", rangeId).
+		Add("count", 1).
+		Set("expires_at", windowEnd.Unix()).
+		ValueWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to count magic links", zap.Error(err))
+		return 0, err
+	}
+
+	return result.Count, nil
+}
@@ -0,0 +1,141 @@
+package magiclink
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/mailer"
+)
+
+const (
+	tokenBytes = 32
+	LinkTTL    = 15 * time.Minute
+	// Links sent to an email within a window, so the API can not be used to flood inboxes
+	maxLinksPerWindow = 5
+	rateWindow        = 15 * time.Minute
+	maxEmailLength    = 254
+)
+
+type Service interface {
+	// Mails a link signing the owner of the email in, errors.ErrTooManyItems when too many have been sent lately
+	Send(ctx context.Context, email string) error
+	// Spends the link and returns the email it was sent to, errors.ErrNotFound when it is unknown or used and
+	// errors.ErrExpired when it has expired. See auth.MagicLinkVerifier
+	Verify(ctx context.Context, secret string) (string, error)
+}
+
+type service struct {
+	repo    Repository
+	mailer  mailer.Mailer
+	linkURL string
+	logger  *zap.Logger
+}
+
+// Links point to MAGIC_LINK_URL, the magic link callback of the API
+func NewService(repo Repository, mailer mailer.Mailer, logger *zap.Logger) Service {
+	return &service{repo: repo, mailer: mailer, linkURL: os.Getenv("MAGIC_LINK_URL"), logger: logger}
+}
+
+// Returns the email in lower case, errors.ErrInvalidParam when it is not a bare address
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > maxEmailLength {
+		return "", errors.ErrInvalidParam
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", errors.ErrInvalidParam
+	}
+
+	return email, nil
+}
+
+func (s *service) Send(ctx context.Context, email string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	windowStart := now.Truncate(rateWindow)
+	sent, err := s.repo.CountSent(ctx, email, windowStart, windowStart.Add(rateWindow))
+	if err != nil {
+		return err
+	}
+	if sent > maxLinksPerWindow {
+		logger.Infow("Too many magic links", zap.Int("Sent", sent))
+		return errors.ErrTooManyItems
+	}
+
+	bytes := make([]byte, tokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(bytes)
+
+	err = s.repo.Create(ctx, entity.MagicLink{
+		TokenHash: hashSecret(secret),
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(LinkTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Sign in to Booklog",
+		Body: fmt.Sprintf("Open this link to sign in to Booklog:\n\n%s?token=%s\n\n"+
+			"It works once and expires in %d minutes. If you did not ask for it, ignore this email.\n",
+			s.linkURL, url.QueryEscape(secret), int(LinkTTL.Minutes())),
+	})
+	if err != nil {
+		// The email is not logged, it is personal data
+		logger.Errorw("Failed to send magic link", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Verify(ctx context.Context, secret string) (string, error) {
+	if secret == "" {
+		return "", errors.ErrNotFound
+	}
+
+	link, err := s.repo.Consume(ctx, hashSecret(secret))
+	if err != nil {
+		return "", err
+	}
+	// Expired links stay until DynamoDB removes them, which might take a while
+	if !link.ExpiresAt.After(time.Now()) {
+		return "", errors.ErrExpired
+	}
+
+	return link.Email, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,145 @@
+package magiclink
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/magiclink/mocks"
+	"bookmark-api/pkg/logger"
+	"bookmark-api/pkg/mailer"
+)
+
+type fakeMailer struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (f *fakeMailer) Send(_ context.Context, message mailer.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, message)
+	return nil
+}
+
+// Returns the secret of the link in the mail
+func linkSecret(t *testing.T, message mailer.Message) string {
+	for _, line := range strings.Split(message.Body, "\n") {
+		if strings.HasPrefix(line, "https://") {
+			link, err := url.Parse(line)
+			if err != nil {
+				t.Fatalf("Expected link, got %v", err)
+			}
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("Expected link in mail")
+	return ""
+}
+
+func TestSend(t *testing.T) {
+	os.Setenv("MAGIC_LINK_URL", "https://api.booklog.link/callback/magic-link")
+	defer os.Unsetenv("MAGIC_LINK_URL")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	fakeMailer := &fakeMailer{}
+	magicLinkService := NewService(mockRepository, fakeMailer, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("MailsLinkAndStoresOnlyHash", func(t *testing.T) {
+		var stored entity.MagicLink
+		mockRepository.EXPECT().CountSent(gomock.Any(), "jane@example.com", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, windowStart, windowEnd time.Time) (int, error) {
+				assert.Equal(t, rateWindow, windowEnd.Sub(windowStart))
+				return 1, nil
+			}).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, link entity.MagicLink) error {
+				stored = link
+				return nil
+			}).Times(1)
+
+		assert.Nil(t, magicLinkService.Send(ctx, " Jane@Example.com "))
+		assert.Len(t, fakeMailer.sent, 1)
+
+		message := fakeMailer.sent[0]
+		assert.Equal(t, "jane@example.com", message.To)
+		assert.Contains(t, message.Body, "https://api.booklog.link/callback/magic-link?token=")
+
+		secret := linkSecret(t, message)
+		assert.Equal(t, hashSecret(secret), stored.TokenHash)
+		assert.Equal(t, "jane@example.com", stored.Email)
+		assert.WithinDuration(t, time.Now().Add(LinkTTL), stored.ExpiresAt, time.Minute)
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		mockRepository.EXPECT().CountSent(gomock.Any(), "jane@example.com", gomock.Any(), gomock.Any()).Return(maxLinksPerWindow+1, nil).Times(1)
+
+		assert.Equal(t, errors.ErrTooManyItems, magicLinkService.Send(ctx, "jane@example.com"))
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		for _, email := range []string{"", "jane", "Jane <jane@example.com>", "jane@example.com\r\nBcc: john@example.com"} {
+			assert.Equal(t, errors.ErrInvalidParam, magicLinkService.Send(ctx, email), email)
+		}
+	})
+
+	t.Run("MailerFails", func(t *testing.T) {
+		fakeMailer.err = mailer.ErrNotConfigured
+		defer func() { fakeMailer.err = nil }()
+		mockRepository.EXPECT().CountSent(gomock.Any(), "jane@example.com", gomock.Any(), gomock.Any()).Return(1, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		assert.Equal(t, mailer.ErrNotConfigured, magicLinkService.Send(ctx, "jane@example.com"))
+	})
+}
+
+func TestVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	magicLinkService := NewService(mockRepository, &fakeMailer{}, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepository.EXPECT().Consume(gomock.Any(), hashSecret("secret")).
+			Return(entity.MagicLink{Email: "jane@example.com", ExpiresAt: time.Now().Add(time.Minute)}, nil).Times(1)
+
+		email, err := magicLinkService.Verify(ctx, "secret")
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", email)
+	})
+
+	t.Run("Used", func(t *testing.T) {
+		mockRepository.EXPECT().Consume(gomock.Any(), hashSecret("secret")).Return(entity.MagicLink{}, errors.ErrNotFound).Times(1)
+
+		_, err := magicLinkService.Verify(ctx, "secret")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockRepository.EXPECT().Consume(gomock.Any(), hashSecret("secret")).
+			Return(entity.MagicLink{Email: "jane@example.com", ExpiresAt: time.Now().Add(-time.Minute)}, nil).Times(1)
+
+		_, err := magicLinkService.Verify(ctx, "secret")
+		assert.Equal(t, errors.ErrExpired, err)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := magicLinkService.Verify(ctx, "")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+}
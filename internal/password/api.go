@@ -0,0 +1,139 @@
+package password
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterPublicHandlers(rg *gin.RouterGroup)
+	RegisterHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+// Local users sign up and reset their password without being signed in. Nothing is registered unless local
+// accounts are enabled
+func (r *resource) RegisterPublicHandlers(rg *gin.RouterGroup) {
+	if !auth.LocalAccountsEnabled() {
+		return
+	}
+
+	rg.POST("/signup", r.register)
+	rg.POST("/password/reset", r.reset)
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	if !auth.LocalAccountsEnabled() {
+		return
+	}
+
+	// A leaked API token must not be able to take over the account
+	rg.PUT("/password", session.RequireScope(entity.ScopeAdmin), r.change)
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type ChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r *resource) register(c *gin.Context) {
+	request := RegisterRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	err := r.service.Register(c.Request.Context(), request.Username, request.Password)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Username must be 3 to 64 of a-z, 0-9, '.', '_' and '-', password 10 to 128 characters"))
+		case errors.ErrAlreadyExist:
+			c.JSON(http.StatusConflict, errors.Conflict("Username is taken"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to sign up"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"username": request.Username})
+}
+
+func (r *resource) change(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	if authUser.Method != auth.Local {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Only local users have a password"))
+		return
+	}
+
+	request := ChangeRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	err := r.service.Change(c.Request.Context(), authUser.Username, request.CurrentPassword, request.NewPassword)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Password must be 10 to 128 characters"))
+		case errors.ErrNotFound:
+			c.JSON(http.StatusForbidden, errors.Forbidden("Current password is wrong"))
+		case errors.ErrLocked:
+			c.JSON(http.StatusTooManyRequests, errors.TooManyRequests("Too many failed attempts, try again later"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to change password"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) reset(c *gin.Context) {
+	request := ResetRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	err := r.service.Reset(c.Request.Context(), request.Token, request.Password)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest("Password must be 10 to 128 characters"))
+		case errors.ErrNotFound, errors.ErrExpired:
+			c.JSON(http.StatusGone, errors.Gone("Reset token is invalid, used or expired"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to reset password"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
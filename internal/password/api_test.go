@@ -0,0 +1,136 @@
+package password
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/password/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestPasswordRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	os.Setenv("LOCAL_ACCOUNTS", "true")
+	defer os.Unsetenv("LOCAL_ACCOUNTS")
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	s := NewService(mockRepository, logger.NewLogger())
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+	api.RegisterHandlers(r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane", Method: auth.Local})
+	}))
+	api.RegisterHandlers(r.Group("/google", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: auth.Google})
+	}))
+	api.RegisterHandlers(r.Group("/scoped", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane", Method: auth.Local, Scopes: []string{entity.ScopeWrite}})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	send := func(method, path string, body interface{}) *http.Response {
+		requestBody, _ := json.Marshal(body)
+		request, _ := http.NewRequest(method, fmt.Sprintf("%s%s", ts.URL, path), bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		mockRepository.EXPECT().Exists(gomock.Any(), "jane").Return(false, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		assert.Equal(t, 201, send(http.MethodPost, "/signup", RegisterRequest{Username: "jane", Password: "correct horse battery"}).StatusCode)
+	})
+
+	t.Run("RegisterTaken", func(t *testing.T) {
+		mockRepository.EXPECT().Exists(gomock.Any(), "jane").Return(true, nil).Times(1)
+
+		assert.Equal(t, 409, send(http.MethodPost, "/signup", RegisterRequest{Username: "jane", Password: "correct horse battery"}).StatusCode)
+	})
+
+	t.Run("RegisterInvalid", func(t *testing.T) {
+		assert.Equal(t, 400, send(http.MethodPost, "/signup", RegisterRequest{Username: "jane@example.com", Password: "correct horse battery"}).StatusCode)
+	})
+
+	t.Run("Change", func(t *testing.T) {
+		hash, _ := hashPassword("correct horse battery")
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash}, nil).Times(1)
+		mockRepository.EXPECT().SetPassword(gomock.Any(), "jane", gomock.Any()).Return(nil).Times(1)
+
+		resp := send(http.MethodPut, "/api/password", ChangeRequest{CurrentPassword: "correct horse battery", NewPassword: "correct horse staple"})
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("ChangeLockedOut", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", LockedUntil: time.Now().Add(time.Minute)}, nil).Times(1)
+
+		resp := send(http.MethodPut, "/api/password", ChangeRequest{CurrentPassword: "correct horse battery", NewPassword: "correct horse staple"})
+		assert.Equal(t, 429, resp.StatusCode)
+	})
+
+	t.Run("ChangeOfOtherMethod", func(t *testing.T) {
+		resp := send(http.MethodPut, "/google/password", ChangeRequest{CurrentPassword: "correct horse battery", NewPassword: "correct horse staple"})
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("ChangeWithToken", func(t *testing.T) {
+		resp := send(http.MethodPut, "/scoped/password", ChangeRequest{CurrentPassword: "correct horse battery", NewPassword: "correct horse staple"})
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeResetToken(gomock.Any(), hashSecret("secret")).
+			Return(entity.PasswordResetToken{Username: "jane", ExpiresAt: time.Now().Add(time.Minute)}, nil).Times(1)
+		mockRepository.EXPECT().SetPassword(gomock.Any(), "jane", gomock.Any()).Return(nil).Times(1)
+
+		assert.Equal(t, 200, send(http.MethodPost, "/password/reset", ResetRequest{Token: "secret", Password: "correct horse staple"}).StatusCode)
+	})
+
+	t.Run("ResetUsed", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeResetToken(gomock.Any(), hashSecret("secret")).
+			Return(entity.PasswordResetToken{}, errors.ErrNotFound).Times(1)
+
+		assert.Equal(t, 410, send(http.MethodPost, "/password/reset", ResetRequest{Token: "secret", Password: "correct horse staple"}).StatusCode)
+	})
+}
+
+func TestPasswordRouteDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewService(mocks.NewMockRepository(ctrl), logger.NewLogger())
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, err := http.Post(fmt.Sprintf("%s/signup", ts.URL), "application/json", bytes.NewBufferString(`{}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -0,0 +1,237 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package password
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	// Stores the local user, errors.ErrAlreadyExist when the username is taken by any sign in method
+	Create(ctx context.Context, user entity.User) error
+	// Returns the local user, errors.ErrNotFound when there is none
+	Get(ctx context.Context, username string) (entity.User, error)
+	// Reports whether any sign in method has a user with the username
+	Exists(ctx context.Context, username string) (bool, error)
+	// Counts a failed login, returns the updated user
+	RecordFailedLogin(ctx context.Context, username string) (entity.User, error)
+	Lock(ctx context.Context, username string, until time.Time) error
+	ResetFailedLogins(ctx context.Context, username string) error
+	// Sets the password hash, unlocks the user and signs it out everywhere
+	SetPassword(ctx context.Context, username, passwordHash string) error
+	CreateResetToken(ctx context.Context, token entity.PasswordResetToken) error
+	// Deletes the reset token and returns it, errors.ErrNotFound when it has been used already
+	ConsumeResetToken(ctx context.Context, tokenHash string) (entity.PasswordResetToken, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, user entity.User) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Put(user).If("attribute_not_exists($)", "username").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create local user", zap.String("Username", user.Username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, username string) (entity.User, error) {
+	table := r.db.Table(db.GetTableUser())
+
+	var result entity.User
+	err := table.Get("username", username).
+		Range("method", dynamo.Equal, method).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.User{}, errors.ErrNotFound
+		default:
+			return entity.User{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) Exists(ctx context.Context, username string) (bool, error) {
+	table := r.db.Table(db.GetTableUser())
+
+	count, err := table.Get("username", username).Limit(1).CountWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *repository) RecordFailedLogin(ctx context.Context, username string) (entity.User, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	var result entity.User
+	err := table.Update("username", username).
+		Range("method", method).
+		Add("failed_logins", 1).
+		If("attribute_exists($)", "username").
+		ValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.User{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to record failed login", zap.String("Username", username), zap.Error(err))
+		return entity.User{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) Lock(ctx context.Context, username string, until time.Time) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Update("username", username).
+		Range("method", method).
+		Set("locked_until", until).
+		If("attribute_exists($)", "username").
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to lock user", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ResetFailedLogins(ctx context.Context, username string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Update("username", username).
+		Range("method", method).
+		Remove("failed_logins", "locked_until").
+		If("attribute_exists($)", "username").
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to reset failed logins", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) SetPassword(ctx context.Context, username, passwordHash string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Update("username", username).
+		Range("method", method).
+		Set("password_hash", passwordHash).
+		Remove("failed_logins", "locked_until").
+		Add("token_generation", 1).
+		If("attribute_exists($)", "username").
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to set password", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) CreateResetToken(ctx context.Context, token entity.PasswordResetToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(token.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create password reset token", zap.String("Username", token.Username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ConsumeResetToken(ctx context.Context, tokenHash string) (entity.PasswordResetToken, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByPasswordReset(tokenHash)
+
+	// Deleting it is what spends the token, so two requests can not both use it
+	var result entity.PasswordResetToken
+	err := table.Delete("id", hashId).
+		Range("range", rangeId).
+		If("attribute_exists($)", "id").
+		OldValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.PasswordResetToken{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to consume password reset token", zap.Error(err))
+		return entity.PasswordResetToken{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,253 @@
+package password
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+)
+
+const (
+	method = string(auth.Local)
+
+	minPasswordLength = 10
+	// Hashing is expensive, very long passwords must not keep the API busy
+	maxPasswordLength = 128
+	// Failed logins in a row which lock the user out
+	maxFailedLogins = 5
+	lockoutDuration = 15 * time.Minute
+	resetTokenBytes = 32
+	ResetTokenTTL   = time.Hour
+)
+
+// Usernames of other sign in methods are emails, local ones never contain @ so they can not take them over
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,64}$`)
+
+// Users who do not exist are checked against this hash, so they take as long as the ones who do
+var dummyHash, _ = hashPassword("not the password of anyone")
+
+type Service interface {
+	// Creates a local user, errors.ErrAlreadyExist when the username is taken by any sign in method
+	Register(ctx context.Context, username, password string) error
+	// Checks the password of the local user, see auth.PasswordVerifier. Failed logins in a row lock the user out
+	Verify(ctx context.Context, username, password string) error
+	// Sets a new password and signs the user out everywhere
+	Change(ctx context.Context, username, currentPassword, newPassword string) error
+	// Issues a single use token to set a new password. The secret is only returned here
+	CreateResetToken(ctx context.Context, username string) (string, error)
+	// Sets a new password with a reset token and signs the user out everywhere
+	Reset(ctx context.Context, secret, newPassword string) error
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo, logger}
+}
+
+func IsValidUsername(username string) bool {
+	return usernamePattern.MatchString(username)
+}
+
+func isValidPassword(password string) bool {
+	return len(password) >= minPasswordLength && len(password) <= maxPasswordLength
+}
+
+func (s *service) Register(ctx context.Context, username, password string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if !IsValidUsername(username) || !isValidPassword(password) {
+		return errors.ErrInvalidParam
+	}
+
+	exists, err := s.repo.Exists(ctx, username)
+	if err != nil {
+		logger.Errorw("Failed to check username", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+	if exists {
+		return errors.ErrAlreadyExist
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Registered users get their account right away, it must never be the one of an earlier user of the name
+	return s.repo.Create(ctx, entity.User{
+		Username:     username,
+		Method:       method,
+		UserID:       user.NewID(),
+		LastLoginAt:  time.Now(),
+		PasswordHash: passwordHash,
+	})
+}
+
+func (s *service) Verify(ctx context.Context, username, password string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if len(password) > maxPasswordLength {
+		return errors.ErrNotFound
+	}
+
+	user, err := s.repo.Get(ctx, username)
+	switch err {
+	case nil:
+	case errors.ErrNotFound:
+		_, _ = verifyPassword(password, dummyHash)
+		return errors.ErrNotFound
+	default:
+		return err
+	}
+
+	if user.LockedUntil.After(time.Now()) {
+		return errors.ErrLocked
+	}
+
+	ok, err := verifyPassword(password, user.PasswordHash)
+	if err != nil {
+		logger.Errorw("Failed to verify password", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+	if !ok {
+		return s.recordFailedLogin(ctx, username)
+	}
+
+	if user.FailedLogins > 0 {
+		err = s.repo.ResetFailedLogins(ctx, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) recordFailedLogin(ctx context.Context, username string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	user, err := s.repo.RecordFailedLogin(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if user.FailedLogins >= maxFailedLogins {
+		logger.Infow("Locking out user", zap.String("Username", username), zap.Int("FailedLogins", user.FailedLogins))
+		err = s.repo.Lock(ctx, username, time.Now().Add(lockoutDuration))
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.ErrNotFound
+}
+
+func (s *service) Change(ctx context.Context, username, currentPassword, newPassword string) error {
+	if !isValidPassword(newPassword) {
+		return errors.ErrInvalidParam
+	}
+
+	err := s.Verify(ctx, username, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.setPassword(ctx, username, newPassword)
+}
+
+func (s *service) CreateResetToken(ctx context.Context, username string) (string, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	_, err := s.repo.Get(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	bytes := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(bytes)
+
+	now := time.Now()
+	err = s.repo.CreateResetToken(ctx, entity.PasswordResetToken{
+		TokenHash: hashSecret(secret),
+		Username:  username,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ResetTokenTTL),
+	})
+	if err != nil {
+		logger.Errorw("Failed to create password reset token", zap.String("Username", username), zap.Error(err))
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s *service) Reset(ctx context.Context, secret, newPassword string) error {
+	if !isValidPassword(newPassword) {
+		return errors.ErrInvalidParam
+	}
+
+	token, err := s.repo.ConsumeResetToken(ctx, hashSecret(secret))
+	if err != nil {
+		return err
+	}
+	// Expired tokens stay until DynamoDB removes them, which might take a while
+	if !token.ExpiresAt.After(time.Now()) {
+		return errors.ErrExpired
+	}
+
+	return s.setPassword(ctx, token.Username, newPassword)
+}
+
+func (s *service) setPassword(ctx context.Context, username, password string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.SetPassword(ctx, username, passwordHash)
+	if err != nil {
+		logger.Errorw("Failed to set password", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
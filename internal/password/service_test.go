@@ -0,0 +1,223 @@
+package password
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/password/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse battery")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$"))
+	assert.NotContains(t, hash, "correct horse battery")
+
+	other, _ := hashPassword("correct horse battery")
+	assert.NotEqual(t, hash, other, "Salts differ")
+
+	ok, err := verifyPassword("correct horse battery", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = verifyPassword("correct horse staple", hash)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = verifyPassword("correct horse battery", "$2a$10$bcrypt")
+	assert.Equal(t, errInvalidHash, err)
+}
+
+func TestRegister(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	passwordService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("StoresHash", func(t *testing.T) {
+		mockRepository.EXPECT().Exists(gomock.Any(), "jane.doe").Return(false, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, user entity.User) error {
+				assert.Equal(t, "local", user.Method)
+				assert.True(t, strings.HasPrefix(user.UserID, "user:"))
+				ok, _ := verifyPassword("correct horse battery", user.PasswordHash)
+				assert.True(t, ok)
+				return nil
+			}).Times(1)
+
+		assert.Nil(t, passwordService.Register(ctx, "jane.doe", "correct horse battery"))
+	})
+
+	t.Run("UsernameTakenByOtherMethod", func(t *testing.T) {
+		mockRepository.EXPECT().Exists(gomock.Any(), "jane").Return(true, nil).Times(1)
+
+		assert.Equal(t, errors.ErrAlreadyExist, passwordService.Register(ctx, "jane", "correct horse battery"))
+	})
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		for _, username := range []string{"ja", "Jane", "jane@example.com", "workspace:1", strings.Repeat("j", 65)} {
+			assert.Equal(t, errors.ErrInvalidParam, passwordService.Register(ctx, username, "correct horse battery"), username)
+		}
+		assert.Equal(t, errors.ErrInvalidParam, passwordService.Register(ctx, "jane", "short"))
+		assert.Equal(t, errors.ErrInvalidParam, passwordService.Register(ctx, "jane", strings.Repeat("p", 129)))
+	})
+}
+
+func TestVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	passwordService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	hash, _ := hashPassword("correct horse battery")
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash}, nil).Times(1)
+
+		assert.Nil(t, passwordService.Verify(ctx, "jane", "correct horse battery"))
+	})
+
+	t.Run("SuccessResetsFailedLogins", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash, FailedLogins: 3}, nil).Times(1)
+		mockRepository.EXPECT().ResetFailedLogins(gomock.Any(), "jane").Return(nil).Times(1)
+
+		assert.Nil(t, passwordService.Verify(ctx, "jane", "correct horse battery"))
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "john").Return(entity.User{}, errors.ErrNotFound).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, passwordService.Verify(ctx, "john", "correct horse battery"))
+	})
+
+	t.Run("WrongPasswordIsCounted", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash}, nil).Times(1)
+		mockRepository.EXPECT().RecordFailedLogin(gomock.Any(), "jane").Return(entity.User{FailedLogins: 1}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, passwordService.Verify(ctx, "jane", "wrong password"))
+	})
+
+	t.Run("LocksOutAfterFailedLogins", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash, FailedLogins: 4}, nil).Times(1)
+		mockRepository.EXPECT().RecordFailedLogin(gomock.Any(), "jane").Return(entity.User{FailedLogins: maxFailedLogins}, nil).Times(1)
+		mockRepository.EXPECT().Lock(gomock.Any(), "jane", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, until time.Time) error {
+				assert.WithinDuration(t, time.Now().Add(lockoutDuration), until, time.Minute)
+				return nil
+			}).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, passwordService.Verify(ctx, "jane", "wrong password"))
+	})
+
+	t.Run("LockedOutRejectsCorrectPassword", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").
+			Return(entity.User{Username: "jane", PasswordHash: hash, FailedLogins: 5, LockedUntil: time.Now().Add(time.Minute)}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrLocked, passwordService.Verify(ctx, "jane", "correct horse battery"))
+	})
+
+	t.Run("LockExpired", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").
+			Return(entity.User{Username: "jane", PasswordHash: hash, FailedLogins: 5, LockedUntil: time.Now().Add(-time.Minute)}, nil).Times(1)
+		mockRepository.EXPECT().ResetFailedLogins(gomock.Any(), "jane").Return(nil).Times(1)
+
+		assert.Nil(t, passwordService.Verify(ctx, "jane", "correct horse battery"))
+	})
+}
+
+func TestChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	passwordService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	hash, _ := hashPassword("correct horse battery")
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash}, nil).Times(1)
+		mockRepository.EXPECT().SetPassword(gomock.Any(), "jane", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, passwordHash string) error {
+				ok, _ := verifyPassword("correct horse staple", passwordHash)
+				assert.True(t, ok)
+				return nil
+			}).Times(1)
+
+		assert.Nil(t, passwordService.Change(ctx, "jane", "correct horse battery", "correct horse staple"))
+	})
+
+	t.Run("WrongCurrentPassword", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane", PasswordHash: hash}, nil).Times(1)
+		mockRepository.EXPECT().RecordFailedLogin(gomock.Any(), "jane").Return(entity.User{FailedLogins: 1}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, passwordService.Change(ctx, "jane", "wrong password", "correct horse staple"))
+	})
+
+	t.Run("InvalidNewPassword", func(t *testing.T) {
+		assert.Equal(t, errors.ErrInvalidParam, passwordService.Change(ctx, "jane", "correct horse battery", "short"))
+	})
+}
+
+func TestReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	passwordService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("StoresOnlyHash", func(t *testing.T) {
+		var stored entity.PasswordResetToken
+		mockRepository.EXPECT().Get(gomock.Any(), "jane").Return(entity.User{Username: "jane"}, nil).Times(1)
+		mockRepository.EXPECT().CreateResetToken(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, token entity.PasswordResetToken) error {
+				stored = token
+				return nil
+			}).Times(1)
+
+		secret, err := passwordService.CreateResetToken(ctx, "jane")
+		assert.Nil(t, err)
+		assert.Equal(t, hashSecret(secret), stored.TokenHash)
+		assert.WithinDuration(t, time.Now().Add(ResetTokenTTL), stored.ExpiresAt, time.Minute)
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "john").Return(entity.User{}, errors.ErrNotFound).Times(1)
+
+		_, err := passwordService.CreateResetToken(ctx, "john")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeResetToken(gomock.Any(), hashSecret("secret")).
+			Return(entity.PasswordResetToken{Username: "jane", ExpiresAt: time.Now().Add(time.Minute)}, nil).Times(1)
+		mockRepository.EXPECT().SetPassword(gomock.Any(), "jane", gomock.Any()).Return(nil).Times(1)
+
+		assert.Nil(t, passwordService.Reset(ctx, "secret", "correct horse staple"))
+	})
+
+	t.Run("Used", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeResetToken(gomock.Any(), hashSecret("secret")).
+			Return(entity.PasswordResetToken{}, errors.ErrNotFound).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, passwordService.Reset(ctx, "secret", "correct horse staple"))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeResetToken(gomock.Any(), hashSecret("secret")).
+			Return(entity.PasswordResetToken{Username: "jane", ExpiresAt: time.Now().Add(-time.Minute)}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrExpired, passwordService.Reset(ctx, "secret", "correct horse staple"))
+	})
+}
@@ -0,0 +1,167 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package refresh
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	CreateFamily(ctx context.Context, family entity.RefreshFamily) error
+	GetFamily(ctx context.Context, familyId string) (entity.RefreshFamily, error)
+	// Revokes every token of the family, errors.ErrNotFound when there is no such family
+	RevokeFamily(ctx context.Context, familyId string, revokedAt time.Time) error
+	Create(ctx context.Context, token entity.RefreshToken) error
+	Get(ctx context.Context, tokenHash string) (entity.RefreshToken, error)
+	// Marks the token used and returns it, errors.ErrNotFound when it is unknown or has been used already
+	Use(ctx context.Context, tokenHash string, usedAt time.Time) (entity.RefreshToken, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) CreateFamily(ctx context.Context, family entity.RefreshFamily) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(family.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create refresh token family", zap.String("UserID", family.UserID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetFamily(ctx context.Context, familyId string) (entity.RefreshFamily, error) {
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByRefreshFamily(familyId)
+
+	var result entity.RefreshFamily
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.RefreshFamily{}, errors.ErrNotFound
+		default:
+			return entity.RefreshFamily{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) RevokeFamily(ctx context.Context, familyId string, revokedAt time.Time) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByRefreshFamily(familyId)
+
+	err := table.Update("id", hashId).
+		Range("range", rangeId).
+		Set("revoked_at", revokedAt).
+		If("attribute_exists($)", "id").
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to revoke refresh token family", zap.String("FamilyID", familyId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Create(ctx context.Context, token entity.RefreshToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(token.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create refresh token", zap.String("FamilyID", token.FamilyID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, tokenHash string) (entity.RefreshToken, error) {
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByRefreshToken(tokenHash)
+
+	var result entity.RefreshToken
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.RefreshToken{}, errors.ErrNotFound
+		default:
+			return entity.RefreshToken{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) Use(ctx context.Context, tokenHash string, usedAt time.Time) (entity.RefreshToken, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByRefreshToken(tokenHash)
+
+	// The condition is what makes the token single use, so two requests can not both rotate it
+	var result entity.RefreshToken
+	err := table.Update("id", hashId).
+		Range("range", rangeId).
+		Set("used_at", usedAt).
+		If("attribute_exists($) AND attribute_not_exists($)", "id", "used_at").
+		ValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.RefreshToken{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to use refresh token", zap.Error(err))
+		return entity.RefreshToken{}, err
+	}
+
+	return result, nil
+}
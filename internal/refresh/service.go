@@ -0,0 +1,168 @@
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+const (
+	tokenBytes = 32
+	// Prefix of refresh tokens, so they are not mistaken for JWTs or API tokens
+	TokenPrefix = "rt_"
+	// Time a refresh token can be used in, clients which stay away longer sign in again
+	TokenTTL = 30 * 24 * time.Hour
+	// Time a family can be refreshed in since the sign in
+	FamilyTTL = 90 * 24 * time.Hour
+)
+
+type Service interface {
+	// Starts a family of refresh tokens for the identity signing in and returns its first token
+	Issue(ctx context.Context, family entity.RefreshFamily) (string, entity.RefreshFamily, error)
+	// Spends the refresh token and returns the next one of its family, errors.ErrNotFound when it is unknown and
+	// errors.ErrExpired when it or its family has expired or been revoked. Using a token a second time revokes its
+	// family, since either the client or someone who stole it used it before, and returns errors.ErrAborted
+	Rotate(ctx context.Context, secret string) (string, entity.RefreshFamily, error)
+	// Revokes every token of the family
+	Revoke(ctx context.Context, familyId string) error
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo, logger}
+}
+
+func (s *service) Issue(ctx context.Context, family entity.RefreshFamily) (string, entity.RefreshFamily, error) {
+	now := time.Now()
+	family.ID = db.GenerateID()
+	family.CreatedAt = now
+	family.ExpiresAt = now.Add(FamilyTTL)
+
+	err := s.repo.CreateFamily(ctx, family)
+	if err != nil {
+		return "", entity.RefreshFamily{}, err
+	}
+
+	secret, err := s.create(ctx, family, now)
+	if err != nil {
+		return "", entity.RefreshFamily{}, err
+	}
+
+	return secret, family, nil
+}
+
+// Creates the next token of the family, it expires with the family at the latest
+func (s *service) create(ctx context.Context, family entity.RefreshFamily, now time.Time) (string, error) {
+	bytes := make([]byte, tokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	secret := TokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	expiresAt := now.Add(TokenTTL)
+	if family.ExpiresAt.Before(expiresAt) {
+		expiresAt = family.ExpiresAt
+	}
+
+	err := s.repo.Create(ctx, entity.RefreshToken{
+		TokenHash: hashSecret(secret),
+		FamilyID:  family.ID,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s *service) Rotate(ctx context.Context, secret string) (string, entity.RefreshFamily, error) {
+	if secret == "" {
+		return "", entity.RefreshFamily{}, errors.ErrNotFound
+	}
+
+	now := time.Now()
+	tokenHash := hashSecret(secret)
+	token, err := s.repo.Use(ctx, tokenHash, now)
+	switch err {
+	case nil:
+	case errors.ErrNotFound:
+		return "", entity.RefreshFamily{}, s.checkReuse(ctx, tokenHash)
+	default:
+		return "", entity.RefreshFamily{}, err
+	}
+	// Expired tokens stay until DynamoDB removes them, which might take a while
+	if !token.ExpiresAt.After(now) {
+		return "", entity.RefreshFamily{}, errors.ErrExpired
+	}
+
+	family, err := s.repo.GetFamily(ctx, token.FamilyID)
+	if err != nil {
+		return "", entity.RefreshFamily{}, err
+	}
+	if family.IsRevoked() || !family.ExpiresAt.After(now) {
+		return "", entity.RefreshFamily{}, errors.ErrExpired
+	}
+
+	next, err := s.create(ctx, family, now)
+	if err != nil {
+		return "", entity.RefreshFamily{}, err
+	}
+
+	return next, family, nil
+}
+
+// Revokes the family of a token which could not be used, when it has been used before
+func (s *service) checkReuse(ctx context.Context, tokenHash string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	token, err := s.repo.Get(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	logger.Warnw("Refresh token used again, revoking its family", zap.String("FamilyID", token.FamilyID))
+	err = s.Revoke(ctx, token.FamilyID)
+	if err != nil && err != errors.ErrNotFound {
+		return err
+	}
+
+	return errors.ErrAborted
+}
+
+func (s *service) Revoke(ctx context.Context, familyId string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	err := s.repo.RevokeFamily(ctx, familyId, time.Now())
+	if err != nil {
+		logger.Errorw("Failed to revoke refresh tokens", zap.String("FamilyID", familyId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+package refresh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/refresh/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestIssue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	refreshService := NewService(mockRepository, logger.NewLogger())
+
+	var created entity.RefreshToken
+	mockRepository.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, family entity.RefreshFamily) error {
+			assert.NotEmpty(t, family.ID)
+			assert.Equal(t, "user:1", family.UserID)
+			assert.WithinDuration(t, time.Now().Add(FamilyTTL), family.ExpiresAt, time.Minute)
+			return nil
+		}).Times(1)
+	mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, token entity.RefreshToken) error {
+			created = token
+			return nil
+		}).Times(1)
+
+	secret, family, err := refreshService.Issue(context.Background(), entity.RefreshFamily{Username: "jane", Method: "google", UserID: "user:1"})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(secret, TokenPrefix))
+	// Only the hash is stored
+	assert.Equal(t, hashSecret(secret), created.TokenHash)
+	assert.NotEqual(t, secret, created.TokenHash)
+	assert.Equal(t, family.ID, created.FamilyID)
+	assert.WithinDuration(t, time.Now().Add(TokenTTL), created.ExpiresAt, time.Minute)
+}
+
+func TestRotate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	refreshService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	family := entity.RefreshFamily{ID: "family", Username: "jane", Method: "google", ExpiresAt: time.Now().Add(time.Hour)}
+	token := entity.RefreshToken{TokenHash: hashSecret("rt_secret"), FamilyID: "family", ExpiresAt: time.Now().Add(time.Minute)}
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepository.EXPECT().Use(gomock.Any(), hashSecret("rt_secret"), gomock.Any()).Return(token, nil).Times(1)
+		mockRepository.EXPECT().GetFamily(gomock.Any(), "family").Return(family, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, next entity.RefreshToken) error {
+				assert.Equal(t, "family", next.FamilyID)
+				// Tokens do not outlive their family
+				assert.Equal(t, family.ExpiresAt, next.ExpiresAt)
+				return nil
+			}).Times(1)
+
+		next, rotated, err := refreshService.Rotate(ctx, "rt_secret")
+		assert.Nil(t, err)
+		assert.NotEqual(t, "rt_secret", next)
+		assert.Equal(t, "jane", rotated.Username)
+	})
+
+	t.Run("Reused", func(t *testing.T) {
+		mockRepository.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).Return(entity.RefreshToken{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().Get(gomock.Any(), hashSecret("rt_secret")).Return(token, nil).Times(1)
+		mockRepository.EXPECT().RevokeFamily(gomock.Any(), "family", gomock.Any()).Return(nil).Times(1)
+
+		_, _, err := refreshService.Rotate(ctx, "rt_secret")
+		assert.Equal(t, errors.ErrAborted, err)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		mockRepository.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).Return(entity.RefreshToken{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().Get(gomock.Any(), gomock.Any()).Return(entity.RefreshToken{}, errors.ErrNotFound).Times(1)
+
+		_, _, err := refreshService.Rotate(ctx, "rt_unknown")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := token
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		mockRepository.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).Return(expired, nil).Times(1)
+
+		_, _, err := refreshService.Rotate(ctx, "rt_secret")
+		assert.Equal(t, errors.ErrExpired, err)
+	})
+
+	t.Run("RevokedFamily", func(t *testing.T) {
+		revoked := family
+		revoked.RevokedAt = time.Now()
+		mockRepository.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).Return(token, nil).Times(1)
+		mockRepository.EXPECT().GetFamily(gomock.Any(), "family").Return(revoked, nil).Times(1)
+
+		_, _, err := refreshService.Rotate(ctx, "rt_secret")
+		assert.Equal(t, errors.ErrExpired, err)
+	})
+
+	t.Run("UseFails", func(t *testing.T) {
+		mockRepository.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any()).Return(entity.RefreshToken{}, fmt.Errorf("Timeout")).Times(1)
+
+		_, _, err := refreshService.Rotate(ctx, "rt_secret")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, _, err := refreshService.Rotate(ctx, "")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+}
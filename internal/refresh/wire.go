@@ -0,0 +1,7 @@
+package refresh
+
+import (
+	"github.com/google/wire"
+)
+
+var Inject = wire.NewSet(NewRepository, NewService)
@@ -1,11 +1,30 @@
 package session
 
 import (
+	"net/http"
+
 	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ID of the implicit workspace every user has on its own
+const PersonalWorkspace = "personal"
+
+// Workspace a request works on and the role of the current user in it
+type Workspace struct {
+	ID string
+	// Owner of the bookmarks of the workspace, the user ID for the personal workspace
+	Owner string
+	Role  string
+}
+
+func (w *Workspace) IsPersonal() bool {
+	return w.ID == PersonalWorkspace
+}
+
 func GetCurrentUser(ctx *gin.Context) *auth.AuthUser {
 	authUser, exist := ctx.Get("user")
 	if exist {
@@ -14,3 +33,60 @@ func GetCurrentUser(ctx *gin.Context) *auth.AuthUser {
 
 	return nil
 }
+
+func SetWorkspace(ctx *gin.Context, workspace *Workspace) {
+	ctx.Set("workspace", workspace)
+}
+
+// Returns the workspace set for the request, the personal workspace of the current user when there is none
+func GetWorkspace(ctx *gin.Context) *Workspace {
+	workspace, exist := ctx.Get("workspace")
+	if exist {
+		return workspace.(*Workspace)
+	}
+
+	authUser := GetCurrentUser(ctx)
+	if authUser == nil {
+		return nil
+	}
+	return &Workspace{ID: PersonalWorkspace, Owner: authUser.ID(), Role: entity.RoleOwner}
+}
+
+// Rejects requests of users without the required role in the workspace
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		workspace := GetWorkspace(ctx)
+		if workspace == nil || !entity.HasRole(workspace.Role, role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.Forbidden("Your role in the workspace does not allow this"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Rejects requests of users without the admin role of the API. API tokens never carry roles
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authUser := GetCurrentUser(ctx)
+		if authUser == nil || !authUser.HasRole(auth.RoleAdmin) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.Forbidden("Only admins may do this"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Rejects requests signed with API tokens which lack the scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authUser := GetCurrentUser(ctx)
+		if authUser == nil || !authUser.HasScope(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.Forbidden("Token scope does not allow this"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,206 @@
+package share
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/bookmark"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+// Slugs are 16 random bytes in unpadded base64url
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+	// Routes served without authentication
+	RegisterPublicHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	rg.POST("/shares", r.create)
+	rg.GET("/shares", r.list)
+	rg.DELETE("/shares/:slug", r.delete)
+}
+
+func (r *resource) RegisterPublicHandlers(rg *gin.RouterGroup) {
+	rg.GET("/public/:slug", r.public)
+}
+
+type CreateShareRequest struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// Tag to share, for tag shares
+	Tag string `json:"tag"`
+	// Bookmarks to share, for collection shares
+	BookmarkIDs []string `json:"bookmark_ids"`
+	// Prefix of the bookmark names to share, for search shares
+	Query string `json:"query"`
+}
+
+type ShareResponse struct {
+	Slug        string    `json:"slug"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title"`
+	Tag         string    `json:"tag,omitempty"`
+	BookmarkIDs []string  `json:"bookmark_ids,omitempty"`
+	Query       string    `json:"query,omitempty"`
+	Path        string    `json:"path"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newShareResponse(share Share) ShareResponse {
+	return ShareResponse{
+		Slug:        share.Slug,
+		Type:        share.Type,
+		Title:       share.Title,
+		Tag:         share.Tag,
+		BookmarkIDs: share.BookmarkIDs,
+		Query:       share.Query,
+		Path:        fmt.Sprintf("/public/%s", share.Slug),
+		CreatedAt:   share.CreatedAt,
+	}
+}
+
+// Public view of a share, nothing but the title and the names and urls of the bookmarks
+type PublicShareResponse struct {
+	Title     string                   `json:"title"`
+	CreatedAt time.Time                `json:"created_at"`
+	Bookmarks []PublicBookmarkResponse `json:"bookmarks"`
+}
+
+type PublicBookmarkResponse struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+func newPublicShareResponse(share Share, bookmarks []bookmark.Bookmark) PublicShareResponse {
+	return PublicShareResponse{
+		Title:     share.Title,
+		CreatedAt: share.CreatedAt,
+		Bookmarks: funk.Map(bookmarks, func(b bookmark.Bookmark) PublicBookmarkResponse {
+			return PublicBookmarkResponse{Name: b.Name, Url: b.Url}
+		}).([]PublicBookmarkResponse),
+	}
+}
+
+func (r *resource) create(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := CreateShareRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	if !funk.ContainsString(ShareTypes, request.Type) {
+		c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Type must be one of %s", strings.Join(ShareTypes, ", "))))
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	share, err := r.service.Create(c.Request.Context(), authUser.ID(), Share{
+		Type:        request.Type,
+		Title:       request.Title,
+		Tag:         request.Tag,
+		BookmarkIDs: request.BookmarkIDs,
+		Query:       request.Query,
+	})
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(
+				fmt.Sprintf("Share needs a tag, a query or 1 to %d existing bookmarks by its type, and a title of at most %d characters", maxCollectionSize, maxTitleLength)))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create share"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newShareResponse(share))
+}
+
+func (r *resource) list(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	shares, err := r.service.List(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list shares"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(shares, newShareResponse))
+}
+
+func (r *resource) delete(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.Delete(c.Request.Context(), authUser.ID(), c.Param("slug"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Share not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete share"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) public(c *gin.Context) {
+	// Revoked shares must disappear right away, and the slug must not leave the page through links
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	slug := c.Param("slug")
+	if !slugPattern.MatchString(slug) {
+		c.JSON(http.StatusNotFound, errors.NotFound("Share not found"))
+		return
+	}
+
+	share, bookmarks, err := r.service.Public(c.Request.Context(), slug)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Share not found"))
+			return
+		default:
+			if _, ok := errors.IsPartial(err); !ok {
+				c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get share"))
+				return
+			}
+			c.Header("X-Partial-Result", "true")
+		}
+	}
+
+	response := newPublicShareResponse(share, bookmarks)
+	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) {
+	case gin.MIMEHTML:
+		c.Render(http.StatusOK, render.HTML{Template: publicPage, Data: response})
+	default:
+		c.JSON(http.StatusOK, response)
+	}
+}
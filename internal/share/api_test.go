@@ -0,0 +1,145 @@
+package share
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/bookmark"
+	bookmarkMocks "bookmark-api/internal/bookmark/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/share/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestShareRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockBookmarkRepository := bookmarkMocks.NewMockRepository(ctrl)
+	shareService := NewService(mockRepository, bookmark.NewService(mockBookmarkRepository, event.NewBus(zapLogger), zapLogger), zapLogger)
+	api := NewApi(shareService, zapLogger)
+
+	r := gin.Default()
+	api.RegisterPublicHandlers(r.Group("/"))
+
+	authenticated := r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "1", Method: "google"})
+	})
+	api.RegisterHandlers(authenticated)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	slug := "AAAAAAAAAAAAAAAAAAAAAA"
+	share := entity.Share{Owner: "1", Slug: slug, Type: entity.ShareTag, Title: "Go <onboarding>", Tag: "go"}
+	bookmarks := []entity.Bookmark{
+		{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go", "secret"}},
+		{Username: "USERNAME_1", ID: "BOOKMARK_3", Name: "Trap", Url: "javascript:alert(1)", Tags: []string{"go"}},
+	}
+
+	t.Run("CreateShare", func(t *testing.T) {
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, share entity.Share) (entity.Share, error) {
+				return share.GetOwnerItem(), nil
+			}).Times(1)
+
+		resp, err := http.Post(fmt.Sprintf("%s/api/shares", ts.URL), "application/json",
+			strings.NewReader(`{"type": "tag", "tag": "go", "title": "Go onboarding"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 201, resp.StatusCode)
+
+		var result ShareResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected share response, got %v", err)
+		}
+		assert.Equal(t, "Go onboarding", result.Title)
+		assert.Equal(t, "/public/"+result.Slug, result.Path)
+	})
+
+	t.Run("CreateUnknownType", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("%s/api/shares", ts.URL), "application/json", strings.NewReader(`{"type": "all"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("PublicAsJson", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), slug).Return(share, nil).Times(1)
+		mockBookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "go").Return(bookmarks[:1], nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/public/%s", ts.URL, slug))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "no-referrer", resp.Header.Get("Referrer-Policy"))
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.JSONEq(t, `{"title": "Go <onboarding>", "created_at": "0001-01-01T00:00:00Z", "bookmarks": [{"name": "Go", "url": "https://go.dev"}]}`, string(body))
+	})
+
+	t.Run("PublicAsHtml", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), slug).Return(share, nil).Times(1)
+		mockBookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "go").Return(bookmarks, nil).Times(1)
+
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/public/%s", ts.URL, slug), nil)
+		request.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		page := string(body)
+		assert.Contains(t, page, "Go &lt;onboarding&gt;")
+		assert.Contains(t, page, `href="https://go.dev"`)
+		assert.NotContains(t, page, `href="javascript:`)
+		assert.NotContains(t, page, "secret")
+	})
+
+	t.Run("PublicRevoked", func(t *testing.T) {
+		mockRepository.EXPECT().Delete(gomock.Any(), "1", slug).Return(nil).Times(1)
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), slug).Return(entity.Share{}, errors.ErrNotFound).Times(1)
+
+		request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/shares/%s", ts.URL, slug), nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		resp, err = http.Get(fmt.Sprintf("%s/public/%s", ts.URL, slug))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("PublicMalformedSlug", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/public/short", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+}
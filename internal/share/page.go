@@ -0,0 +1,28 @@
+package share
+
+import "html/template"
+
+// Page of a public share for browsers, html/template escapes the bookmarks and drops unsafe urls
+var publicPage = template.Must(template.New("public").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<meta name="referrer" content="no-referrer">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+li { margin: 0.5em 0; }
+small { color: #666; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Bookmarks}}<ul>
+{{range .Bookmarks}}<li><a href="{{.Url}}" rel="nofollow noopener noreferrer">{{.Name}}</a><br><small>{{.Url}}</small></li>
+{{end}}</ul>
+{{else}}<p>No bookmarks yet.</p>
+{{end}}</body>
+</html>
+`))
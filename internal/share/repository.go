@@ -0,0 +1,129 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package share
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	// Stores the share under its owner and its slug, errors.ErrAlreadyExist when the slug is taken
+	Create(ctx context.Context, share entity.Share) (entity.Share, error)
+	GetBySlug(ctx context.Context, slug string) (entity.Share, error)
+	List(ctx context.Context, username string) ([]entity.Share, error)
+	// Removes both copies of the share, only the owner can find it
+	Delete(ctx context.Context, username, slug string) error
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, share entity.Share) (entity.Share, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	ownerItem, slugItem := share.GetOwnerItem(), share.GetSlugItem()
+	err := r.db.WriteTx().
+		Put(table.Put(ownerItem).If("attribute_not_exists($)", "id")).
+		Put(table.Put(slugItem).If("attribute_not_exists($)", "id")).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.Share{}, errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create share", zap.String("Username", share.Owner), zap.Error(err))
+		return entity.Share{}, err
+	}
+
+	return ownerItem, nil
+}
+
+func (r *repository) GetBySlug(ctx context.Context, slug string) (entity.Share, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyBySlug(slug)
+	var result entity.Share
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.Share{}, errors.ErrNotFound
+		default:
+			logger.Errorw("Failed to get share", zap.Error(err))
+			return entity.Share{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) List(ctx context.Context, username string) ([]entity.Share, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByShare(username, "")
+	result := []entity.Share{}
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list shares", zap.String("Username", username), zap.Error(err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) Delete(ctx context.Context, username, slug string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	// The copy under the owner proves the ownership of the slug
+	ownerHashId, ownerRangeId := entity.GetSearchKeyByShare(username, slug)
+	slugHashId, slugRangeId := entity.GetSearchKeyBySlug(slug)
+	err := r.db.WriteTx().
+		Delete(table.Delete("id", ownerHashId).Range("range", ownerRangeId).If("attribute_exists($)", "id")).
+		Delete(table.Delete("id", slugHashId).Range("range", slugRangeId)).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to delete share", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/bookmark"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+)
+
+const (
+	// Bytes of randomness in a slug, 128 bits can not be guessed
+	slugBytes = 16
+	// Bookmarks a collection can hold, one BatchGetItem request
+	maxCollectionSize = 100
+	maxTitleLength    = 200
+)
+
+// Kinds of bookmark sets which can be shared
+var ShareTypes = []string{entity.ShareTag, entity.ShareCollection, entity.ShareSearch}
+
+type Service interface {
+	// Publishes the bookmark set under a new slug
+	Create(ctx context.Context, username string, share Share) (Share, error)
+	List(ctx context.Context, username string) ([]Share, error)
+	// Revokes the share, the slug stops resolving right away
+	Delete(ctx context.Context, username, slug string) error
+	// Returns the share and its bookmarks, errors.ErrNotFound when the slug is unknown or revoked.
+	// Bookmarks are limited to the shared set, partial results are returned along with the error
+	Public(ctx context.Context, slug string) (Share, []bookmark.Bookmark, error)
+}
+
+type Share struct {
+	Slug        string
+	Type        string
+	Title       string
+	Tag         string
+	BookmarkIDs []string
+	Query       string
+	CreatedAt   time.Time
+}
+
+func (s *Share) getEntity(username string) entity.Share {
+	return entity.Share{
+		Owner:       username,
+		Slug:        s.Slug,
+		Type:        s.Type,
+		Title:       s.Title,
+		Tag:         s.Tag,
+		BookmarkIDs: s.BookmarkIDs,
+		Query:       s.Query,
+		CreatedAt:   s.CreatedAt,
+	}
+}
+
+func newShare(share entity.Share) Share {
+	return Share{
+		Slug:        share.Slug,
+		Type:        share.Type,
+		Title:       share.Title,
+		Tag:         share.Tag,
+		BookmarkIDs: share.BookmarkIDs,
+		Query:       share.Query,
+		CreatedAt:   share.CreatedAt,
+	}
+}
+
+type service struct {
+	repo            Repository
+	bookmarkService bookmark.Service
+	logger          *zap.Logger
+}
+
+func NewService(repo Repository, bookmarkService bookmark.Service, logger *zap.Logger) Service {
+	return &service{repo, bookmarkService, logger}
+}
+
+func (s *service) Create(ctx context.Context, username string, share Share) (Share, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	share.Title = strings.TrimSpace(share.Title)
+	if len(share.Title) > maxTitleLength {
+		return Share{}, errors.ErrInvalidParam
+	}
+
+	// Only the field of the type is kept, so a share never widens to another set
+	switch share.Type {
+	case entity.ShareTag:
+		if share.Tag == "" {
+			return Share{}, errors.ErrInvalidParam
+		}
+		share.BookmarkIDs, share.Query = nil, ""
+		if share.Title == "" {
+			share.Title = "#" + share.Tag
+		}
+	case entity.ShareSearch:
+		if share.Query == "" {
+			return Share{}, errors.ErrInvalidParam
+		}
+		share.Tag, share.BookmarkIDs = "", nil
+		if share.Title == "" {
+			share.Title = share.Query
+		}
+	case entity.ShareCollection:
+		share.BookmarkIDs = funk.UniqString(share.BookmarkIDs)
+		if len(share.BookmarkIDs) == 0 || len(share.BookmarkIDs) > maxCollectionSize {
+			return Share{}, errors.ErrInvalidParam
+		}
+		share.Tag, share.Query = "", ""
+
+		// Every bookmark of the collection has to be there when it is shared
+		bookmarks, err := s.bookmarkService.GetMany(ctx, username, share.BookmarkIDs)
+		if err != nil {
+			logger.Errorw("Failed to fetch bookmarks of collection", zap.Error(err))
+			return Share{}, err
+		}
+		if len(bookmarks) != len(share.BookmarkIDs) {
+			return Share{}, errors.ErrInvalidParam
+		}
+		if share.Title == "" {
+			share.Title = "Collection"
+		}
+	default:
+		return Share{}, errors.ErrInvalidParam
+	}
+
+	slug, err := newSlug()
+	if err != nil {
+		logger.Errorw("Failed to generate slug", zap.Error(err))
+		return Share{}, err
+	}
+	share.Slug = slug
+	share.CreatedAt = time.Now()
+
+	created, err := s.repo.Create(ctx, share.getEntity(username))
+	if err != nil {
+		logger.Errorw("Failed to create share", zap.Error(err))
+		return Share{}, err
+	}
+
+	return newShare(created), nil
+}
+
+func (s *service) List(ctx context.Context, username string) ([]Share, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	shares, err := s.repo.List(ctx, username)
+	if err != nil {
+		logger.Errorw("Failed to list shares", zap.Error(err))
+		return []Share{}, err
+	}
+
+	return funk.Map(shares, newShare).([]Share), nil
+}
+
+func (s *service) Delete(ctx context.Context, username, slug string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	err := s.repo.Delete(ctx, username, slug)
+	if err != nil {
+		logger.Errorw("Failed to delete share", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Public(ctx context.Context, slug string) (Share, []bookmark.Bookmark, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	share, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return Share{}, []bookmark.Bookmark{}, err
+	}
+
+	var bookmarks []bookmark.Bookmark
+	switch share.Type {
+	case entity.ShareTag:
+		bookmarks, err = s.bookmarkService.SearchByTag(ctx, share.Owner, share.Tag)
+	case entity.ShareSearch:
+		bookmarks, err = s.bookmarkService.SearchByName(ctx, share.Owner, share.Query)
+	case entity.ShareCollection:
+		bookmarks, err = s.bookmarkService.GetMany(ctx, share.Owner, share.BookmarkIDs)
+	default:
+		logger.Errorw("Unknown share type", zap.String("Type", share.Type))
+		return Share{}, []bookmark.Bookmark{}, errors.ErrNotFound
+	}
+	if err != nil {
+		if _, ok := errors.IsPartial(err); !ok {
+			logger.Errorw("Failed to fetch shared bookmarks", zap.String("Type", share.Type), zap.Error(err))
+			return Share{}, []bookmark.Bookmark{}, err
+		}
+	}
+
+	// Index items might be stale, only bookmarks which are in the set by their current data are published
+	result := []bookmark.Bookmark{}
+	for _, b := range bookmarks {
+		if inShare(share, b) {
+			result = append(result, b)
+		}
+	}
+
+	return newShare(share), result, err
+}
+
+func inShare(share entity.Share, b bookmark.Bookmark) bool {
+	switch share.Type {
+	case entity.ShareTag:
+		return funk.ContainsString(b.Tags, share.Tag)
+	case entity.ShareSearch:
+		return strings.HasPrefix(b.Name, share.Query)
+	case entity.ShareCollection:
+		return funk.ContainsString(share.BookmarkIDs, b.ID)
+	}
+	return false
+}
+
+func newSlug() (string, error) {
+	bytes := make([]byte, slugBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,132 @@
+package share
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/bookmark"
+	bookmarkMocks "bookmark-api/internal/bookmark/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/share/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockBookmarkRepository := bookmarkMocks.NewMockRepository(ctrl)
+	shareService := NewService(mockRepository, bookmark.NewService(mockBookmarkRepository, event.NewBus(zapLogger), zapLogger), zapLogger)
+	ctx := context.Background()
+
+	t.Run("TagShareKeepsOnlyTag", func(t *testing.T) {
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, share entity.Share) (entity.Share, error) {
+				return share.GetOwnerItem(), nil
+			}).Times(1)
+
+		share, err := shareService.Create(ctx, "1", Share{Type: entity.ShareTag, Tag: "go", Query: "Private", BookmarkIDs: []string{"3"}})
+		assert.Nil(t, err)
+		assert.Len(t, share.Slug, 22)
+		assert.Equal(t, "#go", share.Title)
+		assert.Empty(t, share.Query)
+		assert.Empty(t, share.BookmarkIDs)
+	})
+
+	t.Run("SlugsAreUnique", func(t *testing.T) {
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, share entity.Share) (entity.Share, error) {
+				return share.GetOwnerItem(), nil
+			}).Times(2)
+
+		first, _ := shareService.Create(ctx, "1", Share{Type: entity.ShareSearch, Query: "Go"})
+		second, _ := shareService.Create(ctx, "1", Share{Type: entity.ShareSearch, Query: "Go"})
+		assert.NotEqual(t, first.Slug, second.Slug)
+	})
+
+	t.Run("CollectionOfMissingBookmark", func(t *testing.T) {
+		mockBookmarkRepository.EXPECT().GetMany(gomock.Any(), "1", []string{"2", "3"}).
+			Return([]entity.Bookmark{{Username: "USERNAME_1", ID: "BOOKMARK_2"}}, nil).Times(1)
+
+		_, err := shareService.Create(ctx, "1", Share{Type: entity.ShareCollection, BookmarkIDs: []string{"2", "3", "2"}})
+		assert.Equal(t, errors.ErrInvalidParam, err)
+	})
+
+	t.Run("InvalidShares", func(t *testing.T) {
+		for _, share := range []Share{
+			{Type: entity.ShareTag},
+			{Type: entity.ShareSearch},
+			{Type: entity.ShareCollection},
+			{Type: "user"},
+		} {
+			_, err := shareService.Create(ctx, "1", share)
+			assert.Equal(t, errors.ErrInvalidParam, err)
+		}
+	})
+}
+
+func TestPublicReturnsOnlySharedSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockBookmarkRepository := bookmarkMocks.NewMockRepository(ctrl)
+	shareService := NewService(mockRepository, bookmark.NewService(mockBookmarkRepository, event.NewBus(zapLogger), zapLogger), zapLogger)
+	ctx := context.Background()
+
+	tagged := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_2", Name: "Go", Url: "https://go.dev", Tags: []string{"go"}}
+	untagged := entity.Bookmark{Username: "USERNAME_1", ID: "BOOKMARK_3", Name: "Gossip", Url: "https://example.com", Tags: []string{"private"}}
+
+	t.Run("Tag", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), "slug").
+			Return(entity.Share{Owner: "1", Slug: "slug", Type: entity.ShareTag, Title: "#go", Tag: "go", CreatedAt: time.Now()}, nil).Times(1)
+		mockBookmarkRepository.EXPECT().SearchByTag(gomock.Any(), "1", "go").
+			Return([]entity.Bookmark{tagged, untagged}, nil).Times(1)
+
+		share, bookmarks, err := shareService.Public(ctx, "slug")
+		assert.Nil(t, err)
+		assert.Equal(t, "#go", share.Title)
+		assert.Len(t, bookmarks, 1)
+		assert.Equal(t, "2", bookmarks[0].ID)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), "slug").
+			Return(entity.Share{Owner: "1", Slug: "slug", Type: entity.ShareSearch, Query: "Go "}, nil).Times(1)
+		mockBookmarkRepository.EXPECT().SearchByName(gomock.Any(), "1", "Go ").
+			Return([]entity.Bookmark{tagged, untagged}, nil).Times(1)
+
+		_, bookmarks, err := shareService.Public(ctx, "slug")
+		assert.Nil(t, err)
+		assert.Empty(t, bookmarks)
+	})
+
+	t.Run("Collection", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), "slug").
+			Return(entity.Share{Owner: "1", Slug: "slug", Type: entity.ShareCollection, BookmarkIDs: []string{"3"}}, nil).Times(1)
+		mockBookmarkRepository.EXPECT().GetMany(gomock.Any(), "1", []string{"3"}).
+			Return([]entity.Bookmark{untagged}, nil).Times(1)
+
+		_, bookmarks, err := shareService.Public(ctx, "slug")
+		assert.Nil(t, err)
+		assert.Len(t, bookmarks, 1)
+		assert.Equal(t, "3", bookmarks[0].ID)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		mockRepository.EXPECT().GetBySlug(gomock.Any(), "slug").Return(entity.Share{}, errors.ErrNotFound).Times(1)
+
+		_, bookmarks, err := shareService.Public(ctx, "slug")
+		assert.Equal(t, errors.ErrNotFound, err)
+		assert.Empty(t, bookmarks)
+	})
+}
@@ -0,0 +1,130 @@
+package token
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	// A leaked token must not be able to issue more of them
+	admin := session.RequireScope(entity.ScopeAdmin)
+	rg.POST("/tokens", admin, r.create)
+	rg.GET("/tokens", admin, r.list)
+	rg.DELETE("/tokens/:id", admin, r.revoke)
+}
+
+var scopes = []string{entity.ScopeRead, entity.ScopeWrite, entity.ScopeAdmin}
+
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Defaults to 90 days
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type TokenResponse struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Only returned when the token is created
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newTokenResponse(token Token) TokenResponse {
+	return TokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		Token:     token.Secret,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
+
+func (r *resource) create(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := CreateTokenRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	token, err := r.service.Create(c.Request.Context(), Owner{Username: authUser.Username, Method: string(authUser.Method), UserID: authUser.ID()}, CreateRequest{
+		Name:   request.Name,
+		Scopes: request.Scopes,
+		TTL:    time.Duration(request.ExpiresInDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(
+				fmt.Sprintf("Token needs a name of at most %d characters, scopes of %s and to expire in at most %d days",
+					maxNameLength, strings.Join(scopes, ", "), int(MaxTTL.Hours()/24))))
+		case errors.ErrTooManyItems:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("You have %d tokens already", maxTokens)))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create token"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTokenResponse(token))
+}
+
+func (r *resource) list(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	tokens, err := r.service.List(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list tokens"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(tokens, newTokenResponse))
+}
+
+func (r *resource) revoke(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	err := r.service.Revoke(c.Request.Context(), authUser.ID(), c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Token not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to revoke token"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
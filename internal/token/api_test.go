@@ -0,0 +1,102 @@
+package token
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/token/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestTokenRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	s := NewService(mockRepository, logger.NewLogger())
+	api := NewApi(s, logger.NewLogger())
+
+	r := gin.Default()
+	api.RegisterHandlers(r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "1", Method: "google"})
+	}))
+	api.RegisterHandlers(r.Group("/scoped", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "1", Method: "google", Scopes: []string{entity.ScopeWrite}})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("CreateReturnsSecretOnce", func(t *testing.T) {
+		mockRepository.EXPECT().List(gomock.Any(), "1").Return([]entity.APIToken{}, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		requestBody, _ := json.Marshal(CreateTokenRequest{Name: "cron", Scopes: []string{entity.ScopeRead}, ExpiresInDays: 30})
+		resp, err := http.Post(fmt.Sprintf("%s/api/tokens", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 201, resp.StatusCode)
+
+		var result TokenResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected token response, got %v", err)
+		}
+		assert.NotEmpty(t, result.Token)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), result.ExpiresAt, time.Minute)
+	})
+
+	t.Run("ListHidesSecrets", func(t *testing.T) {
+		mockRepository.EXPECT().List(gomock.Any(), "1").
+			Return([]entity.APIToken{{ID: "2", Name: "cron", TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}}, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/tokens", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result []map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected token responses, got %v", err)
+		}
+		assert.Len(t, result, 1)
+		assert.NotContains(t, result[0], "token")
+		assert.NotContains(t, result[0], "token_hash")
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		stored := entity.APIToken{ID: "2", Owner: "1", TokenHash: "hash"}
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(stored, nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), stored).Return(nil).Times(1)
+
+		request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/tokens/2", ts.URL), nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("TokensWithoutAdminScope", func(t *testing.T) {
+		requestBody, _ := json.Marshal(CreateTokenRequest{Name: "cron", Scopes: []string{entity.ScopeAdmin}})
+		resp, err := http.Post(fmt.Sprintf("%s/scoped/tokens", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+}
@@ -0,0 +1,136 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package token
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	// Stores both copies of the token, errors.ErrAlreadyExist when its ID or hash is taken
+	Create(ctx context.Context, token entity.APIToken) error
+	Get(ctx context.Context, username, id string) (entity.APIToken, error)
+	GetByHash(ctx context.Context, tokenHash string) (entity.APIToken, error)
+	List(ctx context.Context, username string) ([]entity.APIToken, error)
+	// Deletes both copies of the token, errors.ErrNotFound when it has been revoked already
+	Delete(ctx context.Context, token entity.APIToken) error
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, token entity.APIToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := r.db.WriteTx().
+		Put(table.Put(token.GetOwnerItem()).If("attribute_not_exists($)", "id")).
+		Put(table.Put(token.GetHashItem()).If("attribute_not_exists($)", "id")).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create API token", zap.String("Username", token.Owner), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, username, id string) (entity.APIToken, error) {
+	hashId, rangeId := entity.GetSearchKeyByAPIToken(username, id)
+	return r.get(ctx, hashId, rangeId)
+}
+
+func (r *repository) GetByHash(ctx context.Context, tokenHash string) (entity.APIToken, error) {
+	hashId, rangeId := entity.GetSearchKeyByAPITokenHash(tokenHash)
+	return r.get(ctx, hashId, rangeId)
+}
+
+func (r *repository) get(ctx context.Context, hashId, rangeId string) (entity.APIToken, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	var result entity.APIToken
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.APIToken{}, errors.ErrNotFound
+		default:
+			logger.Errorw("Failed to get API token", zap.Error(err))
+			return entity.APIToken{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) List(ctx context.Context, username string) ([]entity.APIToken, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByAPIToken(username, "")
+
+	result := []entity.APIToken{}
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list API tokens", zap.String("Username", username), zap.Error(err))
+		return []entity.APIToken{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) Delete(ctx context.Context, token entity.APIToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	ownerItem, hashItem := token.GetOwnerItem(), token.GetHashItem()
+	err := r.db.WriteTx().
+		Delete(table.Delete("id", ownerItem.HashKey).Range("range", ownerItem.RangeKey).If("attribute_exists($)", "id")).
+		Delete(table.Delete("id", hashItem.HashKey).Range("range", hashItem.RangeKey)).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to delete API token", zap.String("Username", token.Owner), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+const (
+	tokenBytes    = 32
+	maxNameLength = 100
+	// Tokens a user can have at once
+	maxTokens = 20
+	// Lifetime of tokens created without one
+	DefaultTTL = 90 * 24 * time.Hour
+	MaxTTL     = 365 * 24 * time.Hour
+)
+
+type Service interface {
+	// Issues a token of the user. The secret is only returned here
+	Create(ctx context.Context, owner Owner, request CreateRequest) (Token, error)
+	// Lists the tokens of the user which have not expired, without their secrets
+	List(ctx context.Context, userId string) ([]Token, error)
+	// Revokes the token, errors.ErrNotFound when the user has no such token
+	Revoke(ctx context.Context, userId, id string) error
+	// Returns the token of the secret, errors.ErrNotFound when it is unknown or revoked and errors.ErrExpired when
+	// it has expired. Secrets without the API token prefix, such as feed tokens, are never accepted
+	Verify(ctx context.Context, secret string) (entity.APIToken, error)
+}
+
+// Identity creating a token and the account owning it
+type Owner struct {
+	Username string
+	Method   string
+	UserID   string
+}
+
+type CreateRequest struct {
+	Name   string
+	Scopes []string
+	TTL    time.Duration
+}
+
+type Token struct {
+	ID     string
+	Name   string
+	Scopes []string
+	// Only set when the token is created
+	Secret    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func newToken(token entity.APIToken) Token {
+	return Token{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
+
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{repo, logger}
+}
+
+func (s *service) Create(ctx context.Context, owner Owner, request CreateRequest) (Token, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	name := strings.TrimSpace(request.Name)
+	scopes := funk.UniqString(request.Scopes)
+	if name == "" || len(name) > maxNameLength || len(scopes) == 0 {
+		return Token{}, errors.ErrInvalidParam
+	}
+	for _, scope := range scopes {
+		if !entity.IsScope(scope) {
+			return Token{}, errors.ErrInvalidParam
+		}
+	}
+
+	ttl := request.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < 0 || ttl > MaxTTL {
+		return Token{}, errors.ErrInvalidParam
+	}
+
+	tokens, err := s.List(ctx, owner.UserID)
+	if err != nil {
+		return Token{}, err
+	}
+	if len(tokens) >= maxTokens {
+		return Token{}, errors.ErrTooManyItems
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		logger.Errorw("Failed to generate API token", zap.Error(err))
+		return Token{}, err
+	}
+
+	now := time.Now()
+	apiToken := entity.APIToken{
+		ID:        db.GenerateID(),
+		Owner:     owner.UserID,
+		Username:  owner.Username,
+		Method:    owner.Method,
+		Name:      name,
+		Scopes:    scopes,
+		TokenHash: hashSecret(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	err = s.repo.Create(ctx, apiToken)
+	if err != nil {
+		logger.Errorw("Failed to create API token", zap.Error(err))
+		return Token{}, err
+	}
+
+	result := newToken(apiToken)
+	result.Secret = secret
+	return result, nil
+}
+
+func (s *service) List(ctx context.Context, userId string) ([]Token, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	tokens, err := s.repo.List(ctx, userId)
+	if err != nil {
+		logger.Errorw("Failed to list API tokens", zap.Error(err))
+		return []Token{}, err
+	}
+
+	// Expired tokens stay until DynamoDB removes them, which might take a while
+	result := []Token{}
+	for _, token := range tokens {
+		if !isExpired(token) {
+			result = append(result, newToken(token))
+		}
+	}
+	return result, nil
+}
+
+func (s *service) Revoke(ctx context.Context, userId, id string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	token, err := s.repo.Get(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(ctx, token)
+	if err != nil {
+		logger.Errorw("Failed to revoke API token", zap.String("ID", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Verify(ctx context.Context, secret string) (entity.APIToken, error) {
+	if !strings.HasPrefix(secret, entity.APITokenPrefix) {
+		return entity.APIToken{}, errors.ErrNotFound
+	}
+
+	token, err := s.repo.GetByHash(ctx, hashSecret(secret))
+	if err != nil {
+		return entity.APIToken{}, err
+	}
+	if isExpired(token) {
+		return entity.APIToken{}, errors.ErrExpired
+	}
+
+	return token, nil
+}
+
+func isExpired(token entity.APIToken) bool {
+	return !token.ExpiresAt.After(time.Now())
+}
+
+func newSecret() (string, error) {
+	bytes := make([]byte, tokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return entity.APITokenPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package token
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/token/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	tokenService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	owner := Owner{Username: "jane", Method: "google", UserID: "1"}
+
+	t.Run("StoresOnlyHash", func(t *testing.T) {
+		var stored entity.APIToken
+		mockRepository.EXPECT().List(gomock.Any(), "1").Return([]entity.APIToken{}, nil).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, token entity.APIToken) error {
+				stored = token
+				return nil
+			}).Times(1)
+
+		token, err := tokenService.Create(ctx, owner, CreateRequest{Name: " cron ", Scopes: []string{entity.ScopeRead, entity.ScopeRead}})
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(token.Secret, entity.APITokenPrefix))
+		assert.Equal(t, "cron", token.Name)
+		assert.Equal(t, []string{entity.ScopeRead}, token.Scopes)
+		assert.WithinDuration(t, time.Now().Add(DefaultTTL), token.ExpiresAt, time.Minute)
+		assert.Equal(t, hashSecret(token.Secret), stored.TokenHash)
+		assert.NotContains(t, stored.TokenHash, token.Secret)
+		assert.Equal(t, "google", stored.Method)
+		assert.Equal(t, "1", stored.Owner)
+		assert.Equal(t, "jane", stored.Username)
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		for _, request := range []CreateRequest{
+			{Name: "cron"},
+			{Name: "cron", Scopes: []string{"root"}},
+			{Name: " ", Scopes: []string{entity.ScopeRead}},
+			{Name: "cron", Scopes: []string{entity.ScopeRead}, TTL: MaxTTL + time.Hour},
+			{Name: "cron", Scopes: []string{entity.ScopeRead}, TTL: -time.Hour},
+		} {
+			_, err := tokenService.Create(ctx, owner, request)
+			assert.Equal(t, errors.ErrInvalidParam, err)
+		}
+	})
+
+	t.Run("TooManyTokens", func(t *testing.T) {
+		tokens := make([]entity.APIToken, maxTokens)
+		for i := range tokens {
+			tokens[i].ExpiresAt = time.Now().Add(time.Hour)
+		}
+		mockRepository.EXPECT().List(gomock.Any(), "1").Return(tokens, nil).Times(1)
+
+		_, err := tokenService.Create(ctx, owner, CreateRequest{Name: "cron", Scopes: []string{entity.ScopeWrite}})
+		assert.Equal(t, errors.ErrTooManyItems, err)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	tokenService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("Valid", func(t *testing.T) {
+		stored := entity.APIToken{Owner: "1", Scopes: []string{entity.ScopeRead}, ExpiresAt: time.Now().Add(time.Hour)}
+		mockRepository.EXPECT().GetByHash(gomock.Any(), hashSecret("pat_secret")).Return(stored, nil).Times(1)
+
+		token, err := tokenService.Verify(ctx, "pat_secret")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", token.Owner)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		stored := entity.APIToken{Owner: "1", Scopes: []string{entity.ScopeRead}, ExpiresAt: time.Now().Add(-time.Hour)}
+		mockRepository.EXPECT().GetByHash(gomock.Any(), hashSecret("pat_secret")).Return(stored, nil).Times(1)
+
+		_, err := tokenService.Verify(ctx, "pat_secret")
+		assert.Equal(t, errors.ErrExpired, err)
+	})
+
+	t.Run("FeedTokenIsNotLookedUp", func(t *testing.T) {
+		_, err := tokenService.Verify(ctx, "feed_secret")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+}
@@ -34,6 +34,21 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// ConsumeLinkIntent mocks base method
+func (m *MockRepository) ConsumeLinkIntent(arg0 context.Context, arg1 string) (entity.LinkIntent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeLinkIntent", arg0, arg1)
+	ret0, _ := ret[0].(entity.LinkIntent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeLinkIntent indicates an expected call of ConsumeLinkIntent
+func (mr *MockRepositoryMockRecorder) ConsumeLinkIntent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeLinkIntent", reflect.TypeOf((*MockRepository)(nil).ConsumeLinkIntent), arg0, arg1)
+}
+
 // Create mocks base method
 func (m *MockRepository) Create(arg0 context.Context, arg1 entity.User) (entity.User, error) {
 	m.ctrl.T.Helper()
@@ -49,19 +64,193 @@ func (mr *MockRepositoryMockRecorder) Create(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), arg0, arg1)
 }
 
+// CreateLinkIntent mocks base method
+func (m *MockRepository) CreateLinkIntent(arg0 context.Context, arg1 entity.LinkIntent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLinkIntent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateLinkIntent indicates an expected call of CreateLinkIntent
+func (mr *MockRepositoryMockRecorder) CreateLinkIntent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLinkIntent", reflect.TypeOf((*MockRepository)(nil).CreateLinkIntent), arg0, arg1)
+}
+
+// Delete mocks base method
+func (m *MockRepository) Delete(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockRepositoryMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), arg0, arg1, arg2, arg3)
+}
+
+// DeleteProfile mocks base method
+func (m *MockRepository) DeleteProfile(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteProfile", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteProfile indicates an expected call of DeleteProfile
+func (mr *MockRepositoryMockRecorder) DeleteProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProfile", reflect.TypeOf((*MockRepository)(nil).DeleteProfile), arg0, arg1)
+}
+
 // Get mocks base method
-func (m *MockRepository) Get(arg0 context.Context, arg1 string) (entity.User, error) {
+func (m *MockRepository) Get(arg0 context.Context, arg1, arg2 string) (entity.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", arg0, arg1)
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
 	ret0, _ := ret[0].(entity.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get
-func (mr *MockRepositoryMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), arg0, arg1, arg2)
+}
+
+// GetProfile mocks base method
+func (m *MockRepository) GetProfile(arg0 context.Context, arg1 string) (entity.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfile", arg0, arg1)
+	ret0, _ := ret[0].(entity.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfile indicates an expected call of GetProfile
+func (mr *MockRepositoryMockRecorder) GetProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockRepository)(nil).GetProfile), arg0, arg1)
+}
+
+// GetRevoked mocks base method
+func (m *MockRepository) GetRevoked(arg0 context.Context, arg1 string) (entity.RevokedToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevoked", arg0, arg1)
+	ret0, _ := ret[0].(entity.RevokedToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevoked indicates an expected call of GetRevoked
+func (mr *MockRepositoryMockRecorder) GetRevoked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevoked", reflect.TypeOf((*MockRepository)(nil).GetRevoked), arg0, arg1)
+}
+
+// IncrementTokenGeneration mocks base method
+func (m *MockRepository) IncrementTokenGeneration(arg0 context.Context, arg1, arg2 string) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementTokenGeneration", arg0, arg1, arg2)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementTokenGeneration indicates an expected call of IncrementTokenGeneration
+func (mr *MockRepositoryMockRecorder) IncrementTokenGeneration(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTokenGeneration", reflect.TypeOf((*MockRepository)(nil).IncrementTokenGeneration), arg0, arg1, arg2)
+}
+
+// List mocks base method
+func (m *MockRepository) List(arg0 context.Context, arg1 string) ([]entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].([]entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockRepositoryMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepository)(nil).List), arg0, arg1)
+}
+
+// ListByUserID mocks base method
+func (m *MockRepository) ListByUserID(arg0 context.Context, arg1 string) ([]entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUserID", arg0, arg1)
+	ret0, _ := ret[0].([]entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUserID indicates an expected call of ListByUserID
+func (mr *MockRepositoryMockRecorder) ListByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUserID", reflect.TypeOf((*MockRepository)(nil).ListByUserID), arg0, arg1)
+}
+
+// PutProfile mocks base method
+func (m *MockRepository) PutProfile(arg0 context.Context, arg1 entity.Profile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutProfile", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutProfile indicates an expected call of PutProfile
+func (mr *MockRepositoryMockRecorder) PutProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutProfile", reflect.TypeOf((*MockRepository)(nil).PutProfile), arg0, arg1)
+}
+
+// Revoke mocks base method
+func (m *MockRepository) Revoke(arg0 context.Context, arg1 entity.RevokedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke
+func (mr *MockRepositoryMockRecorder) Revoke(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockRepository)(nil).Revoke), arg0, arg1)
+}
+
+// SetDisabled mocks base method
+func (m *MockRepository) SetDisabled(arg0 context.Context, arg1, arg2 string, arg3 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDisabled", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDisabled indicates an expected call of SetDisabled
+func (mr *MockRepositoryMockRecorder) SetDisabled(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisabled", reflect.TypeOf((*MockRepository)(nil).SetDisabled), arg0, arg1, arg2, arg3)
+}
+
+// SetUserID mocks base method
+func (m *MockRepository) SetUserID(arg0 context.Context, arg1, arg2, arg3 string) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserID", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserID indicates an expected call of SetUserID
+func (mr *MockRepositoryMockRecorder) SetUserID(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserID", reflect.TypeOf((*MockRepository)(nil).SetUserID), arg0, arg1, arg2, arg3)
 }
 
 // Update mocks base method
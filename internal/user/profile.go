@@ -0,0 +1,200 @@
+package user
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+)
+
+// Orders clients list bookmarks in by default
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortName   = "name"
+)
+
+var SortOrders = []string{SortNewest, SortOldest, SortName}
+
+const (
+	defaultTimezone      = "UTC"
+	maxDisplayNameLength = 100
+	maxAvatarURLLength   = 2048
+	maxDefaultTags       = 20
+	maxTagLength         = 100
+	maxFeatures          = 50
+	maxFeatureLength     = 64
+)
+
+// Profile of an account, returned as it is by the API
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	DefaultSort string `json:"default_sort"`
+	// Tags clients put on the bookmarks they create
+	DefaultTags []string        `json:"default_tags"`
+	Timezone    string          `json:"timezone"`
+	Features    map[string]bool `json:"features"`
+}
+
+func newProfile(profile entity.Profile) Profile {
+	result := Profile{
+		DisplayName: profile.DisplayName,
+		AvatarURL:   profile.AvatarURL,
+		DefaultSort: profile.DefaultSort,
+		DefaultTags: profile.DefaultTags,
+		Timezone:    profile.Timezone,
+		Features:    profile.Features,
+	}
+	if result.DefaultSort == "" {
+		result.DefaultSort = SortNewest
+	}
+	if result.DefaultTags == nil {
+		result.DefaultTags = []string{}
+	}
+	if result.Timezone == "" {
+		result.Timezone = defaultTimezone
+	}
+	if result.Features == nil {
+		result.Features = map[string]bool{}
+	}
+	return result
+}
+
+// Fields of the profile to change, nil ones are kept. Empty strings reset the field to its default, the default
+// tags and the features are replaced as a whole
+type ProfileUpdate struct {
+	DisplayName *string
+	AvatarURL   *string
+	DefaultSort *string
+	DefaultTags []string
+	Timezone    *string
+	Features    map[string]bool
+}
+
+func (u ProfileUpdate) isValid() bool {
+	if u.DisplayName != nil && utf8.RuneCountInString(*u.DisplayName) > maxDisplayNameLength {
+		return false
+	}
+	if u.AvatarURL != nil && *u.AvatarURL != "" && !isAvatarURL(*u.AvatarURL) {
+		return false
+	}
+	if u.DefaultSort != nil && *u.DefaultSort != "" && !funk.ContainsString(SortOrders, *u.DefaultSort) {
+		return false
+	}
+	if len(u.DefaultTags) > maxDefaultTags {
+		return false
+	}
+	for _, tag := range u.DefaultTags {
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return false
+		}
+	}
+	if u.Timezone != nil && *u.Timezone != "" {
+		if _, err := time.LoadLocation(*u.Timezone); err != nil {
+			return false
+		}
+	}
+	if len(u.Features) > maxFeatures {
+		return false
+	}
+	for feature := range u.Features {
+		if feature == "" || len(feature) > maxFeatureLength {
+			return false
+		}
+	}
+	return true
+}
+
+func isAvatarURL(avatarURL string) bool {
+	if len(avatarURL) > maxAvatarURLLength {
+		return false
+	}
+	u, err := url.Parse(avatarURL)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+func (s *service) Profile(ctx context.Context, userId string) (Profile, error) {
+	profile, err := s.repo.GetProfile(ctx, userId)
+	if err != nil && err != errors.ErrNotFound {
+		return Profile{}, err
+	}
+
+	return newProfile(profile), nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userId string, update ProfileUpdate) (Profile, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if !update.isValid() {
+		return Profile{}, errors.ErrInvalidParam
+	}
+
+	profile, err := s.repo.GetProfile(ctx, userId)
+	if err != nil && err != errors.ErrNotFound {
+		return Profile{}, err
+	}
+
+	profile.UserID = userId
+	if update.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.AvatarURL != nil {
+		profile.AvatarURL = *update.AvatarURL
+	}
+	if update.DefaultSort != nil {
+		profile.DefaultSort = *update.DefaultSort
+	}
+	if update.DefaultTags != nil {
+		profile.DefaultTags = funk.UniqString(update.DefaultTags)
+	}
+	if update.Timezone != nil {
+		profile.Timezone = *update.Timezone
+	}
+	if update.Features != nil {
+		profile.Features = update.Features
+	}
+	profile.UpdatedAt = time.Now()
+
+	err = s.repo.PutProfile(ctx, profile)
+	if err != nil {
+		logger.Errorw("Failed to update profile", zap.String("UserID", userId), zap.Error(err))
+		return Profile{}, err
+	}
+
+	return newProfile(profile), nil
+}
+
+func (s *service) FillProfile(ctx context.Context, userId, displayName, avatarURL string) error {
+	profile, err := s.repo.GetProfile(ctx, userId)
+	if err != nil && err != errors.ErrNotFound {
+		return err
+	}
+
+	changed := false
+	if profile.DisplayName == "" && displayName != "" && utf8.RuneCountInString(displayName) <= maxDisplayNameLength {
+		profile.DisplayName = displayName
+		changed = true
+	}
+	if profile.AvatarURL == "" && isAvatarURL(avatarURL) {
+		profile.AvatarURL = avatarURL
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	profile.UserID = userId
+	profile.UpdatedAt = time.Now()
+	return s.repo.PutProfile(ctx, profile)
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+
+	t.Run("Defaults", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{}, errors.ErrNotFound).Times(1)
+
+		profile, err := userService.Profile(context.Background(), "user:1")
+		assert.Nil(t, err)
+		assert.Equal(t, Profile{DefaultSort: SortNewest, DefaultTags: []string{}, Timezone: "UTC", Features: map[string]bool{}}, profile)
+	})
+
+	t.Run("Fails", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{}, fmt.Errorf("Timeout")).Times(1)
+
+		_, err := userService.Profile(context.Background(), "user:1")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUpdateProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	stored := entity.Profile{UserID: "user:1", Method: entity.ProfileMethod, DisplayName: "Jane", AvatarURL: "https://example.com/jane.png", Timezone: "Europe/Berlin"}
+
+	str := func(value string) *string {
+		return &value
+	}
+
+	t.Run("KeepsFieldsLeftOut", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(stored, nil).Times(1)
+		mockRepository.EXPECT().PutProfile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, profile entity.Profile) error {
+				assert.Equal(t, "Jane", profile.DisplayName)
+				assert.Equal(t, "https://example.com/jane.png", profile.AvatarURL)
+				assert.Equal(t, []string{"go", "read-later"}, profile.DefaultTags)
+				return nil
+			}).Times(1)
+
+		profile, err := userService.UpdateProfile(ctx, "user:1", ProfileUpdate{
+			DefaultSort: str(SortName),
+			DefaultTags: []string{"go", "read-later", "go"},
+			Features:    map[string]bool{"reader_mode": true},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, SortName, profile.DefaultSort)
+		assert.Equal(t, "Europe/Berlin", profile.Timezone)
+		assert.True(t, profile.Features["reader_mode"])
+	})
+
+	t.Run("EmptyResetsToDefault", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(stored, nil).Times(1)
+		mockRepository.EXPECT().PutProfile(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		profile, err := userService.UpdateProfile(ctx, "user:1", ProfileUpdate{Timezone: str(""), AvatarURL: str("")})
+		assert.Nil(t, err)
+		assert.Equal(t, "UTC", profile.Timezone)
+		assert.Equal(t, "", profile.AvatarURL)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			update ProfileUpdate
+		}{
+			{"LongDisplayName", ProfileUpdate{DisplayName: str(strings.Repeat("a", maxDisplayNameLength+1))}},
+			{"AvatarNotHTTPS", ProfileUpdate{AvatarURL: str("http://example.com/jane.png")}},
+			{"AvatarNotURL", ProfileUpdate{AvatarURL: str("jane.png")}},
+			{"UnknownSort", ProfileUpdate{DefaultSort: str("random")}},
+			{"EmptyTag", ProfileUpdate{DefaultTags: []string{""}}},
+			{"TooManyTags", ProfileUpdate{DefaultTags: make([]string, maxDefaultTags+1)}},
+			{"UnknownTimezone", ProfileUpdate{Timezone: str("Mars/Olympus")}},
+			{"EmptyFeature", ProfileUpdate{Features: map[string]bool{"": true}}},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := userService.UpdateProfile(ctx, "user:1", test.update)
+				assert.Equal(t, errors.ErrInvalidParam, err)
+			})
+		}
+	})
+}
+
+func TestFillProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("NewProfile", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").Return(entity.Profile{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().PutProfile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, profile entity.Profile) error {
+				assert.Equal(t, "user:1", profile.UserID)
+				assert.Equal(t, "Jane", profile.DisplayName)
+				assert.Equal(t, "https://example.com/jane.png", profile.AvatarURL)
+				return nil
+			}).Times(1)
+
+		assert.Nil(t, userService.FillProfile(ctx, "user:1", "Jane", "https://example.com/jane.png"))
+	})
+
+	t.Run("KeepsWhatIsSet", func(t *testing.T) {
+		mockRepository.EXPECT().GetProfile(gomock.Any(), "user:1").
+			Return(entity.Profile{UserID: "user:1", DisplayName: "J", AvatarURL: "https://example.com/j.png"}, nil).Times(1)
+
+		assert.Nil(t, userService.FillProfile(ctx, "user:1", "Jane", "https://example.com/jane.png"))
+	})
+}
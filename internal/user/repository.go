@@ -16,10 +16,33 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, user entity.User) (entity.User, error)
-	Get(ctx context.Context, username string) (entity.User, error)
+	Get(ctx context.Context, username, method string) (entity.User, error)
+	// Lists the users of the username, one for every sign in method
+	List(ctx context.Context, username string) ([]entity.User, error)
+	// Lists the identities linked to the user ID
+	ListByUserID(ctx context.Context, userId string) ([]entity.User, error)
 	Update(ctx context.Context, user entity.User) (entity.User, error)
+	// Links the identity to the user ID unless it has been linked already, errors.ErrAlreadyExist then
+	SetUserID(ctx context.Context, username, method, userId string) (entity.User, error)
+	// Deletes the identity of the user ID, errors.ErrNotFound when the user ID has no such identity
+	Delete(ctx context.Context, username, method, userId string) error
+	// Sets or clears the disabled flag of the identity, errors.ErrNotFound when it does not exist
+	SetDisabled(ctx context.Context, username, method string, disabled bool) error
+	// Signs the user out everywhere by bumping the token generation, returns the updated user
+	IncrementTokenGeneration(ctx context.Context, username, method string) (entity.User, error)
+	Revoke(ctx context.Context, token entity.RevokedToken) error
+	GetRevoked(ctx context.Context, jti string) (entity.RevokedToken, error)
+	CreateLinkIntent(ctx context.Context, intent entity.LinkIntent) error
+	// Deletes the intent and returns it, errors.ErrNotFound when it has been used already
+	ConsumeLinkIntent(ctx context.Context, stateHash string) (entity.LinkIntent, error)
+	GetProfile(ctx context.Context, userId string) (entity.Profile, error)
+	PutProfile(ctx context.Context, profile entity.Profile) error
+	DeleteProfile(ctx context.Context, userId string) error
 }
 
+// Global secondary index of the user table by user ID
+const userIdIndex = "user_id-index"
+
 type repository struct {
 	db     *dynamo.DB
 	logger *zap.Logger
@@ -39,11 +62,15 @@ func (r *repository) Create(ctx context.Context, user entity.User) (entity.User,
 
 	tableUser := r.db.Table(db.GetTableUser())
 
+	// An identity signing in twice at once must not end up in two accounts
 	user.LastLoginAt = time.Now()
-	tx.Put(tableUser.Put(user))
+	tx.Put(tableUser.Put(user).If("attribute_not_exists($)", "username"))
 
-	err := tx.Run()
+	err := tx.RunWithContext(ctx)
 	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.User{}, errors.ErrAlreadyExist
+		}
 		logger.Errorw("Failed to create user", zap.Error(err))
 		return entity.User{}, err
 	}
@@ -51,17 +78,13 @@ func (r *repository) Create(ctx context.Context, user entity.User) (entity.User,
 	return user, nil
 }
 
-func (r *repository) Get(ctx context.Context, username string) (entity.User, error) {
-	logger := r.logger.Sugar()
-	defer func() {
-		_ = logger.Sync()
-	}()
-
+func (r *repository) Get(ctx context.Context, username, method string) (entity.User, error) {
 	table := r.db.Table(db.GetTableUser())
 
-	// TODO: Support multiple methods
 	var result entity.User
-	err := table.Get("username", username).One(&result)
+	err := table.Get("username", username).
+		Range("method", dynamo.Equal, method).
+		OneWithContext(ctx, &result)
 	if err != nil {
 		switch err {
 		case dynamo.ErrNotFound:
@@ -74,24 +97,301 @@ func (r *repository) Get(ctx context.Context, username string) (entity.User, err
 	return result, nil
 }
 
+func (r *repository) List(ctx context.Context, username string) ([]entity.User, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	// The profile of an account named after the username is not one of its identities
+	result := []entity.User{}
+	err := table.Get("username", username).
+		Filter("$ <> ?", "method", entity.ProfileMethod).
+		AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list users", zap.String("Username", username), zap.Error(err))
+		return []entity.User{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) ListByUserID(ctx context.Context, userId string) ([]entity.User, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	result := []entity.User{}
+	err := table.Get("user_id", userId).Index(userIdIndex).AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list identities", zap.String("UserID", userId), zap.Error(err))
+		return []entity.User{}, err
+	}
+
+	return result, nil
+}
+
 func (r *repository) Update(ctx context.Context, user entity.User) (entity.User, error) {
 	logger := r.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	freshUser, err := r.Get(ctx, user.Username)
+	table := r.db.Table((db.GetTableUser()))
+
+	// Only the last login is set, so a concurrent sign out everywhere is not overwritten
+	var result entity.User
+	err := table.Update("username", user.Username).
+		Range("method", user.Method).
+		Set("last_login_at", user.LastLoginAt).
+		If("attribute_exists($)", "username").
+		ValueWithContext(ctx, &result)
 	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.User{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to update user", zap.String("Username", user.Username), zap.Error(err))
 		return entity.User{}, err
 	}
 
+	return result, nil
+}
+
+func (r *repository) SetUserID(ctx context.Context, username, method, userId string) (entity.User, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
 	table := r.db.Table((db.GetTableUser()))
-	freshUser.LastLoginAt = user.LastLoginAt
-	err = table.Put(freshUser).Run()
+
+	var result entity.User
+	err := table.Update("username", username).
+		Range("method", method).
+		Set("user_id", userId).
+		If("attribute_exists($) AND attribute_not_exists($)", "username", "user_id").
+		ValueWithContext(ctx, &result)
 	if err != nil {
-		logger.Errorw("Failed to update user", zap.String("Username", freshUser.Username), zap.Error(err))
+		if db.IsConditionalCheckFailed(err) {
+			return entity.User{}, errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to set user ID", zap.String("Username", username), zap.Error(err))
 		return entity.User{}, err
 	}
 
-	return entity.User{}, nil
+	return result, nil
+}
+
+func (r *repository) Delete(ctx context.Context, username, method, userId string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table((db.GetTableUser()))
+
+	err := table.Delete("username", username).
+		Range("method", method).
+		If("$ = ?", "user_id", userId).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to delete identity", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) SetDisabled(ctx context.Context, username, method string, disabled bool) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	update := table.Update("username", username).Range("method", method)
+	if disabled {
+		update = update.Set("disabled", true)
+	} else {
+		update = update.Remove("disabled")
+	}
+	err := update.If("attribute_exists($)", "username").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to set disabled", zap.String("Username", username), zap.Bool("Disabled", disabled), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) IncrementTokenGeneration(ctx context.Context, username, method string) (entity.User, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table((db.GetTableUser()))
+
+	var result entity.User
+	err := table.Update("username", username).
+		Range("method", method).
+		Add("token_generation", 1).
+		If("attribute_exists($)", "username").
+		ValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.User{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to increment token generation", zap.String("Username", username), zap.Error(err))
+		return entity.User{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) Revoke(ctx context.Context, token entity.RevokedToken) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(token.GetItem()).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to revoke token", zap.String("Username", token.Username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetRevoked(ctx context.Context, jti string) (entity.RevokedToken, error) {
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByRevokedToken(jti)
+
+	var result entity.RevokedToken
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.RevokedToken{}, errors.ErrNotFound
+		default:
+			return entity.RevokedToken{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) CreateLinkIntent(ctx context.Context, intent entity.LinkIntent) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Put(intent.GetItem()).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to create link intent", zap.String("UserID", intent.UserID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ConsumeLinkIntent(ctx context.Context, stateHash string) (entity.LinkIntent, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+	hashId, rangeId := entity.GetSearchKeyByLinkIntent(stateHash)
+
+	var result entity.LinkIntent
+	err := table.Delete("id", hashId).
+		Range("range", rangeId).
+		If("attribute_exists($)", "id").
+		OldValueWithContext(ctx, &result)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.LinkIntent{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to consume link intent", zap.Error(err))
+		return entity.LinkIntent{}, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) GetProfile(ctx context.Context, userId string) (entity.Profile, error) {
+	table := r.db.Table(db.GetTableUser())
+
+	var result entity.Profile
+	err := table.Get("username", userId).
+		Range("method", dynamo.Equal, entity.ProfileMethod).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.Profile{}, errors.ErrNotFound
+		default:
+			return entity.Profile{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) PutProfile(ctx context.Context, profile entity.Profile) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Put(profile.GetItem()).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to put profile", zap.String("UserID", profile.UserID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteProfile(ctx context.Context, userId string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableUser())
+
+	err := table.Delete("username", userId).Range("method", entity.ProfileMethod).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to delete profile", zap.String("UserID", userId), zap.Error(err))
+		return err
+	}
+
+	return nil
 }
@@ -2,29 +2,96 @@ package user
 
 import (
 	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// Prefix of the generated user IDs. Usernames never contain a colon, so the IDs of accounts created before there
+// were user IDs, which are their usernames, are told apart from the generated ones
+const idPrefix = "user:"
+
+// Time the user has to sign in with the provider of the identity being linked
+const LinkTTL = 10 * time.Minute
+
 type Service interface {
+	// Creates the identity in a new account
 	CreateUser(ctx context.Context, username, method string) (User, error)
+	// Creates the identity in a new account the first time it signs in, errors.ErrDisabled when its account has
+	// been disabled
 	UpdateLastLogin(ctx context.Context, username, method string) (User, error)
+	// Lists the identities linked to the account
+	Identities(ctx context.Context, userId string) ([]User, error)
+	// Returns the IDs of the accounts having an identity of the username, whatever method it signs in with
+	AccountIDs(ctx context.Context, username string) ([]string, error)
+	// Saves the intent of the account to link the identity which signs in with the state next
+	StartLink(ctx context.Context, userId, state string) error
+	// Links the identity to the account which started linking with the state, errors.ErrNotFound when the state is
+	// unknown or used, errors.ErrExpired when it has expired and errors.ErrAlreadyExist when the identity belongs
+	// to another account
+	CompleteLink(ctx context.Context, state, username, method string) (User, error)
+	// Removes the identity from the account, errors.ErrNotFound when the account has no such identity and
+	// errors.ErrPreconditionFailed when it is the last one
+	Unlink(ctx context.Context, userId, username, method string) error
+	// Deletes every identity of the account and its profile, which also revokes all of its JWTs
+	DeleteAccount(ctx context.Context, userId string) error
+	// Revokes a JWT until it expires
+	Logout(ctx context.Context, username, jti string, expiresAt time.Time) error
+	// Revokes every JWT of the account issued so far, whichever identity signed them in
+	LogoutAll(ctx context.Context, userId string) error
+	// Disables every identity of the account and signs it out everywhere, errors.ErrNotFound when it has none
+	Disable(ctx context.Context, userId string) error
+	// Lets the identities of the account sign in again, errors.ErrNotFound when it has none
+	Enable(ctx context.Context, userId string) error
+	// Reports whether any identity of the account has been disabled
+	IsDisabled(ctx context.Context, userId string) (bool, error)
+	// Reports whether the JWT with the ID and token generation has been revoked, as JWTs of disabled accounts are
+	IsRevoked(ctx context.Context, username, method, jti string, generation int) (bool, error)
+	// Returns the profile of the account, with the defaults of what it has not set
+	Profile(ctx context.Context, userId string) (Profile, error)
+	// Changes the fields of the profile which are set, errors.ErrInvalidParam when any of them is invalid
+	UpdateProfile(ctx context.Context, userId string, update ProfileUpdate) (Profile, error)
+	// Sets the display name and avatar of the profile from the identity signing in, unless it has them already
+	FillProfile(ctx context.Context, userId, displayName, avatarURL string) error
 }
 
 type User struct {
-	Username    string
-	Method      string
-	LastLoginAt time.Time
+	Username        string
+	Method          string
+	UserID          string
+	LastLoginAt     time.Time
+	TokenGeneration int
+	Disabled        bool
 }
 
 func newUser(user entity.User) User {
 	return User{
-		Username:    user.Username,
-		Method:      user.Method,
-		LastLoginAt: user.LastLoginAt,
+		Username:        user.Username,
+		Method:          user.Method,
+		UserID:          accountOf(user),
+		LastLoginAt:     user.LastLoginAt,
+		TokenGeneration: user.TokenGeneration,
+		Disabled:        user.Disabled,
+	}
+}
+
+// Returns a new user ID
+func NewID() string {
+	return idPrefix + db.GenerateID()
+}
+
+// Identities created before there were user IDs belong to the account of their username, which owns their bookmarks
+func accountOf(user entity.User) string {
+	if user.UserID == "" {
+		return user.Username
 	}
+	return user.UserID
 }
 
 type service struct {
@@ -37,6 +104,10 @@ func NewService(repo Repository, logger *zap.Logger) Service {
 }
 
 func (s *service) CreateUser(ctx context.Context, username, method string) (User, error) {
+	return s.createIdentity(ctx, username, method, NewID())
+}
+
+func (s *service) createIdentity(ctx context.Context, username, method, userId string) (User, error) {
 	logger := s.logger.Sugar()
 	defer func() {
 		_ = logger.Sync()
@@ -45,6 +116,7 @@ func (s *service) CreateUser(ctx context.Context, username, method string) (User
 	user, err := s.repo.Create(ctx, entity.User{
 		Username:    username,
 		Method:      method,
+		UserID:      userId,
 		LastLoginAt: time.Now(),
 	})
 
@@ -62,10 +134,26 @@ func (s *service) UpdateLastLogin(ctx context.Context, username, method string)
 		_ = logger.Sync()
 	}()
 
-	user, err := s.repo.Get(ctx, username)
-	if err != nil {
-		// Create new user
+	user, err := s.repo.Get(ctx, username, method)
+	switch err {
+	case nil:
+	case errors.ErrNotFound:
 		return s.CreateUser(ctx, username, method)
+	default:
+		// A failing lookup must not sign the identity in to a new, empty account
+		logger.Errorw("Failed to get user", zap.String("Username", username), zap.Error(err))
+		return User{}, err
+	}
+	if user.Disabled {
+		logger.Infow("Refused sign in of disabled user", zap.String("Username", username), zap.String("Method", method))
+		return User{}, errors.ErrDisabled
+	}
+
+	if user.UserID == "" {
+		user, err = s.setUserID(ctx, user, user.Username)
+		if err != nil {
+			return User{}, err
+		}
 	}
 
 	user.LastLoginAt = time.Now()
@@ -77,3 +165,324 @@ func (s *service) UpdateLastLogin(ctx context.Context, username, method string)
 
 	return newUser(updatedUser), nil
 }
+
+// Links the identity without a user ID to the account, unless it has been linked meanwhile
+func (s *service) setUserID(ctx context.Context, user entity.User, userId string) (entity.User, error) {
+	updatedUser, err := s.repo.SetUserID(ctx, user.Username, user.Method, userId)
+	switch err {
+	case nil:
+		return updatedUser, nil
+	case errors.ErrAlreadyExist:
+		return s.repo.Get(ctx, user.Username, user.Method)
+	default:
+		return entity.User{}, err
+	}
+}
+
+func (s *service) Identities(ctx context.Context, userId string) ([]User, error) {
+	identities, err := s.identities(ctx, userId)
+	if err != nil {
+		return []User{}, err
+	}
+
+	result := []User{}
+	for _, identity := range identities {
+		result = append(result, newUser(identity))
+	}
+	return result, nil
+}
+
+func (s *service) AccountIDs(ctx context.Context, username string) ([]string, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	users, err := s.repo.List(ctx, username)
+	if err != nil {
+		logger.Errorw("Failed to list identities of username", zap.Error(err))
+		return []string{}, err
+	}
+
+	accountIds := []string{}
+	seen := make(map[string]bool)
+	for _, user := range users {
+		accountId := accountOf(user)
+		if !seen[accountId] {
+			seen[accountId] = true
+			accountIds = append(accountIds, accountId)
+		}
+	}
+	return accountIds, nil
+}
+
+// Identities of accounts created before there were user IDs get theirs when they sign in again, until then they
+// are found by the username the account is named after
+func (s *service) identities(ctx context.Context, userId string) ([]entity.User, error) {
+	identities, err := s.repo.ListByUserID(ctx, userId)
+	if err != nil {
+		return []entity.User{}, err
+	}
+	if strings.HasPrefix(userId, idPrefix) {
+		return identities, nil
+	}
+
+	users, err := s.repo.List(ctx, userId)
+	if err != nil {
+		return []entity.User{}, err
+	}
+	for _, user := range users {
+		if user.UserID == "" {
+			identities = append(identities, user)
+		}
+	}
+	return identities, nil
+}
+
+func (s *service) StartLink(ctx context.Context, userId, state string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	now := time.Now()
+	err := s.repo.CreateLinkIntent(ctx, entity.LinkIntent{
+		StateHash: hashState(state),
+		UserID:    userId,
+		CreatedAt: now,
+		ExpiresAt: now.Add(LinkTTL),
+	})
+	if err != nil {
+		logger.Errorw("Failed to start linking", zap.String("UserID", userId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) CompleteLink(ctx context.Context, state, username, method string) (User, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	intent, err := s.repo.ConsumeLinkIntent(ctx, hashState(state))
+	if err != nil {
+		return User{}, err
+	}
+	// Expired intents stay until DynamoDB removes them, which might take a while
+	if !intent.ExpiresAt.After(time.Now()) {
+		return User{}, errors.ErrExpired
+	}
+
+	user, err := s.repo.Get(ctx, username, method)
+	switch err {
+	case nil:
+	case errors.ErrNotFound:
+		return s.createIdentity(ctx, username, method, intent.UserID)
+	default:
+		return User{}, err
+	}
+
+	// Moving an identity would leave the bookmarks of its account behind
+	if accountOf(user) != intent.UserID {
+		logger.Infow("Identity belongs to another account", zap.String("Username", username), zap.String("Method", method))
+		return User{}, errors.ErrAlreadyExist
+	}
+
+	return s.UpdateLastLogin(ctx, username, method)
+}
+
+func (s *service) Unlink(ctx context.Context, userId, username, method string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	identities, err := s.identities(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	var identity *entity.User
+	for i := range identities {
+		if identities[i].Username == username && identities[i].Method == method {
+			identity = &identities[i]
+		}
+	}
+	if identity == nil {
+		return errors.ErrNotFound
+	}
+	if len(identities) < 2 {
+		return errors.ErrPreconditionFailed
+	}
+
+	if identity.UserID == "" {
+		_, err = s.setUserID(ctx, *identity, userId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// JWTs of the identity are revoked with it, there is no user left to check their generation against
+	err = s.repo.Delete(ctx, username, method, userId)
+	if err != nil {
+		logger.Errorw("Failed to unlink identity", zap.String("UserID", userId), zap.String("Method", method), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) DeleteAccount(ctx context.Context, userId string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	identities, err := s.identities(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, identity := range identities {
+		// Identities are deleted by their user ID, so one linked to another account meanwhile is kept
+		if identity.UserID == "" {
+			if _, err := s.setUserID(ctx, identity, userId); err != nil {
+				return err
+			}
+		}
+
+		err = s.repo.Delete(ctx, identity.Username, identity.Method, userId)
+		if err != nil && err != errors.ErrNotFound {
+			logger.Errorw("Failed to delete identity", zap.String("UserID", userId), zap.String("Method", identity.Method), zap.Error(err))
+			return err
+		}
+	}
+
+	return s.repo.DeleteProfile(ctx, userId)
+}
+
+func (s *service) Logout(ctx context.Context, username, jti string, expiresAt time.Time) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	err := s.repo.Revoke(ctx, entity.RevokedToken{JTI: jti, Username: username, RevokedAt: time.Now(), ExpiresAt: expiresAt})
+	if err != nil {
+		logger.Errorw("Failed to logout", zap.String("Username", username), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) LogoutAll(ctx context.Context, userId string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	users, err := s.identities(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		_, err = s.repo.IncrementTokenGeneration(ctx, user.Username, user.Method)
+		if err != nil {
+			logger.Errorw("Failed to logout everywhere", zap.String("UserID", userId), zap.String("Method", user.Method), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) Disable(ctx context.Context, userId string) error {
+	return s.setDisabled(ctx, userId, true)
+}
+
+func (s *service) Enable(ctx context.Context, userId string) error {
+	return s.setDisabled(ctx, userId, false)
+}
+
+// The flag is kept on the identities, which are read to check every JWT anyway. Disabling also bumps their token
+// generation, so the account signs in again once it is enabled
+func (s *service) setDisabled(ctx context.Context, userId string, disabled bool) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	identities, err := s.identities(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return errors.ErrNotFound
+	}
+
+	for _, identity := range identities {
+		err = s.repo.SetDisabled(ctx, identity.Username, identity.Method, disabled)
+		if err != nil && err != errors.ErrNotFound {
+			logger.Errorw("Failed to set disabled", zap.String("UserID", userId), zap.String("Method", identity.Method), zap.Error(err))
+			return err
+		}
+		if !disabled || err == errors.ErrNotFound {
+			continue
+		}
+
+		_, err = s.repo.IncrementTokenGeneration(ctx, identity.Username, identity.Method)
+		if err != nil && err != errors.ErrNotFound {
+			logger.Errorw("Failed to sign out disabled identity", zap.String("UserID", userId), zap.String("Method", identity.Method), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) IsDisabled(ctx context.Context, userId string) (bool, error) {
+	identities, err := s.identities(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, identity := range identities {
+		if identity.Disabled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *service) IsRevoked(ctx context.Context, username, method, jti string, generation int) (bool, error) {
+	if jti != "" {
+		_, err := s.repo.GetRevoked(ctx, jti)
+		switch err {
+		case nil:
+			return true, nil
+		case errors.ErrNotFound:
+		default:
+			return false, err
+		}
+	}
+
+	user, err := s.repo.Get(ctx, username, method)
+	switch err {
+	case nil:
+		return generation < user.TokenGeneration || user.Disabled, nil
+	case errors.ErrNotFound:
+		// Tokens outlive no user
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,259 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func updated(_ context.Context, user entity.User) (entity.User, error) {
+	return user, nil
+}
+
+func TestUpdateLastLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("NewIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(entity.User{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(updated).Times(1)
+
+		user, err := userService.UpdateLastLogin(ctx, "jane@example.com", "github")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(user.UserID, idPrefix))
+	})
+
+	t.Run("LookupFails", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(entity.User{}, fmt.Errorf("Timeout")).Times(1)
+
+		// No new account is created, the identity may well have one
+		_, err := userService.UpdateLastLogin(ctx, "jane@example.com", "github")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("LinkedIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github", UserID: "user:1"}, nil).Times(1)
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(updated).Times(1)
+
+		user, err := userService.UpdateLastLogin(ctx, "jane@example.com", "github")
+		assert.Nil(t, err)
+		assert.Equal(t, "user:1", user.UserID)
+	})
+
+	t.Run("DisabledIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github", UserID: "user:1", Disabled: true}, nil).Times(1)
+
+		_, err := userService.UpdateLastLogin(ctx, "jane@example.com", "github")
+		assert.Equal(t, errors.ErrDisabled, err)
+	})
+
+	t.Run("IdentityWithoutUserID", func(t *testing.T) {
+		legacy := entity.User{Username: "jane@example.com", Method: "google"}
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "google").Return(legacy, nil).Times(1)
+		legacy.UserID = "jane@example.com"
+		mockRepository.EXPECT().SetUserID(gomock.Any(), "jane@example.com", "google", "jane@example.com").Return(legacy, nil).Times(1)
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(updated).Times(1)
+
+		// The account keeps the bookmarks stored under the username
+		user, err := userService.UpdateLastLogin(ctx, "jane@example.com", "google")
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", user.UserID)
+	})
+}
+
+func TestCompleteLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	intent := entity.LinkIntent{UserID: "user:1", ExpiresAt: time.Now().Add(time.Minute)}
+
+	t.Run("Link", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), hashState("state")).Return(intent, nil).Times(1)
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(entity.User{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(updated).Times(1)
+
+		user, err := userService.CompleteLink(ctx, "state", "jane@example.com", "github")
+		assert.Nil(t, err)
+		assert.Equal(t, "user:1", user.UserID)
+	})
+
+	t.Run("AlreadyLinked", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), gomock.Any()).Return(intent, nil).Times(1)
+		linked := entity.User{Username: "jane@example.com", Method: "github", UserID: "user:1"}
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").Return(linked, nil).Times(2)
+		mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(updated).Times(1)
+
+		_, err := userService.CompleteLink(ctx, "state", "jane@example.com", "github")
+		assert.Nil(t, err)
+	})
+
+	t.Run("IdentityOfAnotherAccount", func(t *testing.T) {
+		mockRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), gomock.Any()).Return(intent, nil).Times(1)
+		// Identities without a user ID belong to the account of their username
+		mockRepository.EXPECT().Get(gomock.Any(), "jane@example.com", "github").
+			Return(entity.User{Username: "jane@example.com", Method: "github"}, nil).Times(1)
+
+		_, err := userService.CompleteLink(ctx, "state", "jane@example.com", "github")
+		assert.Equal(t, errors.ErrAlreadyExist, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := entity.LinkIntent{UserID: "user:1", ExpiresAt: time.Now().Add(-time.Minute)}
+		mockRepository.EXPECT().ConsumeLinkIntent(gomock.Any(), gomock.Any()).Return(expired, nil).Times(1)
+
+		_, err := userService.CompleteLink(ctx, "state", "jane@example.com", "github")
+		assert.Equal(t, errors.ErrExpired, err)
+	})
+}
+
+func TestUnlink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("IdentityWithoutUserID", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "jane@example.com").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "jane@example.com"}}, nil).Times(1)
+		mockRepository.EXPECT().List(gomock.Any(), "jane@example.com").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "jane@example.com"}, {Username: "jane@example.com", Method: "github"}}, nil).Times(1)
+		mockRepository.EXPECT().SetUserID(gomock.Any(), "jane@example.com", "github", "jane@example.com").Return(entity.User{}, nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "github", "jane@example.com").Return(nil).Times(1)
+
+		err := userService.Unlink(ctx, "jane@example.com", "jane@example.com", "github")
+		assert.Nil(t, err)
+	})
+
+	t.Run("NotLinked", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+
+		err := userService.Unlink(ctx, "user:1", "john@example.com", "google")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("LastIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+
+		err := userService.Unlink(ctx, "user:1", "jane@example.com", "google")
+		assert.Equal(t, errors.ErrPreconditionFailed, err)
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+
+	t.Run("EveryIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "jane@example.com").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "jane@example.com"}}, nil).Times(1)
+		mockRepository.EXPECT().List(gomock.Any(), "jane@example.com").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "jane@example.com"}, {Username: "jane@example.com", Method: "github"}}, nil).Times(1)
+		mockRepository.EXPECT().SetUserID(gomock.Any(), "jane@example.com", "github", "jane@example.com").Return(entity.User{}, nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "google", "jane@example.com").Return(nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "github", "jane@example.com").Return(nil).Times(1)
+		mockRepository.EXPECT().DeleteProfile(gomock.Any(), "jane@example.com").Return(nil).Times(1)
+
+		err := userService.DeleteAccount(ctx, "jane@example.com")
+		assert.Nil(t, err)
+	})
+
+	t.Run("AlreadyDeleted", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), "jane@example.com", "google", "user:1").Return(errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().DeleteProfile(gomock.Any(), "user:1").Return(nil).Times(1)
+
+		err := userService.DeleteAccount(ctx, "user:1")
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeleteFails", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{{Username: "jane@example.com", Method: "google", UserID: "user:1"}}, nil).Times(1)
+		mockRepository.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("Timeout")).Times(1)
+
+		err := userService.DeleteAccount(ctx, "user:1")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestDisable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := NewService(mockRepository, logger.NewLogger())
+	ctx := context.Background()
+	identities := []entity.User{
+		{Username: "jane@example.com", Method: "google", UserID: "user:1"},
+		{Username: "jane", Method: "github", UserID: "user:1"},
+	}
+
+	t.Run("SignsOutEveryIdentity", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(1)
+		mockRepository.EXPECT().SetDisabled(gomock.Any(), "jane@example.com", "google", true).Return(nil).Times(1)
+		mockRepository.EXPECT().SetDisabled(gomock.Any(), "jane", "github", true).Return(nil).Times(1)
+		mockRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane@example.com", "google").Return(entity.User{}, nil).Times(1)
+		mockRepository.EXPECT().IncrementTokenGeneration(gomock.Any(), "jane", "github").Return(entity.User{}, nil).Times(1)
+
+		assert.Nil(t, userService.Disable(ctx, "user:1"))
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").Return(identities, nil).Times(1)
+		mockRepository.EXPECT().SetDisabled(gomock.Any(), gomock.Any(), gomock.Any(), false).Return(nil).Times(2)
+
+		assert.Nil(t, userService.Enable(ctx, "user:1"))
+	})
+
+	t.Run("UnknownAccount", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:2").Return([]entity.User{}, nil).Times(1)
+
+		assert.Equal(t, errors.ErrNotFound, userService.Disable(ctx, "user:2"))
+	})
+
+	t.Run("IsDisabled", func(t *testing.T) {
+		mockRepository.EXPECT().ListByUserID(gomock.Any(), "user:1").
+			Return([]entity.User{identities[0], {Username: "jane", Method: "github", UserID: "user:1", Disabled: true}}, nil).Times(1)
+
+		disabled, err := userService.IsDisabled(ctx, "user:1")
+		assert.Nil(t, err)
+		assert.True(t, disabled)
+	})
+
+	t.Run("RevokesJWTs", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "jane", "github").
+			Return(entity.User{Username: "jane", Method: "github", UserID: "user:1", TokenGeneration: 1, Disabled: true}, nil).Times(1)
+
+		revoked, err := userService.IsRevoked(ctx, "jane", "github", "", 1)
+		assert.Nil(t, err)
+		assert.True(t, revoked)
+	})
+}
@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 100
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+// Webhooks belong to the workspace of the request, so they get its events. Only owners manage them, the personal
+// workspace is owned by its user
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	owner := session.RequireRole(entity.RoleOwner)
+
+	rg.POST("/webhooks", owner, r.create)
+	rg.GET("/webhooks", owner, r.list)
+	rg.GET("/webhooks/:id", owner, r.get)
+	rg.DELETE("/webhooks/:id", owner, r.delete)
+
+	// Delivery log
+	rg.GET("/webhooks/:id/deliveries", owner, r.deliveries)
+}
+
+type CreateWebhookRequest struct {
+	Url string `json:"url"`
+	// Event types to deliver, all of them when empty
+	Events []string `json:"events"`
+}
+
+type WebhookResponse struct {
+	ID     string   `json:"id"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	// Only returned when the webhook is created
+	Secret     string     `json:"secret,omitempty"`
+	Failures   int        `json:"failures"`
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newWebhookResponse(webhook Webhook) WebhookResponse {
+	response := WebhookResponse{
+		ID:        webhook.ID,
+		Url:       webhook.Url,
+		Events:    webhook.Events,
+		Failures:  webhook.Failures,
+		Disabled:  webhook.Disabled,
+		CreatedAt: webhook.CreatedAt,
+	}
+	if response.Events == nil {
+		response.Events = []string{}
+	}
+	if webhook.Disabled {
+		response.DisabledAt = &webhook.DisabledAt
+	}
+	return response
+}
+
+type DeliveryResponse struct {
+	DeliveryID  string    `json:"delivery_id"`
+	EventID     string    `json:"event_id"`
+	Event       string    `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	DurationMs  int64     `json:"duration_ms"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+func newDeliveryResponse(delivery Delivery) DeliveryResponse {
+	return DeliveryResponse{
+		DeliveryID:  delivery.DeliveryID,
+		EventID:     delivery.EventID,
+		Event:       delivery.EventType,
+		Attempt:     delivery.Attempt,
+		StatusCode:  delivery.StatusCode,
+		Error:       delivery.Error,
+		Success:     delivery.Success,
+		DurationMs:  delivery.Duration.Milliseconds(),
+		DeliveredAt: delivery.DeliveredAt,
+	}
+}
+
+func (r *resource) create(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := CreateWebhookRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	target, err := url.Parse(request.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Url must be an absolute http or https url"))
+		return
+	}
+	if err := checkHost(c.Request.Context(), target.Hostname()); err != nil {
+		logger.Infow("Rejected webhook url", zap.String("Host", target.Hostname()), zap.Error(err))
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Url must point to a public address"))
+		return
+	}
+
+	workspace := session.GetWorkspace(c)
+	webhook, err := r.service.Create(c.Request.Context(), workspace.Owner, request.Url, request.Events)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Events must be some of %s", strings.Join(EventTypes, ", "))))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create webhook"))
+		}
+		return
+	}
+
+	response := newWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+func (r *resource) list(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	webhooks, err := r.service.List(c.Request.Context(), workspace.Owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list webhooks"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(webhooks, newWebhookResponse))
+}
+
+func (r *resource) get(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	webhook, err := r.service.Get(c.Request.Context(), workspace.Owner, c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Webhook not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get webhook"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookResponse(webhook))
+}
+
+func (r *resource) delete(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	err := r.service.Delete(c.Request.Context(), workspace.Owner, c.Param("id"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Webhook not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to delete webhook"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) deliveries(c *gin.Context) {
+	limit := defaultDeliveriesLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxDeliveriesLimit {
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Limit must be between 1 and %d", maxDeliveriesLimit)))
+			return
+		}
+	}
+
+	workspace := session.GetWorkspace(c)
+	deliveries, err := r.service.Deliveries(c.Request.Context(), workspace.Owner, c.Param("id"), limit)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Webhook not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list deliveries"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(deliveries, newDeliveryResponse))
+}
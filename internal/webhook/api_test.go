@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/session"
+	"bookmark-api/internal/webhook/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestWebhookRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	webhookService := NewService(mockRepository, mocks.NewMockQueue(ctrl), zapLogger)
+
+	api := NewApi(webhookService, zapLogger)
+
+	addresses := map[string]string{"example.com": "93.184.216.34", "internal.example.com": "10.0.0.5"}
+	defer func(lookup func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		address, ok := addresses[host]
+		if !ok {
+			return nil, fmt.Errorf("No such host %s", host)
+		}
+		return []net.IPAddr{{IP: net.ParseIP(address)}}, nil
+	}
+
+	r := gin.Default()
+
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "1", Method: "google"})
+	})
+
+	api.RegisterHandlers(r.Group("/api"))
+	// Stands in for the workspace API resolving the role of the user
+	roles := map[string]string{"team": entity.RoleOwner, "shared": entity.RoleEditor}
+	api.RegisterHandlers(r.Group("/api/workspaces/:workspace", func(ctx *gin.Context) {
+		workspaceId := ctx.Param("workspace")
+		session.SetWorkspace(ctx, &session.Workspace{ID: workspaceId, Owner: entity.WorkspaceOwner(workspaceId), Role: roles[workspaceId]})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("CreateReturnsSecretOnce", func(t *testing.T) {
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ interface{}, webhook entity.Webhook) (entity.Webhook, error) {
+				webhook.Username, webhook.ID = entity.GetSearchKeyByWebhook(webhook.Username, "2")
+				return webhook, nil
+			}).Times(1)
+
+		resp, err := http.Post(fmt.Sprintf("%s/api/webhooks", ts.URL), "application/json",
+			strings.NewReader(`{"url": "https://example.com/hook", "events": ["bookmark.created"]}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 201, resp.StatusCode)
+
+		var result WebhookResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected webhook response, got %v", err)
+		}
+		assert.Equal(t, "2", result.ID)
+		assert.True(t, strings.HasPrefix(result.Secret, "whsec_"))
+		assert.Equal(t, []string{"bookmark.created"}, result.Events)
+
+		mockRepository.EXPECT().List(gomock.Any(), "1").
+			Return([]entity.Webhook{{Username: "USERNAME_1", ID: "WEBHOOK_2", Secret: result.Secret}}, nil).Times(1)
+
+		resp, err = http.Get(fmt.Sprintf("%s/api/webhooks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var list []WebhookResponse
+		_ = json.NewDecoder(resp.Body).Decode(&list)
+		assert.Len(t, list, 1)
+		assert.Empty(t, list[0].Secret)
+	})
+
+	t.Run("CreateWithPrivateUrl", func(t *testing.T) {
+		urls := []string{
+			"http://127.0.0.1/hook",
+			"http://[::1]/hook",
+			"http://0.0.0.0/hook",
+			"http://169.254.169.254/latest/meta-data",
+			"https://192.168.1.1/hook",
+			"http://[::ffff:10.0.0.1]/hook",
+			"https://internal.example.com/hook",
+			"https://unknown.example.com/hook",
+		}
+		for _, url := range urls {
+			resp, err := http.Post(fmt.Sprintf("%s/api/webhooks", ts.URL), "application/json", strings.NewReader(fmt.Sprintf(`{"url": %q}`, url)))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			assert.Equal(t, 400, resp.StatusCode, url)
+		}
+	})
+
+	t.Run("CreateWithInvalidUrl", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("%s/api/webhooks", ts.URL), "application/json", strings.NewReader(`{"url": "ftp://example.com"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("WorkspaceWebhooks", func(t *testing.T) {
+		mockRepository.EXPECT().List(gomock.Any(), "workspace:team").
+			Return([]entity.Webhook{{Username: "WORKSPACE_team", ID: "WEBHOOK_2"}}, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/workspaces/team/webhooks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		// Editors may change the bookmarks but not where their events go
+		resp, err = http.Get(fmt.Sprintf("%s/api/workspaces/shared/webhooks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+)
+
+const (
+	deliveryTimeout = 10 * time.Second
+	// Attempts of a single delivery, the last one is about 45 minutes after the first
+	maxAttempts    = 8
+	initialBackoff = 30 * time.Second
+	// Failed attempts in a row after which the webhook is disabled
+	maxFailures = 20
+	// Delivery log is kept for a month
+	deliveryRetention = 30 * 24 * time.Hour
+
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Dispatcher delivers the events queued by Service.Notify, it runs in the worker
+type Dispatcher interface {
+	// Queues a delivery of the event to every active webhook of the user subscribing to it
+	FanOut(ctx context.Context, message entity.WebhookMessage) error
+	Deliver(ctx context.Context, message entity.WebhookMessage) error
+}
+
+type dispatcher struct {
+	repo   Repository
+	queue  Queue
+	client *http.Client
+	logger *zap.Logger
+}
+
+func NewDispatcher(repo Repository, queue Queue, logger *zap.Logger) Dispatcher {
+	return &dispatcher{repo: repo, queue: queue, client: newDeliveryClient(), logger: logger}
+}
+
+// Returns an error when the event has to be received again. The delivery IDs are derived from the event
+// so the deliveries queued again keep their ID and receivers can drop the duplicates.
+func (d *dispatcher) FanOut(ctx context.Context, message entity.WebhookMessage) error {
+	webhooks, err := d.repo.List(ctx, message.Username)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Disabled || !webhook.Accepts(message.EventType) {
+			continue
+		}
+
+		deliveryId := fmt.Sprintf("%s_%s", message.EventID, webhook.GetWebhookId())
+		payload, err := json.Marshal(Payload{
+			DeliveryID: deliveryId,
+			EventID:    message.EventID,
+			Event:      message.EventType,
+			CreatedAt:  message.CreatedAt,
+			Data:       message.Payload,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = d.queue.Send(ctx, entity.WebhookMessage{
+			Type:       MessageTypeDelivery,
+			Username:   message.Username,
+			WebhookID:  webhook.GetWebhookId(),
+			DeliveryID: deliveryId,
+			EventID:    message.EventID,
+			EventType:  message.EventType,
+			Payload:    payload,
+			Attempt:    1,
+		}, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sends the payload to the webhook and logs the attempt. Failed attempts are queued again with exponential backoff.
+// Returns an error only when the message has to be received again.
+func (d *dispatcher) Deliver(ctx context.Context, message entity.WebhookMessage) error {
+	logger := d.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	webhook, err := d.repo.Get(ctx, message.Username, message.WebhookID)
+	if err == errors.ErrNotFound {
+		logger.Infow("Dropping delivery of deleted webhook", zap.String("WebhookID", message.WebhookID))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if webhook.Disabled {
+		logger.Infow("Dropping delivery of disabled webhook", zap.String("WebhookID", message.WebhookID))
+		return nil
+	}
+
+	delivery := d.send(ctx, webhook, message)
+	webhook, err = d.repo.RecordDelivery(ctx, delivery, maxFailures)
+	if err == errors.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		logger.Errorw("Failed to record delivery", zap.String("WebhookID", message.WebhookID), zap.Error(err))
+	}
+
+	if delivery.Success || webhook.Disabled {
+		return nil
+	}
+	if message.Attempt >= maxAttempts {
+		logger.Warnw("Giving up delivery", zap.String("WebhookID", message.WebhookID), zap.String("DeliveryID", message.DeliveryID))
+		return nil
+	}
+
+	retry := message
+	retry.Attempt++
+	return d.queue.Send(ctx, retry, backoff(message.Attempt))
+}
+
+func (d *dispatcher) send(ctx context.Context, webhook entity.Webhook, message entity.WebhookMessage) entity.WebhookDelivery {
+	delivery := entity.WebhookDelivery{
+		Username:    message.Username,
+		WebhookID:   message.WebhookID,
+		DeliveryID:  message.DeliveryID,
+		EventID:     message.EventID,
+		EventType:   message.EventType,
+		Attempt:     message.Attempt,
+		DeliveredAt: time.Now(),
+	}
+	delivery.ExpiresAt = delivery.DeliveredAt.Add(deliveryRetention)
+
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(message.Payload))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	timestamp := delivery.DeliveredAt.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "bookmark-api-webhook")
+	request.Header.Set(HeaderEvent, message.EventType)
+	request.Header.Set(HeaderDelivery, message.DeliveryID)
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, message.Payload))
+
+	response, err := d.client.Do(request.WithContext(ctx))
+	delivery.Duration = time.Since(delivery.DeliveredAt).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer response.Body.Close()
+	// Drain a bit of the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(response.Body, 4096))
+
+	delivery.StatusCode = response.StatusCode
+	delivery.Success = response.StatusCode >= 200 && response.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("Unexpected status %d", response.StatusCode)
+	}
+	return delivery
+}
+
+// Returns the hex encoded HMAC-SHA256 of "{timestamp}.{payload}" with the secret of the webhook.
+// Receivers compute the same to verify the X-Webhook-Signature header.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delay before the next attempt, doubling from 30 seconds up to the SQS limit of 15 minutes
+func backoff(attempt int) time.Duration {
+	delay := initialBackoff << uint(attempt-1)
+	if delay > maxMessageDelay || delay <= 0 {
+		return maxMessageDelay
+	}
+	return delay
+}
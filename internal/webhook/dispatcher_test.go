@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/webhook/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestFanOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockQueue := mocks.NewMockQueue(ctrl)
+	d := NewDispatcher(mockRepository, mockQueue, logger.NewLogger())
+
+	message := entity.WebhookMessage{
+		Type:      MessageTypeEvent,
+		Username:  "1",
+		EventID:   "5",
+		EventType: event.BookmarkDeleted,
+		CreatedAt: time.Now(),
+		Payload:   []byte(`{"id":"2"}`),
+	}
+
+	mockRepository.EXPECT().List(gomock.Any(), "1").Return([]entity.Webhook{
+		{Username: "USERNAME_1", ID: "WEBHOOK_all"},
+		{Username: "USERNAME_1", ID: "WEBHOOK_tags", Events: []string{event.TagAdded}},
+		{Username: "USERNAME_1", ID: "WEBHOOK_disabled", Disabled: true},
+	}, nil).Times(2)
+
+	var deliveryId string
+	mockQueue.EXPECT().Send(gomock.Any(), gomock.Any(), time.Duration(0)).
+		DoAndReturn(func(ctx context.Context, delivery entity.WebhookMessage, delay time.Duration) error {
+			assert.Equal(t, MessageTypeDelivery, delivery.Type)
+			assert.Equal(t, "all", delivery.WebhookID)
+			assert.Equal(t, 1, delivery.Attempt)
+			// Receiving the event again queues the same delivery
+			if deliveryId != "" {
+				assert.Equal(t, deliveryId, delivery.DeliveryID)
+			}
+			deliveryId = delivery.DeliveryID
+
+			var payload Payload
+			assert.Nil(t, json.Unmarshal(delivery.Payload, &payload))
+			assert.Equal(t, event.BookmarkDeleted, payload.Event)
+			assert.Equal(t, delivery.DeliveryID, payload.DeliveryID)
+			assert.Equal(t, "5", payload.EventID)
+			assert.Equal(t, map[string]interface{}{"id": "2"}, payload.Data)
+			return nil
+		}).Times(2)
+
+	assert.Nil(t, d.FanOut(context.Background(), message))
+	assert.Nil(t, d.FanOut(context.Background(), message))
+}
+
+func TestDeliver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	status := http.StatusOK
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockQueue := mocks.NewMockQueue(ctrl)
+	d := &dispatcher{repo: mockRepository, queue: mockQueue, client: receiver.Client(), logger: logger.NewLogger()}
+
+	webhook := entity.Webhook{Username: "USERNAME_1", ID: "WEBHOOK_2", Url: receiver.URL, Secret: "whsec_test"}
+	message := entity.WebhookMessage{
+		Type:       MessageTypeDelivery,
+		Username:   "1",
+		WebhookID:  "2",
+		DeliveryID: "3",
+		EventID:    "4",
+		EventType:  "bookmark.created",
+		Payload:    []byte(`{"event":"bookmark.created"}`),
+		Attempt:    1,
+	}
+
+	t.Run("SignedPayload", func(t *testing.T) {
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(webhook, nil).Times(1)
+		mockRepository.EXPECT().RecordDelivery(gomock.Any(), gomock.Any(), maxFailures).
+			DoAndReturn(func(ctx context.Context, delivery entity.WebhookDelivery, disableAfter int) (entity.Webhook, error) {
+				assert.True(t, delivery.Success)
+				assert.Equal(t, http.StatusOK, delivery.StatusCode)
+				assert.Equal(t, "3", delivery.DeliveryID)
+				return webhook, nil
+			}).Times(1)
+
+		assert.Nil(t, d.Deliver(context.Background(), message))
+
+		assert.Equal(t, `{"event":"bookmark.created"}`, string(body))
+		assert.Equal(t, "bookmark.created", received.Header.Get(HeaderEvent))
+		assert.Equal(t, "3", received.Header.Get(HeaderDelivery))
+		timestamp, err := strconv.ParseInt(received.Header.Get(HeaderTimestamp), 10, 64)
+		assert.Nil(t, err)
+		assert.Equal(t, "sha256="+Sign("whsec_test", timestamp, body), received.Header.Get(HeaderSignature))
+	})
+
+	t.Run("RetryWithBackoff", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusOK }()
+
+		failing := webhook
+		failing.Failures = 3
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(webhook, nil).Times(1)
+		mockRepository.EXPECT().RecordDelivery(gomock.Any(), gomock.Any(), maxFailures).
+			DoAndReturn(func(ctx context.Context, delivery entity.WebhookDelivery, disableAfter int) (entity.Webhook, error) {
+				assert.False(t, delivery.Success)
+				assert.Equal(t, http.StatusInternalServerError, delivery.StatusCode)
+				return failing, nil
+			}).Times(1)
+
+		retry := message
+		retry.Attempt = 3
+		next := retry
+		next.Attempt = 4
+		mockQueue.EXPECT().Send(gomock.Any(), next, 2*time.Minute).Return(nil).Times(1)
+
+		assert.Nil(t, d.Deliver(context.Background(), retry))
+	})
+
+	t.Run("DisabledAfterFailures", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusOK }()
+
+		disabled := webhook
+		disabled.Disabled = true
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(webhook, nil).Times(1)
+		mockRepository.EXPECT().RecordDelivery(gomock.Any(), gomock.Any(), maxFailures).Return(disabled, nil).Times(1)
+
+		// No retry is queued
+		assert.Nil(t, d.Deliver(context.Background(), message))
+	})
+
+	t.Run("GiveUpAfterMaxAttempts", func(t *testing.T) {
+		status = http.StatusBadGateway
+		defer func() { status = http.StatusOK }()
+
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(webhook, nil).Times(1)
+		mockRepository.EXPECT().RecordDelivery(gomock.Any(), gomock.Any(), maxFailures).Return(webhook, nil).Times(1)
+
+		last := message
+		last.Attempt = maxAttempts
+		assert.Nil(t, d.Deliver(context.Background(), last))
+	})
+
+	t.Run("SkipDisabledWebhook", func(t *testing.T) {
+		disabled := webhook
+		disabled.Disabled = true
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(disabled, nil).Times(1)
+
+		received = nil
+		assert.Nil(t, d.Deliver(context.Background(), message))
+		assert.Nil(t, received)
+	})
+
+	t.Run("RefuseLoopbackAddress", func(t *testing.T) {
+		// The receiver listens on 127.0.0.1, like a host resolving to a private address after the webhook was created
+		blocked := NewDispatcher(mockRepository, mockQueue, logger.NewLogger())
+		mockRepository.EXPECT().Get(gomock.Any(), "1", "2").Return(webhook, nil).Times(1)
+		mockRepository.EXPECT().RecordDelivery(gomock.Any(), gomock.Any(), maxFailures).
+			DoAndReturn(func(ctx context.Context, delivery entity.WebhookDelivery, disableAfter int) (entity.Webhook, error) {
+				assert.False(t, delivery.Success)
+				assert.Contains(t, delivery.Error, "is not allowed")
+				return webhook, nil
+			}).Times(1)
+		next := message
+		next.Attempt = 2
+		mockQueue.EXPECT().Send(gomock.Any(), next, 30*time.Second).Return(nil).Times(1)
+
+		received = nil
+		assert.Nil(t, blocked.Deliver(context.Background(), message))
+		assert.Nil(t, received)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, time.Minute, backoff(2))
+	assert.Equal(t, 8*time.Minute, backoff(5))
+	assert.Equal(t, 15*time.Minute, backoff(6))
+	assert.Equal(t, 15*time.Minute, backoff(100))
+}
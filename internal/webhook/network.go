@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Networks webhooks may not reach, a url there would let users probe the services next to the API
+var blockedNetworks = parseNetworks(
+	// Unspecified, loopback, private, carrier-grade NAT and link-local including the cloud metadata endpoint
+	"0.0.0.0/8", "127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "169.254.0.0/16",
+	// Unspecified, loopback, unique local and link-local
+	"::/128", "::1/128", "fc00::/7", "fe80::/10",
+)
+
+// Resolves the host of a webhook url when it is created
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// IPv4 addresses mapped to IPv6 are matched by the IPv4 networks
+func isBlockedIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an error when the host is or resolves to an address webhooks may not reach
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedIP(ip) {
+			return fmt.Errorf("%s is not a public address", host)
+		}
+		return nil
+	}
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if isBlockedIP(addr.IP) {
+			return fmt.Errorf("%s resolves to %s which is not a public address", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// Checks the address right before connecting, the host may resolve to another address than when it was created
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isBlockedIP(ip) {
+		return fmt.Errorf("Connecting to %s is not allowed", address)
+	}
+	return nil
+}
+
+// Client delivering to public addresses only, redirects are checked when they are dialed as well
+func newDeliveryClient() *http.Client {
+	dialer := &net.Dialer{Timeout: deliveryTimeout, Control: dialControl}
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		// No proxy from the environment, it would be dialed instead of the webhook
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,93 @@
+// queue.go
+//go:generate mockgen -destination=mocks/queue_mock.go -package=mocks . Queue
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/pkg/queue"
+)
+
+// Types of the messages on the processing queue, which is shared with other work
+const (
+	// Event to queue the deliveries of
+	MessageTypeEvent    = "webhook_event"
+	MessageTypeDelivery = "webhook_delivery"
+)
+
+// SQS limit of the delay of a message
+const maxMessageDelay = 15 * time.Minute
+
+type Queue interface {
+	Send(ctx context.Context, message entity.WebhookMessage, delay time.Duration) error
+}
+
+type sqsQueue struct {
+	client sqsiface.SQSAPI
+	name   string
+	url    string
+	mutex  sync.Mutex
+	logger *zap.Logger
+}
+
+func NewQueue(logger *zap.Logger) Queue {
+	return &sqsQueue{client: queue.GetSQS(), name: queue.GetQueueBookmark(), logger: logger}
+}
+
+func (q *sqsQueue) Send(ctx context.Context, message entity.WebhookMessage, delay time.Duration) error {
+	logger := q.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	url, err := q.getUrl(ctx)
+	if err != nil {
+		logger.Errorw("Failed to get queue url", zap.String("Queue", q.name), zap.Error(err))
+		return err
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if delay > maxMessageDelay {
+		delay = maxMessageDelay
+	}
+	_, err = q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(url),
+		MessageBody:  aws.String(string(body)),
+		DelaySeconds: aws.Int64(int64(delay / time.Second)),
+	})
+	if err != nil {
+		logger.Errorw("Failed to send message", zap.String("Queue", q.name), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (q *sqsQueue) getUrl(ctx context.Context) (string, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.url != "" {
+		return q.url, nil
+	}
+
+	output, err := q.client.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(q.name)})
+	if err != nil {
+		return "", err
+	}
+	q.url = aws.StringValue(output.QueueUrl)
+	return q.url, nil
+}
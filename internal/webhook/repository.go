@@ -0,0 +1,205 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	Create(ctx context.Context, webhook entity.Webhook) (entity.Webhook, error)
+	Get(ctx context.Context, username, webhookId string) (entity.Webhook, error)
+	List(ctx context.Context, username string) ([]entity.Webhook, error)
+	Delete(ctx context.Context, username, webhookId string) error
+	// Logs the delivery and counts the failures of the webhook, which is disabled when they reach disableAfter
+	RecordDelivery(ctx context.Context, delivery entity.WebhookDelivery, disableAfter int) (entity.Webhook, error)
+	// Returns the latest deliveries of the webhook first
+	Deliveries(ctx context.Context, username, webhookId string, limit int) ([]entity.WebhookDelivery, error)
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, webhook entity.Webhook) (entity.Webhook, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	webhook.Username, webhook.ID = entity.GetSearchKeyByWebhook(webhook.Username, db.GenerateID())
+	webhook.CreatedAt = time.Now()
+	err := table.Put(webhook).If("attribute_not_exists($)", "id").RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to create webhook", zap.Error(err))
+		return entity.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (r *repository) Get(ctx context.Context, username, webhookId string) (entity.Webhook, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByWebhook(username, webhookId)
+	var result entity.Webhook
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, &result)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return entity.Webhook{}, errors.ErrNotFound
+		default:
+			logger.Errorw("Failed to get webhook", zap.String("Username", username), zap.String("ID", webhookId), zap.Error(err))
+			return entity.Webhook{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) List(ctx context.Context, username string) ([]entity.Webhook, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByWebhook(username, "")
+	result := []entity.Webhook{}
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list webhooks", zap.String("Username", username), zap.Error(err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *repository) Delete(ctx context.Context, username, webhookId string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByWebhook(username, webhookId)
+	err := table.Delete("id", hashId).
+		Range("range", rangeId).
+		If("attribute_exists($)", "id").
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to delete webhook", zap.String("Username", username), zap.String("ID", webhookId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) RecordDelivery(ctx context.Context, delivery entity.WebhookDelivery, disableAfter int) (entity.Webhook, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	username := delivery.Username
+	delivery.Username, delivery.ID = entity.GetSearchKeyByDelivery(username, delivery.WebhookID, db.GenerateID())
+	err := table.Put(delivery).RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to log delivery", zap.String("WebhookID", delivery.WebhookID), zap.Error(err))
+		return entity.Webhook{}, err
+	}
+
+	hashId, rangeId := entity.GetSearchKeyByWebhook(username, delivery.WebhookID)
+	update := table.Update("id", hashId).
+		Range("range", rangeId).
+		If("attribute_exists($)", "id")
+	if delivery.Success {
+		update = update.Set("failures", 0)
+	} else {
+		update = update.Add("failures", 1)
+	}
+
+	var webhook entity.Webhook
+	err = update.ValueWithContext(ctx, &webhook)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return entity.Webhook{}, errors.ErrNotFound
+		}
+		logger.Errorw("Failed to count failures", zap.String("WebhookID", delivery.WebhookID), zap.Error(err))
+		return entity.Webhook{}, err
+	}
+
+	if webhook.Disabled || webhook.Failures < disableAfter {
+		return webhook, nil
+	}
+
+	webhook.Disabled = true
+	webhook.DisabledAt = time.Now()
+	err = table.Update("id", hashId).
+		Range("range", rangeId).
+		Set("disabled", true).
+		Set("disabled_at", webhook.DisabledAt).
+		If("attribute_exists($)", "id").
+		RunWithContext(ctx)
+	if err != nil && !db.IsConditionalCheckFailed(err) {
+		logger.Errorw("Failed to disable webhook", zap.String("WebhookID", delivery.WebhookID), zap.Error(err))
+		return entity.Webhook{}, err
+	}
+
+	logger.Infow("Disabled webhook after repeated failures", zap.String("WebhookID", delivery.WebhookID), zap.Int("Failures", webhook.Failures))
+	return webhook, nil
+}
+
+func (r *repository) Deliveries(ctx context.Context, username, webhookId string, limit int) ([]entity.WebhookDelivery, error) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	hashId, rangeId := entity.GetSearchKeyByDelivery(username, webhookId, "")
+	result := []entity.WebhookDelivery{}
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		Order(dynamo.Descending).
+		Limit(int64(limit)).
+		AllWithContext(ctx, &result)
+	if err != nil {
+		logger.Errorw("Failed to list deliveries", zap.String("Username", username), zap.String("WebhookID", webhookId), zap.Error(err))
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+)
+
+// Time to queue an event
+const notifyTimeout = 5 * time.Second
+
+// Event types webhooks can subscribe to
+var EventTypes = []string{event.BookmarkCreated, event.BookmarkUpdated, event.BookmarkDeleted, event.TagAdded, event.TagRemoved}
+
+type Service interface {
+	// Creates a webhook for the event types, all of them when empty. The secret is only returned here
+	Create(ctx context.Context, username, url string, events []string) (Webhook, error)
+	Get(ctx context.Context, username, webhookId string) (Webhook, error)
+	List(ctx context.Context, username string) ([]Webhook, error)
+	Delete(ctx context.Context, username, webhookId string) error
+	Deliveries(ctx context.Context, username, webhookId string, limit int) ([]Delivery, error)
+	// Queues the event, the worker queues a delivery of it to every active webhook of the user subscribing to it
+	Notify(e event.Event)
+}
+
+type Webhook struct {
+	ID         string
+	Url        string
+	Events     []string
+	Secret     string
+	Failures   int
+	Disabled   bool
+	DisabledAt time.Time
+	CreatedAt  time.Time
+}
+
+func newWebhook(webhook entity.Webhook) Webhook {
+	return Webhook{
+		ID:         webhook.GetWebhookId(),
+		Url:        webhook.Url,
+		Events:     webhook.Events,
+		Secret:     webhook.Secret,
+		Failures:   webhook.Failures,
+		Disabled:   webhook.Disabled,
+		DisabledAt: webhook.DisabledAt,
+		CreatedAt:  webhook.CreatedAt,
+	}
+}
+
+type Delivery struct {
+	DeliveryID  string
+	EventID     string
+	EventType   string
+	Attempt     int
+	StatusCode  int
+	Error       string
+	Success     bool
+	Duration    time.Duration
+	DeliveredAt time.Time
+}
+
+// Body of the requests sent to webhooks
+type Payload struct {
+	DeliveryID string      `json:"delivery_id"`
+	EventID    string      `json:"event_id"`
+	Event      string      `json:"event"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Data       interface{} `json:"data"`
+}
+
+type service struct {
+	repo   Repository
+	queue  Queue
+	logger *zap.Logger
+}
+
+func NewService(repo Repository, queue Queue, logger *zap.Logger) Service {
+	return &service{repo: repo, queue: queue, logger: logger}
+}
+
+func (s *service) Create(ctx context.Context, username, url string, events []string) (Webhook, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	for _, eventType := range events {
+		if !funk.ContainsString(EventTypes, eventType) {
+			return Webhook{}, errors.ErrInvalidParam
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		logger.Errorw("Failed to generate secret", zap.Error(err))
+		return Webhook{}, err
+	}
+
+	webhook, err := s.repo.Create(ctx, entity.Webhook{
+		Username: username,
+		Url:      url,
+		Events:   funk.UniqString(events),
+		Secret:   secret,
+	})
+	if err != nil {
+		logger.Errorw("Failed to create webhook", zap.Error(err))
+		return Webhook{}, err
+	}
+
+	return newWebhook(webhook), nil
+}
+
+func (s *service) Get(ctx context.Context, username, webhookId string) (Webhook, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	webhook, err := s.repo.Get(ctx, username, webhookId)
+	if err != nil {
+		logger.Errorw("Failed to get webhook", zap.String("ID", webhookId), zap.Error(err))
+		return Webhook{}, err
+	}
+
+	return newWebhook(webhook), nil
+}
+
+func (s *service) List(ctx context.Context, username string) ([]Webhook, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	webhooks, err := s.repo.List(ctx, username)
+	if err != nil {
+		logger.Errorw("Failed to list webhooks", zap.Error(err))
+		return []Webhook{}, err
+	}
+
+	return funk.Map(webhooks, newWebhook).([]Webhook), nil
+}
+
+func (s *service) Delete(ctx context.Context, username, webhookId string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	err := s.repo.Delete(ctx, username, webhookId)
+	if err != nil {
+		logger.Errorw("Failed to delete webhook", zap.String("ID", webhookId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Deliveries(ctx context.Context, username, webhookId string, limit int) ([]Delivery, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	// Deliveries of deleted webhooks are not listed
+	if _, err := s.repo.Get(ctx, username, webhookId); err != nil {
+		return []Delivery{}, err
+	}
+
+	deliveries, err := s.repo.Deliveries(ctx, username, webhookId, limit)
+	if err != nil {
+		logger.Errorw("Failed to list deliveries", zap.String("ID", webhookId), zap.Error(err))
+		return []Delivery{}, err
+	}
+
+	return funk.Map(deliveries, func(delivery entity.WebhookDelivery) Delivery {
+		return Delivery{
+			DeliveryID:  delivery.DeliveryID,
+			EventID:     delivery.EventID,
+			EventType:   delivery.EventType,
+			Attempt:     delivery.Attempt,
+			StatusCode:  delivery.StatusCode,
+			Error:       delivery.Error,
+			Success:     delivery.Success,
+			Duration:    time.Duration(delivery.Duration) * time.Millisecond,
+			DeliveredAt: delivery.DeliveredAt,
+		}
+	}).([]Delivery), nil
+}
+
+// Lambda freezes once the response is returned, so the event is queued in the request with a single message.
+// Listing the webhooks and queueing their deliveries is left to the worker
+func (s *service) Notify(e event.Event) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		logger.Errorw("Failed to encode event", zap.String("EventID", e.ID), zap.Error(err))
+		return
+	}
+
+	err = s.queue.Send(ctx, entity.WebhookMessage{
+		Type:      MessageTypeEvent,
+		Username:  e.Username,
+		EventID:   e.ID,
+		EventType: e.Type,
+		CreatedAt: e.Time,
+		Payload:   data,
+	}, 0)
+	if err != nil {
+		logger.Errorw("Failed to queue event", zap.String("EventID", e.ID), zap.String("Type", e.Type), zap.Error(err))
+	}
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/webhook/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestNotify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockQueue := mocks.NewMockQueue(ctrl)
+	s := NewService(mockRepository, mockQueue, logger.NewLogger())
+
+	created := time.Now()
+	// A single message per event, the webhooks are listed by the worker
+	mockQueue.EXPECT().Send(gomock.Any(), gomock.Any(), time.Duration(0)).
+		DoAndReturn(func(ctx context.Context, message entity.WebhookMessage, delay time.Duration) error {
+			assert.Equal(t, MessageTypeEvent, message.Type)
+			assert.Equal(t, "1", message.Username)
+			assert.Equal(t, "5", message.EventID)
+			assert.Equal(t, event.BookmarkDeleted, message.EventType)
+			assert.Equal(t, created, message.CreatedAt)
+			assert.JSONEq(t, `{"id":"2"}`, string(message.Payload))
+			return nil
+		}).Times(1)
+
+	s.Notify(event.Event{ID: "5", Username: "1", Type: event.BookmarkDeleted, Data: map[string]string{"id": "2"}, Time: created})
+}
+
+func TestCreateWithUnknownEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewService(mocks.NewMockRepository(ctrl), mocks.NewMockQueue(ctrl), logger.NewLogger())
+
+	_, err := s.Create(context.Background(), "1", "https://example.com", []string{"bookmark.moved"})
+	assert.Equal(t, errors.ErrInvalidParam, err)
+}
@@ -0,0 +1,7 @@
+package webhook
+
+import (
+	"github.com/google/wire"
+)
+
+var Inject = wire.NewSet(NewApi, NewRepository, NewService, NewDispatcher, NewQueue)
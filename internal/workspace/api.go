@@ -0,0 +1,421 @@
+package workspace
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/session"
+)
+
+func NewApi(service Service, logger *zap.Logger) Api {
+	return &resource{service, logger}
+}
+
+type Api interface {
+	RegisterHandlers(rg *gin.RouterGroup)
+	// Returns the group of routes working on the workspace in the path, which the current user must be a member of.
+	// The workspace "personal" stands for the bookmarks of the user
+	Scoped(rg *gin.RouterGroup) *gin.RouterGroup
+}
+
+type resource struct {
+	service Service
+	logger  *zap.Logger
+}
+
+func (r *resource) RegisterHandlers(rg *gin.RouterGroup) {
+	rg.POST("/workspaces", r.create)
+	rg.GET("/workspaces", r.list)
+
+	rg.GET("/invitations", r.pendingInvitations)
+	rg.POST("/invitations/:workspace/accept", r.accept)
+	rg.DELETE("/invitations/:workspace", r.decline)
+
+	scoped := r.Scoped(rg)
+	owner := session.RequireRole(entity.RoleOwner)
+	scoped.GET("/members", session.RequireRole(entity.RoleViewer), r.members)
+	scoped.PUT("/members/:username", owner, r.setRole)
+	scoped.DELETE("/members/:username", r.removeMember)
+	scoped.POST("/invitations", owner, r.invite)
+	scoped.GET("/invitations", owner, r.invitations)
+	scoped.DELETE("/invitations/:email", owner, r.revokeInvitation)
+}
+
+func (r *resource) Scoped(rg *gin.RouterGroup) *gin.RouterGroup {
+	return rg.Group("/workspaces/:workspace", r.resolve)
+}
+
+// Sets the workspace of the request and the role of the current user in it
+func (r *resource) resolve(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	workspaceId := c.Param("workspace")
+	if workspaceId == session.PersonalWorkspace {
+		session.SetWorkspace(c, &session.Workspace{ID: session.PersonalWorkspace, Owner: authUser.ID(), Role: entity.RoleOwner})
+		c.Next()
+		return
+	}
+
+	member, err := r.service.Member(c.Request.Context(), workspaceId, authUser.ID())
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			// Workspaces of others are not told apart from ones which do not exist
+			c.AbortWithStatusJSON(http.StatusNotFound, errors.NotFound("Workspace not found"))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errors.InternalServerError("Failed to get workspace"))
+		}
+		return
+	}
+
+	session.SetWorkspace(c, &session.Workspace{ID: workspaceId, Owner: entity.WorkspaceOwner(workspaceId), Role: member.Role})
+	c.Next()
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type WorkspaceResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Role of the current user
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at,omitempty"`
+}
+
+func newWorkspaceResponse(membership Membership) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:       membership.WorkspaceID,
+		Name:     membership.WorkspaceName,
+		Role:     membership.Role,
+		JoinedAt: membership.JoinedAt,
+	}
+}
+
+type MemberResponse struct {
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func newMemberResponse(membership Membership) MemberResponse {
+	return MemberResponse{
+		Username: membership.Username,
+		Role:     membership.Role,
+		JoinedAt: membership.JoinedAt,
+	}
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type InvitationResponse struct {
+	WorkspaceID   string    `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	InvitedBy     string    `json:"invited_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func newInvitationResponse(invitation Invitation) InvitationResponse {
+	return InvitationResponse{
+		WorkspaceID:   invitation.WorkspaceID,
+		WorkspaceName: invitation.WorkspaceName,
+		Email:         invitation.Email,
+		Role:          invitation.Role,
+		InvitedBy:     invitation.InvitedBy,
+		CreatedAt:     invitation.CreatedAt,
+		ExpiresAt:     invitation.ExpiresAt,
+	}
+}
+
+var roles = []string{entity.RoleViewer, entity.RoleEditor, entity.RoleOwner}
+
+func (r *resource) create(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	request := CreateWorkspaceRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	membership, err := r.service.Create(c.Request.Context(), authUser.ID(), request.Name)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Workspace needs a name of at most %d characters", maxNameLength)))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to create workspace"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newWorkspaceResponse(membership))
+}
+
+func (r *resource) list(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	memberships, err := r.service.List(c.Request.Context(), authUser.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list workspaces"))
+		return
+	}
+
+	personal := WorkspaceResponse{ID: session.PersonalWorkspace, Name: "Personal", Role: entity.RoleOwner}
+	c.JSON(http.StatusOK, append([]WorkspaceResponse{personal}, funk.Map(memberships, newWorkspaceResponse).([]WorkspaceResponse)...))
+}
+
+func (r *resource) members(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	if workspace.IsPersonal() {
+		authUser := session.GetCurrentUser(c)
+		c.JSON(http.StatusOK, []MemberResponse{{Username: authUser.ID(), Role: entity.RoleOwner}})
+		return
+	}
+
+	members, err := r.service.Members(c.Request.Context(), workspace.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list members"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(members, newMemberResponse))
+}
+
+func (r *resource) setRole(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	workspace := session.GetWorkspace(c)
+	if rejectPersonal(c, workspace) {
+		return
+	}
+
+	request := SetRoleRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	membership, err := r.service.SetRole(c.Request.Context(), workspace.ID, c.Param("username"), request.Role)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Role must be one of %s", strings.Join(roles, ", "))))
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Member not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Workspace must keep an owner"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to set role"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newMemberResponse(membership))
+}
+
+// Owners remove members, any member can leave on its own
+func (r *resource) removeMember(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	if rejectPersonal(c, workspace) {
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	username := c.Param("username")
+	if username != authUser.ID() && !entity.HasRole(workspace.Role, entity.RoleOwner) {
+		c.JSON(http.StatusForbidden, errors.Forbidden("Your role in the workspace does not allow this"))
+		return
+	}
+
+	err := r.service.RemoveMember(c.Request.Context(), workspace.ID, username)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Member not found"))
+		case errors.ErrPreconditionFailed:
+			c.JSON(http.StatusPreconditionFailed, errors.PreconditionFailed("Workspace must keep an owner"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to remove member"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) invite(c *gin.Context) {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	workspace := session.GetWorkspace(c)
+	if rejectPersonal(c, workspace) {
+		return
+	}
+
+	request := InviteRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Errorw("Could not bind payload")
+		c.JSON(http.StatusBadRequest, errors.BadRequest("Payload is in wrong format"))
+		return
+	}
+
+	authUser := session.GetCurrentUser(c)
+	invitation, err := r.service.Invite(c.Request.Context(), workspace.ID, authUser.Username, request.Email, request.Role)
+	if err != nil {
+		switch err {
+		case errors.ErrInvalidParam:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Invitation needs an email and a role of %s", strings.Join(roles, ", "))))
+		case errors.ErrAlreadyExist:
+			c.JSON(http.StatusConflict, errors.Conflict("User is a member already"))
+		case errors.ErrTooManyItems:
+			c.JSON(http.StatusBadRequest, errors.BadRequest(fmt.Sprintf("Workspace has %d pending invitations already", maxPendingInvite)))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to invite"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newInvitationResponse(invitation))
+}
+
+func (r *resource) invitations(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	if rejectPersonal(c, workspace) {
+		return
+	}
+
+	invitations, err := r.service.Invitations(c.Request.Context(), workspace.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list invitations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(invitations, newInvitationResponse))
+}
+
+func (r *resource) revokeInvitation(c *gin.Context) {
+	workspace := session.GetWorkspace(c)
+	if rejectPersonal(c, workspace) {
+		return
+	}
+
+	err := r.service.RevokeInvitation(c.Request.Context(), workspace.ID, c.Param("email"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Invitation not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to revoke invitation"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (r *resource) pendingInvitations(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	// Invitations are sent to emails, a username which merely looks like one has none
+	if !authUser.HasVerifiedEmail() {
+		c.JSON(http.StatusOK, []InvitationResponse{})
+		return
+	}
+
+	invitations, err := r.service.PendingInvitations(c.Request.Context(), authUser.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to list invitations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, funk.Map(invitations, newInvitationResponse))
+}
+
+func (r *resource) accept(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	if rejectUnverified(c, authUser) {
+		return
+	}
+
+	membership, err := r.service.Accept(c.Request.Context(), authUser.Username, authUser.ID(), c.Param("workspace"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Invitation not found"))
+		case errors.ErrAlreadyExist:
+			c.JSON(http.StatusConflict, errors.Conflict("You are a member already"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to accept invitation"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newWorkspaceResponse(membership))
+}
+
+func (r *resource) decline(c *gin.Context) {
+	authUser := session.GetCurrentUser(c)
+	if rejectUnverified(c, authUser) {
+		return
+	}
+
+	err := r.service.Decline(c.Request.Context(), authUser.Username, c.Param("workspace"))
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, errors.NotFound("Invitation not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, errors.InternalServerError("Failed to decline invitation"))
+		}
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Invitations are answered by the owner of the email, a username which merely looks like one could be anyone's
+func rejectUnverified(c *gin.Context, authUser *auth.AuthUser) bool {
+	if !authUser.HasVerifiedEmail() {
+		c.JSON(http.StatusForbidden, errors.Forbidden("Invitations are answered by signing in with a verified email"))
+		return true
+	}
+	return false
+}
+
+// The personal workspace belongs to its user alone
+func rejectPersonal(c *gin.Context, workspace *session.Workspace) bool {
+	if workspace.IsPersonal() {
+		c.JSON(http.StatusBadRequest, errors.BadRequest("The personal workspace can not be shared"))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package workspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/auth"
+	"bookmark-api/internal/bookmark"
+	bookmarkMocks "bookmark-api/internal/bookmark/mocks"
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/event"
+	"bookmark-api/internal/user"
+	userMocks "bookmark-api/internal/user/mocks"
+	"bookmark-api/internal/workspace/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestWorkspaceRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	userService := user.NewService(userMocks.NewMockRepository(ctrl), zapLogger)
+	api := NewApi(NewService(mockRepository, userService, zapLogger), zapLogger)
+
+	mockBookmarkRepository := bookmarkMocks.NewMockRepository(ctrl)
+	bus := event.NewBus(zapLogger)
+	bookmarkApi := bookmark.NewApi(bookmark.NewService(mockBookmarkRepository, bus, zapLogger), bus, zapLogger)
+
+	r := gin.Default()
+	authenticated := r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: "google"})
+	})
+	api.RegisterHandlers(authenticated)
+	bookmarkApi.RegisterHandlers(authenticated)
+	bookmarkApi.RegisterHandlers(api.Scoped(authenticated))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	member := func(role string) entity.WorkspaceMember {
+		return entity.WorkspaceMember{WorkspaceID: "w", WorkspaceName: "Team", Username: "jane@example.com", Role: role}
+	}
+
+	t.Run("ListIncludesPersonal", func(t *testing.T) {
+		mockRepository.EXPECT().ListMemberships(gomock.Any(), "jane@example.com").Return([]entity.WorkspaceMember{member(entity.RoleEditor)}, nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/workspaces", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result []WorkspaceResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Fatalf("Expected workspace responses, got %v", err)
+		}
+		assert.Len(t, result, 2)
+		assert.Equal(t, "personal", result[0].ID)
+		assert.Equal(t, "w", result[1].ID)
+		assert.Equal(t, entity.RoleEditor, result[1].Role)
+	})
+
+	t.Run("WorkspaceBookmarks", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(member(entity.RoleViewer), nil).Times(1)
+		mockBookmarkRepository.EXPECT().List(gomock.Any(), "workspace:w", gomock.Any(), "").Return([]entity.Bookmark{}, "", nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/workspaces/w/bookmarks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("PersonalBookmarks", func(t *testing.T) {
+		mockBookmarkRepository.EXPECT().List(gomock.Any(), "jane@example.com", gomock.Any(), "").Return([]entity.Bookmark{}, "", nil).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/workspaces/personal/bookmarks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("ViewerCanNotCreate", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(member(entity.RoleViewer), nil).Times(1)
+
+		requestBody, _ := json.Marshal(bookmark.CreateBookmarkRequest{Name: "Go", Url: "https://go.dev"})
+		resp, err := http.Post(fmt.Sprintf("%s/api/workspaces/w/bookmarks", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+
+	t.Run("NotMember", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "other", "jane@example.com").Return(entity.WorkspaceMember{}, errors.ErrNotFound).Times(1)
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/workspaces/other/bookmarks", ts.URL))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("EditorCanNotInvite", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(member(entity.RoleEditor), nil).Times(1)
+
+		requestBody, _ := json.Marshal(InviteRequest{Email: "john@example.com", Role: entity.RoleOwner})
+		resp, err := http.Post(fmt.Sprintf("%s/api/workspaces/w/invitations", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+
+	t.Run("MemberLeaves", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(member(entity.RoleViewer), nil).Times(2)
+		mockRepository.EXPECT().DeleteMember(gomock.Any(), member(entity.RoleViewer)).Return(nil).Times(1)
+
+		request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/workspaces/w/members/jane@example.com", ts.URL), nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("LastOwnerCanNotLeave", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(member(entity.RoleOwner), nil).Times(2)
+		mockRepository.EXPECT().DeleteMember(gomock.Any(), member(entity.RoleOwner)).Return(errors.ErrPreconditionFailed).Times(1)
+
+		request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/workspaces/w/members/jane@example.com", ts.URL), nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 412, resp.StatusCode)
+	})
+
+	t.Run("PersonalCanNotBeShared", func(t *testing.T) {
+		requestBody, _ := json.Marshal(InviteRequest{Email: "john@example.com", Role: entity.RoleViewer})
+		resp, err := http.Post(fmt.Sprintf("%s/api/workspaces/personal/invitations", ts.URL), "application/json", bytes.NewBuffer(requestBody))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
+
+func TestInvitationsNeedVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	userService := user.NewService(userMocks.NewMockRepository(ctrl), zapLogger)
+	api := NewApi(NewService(mocks.NewMockRepository(ctrl), userService, zapLogger), zapLogger)
+
+	r := gin.Default()
+	// Local users may register any email as their username
+	api.RegisterHandlers(r.Group("/api", func(ctx *gin.Context) {
+		ctx.Set("user", &auth.AuthUser{Username: "jane@example.com", Method: auth.Local, UserID: "1"})
+	}))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, err := http.Post(fmt.Sprintf("%s/api/invitations/w/accept", ts.URL), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("%s/api/invitations", ts.URL))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var invitations []InvitationResponse
+	err = json.NewDecoder(resp.Body).Decode(&invitations)
+	if err != nil {
+		t.Fatalf("Expected invitation responses, got %v", err)
+	}
+	assert.Empty(t, invitations)
+}
@@ -0,0 +1,298 @@
+// repository.go
+//go:generate mockgen -destination=mocks/repository_mock.go -package=mocks . Repository
+package workspace
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/pkg/db"
+)
+
+type Repository interface {
+	// Creates the workspace along with its first owner
+	Create(ctx context.Context, workspace entity.Workspace, owner entity.WorkspaceMember) error
+	Get(ctx context.Context, workspaceId string) (entity.Workspace, error)
+	GetMember(ctx context.Context, workspaceId, username string) (entity.WorkspaceMember, error)
+	ListMembers(ctx context.Context, workspaceId string) ([]entity.WorkspaceMember, error)
+	// Lists the memberships of the user in team workspaces
+	ListMemberships(ctx context.Context, username string) ([]entity.WorkspaceMember, error)
+	// Changes the role of a member from the previous role.
+	// Returns errors.ErrPreconditionFailed when the role has changed in the meantime or the last owner would be demoted
+	UpdateRole(ctx context.Context, member entity.WorkspaceMember, previousRole string) error
+	// Returns errors.ErrPreconditionFailed when the role has changed in the meantime or the member is the last owner
+	DeleteMember(ctx context.Context, member entity.WorkspaceMember) error
+	// Stores the invitation, replacing a previous invitation of the email
+	Invite(ctx context.Context, invitation entity.WorkspaceInvitation) error
+	GetInvitation(ctx context.Context, workspaceId, email string) (entity.WorkspaceInvitation, error)
+	ListInvitations(ctx context.Context, workspaceId string) ([]entity.WorkspaceInvitation, error)
+	// Lists the invitations sent to the email
+	ListInvitationsOf(ctx context.Context, email string) ([]entity.WorkspaceInvitation, error)
+	DeleteInvitation(ctx context.Context, invitation entity.WorkspaceInvitation) error
+	// Turns the invitation into the membership, errors.ErrAlreadyExist when the user is a member already
+	Accept(ctx context.Context, invitation entity.WorkspaceInvitation, member entity.WorkspaceMember) error
+}
+
+type repository struct {
+	db     *dynamo.DB
+	logger *zap.Logger
+}
+
+func NewRepository(logger *zap.Logger) Repository {
+	return &repository{db: db.GetDynamoDb(), logger: logger}
+}
+
+func (r *repository) Create(ctx context.Context, workspace entity.Workspace, owner entity.WorkspaceMember) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := r.db.WriteTx().
+		Put(table.Put(workspace.GetItem()).If("attribute_not_exists($)", "id")).
+		Put(table.Put(owner.GetWorkspaceItem())).
+		Put(table.Put(owner.GetUserItem())).
+		RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to create workspace", zap.String("ID", workspace.ID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, workspaceId string) (entity.Workspace, error) {
+	var result entity.Workspace
+	hashId, rangeId := entity.GetSearchKeyByWorkspace(workspaceId)
+	err := r.get(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) GetMember(ctx context.Context, workspaceId, username string) (entity.WorkspaceMember, error) {
+	var result entity.WorkspaceMember
+	hashId, rangeId := entity.GetSearchKeyByMember(workspaceId, username)
+	err := r.get(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) GetInvitation(ctx context.Context, workspaceId, email string) (entity.WorkspaceInvitation, error) {
+	var result entity.WorkspaceInvitation
+	hashId, rangeId := entity.GetSearchKeyByInvitation(workspaceId, email)
+	err := r.get(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) get(ctx context.Context, hashId, rangeId string, out interface{}) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Get("id", hashId).
+		Range("range", dynamo.Equal, rangeId).
+		OneWithContext(ctx, out)
+	if err != nil {
+		switch err {
+		case dynamo.ErrNotFound:
+			return errors.ErrNotFound
+		default:
+			logger.Errorw("Failed to get item", zap.String("HashId", hashId), zap.String("RangeId", rangeId), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) ListMembers(ctx context.Context, workspaceId string) ([]entity.WorkspaceMember, error) {
+	result := []entity.WorkspaceMember{}
+	hashId, rangeId := entity.GetSearchKeyByMember(workspaceId, "")
+	err := r.list(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) ListMemberships(ctx context.Context, username string) ([]entity.WorkspaceMember, error) {
+	result := []entity.WorkspaceMember{}
+	hashId, rangeId := entity.GetSearchKeyByMembership(username, "")
+	err := r.list(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) ListInvitations(ctx context.Context, workspaceId string) ([]entity.WorkspaceInvitation, error) {
+	result := []entity.WorkspaceInvitation{}
+	hashId, rangeId := entity.GetSearchKeyByInvitation(workspaceId, "")
+	err := r.list(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) ListInvitationsOf(ctx context.Context, email string) ([]entity.WorkspaceInvitation, error) {
+	result := []entity.WorkspaceInvitation{}
+	hashId, rangeId := entity.GetSearchKeyByInvitationOf(email, "")
+	err := r.list(ctx, hashId, rangeId, &result)
+	return result, err
+}
+
+func (r *repository) list(ctx context.Context, hashId, rangeId string, out interface{}) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := table.Get("id", hashId).
+		Range("range", dynamo.BeginsWith, rangeId).
+		AllWithContext(ctx, out)
+	if err != nil {
+		logger.Errorw("Failed to list items", zap.String("HashId", hashId), zap.String("RangeId", rangeId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateRole(ctx context.Context, member entity.WorkspaceMember, previousRole string) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	tx := r.db.WriteTx()
+	for _, item := range []entity.WorkspaceMember{member.GetWorkspaceItem(), member.GetUserItem()} {
+		tx.Update(table.Update("id", item.HashKey).
+			Range("range", item.RangeKey).
+			Set("role", member.Role).
+			If("$ = ?", "role", previousRole))
+	}
+	r.countOwners(tx, table, member.WorkspaceID, previousRole, member.Role)
+
+	err := tx.RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrPreconditionFailed
+		}
+		logger.Errorw("Failed to update role", zap.String("WorkspaceID", member.WorkspaceID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteMember(ctx context.Context, member entity.WorkspaceMember) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	workspaceItem, userItem := member.GetWorkspaceItem(), member.GetUserItem()
+	tx := r.db.WriteTx().
+		Delete(table.Delete("id", workspaceItem.HashKey).Range("range", workspaceItem.RangeKey).If("$ = ?", "role", member.Role)).
+		Delete(table.Delete("id", userItem.HashKey).Range("range", userItem.RangeKey))
+	r.countOwners(tx, table, member.WorkspaceID, member.Role, "")
+
+	err := tx.RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrPreconditionFailed
+		}
+		logger.Errorw("Failed to delete member", zap.String("WorkspaceID", member.WorkspaceID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Keeps the number of owners of the workspace, which must not drop to zero
+func (r *repository) countOwners(tx *dynamo.WriteTx, table dynamo.Table, workspaceId, previousRole, role string) {
+	hashId, rangeId := entity.GetSearchKeyByWorkspace(workspaceId)
+	switch {
+	case previousRole == entity.RoleOwner && role != entity.RoleOwner:
+		tx.Update(table.Update("id", hashId).Range("range", rangeId).Add("owners", -1).If("$ > ?", "owners", 1))
+	case previousRole != entity.RoleOwner && role == entity.RoleOwner:
+		tx.Update(table.Update("id", hashId).Range("range", rangeId).Add("owners", 1).If("attribute_exists($)", "id"))
+	}
+}
+
+func (r *repository) Invite(ctx context.Context, invitation entity.WorkspaceInvitation) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	err := r.db.WriteTx().
+		Put(table.Put(invitation.GetWorkspaceItem())).
+		Put(table.Put(invitation.GetUserItem())).
+		RunWithContext(ctx)
+	if err != nil {
+		logger.Errorw("Failed to invite", zap.String("WorkspaceID", invitation.WorkspaceID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteInvitation(ctx context.Context, invitation entity.WorkspaceInvitation) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	workspaceItem, userItem := invitation.GetWorkspaceItem(), invitation.GetUserItem()
+	err := r.db.WriteTx().
+		Delete(table.Delete("id", workspaceItem.HashKey).Range("range", workspaceItem.RangeKey).If("attribute_exists($)", "id")).
+		Delete(table.Delete("id", userItem.HashKey).Range("range", userItem.RangeKey)).
+		RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrNotFound
+		}
+		logger.Errorw("Failed to delete invitation", zap.String("WorkspaceID", invitation.WorkspaceID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) Accept(ctx context.Context, invitation entity.WorkspaceInvitation, member entity.WorkspaceMember) error {
+	logger := r.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	table := r.db.Table(db.GetTableBookmark())
+
+	workspaceItem, userItem := invitation.GetWorkspaceItem(), invitation.GetUserItem()
+	tx := r.db.WriteTx().
+		Delete(table.Delete("id", workspaceItem.HashKey).Range("range", workspaceItem.RangeKey).If("attribute_exists($)", "id")).
+		Delete(table.Delete("id", userItem.HashKey).Range("range", userItem.RangeKey)).
+		Put(table.Put(member.GetWorkspaceItem()).If("attribute_not_exists($)", "id")).
+		Put(table.Put(member.GetUserItem()))
+	r.countOwners(tx, table, member.WorkspaceID, "", member.Role)
+
+	err := tx.RunWithContext(ctx)
+	if err != nil {
+		if db.IsConditionalCheckFailed(err) {
+			return errors.ErrAlreadyExist
+		}
+		logger.Errorw("Failed to accept invitation", zap.String("WorkspaceID", invitation.WorkspaceID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package workspace
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/thoas/go-funk"
+	"go.uber.org/zap"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	"bookmark-api/pkg/db"
+)
+
+const (
+	invitationTTL    = 7 * 24 * time.Hour
+	maxNameLength    = 100
+	maxPendingInvite = 50
+)
+
+type Service interface {
+	// Creates a team workspace owned by the user
+	Create(ctx context.Context, username, name string) (Membership, error)
+	// Lists the team workspaces the user is a member of
+	List(ctx context.Context, username string) ([]Membership, error)
+	// Returns the membership of the user, errors.ErrNotFound when the user is not a member
+	Member(ctx context.Context, workspaceId, username string) (Membership, error)
+	Members(ctx context.Context, workspaceId string) ([]Membership, error)
+	// Returns errors.ErrPreconditionFailed when the last owner would be demoted
+	SetRole(ctx context.Context, workspaceId, username, role string) (Membership, error)
+	// Returns errors.ErrPreconditionFailed when the member is the last owner
+	RemoveMember(ctx context.Context, workspaceId, username string) error
+	// Invites the email with the role, replacing a previous invitation. Members can not be invited again,
+	// errors.ErrAlreadyExist is returned when an account the email signs in to is a member
+	Invite(ctx context.Context, workspaceId, invitedBy, email, role string) (Invitation, error)
+	Invitations(ctx context.Context, workspaceId string) ([]Invitation, error)
+	RevokeInvitation(ctx context.Context, workspaceId, email string) error
+	// Lists the invitations sent to the user which have not expired
+	PendingInvitations(ctx context.Context, username string) ([]Invitation, error)
+	// Makes the account of the user a member with the role of the invitation sent to the username,
+	// errors.ErrNotFound when there is none or the username does not sign in to the account
+	Accept(ctx context.Context, username, userId, workspaceId string) (Membership, error)
+	Decline(ctx context.Context, username, workspaceId string) error
+}
+
+type Membership struct {
+	WorkspaceID   string
+	WorkspaceName string
+	Username      string
+	Role          string
+	JoinedAt      time.Time
+}
+
+func newMembership(member entity.WorkspaceMember) Membership {
+	return Membership{
+		WorkspaceID:   member.WorkspaceID,
+		WorkspaceName: member.WorkspaceName,
+		Username:      member.Username,
+		Role:          member.Role,
+		JoinedAt:      member.JoinedAt,
+	}
+}
+
+type Invitation struct {
+	WorkspaceID   string
+	WorkspaceName string
+	Email         string
+	Role          string
+	InvitedBy     string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+func newInvitation(invitation entity.WorkspaceInvitation) Invitation {
+	return Invitation{
+		WorkspaceID:   invitation.WorkspaceID,
+		WorkspaceName: invitation.WorkspaceName,
+		Email:         invitation.Email,
+		Role:          invitation.Role,
+		InvitedBy:     invitation.InvitedBy,
+		CreatedAt:     invitation.CreatedAt,
+		ExpiresAt:     invitation.ExpiresAt,
+	}
+}
+
+type service struct {
+	repo        Repository
+	userService user.Service
+	logger      *zap.Logger
+}
+
+func NewService(repo Repository, userService user.Service, logger *zap.Logger) Service {
+	return &service{repo, userService, logger}
+}
+
+func (s *service) Create(ctx context.Context, username, name string) (Membership, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return Membership{}, errors.ErrInvalidParam
+	}
+
+	now := time.Now()
+	workspace := entity.Workspace{ID: db.GenerateID(), Name: name, Owners: 1, CreatedBy: username, CreatedAt: now}
+	owner := entity.WorkspaceMember{WorkspaceID: workspace.ID, WorkspaceName: name, Username: username, Role: entity.RoleOwner, JoinedAt: now}
+	err := s.repo.Create(ctx, workspace, owner)
+	if err != nil {
+		logger.Errorw("Failed to create workspace", zap.Error(err))
+		return Membership{}, err
+	}
+
+	return newMembership(owner), nil
+}
+
+func (s *service) List(ctx context.Context, username string) ([]Membership, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	memberships, err := s.repo.ListMemberships(ctx, username)
+	if err != nil {
+		logger.Errorw("Failed to list workspaces", zap.Error(err))
+		return []Membership{}, err
+	}
+
+	return funk.Map(memberships, newMembership).([]Membership), nil
+}
+
+func (s *service) Member(ctx context.Context, workspaceId, username string) (Membership, error) {
+	member, err := s.repo.GetMember(ctx, workspaceId, username)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	return newMembership(member), nil
+}
+
+func (s *service) Members(ctx context.Context, workspaceId string) ([]Membership, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	members, err := s.repo.ListMembers(ctx, workspaceId)
+	if err != nil {
+		logger.Errorw("Failed to list members", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return []Membership{}, err
+	}
+
+	return funk.Map(members, newMembership).([]Membership), nil
+}
+
+func (s *service) SetRole(ctx context.Context, workspaceId, username, role string) (Membership, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	if !entity.IsRole(role) {
+		return Membership{}, errors.ErrInvalidParam
+	}
+
+	member, err := s.repo.GetMember(ctx, workspaceId, username)
+	if err != nil {
+		return Membership{}, err
+	}
+	if member.Role == role {
+		return newMembership(member), nil
+	}
+
+	previousRole := member.Role
+	member.Role = role
+	err = s.repo.UpdateRole(ctx, member, previousRole)
+	if err != nil {
+		logger.Errorw("Failed to set role", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return Membership{}, err
+	}
+
+	return newMembership(member), nil
+}
+
+func (s *service) RemoveMember(ctx context.Context, workspaceId, username string) error {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	member, err := s.repo.GetMember(ctx, workspaceId, username)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteMember(ctx, member)
+	if err != nil {
+		logger.Errorw("Failed to remove member", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) Invite(ctx context.Context, workspaceId, invitedBy, email, role string) (Invitation, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") || !entity.IsRole(role) {
+		return Invitation{}, errors.ErrInvalidParam
+	}
+
+	// Members are keyed by their account, which the email may sign in to with several methods
+	accountIds, err := s.userService.AccountIDs(ctx, email)
+	if err != nil {
+		return Invitation{}, err
+	}
+	for _, accountId := range accountIds {
+		_, err := s.repo.GetMember(ctx, workspaceId, accountId)
+		switch err {
+		case nil:
+			return Invitation{}, errors.ErrAlreadyExist
+		case errors.ErrNotFound:
+		default:
+			return Invitation{}, err
+		}
+	}
+
+	pending, err := s.repo.ListInvitations(ctx, workspaceId)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if len(pending) >= maxPendingInvite {
+		return Invitation{}, errors.ErrTooManyItems
+	}
+
+	workspace, err := s.repo.Get(ctx, workspaceId)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	now := time.Now()
+	invitation := entity.WorkspaceInvitation{
+		WorkspaceID:   workspaceId,
+		WorkspaceName: workspace.Name,
+		Email:         email,
+		Role:          role,
+		InvitedBy:     invitedBy,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(invitationTTL),
+	}
+	err = s.repo.Invite(ctx, invitation)
+	if err != nil {
+		logger.Errorw("Failed to invite", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return Invitation{}, err
+	}
+
+	return newInvitation(invitation), nil
+}
+
+func (s *service) Invitations(ctx context.Context, workspaceId string) ([]Invitation, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	invitations, err := s.repo.ListInvitations(ctx, workspaceId)
+	if err != nil {
+		logger.Errorw("Failed to list invitations", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return []Invitation{}, err
+	}
+
+	return pendingOnly(invitations), nil
+}
+
+func (s *service) RevokeInvitation(ctx context.Context, workspaceId, email string) error {
+	invitation, err := s.repo.GetInvitation(ctx, workspaceId, strings.ToLower(email))
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteInvitation(ctx, invitation)
+}
+
+func (s *service) PendingInvitations(ctx context.Context, username string) ([]Invitation, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	invitations, err := s.repo.ListInvitationsOf(ctx, strings.ToLower(username))
+	if err != nil {
+		logger.Errorw("Failed to list invitations of user", zap.Error(err))
+		return []Invitation{}, err
+	}
+
+	return pendingOnly(invitations), nil
+}
+
+func (s *service) Accept(ctx context.Context, username, userId, workspaceId string) (Membership, error) {
+	logger := s.logger.Sugar()
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	// The invitation goes to the email, only the accounts it signs in to may take it
+	accountIds, err := s.userService.AccountIDs(ctx, strings.ToLower(username))
+	if err != nil {
+		return Membership{}, err
+	}
+	if !funk.ContainsString(accountIds, userId) {
+		logger.Warnw("Refused invitation of another account", zap.String("WorkspaceID", workspaceId), zap.String("UserID", userId))
+		return Membership{}, errors.ErrNotFound
+	}
+
+	invitation, err := s.repo.GetInvitation(ctx, workspaceId, strings.ToLower(username))
+	if err != nil {
+		return Membership{}, err
+	}
+	if isExpired(invitation) {
+		return Membership{}, errors.ErrNotFound
+	}
+
+	member := entity.WorkspaceMember{
+		WorkspaceID:   workspaceId,
+		WorkspaceName: invitation.WorkspaceName,
+		Username:      userId,
+		Role:          invitation.Role,
+		JoinedAt:      time.Now(),
+	}
+	err = s.repo.Accept(ctx, invitation, member)
+	if err != nil {
+		logger.Errorw("Failed to accept invitation", zap.String("WorkspaceID", workspaceId), zap.Error(err))
+		return Membership{}, err
+	}
+
+	return newMembership(member), nil
+}
+
+func (s *service) Decline(ctx context.Context, username, workspaceId string) error {
+	return s.RevokeInvitation(ctx, workspaceId, username)
+}
+
+// Expired invitations stay until DynamoDB removes them, which might take a while
+func pendingOnly(invitations []entity.WorkspaceInvitation) []Invitation {
+	result := []Invitation{}
+	for _, invitation := range invitations {
+		if !isExpired(invitation) {
+			result = append(result, newInvitation(invitation))
+		}
+	}
+	return result
+}
+
+func isExpired(invitation entity.WorkspaceInvitation) bool {
+	return !invitation.ExpiresAt.After(time.Now())
+}
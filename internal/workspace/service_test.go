@@ -0,0 +1,197 @@
+package workspace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"bookmark-api/internal/entity"
+	"bookmark-api/internal/errors"
+	"bookmark-api/internal/user"
+	userMocks "bookmark-api/internal/user/mocks"
+	"bookmark-api/internal/workspace/mocks"
+	"bookmark-api/pkg/logger"
+)
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	workspaceService := NewService(mockRepository, user.NewService(userMocks.NewMockRepository(ctrl), zapLogger), zapLogger)
+	ctx := context.Background()
+
+	t.Run("CreatorIsOwner", func(t *testing.T) {
+		mockRepository.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, workspace entity.Workspace, owner entity.WorkspaceMember) error {
+				assert.Equal(t, 1, workspace.Owners)
+				assert.Equal(t, workspace.ID, owner.WorkspaceID)
+				assert.Equal(t, entity.RoleOwner, owner.Role)
+				return nil
+			}).Times(1)
+
+		membership, err := workspaceService.Create(ctx, "1", " Team ")
+		assert.Nil(t, err)
+		assert.Equal(t, "Team", membership.WorkspaceName)
+		assert.Equal(t, "1", membership.Username)
+		assert.Equal(t, entity.RoleOwner, membership.Role)
+		assert.NotEmpty(t, membership.WorkspaceID)
+	})
+
+	t.Run("EmptyName", func(t *testing.T) {
+		_, err := workspaceService.Create(ctx, "1", " ")
+		assert.Equal(t, errors.ErrInvalidParam, err)
+	})
+}
+
+func TestSetRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	workspaceService := NewService(mockRepository, user.NewService(userMocks.NewMockRepository(ctrl), zapLogger), zapLogger)
+	ctx := context.Background()
+	owner := entity.WorkspaceMember{WorkspaceID: "w", Username: "1", Role: entity.RoleOwner}
+
+	t.Run("Demote", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "1").Return(owner, nil).Times(1)
+		mockRepository.EXPECT().UpdateRole(gomock.Any(), gomock.Any(), entity.RoleOwner).
+			DoAndReturn(func(_ context.Context, member entity.WorkspaceMember, _ string) error {
+				assert.Equal(t, entity.RoleEditor, member.Role)
+				return nil
+			}).Times(1)
+
+		membership, err := workspaceService.SetRole(ctx, "w", "1", entity.RoleEditor)
+		assert.Nil(t, err)
+		assert.Equal(t, entity.RoleEditor, membership.Role)
+	})
+
+	t.Run("LastOwner", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "1").Return(owner, nil).Times(1)
+		mockRepository.EXPECT().UpdateRole(gomock.Any(), gomock.Any(), entity.RoleOwner).Return(errors.ErrPreconditionFailed).Times(1)
+
+		_, err := workspaceService.SetRole(ctx, "w", "1", entity.RoleViewer)
+		assert.Equal(t, errors.ErrPreconditionFailed, err)
+	})
+
+	t.Run("SameRole", func(t *testing.T) {
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "1").Return(owner, nil).Times(1)
+
+		_, err := workspaceService.SetRole(ctx, "w", "1", entity.RoleOwner)
+		assert.Nil(t, err)
+	})
+
+	t.Run("UnknownRole", func(t *testing.T) {
+		_, err := workspaceService.SetRole(ctx, "w", "1", "admin")
+		assert.Equal(t, errors.ErrInvalidParam, err)
+	})
+}
+
+func TestInvite(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockUserRepository := userMocks.NewMockRepository(ctrl)
+	workspaceService := NewService(mockRepository, user.NewService(mockUserRepository, zapLogger), zapLogger)
+	ctx := context.Background()
+
+	t.Run("Invite", func(t *testing.T) {
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return([]entity.User{}, nil).Times(1)
+		mockRepository.EXPECT().ListInvitations(gomock.Any(), "w").Return([]entity.WorkspaceInvitation{}, nil).Times(1)
+		mockRepository.EXPECT().Get(gomock.Any(), "w").Return(entity.Workspace{ID: "w", Name: "Team"}, nil).Times(1)
+		mockRepository.EXPECT().Invite(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		invitation, err := workspaceService.Invite(ctx, "w", "1", "Jane@Example.com ", entity.RoleEditor)
+		assert.Nil(t, err)
+		assert.Equal(t, "jane@example.com", invitation.Email)
+		assert.Equal(t, "Team", invitation.WorkspaceName)
+		assert.WithinDuration(t, time.Now().Add(invitationTTL), invitation.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return([]entity.User{
+			{Username: "jane@example.com", Method: "google", UserID: "user:1"},
+			{Username: "jane@example.com", Method: "github", UserID: "user:2"},
+		}, nil).Times(1)
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "user:1").Return(entity.WorkspaceMember{}, errors.ErrNotFound).Times(1)
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "user:2").Return(entity.WorkspaceMember{}, nil).Times(1)
+
+		_, err := workspaceService.Invite(ctx, "w", "1", "jane@example.com", entity.RoleEditor)
+		assert.Equal(t, errors.ErrAlreadyExist, err)
+	})
+
+	t.Run("MemberCreatedBeforeUserIDs", func(t *testing.T) {
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return([]entity.User{{Username: "jane@example.com", Method: "google"}}, nil).Times(1)
+		mockRepository.EXPECT().GetMember(gomock.Any(), "w", "jane@example.com").Return(entity.WorkspaceMember{}, nil).Times(1)
+
+		_, err := workspaceService.Invite(ctx, "w", "1", "jane@example.com", entity.RoleEditor)
+		assert.Equal(t, errors.ErrAlreadyExist, err)
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		_, err := workspaceService.Invite(ctx, "w", "1", "jane", entity.RoleEditor)
+		assert.Equal(t, errors.ErrInvalidParam, err)
+	})
+}
+
+func TestAccept(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	zapLogger := logger.NewLogger()
+	mockRepository := mocks.NewMockRepository(ctrl)
+	mockUserRepository := userMocks.NewMockRepository(ctrl)
+	workspaceService := NewService(mockRepository, user.NewService(mockUserRepository, zapLogger), zapLogger)
+	ctx := context.Background()
+	invitation := entity.WorkspaceInvitation{WorkspaceID: "w", WorkspaceName: "Team", Email: "jane@example.com", Role: entity.RoleViewer}
+	identities := []entity.User{{Username: "jane@example.com", Method: "google", UserID: "1"}}
+
+	t.Run("Accept", func(t *testing.T) {
+		invitation.ExpiresAt = time.Now().Add(time.Hour)
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return(identities, nil).Times(1)
+		mockRepository.EXPECT().GetInvitation(gomock.Any(), "w", "jane@example.com").Return(invitation, nil).Times(1)
+		mockRepository.EXPECT().Accept(gomock.Any(), invitation, gomock.Any()).Return(nil).Times(1)
+
+		membership, err := workspaceService.Accept(ctx, "jane@example.com", "1", "w")
+		assert.Nil(t, err)
+		assert.Equal(t, entity.RoleViewer, membership.Role)
+		assert.Equal(t, "Team", membership.WorkspaceName)
+		assert.Equal(t, "1", membership.Username)
+	})
+
+	t.Run("OtherAccount", func(t *testing.T) {
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return(identities, nil).Times(1)
+
+		// The email does not sign in to the account
+		_, err := workspaceService.Accept(ctx, "jane@example.com", "2", "w")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		invitation.ExpiresAt = time.Now().Add(-time.Hour)
+		mockUserRepository.EXPECT().List(gomock.Any(), "jane@example.com").Return(identities, nil).Times(1)
+		mockRepository.EXPECT().GetInvitation(gomock.Any(), "w", "jane@example.com").Return(invitation, nil).Times(1)
+
+		_, err := workspaceService.Accept(ctx, "jane@example.com", "1", "w")
+		assert.Equal(t, errors.ErrNotFound, err)
+	})
+
+	t.Run("PendingSkipsExpired", func(t *testing.T) {
+		pending, expired := invitation, invitation
+		pending.ExpiresAt = time.Now().Add(time.Hour)
+		expired.ExpiresAt = time.Now().Add(-time.Hour)
+		mockRepository.EXPECT().ListInvitationsOf(gomock.Any(), "jane@example.com").
+			Return([]entity.WorkspaceInvitation{pending, expired}, nil).Times(1)
+
+		invitations, err := workspaceService.PendingInvitations(ctx, "jane@example.com")
+		assert.Nil(t, err)
+		assert.Len(t, invitations, 1)
+	})
+}
@@ -3,11 +3,13 @@ package db
 import (
 	"bookmark-api/pkg/utils"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/google/uuid"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/guregu/dynamo"
 )
 
@@ -27,10 +29,6 @@ func GetTableUser() string {
 	return v
 }
 
-func GenerateID() string {
-	return uuid.New().String()
-}
-
 func GetDynamoDb() *dynamo.DB {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
@@ -43,3 +41,33 @@ func GetDynamoDb() *dynamo.DB {
 		return dynamo.New(sess)
 	}
 }
+
+// IsConditionalCheckFailed reports whether err was caused by a failed condition
+// expression, either on a single write or inside a transaction.
+func IsConditionalCheckFailed(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeConditionalCheckFailedException:
+			return true
+		case dynamodb.ErrCodeTransactionCanceledException:
+			return strings.Contains(aerr.Message(), "ConditionalCheckFailed")
+		}
+	}
+
+	return false
+}
+
+// IsTransactionConflict reports whether err was caused by another request
+// writing one of the items of a transaction at the same time.
+func IsTransactionConflict(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeTransactionConflictException:
+			return true
+		case dynamodb.ErrCodeTransactionCanceledException:
+			return strings.Contains(aerr.Message(), "TransactionConflict")
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		conditional bool
+		conflict    bool
+	}{
+		{"ConditionalCheckFailed", awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil), true, false},
+		{"CanceledByCondition", awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]", nil), true, false},
+		{"CanceledByConflict", awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [TransactionConflict, None]", nil), false, true},
+		{"Conflict", awserr.New(dynamodb.ErrCodeTransactionConflictException, "Transaction is ongoing for the item", nil), false, true},
+		{"Other", fmt.Errorf("Timeout"), false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.conditional, IsConditionalCheckFailed(test.err))
+			assert.Equal(t, test.conflict, IsTransactionConflict(test.err))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	idMutex      sync.Mutex
+	lastIDMillis int64
+	idSequence   uint16
+)
+
+// GenerateID returns a UUIDv7, so IDs sort in the order they are generated.
+// Within the same millisecond the 12 bit sequence keeps them increasing.
+func GenerateID() string {
+	var id uuid.UUID
+	_, _ = rand.Read(id[6:])
+
+	idMutex.Lock()
+	millis := time.Now().UnixNano() / int64(time.Millisecond)
+	if millis > lastIDMillis {
+		lastIDMillis = millis
+		// Start low enough to leave room for the following IDs
+		idSequence = binary.BigEndian.Uint16(id[6:8]) & 0x7ff
+	} else {
+		idSequence++
+		if idSequence > 0xfff {
+			lastIDMillis++
+			idSequence = 0
+		}
+	}
+	millis = lastIDMillis
+	sequence := idSequence
+	idMutex.Unlock()
+
+	id[0] = byte(millis >> 40)
+	id[1] = byte(millis >> 32)
+	id[2] = byte(millis >> 24)
+	id[3] = byte(millis >> 16)
+	id[4] = byte(millis >> 8)
+	id[5] = byte(millis)
+	id[6] = 0x70 | byte(sequence>>8)&0x0f
+	id[7] = byte(sequence)
+	id[8] = 0x80 | id[8]&0x3f
+
+	return id.String()
+}
+
+// IsSortableID reports whether the ID is a UUIDv7. IDs generated before are random UUIDv4.
+func IsSortableID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Version() == 7
+}
+
+// SortableIDPrefix returns the prefix of the IDs generated at the given time
+func SortableIDPrefix(t time.Time) string {
+	millis := t.UnixNano() / int64(time.Millisecond)
+	hex := fmt.Sprintf("%012x", millis)
+	return hex[:8] + "-" + hex[8:]
+}
+
+// SortableIDTime returns the time a UUIDv7 was generated at, to the millisecond
+func SortableIDTime(id string) (time.Time, bool) {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.Version() != 7 {
+		return time.Time{}, false
+	}
+
+	var millis int64
+	for _, b := range parsed[:6] {
+		millis = millis<<8 | int64(b)
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), true
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateID(t *testing.T) {
+	ids := make([]string, 10000)
+	for i := range ids {
+		ids[i] = GenerateID()
+	}
+
+	assert.True(t, sort.StringsAreSorted(ids), "IDs should sort in the order they are generated")
+	for _, id := range ids {
+		assert.True(t, IsSortableID(id))
+	}
+
+	unique := make(map[string]bool)
+	for _, id := range ids {
+		unique[id] = true
+	}
+	assert.Len(t, unique, len(ids))
+}
+
+func TestIsSortableID(t *testing.T) {
+	assert.False(t, IsSortableID("0b5fbd3a-2a16-4c4e-9a3e-6b1f2c3d4e5f"))
+	assert.False(t, IsSortableID("not-an-id"))
+}
+
+func TestSortableIDPrefix(t *testing.T) {
+	before := SortableIDPrefix(time.Now())
+	id := GenerateID()
+	after := SortableIDPrefix(time.Now().Add(time.Millisecond))
+
+	assert.True(t, before <= id[:len(before)])
+	assert.True(t, id < after)
+}
+
+func TestSortableIDTime(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	generated, ok := SortableIDTime(GenerateID())
+	assert.True(t, ok)
+	assert.False(t, generated.Before(now))
+	assert.True(t, generated.Before(now.Add(time.Second)))
+
+	_, ok = SortableIDTime("0b5fbd3a-2a16-4c4e-9a3e-6b1f2c3d4e5f")
+	assert.False(t, ok)
+}
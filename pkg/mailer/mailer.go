@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+var ErrNotConfigured = errors.New("Mailer is not configured")
+
+type Message struct {
+	To      string
+	Subject string
+	// Plain text body
+	Body string
+}
+
+// Mailer sends emails. SMTP is the only transport so far, others like SES only have to implement it
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Returns the SMTP mailer configured by SMTP_HOST, or one failing to send anything when there is none
+func NewMailer() Mailer {
+	if os.Getenv("SMTP_HOST") == "" {
+		return unconfigured{}
+	}
+
+	return NewSMTPMailer(SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	})
+}
+
+type unconfigured struct{}
+
+func (unconfigured) Send(ctx context.Context, message Message) error {
+	return ErrNotConfigured
+}
@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// Serves SMTP on localhost well enough for net/smtp, passing every mail it receives to the channel
+func newFakeSMTPServer(t *testing.T) (net.Listener, <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected listener, got %v", err)
+	}
+
+	mails := make(chan receivedMail, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, mails)
+		}
+	}()
+
+	return listener, mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- receivedMail) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	var mail receivedMail
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			mail.auth = line
+			reply("235 Authenticated")
+		case "MAIL":
+			mail.from = line
+			reply("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			mail.data = data.String()
+			mails <- mail
+			mail = receivedMail{}
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, mails := newFakeSMTPServer(t)
+	defer listener.Close()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	t.Run("Send", func(t *testing.T) {
+		mailer := NewSMTPMailer(SMTPConfig{Host: host, Port: port, Username: "user", Password: "secret", From: "Booklog <noreply@booklog.link>"})
+
+		err := mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Sign in to Booklog", Body: "Open the link\n.\nbye"})
+		assert.Nil(t, err)
+
+		select {
+		case mail := <-mails:
+			assert.True(t, strings.HasPrefix(mail.auth, "AUTH PLAIN"))
+			assert.Equal(t, "MAIL FROM:<noreply@booklog.link>", strings.SplitN(mail.from, " BODY", 2)[0])
+			assert.Equal(t, []string{"RCPT TO:<jane@example.com>"}, mail.to)
+			assert.Contains(t, mail.data, "To: <jane@example.com>\r\n")
+			assert.Contains(t, mail.data, "Subject: Sign in to Booklog\r\n")
+			// Lines with a single dot are escaped, so they do not end the mail
+			assert.Contains(t, mail.data, "\r\n\r\nOpen the link\r\n..\r\nbye")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected mail")
+		}
+	})
+
+	t.Run("RejectsInjectedRecipients", func(t *testing.T) {
+		mailer := NewSMTPMailer(SMTPConfig{Host: host, Port: port, From: "noreply@booklog.link"})
+
+		err := mailer.Send(context.Background(), Message{To: "jane@example.com\r\nBcc: john@example.com", Subject: "Hi", Body: "Hi"})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Unconfigured", func(t *testing.T) {
+		assert.Equal(t, ErrNotConfigured, NewMailer().Send(context.Background(), Message{To: "jane@example.com"}))
+	})
+}
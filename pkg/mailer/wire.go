@@ -0,0 +1,7 @@
+package mailer
+
+import (
+	"github.com/google/wire"
+)
+
+var Inject = wire.NewSet(NewMailer)
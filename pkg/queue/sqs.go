@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"bookmark-api/pkg/utils"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func GetQueueBookmark() string {
+	v := os.Getenv("SQS_QUEUE_BOOKMARK")
+	if v == "" {
+		return "dev-bookmark-api-processing-queue"
+	}
+	return v
+}
+
+func GetSQS() *sqs.SQS {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	if utils.IsOffline() {
+		cred := credentials.NewStaticCredentials(os.Getenv("AWS_ACCESS_KEY"), os.Getenv("AWS_ACCESS_SECRET"), "")
+		return sqs.New(sess, aws.NewConfig().WithRegion(os.Getenv("AWS_ACCESS_REGION")).WithCredentials(cred))
+	} else {
+		return sqs.New(sess)
+	}
+}